@@ -237,6 +237,15 @@ func DeleteTransaction(c echo.Context) error {
 		return c.JSON(http.StatusForbidden, map[string]string{"error": "Cannot delete successful transactions"})
 	}
 	
+	// Refunded transactions keep their refund ledger, including pending refunds
+	refunds, err := transactionRepo.ListRefunds(transaction.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch refunds"})
+	}
+	if transaction.RefundedAmount > 0 || len(refunds) > 0 {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Cannot delete refunded transactions"})
+	}
+	
 	err = transactionRepo.Delete(id)
 	if err != nil {
 		log.Printf("[DeleteTransaction] Error: %v", err)
@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
+)
+
+// AIBudgetStatus is the state of one monthly AI budget. Limits of 0 are unlimited.
+type AIBudgetStatus struct {
+	Scope      string  `json:"scope"` // "tenant" or "course"
+	ScopeID    string  `json:"scope_id,omitempty"`
+	TokensUsed int64   `json:"tokens_used"`
+	TokenLimit int64   `json:"token_limit"`
+	CostUsed   float64 `json:"cost_used"`
+	CostLimit  float64 `json:"cost_limit"`
+	// Percent is the highest usage percentage of the token and cost limits
+	Percent  float64 `json:"percent"`
+	Warning  bool    `json:"warning"`
+	Exceeded bool    `json:"exceeded"`
+}
+
+// newAIBudgetStatus computes usage percentage and thresholds for a budget
+func newAIBudgetStatus(scope, scopeID string, tokensUsed, tokenLimit int64, costUsed, costLimit float64) AIBudgetStatus {
+	status := AIBudgetStatus{
+		Scope:      scope,
+		ScopeID:    scopeID,
+		TokensUsed: tokensUsed,
+		TokenLimit: tokenLimit,
+		CostUsed:   costUsed,
+		CostLimit:  costLimit,
+	}
+	if tokenLimit > 0 {
+		status.Percent = float64(tokensUsed) * 100 / float64(tokenLimit)
+	}
+	if costLimit > 0 {
+		if p := costUsed * 100 / costLimit; p > status.Percent {
+			status.Percent = p
+		}
+	}
+
+	warnAt := float64(getSettingInt("ai_budget_warning_percent", 80))
+	status.Warning = (tokenLimit > 0 || costLimit > 0) && status.Percent >= warnAt
+	status.Exceeded = status.Percent >= 100
+	return status
+}
+
+// tenantAIBudget returns the monthly budget that applies to a user's tenant: the
+// tenant's feature_config overrides the platform-wide settings
+func tenantAIBudget(ctx context.Context, repo *postgres.AIRepository, userID string) (AIBudgetStatus, error) {
+	tokenLimit := int64(getSettingInt("ai_budget_monthly_tokens", 0))
+	costLimit := getSettingFloat("ai_budget_monthly_cost", 0)
+
+	tenantID, maxTokens, maxCost, err := repo.GetTenantBudget(ctx, userID)
+	if err != nil {
+		return AIBudgetStatus{}, err
+	}
+	if maxTokens != nil {
+		tokenLimit = *maxTokens
+	}
+	if maxCost != nil {
+		costLimit = *maxCost
+	}
+	if tokenLimit <= 0 && costLimit <= 0 {
+		return newAIBudgetStatus("tenant", "", 0, 0, 0, 0), nil
+	}
+
+	usage, err := repo.GetTenantMonthlyUsage(ctx, tenantID)
+	if err != nil {
+		return AIBudgetStatus{}, err
+	}
+	scopeID := ""
+	if tenantID != nil {
+		scopeID = *tenantID
+	}
+	return newAIBudgetStatus("tenant", scopeID, usage.Tokens(), tokenLimit, usage.Cost, costLimit), nil
+}
+
+// courseAIBudget returns the status of a course's monthly budget
+func courseAIBudget(budget postgres.CourseBudget) AIBudgetStatus {
+	var tokenLimit int64
+	var costLimit float64
+	if budget.MonthlyTokenLimit != nil {
+		tokenLimit = *budget.MonthlyTokenLimit
+	}
+	if budget.MonthlyCostLimit != nil {
+		costLimit = *budget.MonthlyCostLimit
+	}
+	return newAIBudgetStatus("course", budget.CourseID, budget.TokensUsed, tokenLimit, budget.CostUsed, costLimit)
+}
+
+// checkAIBudget evaluates the tenant and course budgets for a request. It returns
+// the first budget that blocks the request (only when hard stop is on) and every
+// budget past its warning threshold. Budgets that cannot be loaded do not block.
+func checkAIBudget(ctx context.Context, userID, courseID string) (*AIBudgetStatus, []AIBudgetStatus) {
+	repo := getAIRepo()
+	var statuses []AIBudgetStatus
+
+	tenant, err := tenantAIBudget(ctx, repo, userID)
+	if err != nil {
+		log.Printf("[AI Budget] Failed to load tenant budget for user %s: %v", userID, err)
+	} else {
+		statuses = append(statuses, tenant)
+	}
+
+	if courseID != "" {
+		budget, err := repo.GetCourseBudget(ctx, courseID)
+		if err != nil {
+			log.Printf("[AI Budget] Failed to load budget for course %s: %v", courseID, err)
+		} else if budget != nil {
+			statuses = append(statuses, courseAIBudget(*budget))
+		}
+	}
+
+	hardStop := getSettingBool("ai_budget_hard_stop", true)
+	var warnings []AIBudgetStatus
+	for i := range statuses {
+		if statuses[i].Exceeded && hardStop {
+			return &statuses[i], nil
+		}
+		if statuses[i].Warning {
+			warnings = append(warnings, statuses[i])
+		}
+	}
+	return nil, warnings
+}
+
+// aiBudgetWarningMessage is the notice shown to users when a budget is nearly used up
+func aiBudgetWarningMessage(warnings []AIBudgetStatus) string {
+	if len(warnings) == 0 {
+		return ""
+	}
+	return "Kuota AI bulan ini hampir habis."
+}
+
+// aiBudgetExceededResponse writes the response for a request refused by a budget
+func aiBudgetExceededResponse(c echo.Context, status *AIBudgetStatus) error {
+	msg := "Kuota AI platform bulan ini telah habis. Silakan hubungi administrator."
+	if status.Scope == "course" {
+		msg = "Kuota AI untuk kursus ini bulan ini telah habis. Silakan hubungi instruktur atau administrator."
+	}
+	return c.JSON(http.StatusTooManyRequests, map[string]interface{}{
+		"error":  msg,
+		"code":   "budget_exceeded",
+		"budget": status,
+	})
+}
+
+// GetAIBudgets returns the platform budget settings, the current tenant budget
+// and the per-course budgets with this month's usage
+// GET /api/admin/ai/budgets
+func GetAIBudgets(c echo.Context) error {
+	ctx := c.Request().Context()
+	repo := getAIRepo()
+
+	userID := getUserIDFromToken(c)
+	tenant, err := tenantAIBudget(ctx, repo, userID)
+	if err != nil {
+		log.Printf("[AI Budget] Failed to load tenant budget: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load budgets"})
+	}
+
+	courses, err := repo.ListCourseBudgets(ctx)
+	if err != nil {
+		log.Printf("[AI Budget] Failed to list course budgets: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load budgets"})
+	}
+	courseStatuses := make([]map[string]interface{}, 0, len(courses))
+	for _, budget := range courses {
+		courseStatuses = append(courseStatuses, map[string]interface{}{
+			"course_id":           budget.CourseID,
+			"course_title":        budget.CourseTitle,
+			"monthly_token_limit": budget.MonthlyTokenLimit,
+			"monthly_cost_limit":  budget.MonthlyCostLimit,
+			"status":              courseAIBudget(budget),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"settings": map[string]interface{}{
+			"monthly_tokens":  getSettingInt("ai_budget_monthly_tokens", 0),
+			"monthly_cost":    getSettingFloat("ai_budget_monthly_cost", 0),
+			"warning_percent": getSettingInt("ai_budget_warning_percent", 80),
+			"hard_stop":       getSettingBool("ai_budget_hard_stop", true),
+		},
+		"tenant":  tenant,
+		"courses": courseStatuses,
+	})
+}
+
+// UpdateAIBudgetSettingsRequest updates the platform-wide budget settings
+type UpdateAIBudgetSettingsRequest struct {
+	MonthlyTokens  *int     `json:"monthly_tokens"`
+	MonthlyCost    *float64 `json:"monthly_cost"`
+	WarningPercent *int     `json:"warning_percent"`
+	HardStop       *bool    `json:"hard_stop"`
+}
+
+// UpdateAIBudgetSettings updates the platform-wide monthly budget and thresholds
+// PUT /api/admin/ai/budgets
+func UpdateAIBudgetSettings(c echo.Context) error {
+	var req UpdateAIBudgetSettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if (req.MonthlyTokens != nil && *req.MonthlyTokens < 0) || (req.MonthlyCost != nil && *req.MonthlyCost < 0) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Budgets cannot be negative"})
+	}
+	if req.WarningPercent != nil && (*req.WarningPercent < 1 || *req.WarningPercent > 100) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Warning percent must be between 1 and 100"})
+	}
+
+	if req.MonthlyTokens != nil {
+		setSettingValue("ai_budget_monthly_tokens", strconv.Itoa(*req.MonthlyTokens))
+	}
+	if req.MonthlyCost != nil {
+		setSettingValue("ai_budget_monthly_cost", strconv.FormatFloat(*req.MonthlyCost, 'f', 2, 64))
+	}
+	if req.WarningPercent != nil {
+		setSettingValue("ai_budget_warning_percent", strconv.Itoa(*req.WarningPercent))
+	}
+	if req.HardStop != nil {
+		setSettingValue("ai_budget_hard_stop", boolToString(*req.HardStop))
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Budget settings updated"})
+}
+
+// UpdateCourseAIBudgetRequest sets a course's monthly limits; null or 0 removes a limit
+type UpdateCourseAIBudgetRequest struct {
+	MonthlyTokenLimit *int64   `json:"monthly_token_limit"`
+	MonthlyCostLimit  *float64 `json:"monthly_cost_limit"`
+}
+
+// UpdateCourseAIBudget sets or clears a course's monthly AI budget
+// PUT /api/admin/ai/budgets/courses/:courseId
+func UpdateCourseAIBudget(c echo.Context) error {
+	courseID := c.Param("courseId")
+
+	var req UpdateCourseAIBudgetRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if (req.MonthlyTokenLimit != nil && *req.MonthlyTokenLimit < 0) || (req.MonthlyCostLimit != nil && *req.MonthlyCostLimit < 0) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Budgets cannot be negative"})
+	}
+	if req.MonthlyTokenLimit != nil && *req.MonthlyTokenLimit == 0 {
+		req.MonthlyTokenLimit = nil
+	}
+	if req.MonthlyCostLimit != nil && *req.MonthlyCostLimit == 0 {
+		req.MonthlyCostLimit = nil
+	}
+
+	ctx := c.Request().Context()
+	repo := getAIRepo()
+
+	budget, err := repo.GetCourseBudget(ctx, courseID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load course"})
+	}
+	if budget == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Course not found"})
+	}
+
+	if err := repo.SetCourseBudget(ctx, courseID, req.MonthlyTokenLimit, req.MonthlyCostLimit); err != nil {
+		log.Printf("[AI Budget] Failed to save budget for course %s: %v", courseID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save budget"})
+	}
+
+	budget.MonthlyTokenLimit = req.MonthlyTokenLimit
+	budget.MonthlyCostLimit = req.MonthlyCostLimit
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"course_id":           courseID,
+		"monthly_token_limit": budget.MonthlyTokenLimit,
+		"monthly_cost_limit":  budget.MonthlyCostLimit,
+		"status":              courseAIBudget(*budget),
+	})
+}
+
+// GetAIModelPricing lists the pricing table
+// GET /api/admin/ai/pricing
+func GetAIModelPricing(c echo.Context) error {
+	prices, err := getAIRepo().ListModelPricing(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load pricing"})
+	}
+	return c.JSON(http.StatusOK, prices)
+}
+
+// UpsertAIModelPricing creates or updates a model price
+// PUT /api/admin/ai/pricing
+func UpsertAIModelPricing(c echo.Context) error {
+	var price postgres.ModelPricing
+	if err := c.Bind(&price); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	price.Provider = strings.TrimSpace(price.Provider)
+	price.Model = strings.TrimSpace(price.Model)
+	if price.Model == "" {
+		price.Model = "*"
+	}
+	if price.Provider == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Provider is required"})
+	}
+	if price.InputPricePerMTok < 0 || price.OutputPricePerMTok < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Prices cannot be negative"})
+	}
+
+	if err := getAIRepo().UpsertModelPricing(c.Request().Context(), &price); err != nil {
+		log.Printf("[AI Budget] Failed to save price for %s/%s: %v", price.Provider, price.Model, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save price"})
+	}
+	return c.JSON(http.StatusOK, price)
+}
+
+// DeleteAIModelPricing removes a model price
+// DELETE /api/admin/ai/pricing/:id
+func DeleteAIModelPricing(c echo.Context) error {
+	found, err := getAIRepo().DeleteModelPricing(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete price"})
+	}
+	if !found {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Price not found"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Price deleted"})
+}
+
+// GetAIUsageReport aggregates tokens and estimated spend by course, user or day
+// GET /api/admin/ai/usage?group_by=course&from=2024-01-01&to=2024-01-31
+func GetAIUsageReport(c echo.Context) error {
+	groupBy := c.QueryParam("group_by")
+	if groupBy == "" {
+		groupBy = "course"
+	}
+	if !postgres.IsUsageReportGroup(groupBy) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "group_by must be course, user or day"})
+	}
+
+	// Defaults to the current month; "to" is inclusive
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to := now
+	if v := c.QueryParam("from"); v != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", v, now.Location())
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "from must be YYYY-MM-DD"})
+		}
+		from = parsed
+	}
+	if v := c.QueryParam("to"); v != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", v, now.Location())
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "to must be YYYY-MM-DD"})
+		}
+		to = parsed
+	}
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, to.Location()).AddDate(0, 0, 1)
+	if !from.Before(end) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "from must not be after to"})
+	}
+
+	rows, err := getAIRepo().UsageReport(c.Request().Context(), groupBy, from, end)
+	if err != nil {
+		log.Printf("[AI Budget] Failed to build usage report: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to build usage report"})
+	}
+
+	var totals postgres.UsageTotals
+	for _, row := range rows {
+		totals.Requests += row.Requests
+		totals.TokensInput += row.TokensInput
+		totals.TokensOutput += row.TokensOutput
+		totals.Cost += row.Cost
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"group_by": groupBy,
+		"from":     from.Format("2006-01-02"),
+		"to":       end.AddDate(0, 0, -1).Format("2006-01-02"),
+		"rows":     rows,
+		"totals":   totals,
+		"currency": "USD",
+	})
+}
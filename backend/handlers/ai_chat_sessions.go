@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
+)
+
+const maxChatTitleLength = 100
+
+// ChatSessionRequest creates or updates a tutor conversation
+type ChatSessionRequest struct {
+	Title    *string `json:"title"`
+	Archived *bool   `json:"archived"`
+}
+
+// normalizeChatTitle trims a session title and cuts it to maxChatTitleLength runes
+func normalizeChatTitle(title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	if utf8.RuneCountInString(title) > maxChatTitleLength {
+		title = string([]rune(title)[:maxChatTitleLength])
+	}
+	return title
+}
+
+// chatTitleFromMessage names a new conversation after its first question
+func chatTitleFromMessage(message string) string {
+	title := strings.Join(strings.Fields(message), " ")
+	if utf8.RuneCountInString(title) > 60 {
+		title = strings.TrimSpace(string([]rune(title)[:60])) + "…"
+	}
+	if title == "" {
+		return postgres.DefaultChatTitle
+	}
+	return title
+}
+
+// loadOwnedChatSession returns the student's session in the course. On failure the
+// error response has already been written and (nil, err) is returned.
+func loadOwnedChatSession(c echo.Context, sessionID, userID, courseID string) (*postgres.ChatSession, error) {
+	session, err := getAIRepo().GetChatSessionByID(c.Request().Context(), sessionID)
+	if err != nil {
+		return nil, c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get session"})
+	}
+	if session == nil || session.UserID != userID || session.CourseID != courseID {
+		return nil, c.JSON(http.StatusNotFound, map[string]string{"error": "Percakapan tidak ditemukan"})
+	}
+	return session, nil
+}
+
+// ListChatSessions returns the student's tutor conversations in a course
+// GET /api/courses/:id/chat/sessions?archived=true
+func ListChatSessions(c echo.Context) error {
+	userID := getUserIDFromToken(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	includeArchived := c.QueryParam("archived") == "true"
+	sessions, err := getAIRepo().ListChatSessions(c.Request().Context(), userID, c.Param("id"), includeArchived)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list sessions"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"sessions": sessions})
+}
+
+// CreateChatSession starts a new tutor conversation
+// POST /api/courses/:id/chat/sessions
+func CreateChatSession(c echo.Context) error {
+	userID := getUserIDFromToken(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	var req ChatSessionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	title := postgres.DefaultChatTitle
+	if req.Title != nil {
+		if t := normalizeChatTitle(*req.Title); t != "" {
+			title = t
+		}
+	}
+
+	session, err := getAIRepo().CreateChatSession(c.Request().Context(), userID, c.Param("id"), title)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create session"})
+	}
+	return c.JSON(http.StatusCreated, session)
+}
+
+// UpdateChatSession renames, archives or reopens a tutor conversation
+// PUT /api/courses/:id/chat/sessions/:sessionId
+func UpdateChatSession(c echo.Context) error {
+	userID := getUserIDFromToken(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	var req ChatSessionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	session, err := loadOwnedChatSession(c, c.Param("sessionId"), userID, c.Param("id"))
+	if session == nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	repo := getAIRepo()
+
+	if req.Title != nil {
+		title := normalizeChatTitle(*req.Title)
+		if title == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Judul percakapan wajib diisi"})
+		}
+		if err := repo.RenameChatSession(ctx, session.ID, title); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to rename session"})
+		}
+		session.Title = title
+	}
+
+	if req.Archived != nil {
+		if err := repo.SetChatSessionArchived(ctx, session.ID, *req.Archived); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to archive session"})
+		}
+		session.IsActive = !*req.Archived
+	}
+
+	return c.JSON(http.StatusOK, session)
+}
+
+// DeleteChatSession removes a tutor conversation and its messages
+// DELETE /api/courses/:id/chat/sessions/:sessionId
+func DeleteChatSession(c echo.Context) error {
+	userID := getUserIDFromToken(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	session, err := loadOwnedChatSession(c, c.Param("sessionId"), userID, c.Param("id"))
+	if session == nil {
+		return err
+	}
+
+	if err := getAIRepo().DeleteChatSession(c.Request().Context(), session.ID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete session"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Session deleted"})
+}
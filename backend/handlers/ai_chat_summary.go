@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/providers"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/rag"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
+)
+
+const (
+	chatHistoryFetchLimit   = 50 // Unsummarized messages loaded per turn
+	chatSummaryKeepMessages = 6  // Latest messages always sent verbatim
+	chatSummaryMaxTokens    = 600
+	chatSummaryTemperature  = 0.2
+	chatSummaryTimeout      = 30 * time.Second
+)
+
+// loadChatMemory returns the running summary and the recent turns of a session for
+// the next prompt. When the turns not yet summarized exceed ai_history_token_budget,
+// the older ones are folded into the summary first; if that fails, the prompt is
+// built from the recent turns only. Turns are redacted by the guard.
+func loadChatMemory(ctx context.Context, repo *postgres.AIRepository, session *postgres.ChatSession, guard *chatGuard) (string, []providers.Message) {
+	summary := ""
+	if session.Summary != nil {
+		summary = *session.Summary
+	}
+
+	history, err := repo.GetUnsummarizedHistory(ctx, session, chatHistoryFetchLimit)
+	if err != nil {
+		log.Printf("[AI Chat] Failed to load history for session %s: %v", session.ID, err)
+		return summary, nil
+	}
+
+	turns := make([]providers.Message, len(history))
+	for i, msg := range history {
+		turns[i] = providers.Message{Role: msg.Role, Content: guard.redact(msg.Content)}
+	}
+
+	budget := getSettingInt("ai_history_token_budget", 2000)
+	older, recent := rag.SplitHistory(turns, budget, chatSummaryKeepMessages)
+	if len(older) == 0 {
+		return summary, recent
+	}
+
+	updated, err := summarizeChatTurns(ctx, repo, session, summary, older)
+	if err != nil {
+		log.Printf("[AI Chat] Failed to summarize session %s: %v", session.ID, err)
+		return summary, recent
+	}
+
+	summarizedUntil := history[len(older)-1].CreatedAt
+	if err := repo.UpdateChatSummary(ctx, session.ID, updated, summarizedUntil); err != nil {
+		log.Printf("[AI Chat] Failed to save summary for session %s: %v", session.ID, err)
+	} else {
+		session.Summary = &updated
+		session.SummarizedUntil = &summarizedUntil
+	}
+	return updated, recent
+}
+
+// summarizeChatTurns folds turns into a session's running summary
+func summarizeChatTurns(ctx context.Context, repo *postgres.AIRepository, session *postgres.ChatSession, previous string, turns []providers.Message) (string, error) {
+	provider, providerName, err := newChatProvider(chatSummaryMaxTokens, chatSummaryTemperature)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, chatSummaryTimeout)
+	defer cancel()
+
+	response, err := provider.Chat(ctx, providers.ChatRequest{
+		Messages:    rag.BuildSummaryMessages(previous, turns),
+		MaxTokens:   chatSummaryMaxTokens,
+		Temperature: chatSummaryTemperature,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	answeredBy := providerName
+	if response.Provider != "" {
+		answeredBy = response.Provider
+	}
+	repo.LogUsage(ctx, session.UserID, session.CourseID, "chat_summary", answeredBy, response.Model, response.TokensInput, response.TokensOutput)
+
+	summary := strings.TrimSpace(response.Content)
+	if summary == "" {
+		return "", errors.New("empty summary")
+	}
+	log.Printf("[AI Chat] Folded %d messages into the summary of session %s", len(turns), session.ID)
+	return summary, nil
+}
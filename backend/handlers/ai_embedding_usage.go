@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/embeddings"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/rag"
+)
+
+// embeddingUsageAction is the ai_usage_log action type of embedding calls
+const embeddingUsageAction = "embedding"
+
+// meteredEmbedder counts the tokens an embedder uses, so course indexing, tutor
+// queries and RAG evaluations are logged and priced like chat completions
+type meteredEmbedder struct {
+	embeddings.Embedder
+	tokens atomic.Int64
+}
+
+// newMeteredEmbedder wraps the embedder for a provider in a token counter
+func newMeteredEmbedder(provider, apiKey string) *meteredEmbedder {
+	return &meteredEmbedder{Embedder: newEmbedder(provider, apiKey)}
+}
+
+// Embed generates an embedding and counts its tokens. Servers that don't report
+// usage are counted with the same estimate as streamed replies.
+func (m *meteredEmbedder) Embed(ctx context.Context, text string) (*embeddings.EmbeddingResult, error) {
+	result, err := m.Embedder.Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	m.count(result.TokensUsed, text)
+	return result, nil
+}
+
+// EmbedBatch generates embeddings and counts their tokens
+func (m *meteredEmbedder) EmbedBatch(ctx context.Context, texts []string) ([]embeddings.EmbeddingResult, error) {
+	results, err := m.Embedder.EmbedBatch(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		m.count(results[i].TokensUsed, texts[i])
+	}
+	return results, nil
+}
+
+func (m *meteredEmbedder) count(tokensUsed int, text string) {
+	if tokensUsed <= 0 {
+		tokensUsed = rag.EstimateTokens(text)
+	}
+	m.tokens.Add(int64(tokensUsed))
+}
+
+// logUsage records the tokens counted since the last call as embedding usage of
+// a user and course. The tokens were spent even if the caller's request was
+// cancelled, so the log entry doesn't use its context.
+func (m *meteredEmbedder) logUsage(userID, courseID string) {
+	tokens := m.tokens.Swap(0)
+	if tokens == 0 {
+		return
+	}
+	if userID == "" {
+		log.Printf("[AI Usage] %d embedding tokens for course %s have no user to log them for", tokens, courseID)
+		return
+	}
+	err := getAIRepo().LogUsage(context.Background(), userID, courseID, embeddingUsageAction, m.Name(), m.GetModel(), int(tokens), 0)
+	if err != nil {
+		log.Printf("[AI Usage] Failed to log embedding usage for course %s: %v", courseID, err)
+	}
+}
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/rag"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
+)
+
+const (
+	maxRAGEvalQuestions = 200 // Per run; each question costs one embedding call
+	ragEvalRunTimeout   = 10 * time.Minute
+)
+
+// RAGEvalQuestionRequest is a golden question with the lessons that should answer it
+type RAGEvalQuestionRequest struct {
+	Question          string   `json:"question"`
+	ExpectedLessonIDs []string `json:"expected_lesson_ids"`
+	ReferenceAnswer   *string  `json:"reference_answer"`
+}
+
+// CreateRAGEvalQuestionsRequest adds one or more golden questions
+type CreateRAGEvalQuestionsRequest struct {
+	Questions []RAGEvalQuestionRequest `json:"questions"`
+}
+
+// RunRAGEvalRequest overrides the default retriever configuration for a run
+type RunRAGEvalRequest struct {
+	Label           string   `json:"label"`
+	TopK            *int     `json:"top_k"`
+	MinSimilarity   *float64 `json:"min_similarity"`
+	MaxContextChars *int     `json:"max_context_chars"`
+	RRFScoreScale   *float64 `json:"rrf_score_scale"`
+}
+
+// ragEvalConfig is the retriever and chunker configuration stored with a run
+type ragEvalConfig struct {
+	TopK              int     `json:"top_k"`
+	MinSimilarity     float64 `json:"min_similarity"`
+	MaxContextChars   int     `json:"max_context_chars"`
+	RRFScoreScale     float64 `json:"rrf_score_scale"`
+	EmbeddingProvider string  `json:"embedding_provider"`
+	EmbeddingModel    string  `json:"embedding_model"`
+	ChunkerVersion    string  `json:"chunker_version"`
+	MaxChunkSize      int     `json:"max_chunk_size"`
+	ChunkOverlap      int     `json:"chunk_overlap"`
+}
+
+// validateRAGEvalQuestion trims a golden question and checks its expected lessons
+// belong to the course. Returns an error message for the client, or "".
+func validateRAGEvalQuestion(ctx context.Context, repo *postgres.AIRepository, courseID string, req *RAGEvalQuestionRequest) (string, error) {
+	req.Question = strings.TrimSpace(req.Question)
+	req.ExpectedLessonIDs = uniqueStrings(req.ExpectedLessonIDs)
+	if req.Question == "" {
+		return "Question is required", nil
+	}
+	if len(req.ExpectedLessonIDs) == 0 {
+		return "At least one expected lesson is required", nil
+	}
+	for _, id := range req.ExpectedLessonIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return "Invalid expected lesson ID: " + id, nil
+		}
+	}
+	if req.ReferenceAnswer != nil {
+		trimmed := strings.TrimSpace(*req.ReferenceAnswer)
+		if trimmed == "" {
+			req.ReferenceAnswer = nil
+		} else {
+			req.ReferenceAnswer = &trimmed
+		}
+	}
+
+	count, err := repo.CountCourseLessons(ctx, courseID, req.ExpectedLessonIDs)
+	if err != nil {
+		return "", err
+	}
+	if count != len(req.ExpectedLessonIDs) {
+		return "Expected lessons must belong to the course", nil
+	}
+	return "", nil
+}
+
+// ListRAGEvalQuestions returns a course's golden questions
+// GET /api/admin/courses/:id/rag-eval/questions
+func ListRAGEvalQuestions(c echo.Context) error {
+	questions, err := getAIRepo().ListEvalQuestions(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load questions"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"questions": questions})
+}
+
+// CreateRAGEvalQuestions adds golden questions to a course
+// POST /api/admin/courses/:id/rag-eval/questions
+func CreateRAGEvalQuestions(c echo.Context) error {
+	courseID := c.Param("id")
+	userID := getUserIDFromToken(c)
+
+	var req CreateRAGEvalQuestionsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if len(req.Questions) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No questions provided"})
+	}
+	if len(req.Questions) > maxRAGEvalQuestions {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Too many questions"})
+	}
+
+	ctx := c.Request().Context()
+	repo := getAIRepo()
+
+	questions := make([]postgres.RAGEvalQuestion, 0, len(req.Questions))
+	for i := range req.Questions {
+		q := &req.Questions[i]
+		msg, err := validateRAGEvalQuestion(ctx, repo, courseID, q)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to validate lessons"})
+		}
+		if msg != "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+		}
+		questions = append(questions, postgres.RAGEvalQuestion{
+			Question:          q.Question,
+			ExpectedLessonIDs: q.ExpectedLessonIDs,
+			ReferenceAnswer:   q.ReferenceAnswer,
+		})
+	}
+
+	created, err := repo.CreateEvalQuestions(ctx, courseID, userID, questions)
+	if err != nil {
+		log.Printf("[RAG Eval] Failed to save questions for course %s: %v", courseID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save questions"})
+	}
+	return c.JSON(http.StatusCreated, map[string]interface{}{"questions": created})
+}
+
+// UpdateRAGEvalQuestion edits a golden question
+// PUT /api/admin/rag-eval/questions/:questionId
+func UpdateRAGEvalQuestion(c echo.Context) error {
+	var req RAGEvalQuestionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	ctx := c.Request().Context()
+	repo := getAIRepo()
+
+	question, err := repo.GetEvalQuestion(ctx, c.Param("questionId"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load question"})
+	}
+	if question == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Question not found"})
+	}
+
+	msg, err := validateRAGEvalQuestion(ctx, repo, question.CourseID, &req)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to validate lessons"})
+	}
+	if msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+	}
+
+	question.Question = req.Question
+	question.ExpectedLessonIDs = req.ExpectedLessonIDs
+	question.ReferenceAnswer = req.ReferenceAnswer
+	if err := repo.UpdateEvalQuestion(ctx, question); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save question"})
+	}
+	return c.JSON(http.StatusOK, question)
+}
+
+// DeleteRAGEvalQuestion removes a golden question
+// DELETE /api/admin/rag-eval/questions/:questionId
+func DeleteRAGEvalQuestion(c echo.Context) error {
+	if err := getAIRepo().DeleteEvalQuestion(c.Request().Context(), c.Param("questionId")); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete question"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Question deleted"})
+}
+
+// RunRAGEvaluation runs a course's golden questions through the retriever with
+// the given configuration and stores the scored run
+// POST /api/admin/courses/:id/rag-eval/runs
+func RunRAGEvaluation(c echo.Context) error {
+	courseID := c.Param("id")
+	userID := getUserIDFromToken(c)
+
+	var req RunRAGEvalRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	retrieverConfig := rag.DefaultRetrieverConfig()
+	if req.TopK != nil {
+		if *req.TopK < 1 || *req.TopK > 50 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "top_k must be between 1 and 50"})
+		}
+		retrieverConfig.TopK = *req.TopK
+	}
+	if req.MinSimilarity != nil {
+		if *req.MinSimilarity < 0 || *req.MinSimilarity > 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "min_similarity must be between 0 and 1"})
+		}
+		retrieverConfig.MinSimilarity = *req.MinSimilarity
+	}
+	if req.MaxContextChars != nil {
+		if *req.MaxContextChars < 500 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "max_context_chars must be at least 500"})
+		}
+		retrieverConfig.MaxContextChars = *req.MaxContextChars
+	}
+	if req.RRFScoreScale != nil {
+		if *req.RRFScoreScale <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "rrf_score_scale must be positive"})
+		}
+		retrieverConfig.RRFScoreScale = *req.RRFScoreScale
+	}
+
+	ctx := c.Request().Context()
+	repo := getAIRepo()
+
+	stored, err := repo.ListEvalQuestions(ctx, courseID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load questions"})
+	}
+	if len(stored) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Course has no golden questions"})
+	}
+	if len(stored) > maxRAGEvalQuestions {
+		stored = stored[:maxRAGEvalQuestions]
+	}
+
+	provider := getSettingValue("ai_provider", "openai")
+	apiKey, ok := aiProviderKey(provider)
+	if !ok {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "AI provider is not configured"})
+	}
+	embedder := newMeteredEmbedder(provider, apiKey)
+	retriever := rag.NewRetriever(embedder, repo, retrieverConfig)
+
+	questions := make([]rag.EvalQuestion, len(stored))
+	for i, q := range stored {
+		questions[i] = q.EvalQuestion()
+	}
+
+	evalCtx, cancel := context.WithTimeout(ctx, ragEvalRunTimeout)
+	defer cancel()
+	results, summary := retriever.Evaluate(evalCtx, courseID, questions)
+	embedder.logUsage(userID, courseID)
+
+	chunkConfig := rag.DefaultChunkConfig()
+	config, _ := json.Marshal(ragEvalConfig{
+		TopK:              retrieverConfig.TopK,
+		MinSimilarity:     retrieverConfig.MinSimilarity,
+		MaxContextChars:   retrieverConfig.MaxContextChars,
+		RRFScoreScale:     retrieverConfig.RRFScoreScale,
+		EmbeddingProvider: embedder.Name(),
+		EmbeddingModel:    embedder.GetModel(),
+		ChunkerVersion:    chunkerVersion,
+		MaxChunkSize:      chunkConfig.MaxChunkSize,
+		ChunkOverlap:      chunkConfig.ChunkOverlap,
+	})
+
+	run := &postgres.RAGEvalRun{
+		CourseID:         courseID,
+		Config:           config,
+		QuestionCount:    summary.Questions,
+		ErrorCount:       summary.Errors,
+		RecallAtK:        summary.RecallAtK,
+		MRR:              summary.MRR,
+		CitationAccuracy: summary.CitationAccuracy,
+		HitRate:          summary.HitRate,
+		Results:          results,
+	}
+	if label := strings.TrimSpace(req.Label); label != "" {
+		run.Label = &label
+	}
+
+	if err := repo.CreateEvalRun(ctx, run, userID); err != nil {
+		log.Printf("[RAG Eval] Failed to save run for course %s: %v", courseID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save evaluation run"})
+	}
+
+	log.Printf("[RAG Eval] Course %s: %d questions, recall@%d=%.3f, MRR=%.3f, citation accuracy=%.3f",
+		courseID, summary.Questions, retrieverConfig.TopK, summary.RecallAtK, summary.MRR, summary.CitationAccuracy)
+	return c.JSON(http.StatusCreated, run)
+}
+
+// ListRAGEvalRuns returns a course's evaluation runs, newest first
+// GET /api/admin/courses/:id/rag-eval/runs
+func ListRAGEvalRuns(c echo.Context) error {
+	runs, err := getAIRepo().ListEvalRuns(c.Request().Context(), c.Param("id"), 50)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load evaluation runs"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"runs": runs})
+}
+
+// GetRAGEvalRun returns an evaluation run with its per-question results
+// GET /api/admin/rag-eval/runs/:runId
+func GetRAGEvalRun(c echo.Context) error {
+	run, err := getAIRepo().GetEvalRun(c.Request().Context(), c.Param("runId"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load evaluation run"})
+	}
+	if run == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Evaluation run not found"})
+	}
+	return c.JSON(http.StatusOK, run)
+}
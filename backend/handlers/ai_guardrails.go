@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/guardrails"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/rag"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
+)
+
+// Guardrail event types
+const (
+	guardEventPromptInjection  = "prompt_injection"
+	guardEventContextInjection = "context_injection"
+	guardEventOffTopic         = "off_topic"
+	guardEventPIIRedacted      = "pii_redacted"
+)
+
+const guardExcerptLength = 300
+
+// chatGuard applies the tutor guardrails to one chat turn and records the events
+type chatGuard struct {
+	userID    string
+	courseID  string
+	sessionID string
+
+	injection    bool // Block injection attempts and drop injected chunks
+	piiRedaction bool // Redact personal data before it reaches the provider
+	offTopic     bool // Refuse questions the course material doesn't cover
+}
+
+// newChatGuard reads the guardrail settings for a turn
+func newChatGuard(userID, courseID string) *chatGuard {
+	return &chatGuard{
+		userID:       userID,
+		courseID:     courseID,
+		injection:    getSettingBool("ai_guard_injection", true),
+		piiRedaction: getSettingBool("ai_guard_pii_redaction", true),
+		offTopic:     getSettingBool("ai_guard_off_topic", false),
+	}
+}
+
+// record stores a guardrail event; failures are only logged
+func (g *chatGuard) record(ctx context.Context, eventType, action, rule, excerpt, lessonID string) {
+	event := &postgres.GuardrailEvent{
+		EventType: eventType,
+		Action:    action,
+		UserID:    optionalString(g.userID),
+		CourseID:  optionalString(g.courseID),
+		SessionID: optionalString(g.sessionID),
+		LessonID:  optionalString(lessonID),
+		Rule:      optionalString(rule),
+		Excerpt:   optionalString(excerpt),
+	}
+	if err := getAIRepo().LogGuardrailEvent(ctx, event); err != nil {
+		log.Printf("[AI Guard] Failed to record %s event: %v", eventType, err)
+	}
+}
+
+// excerpt returns a short, redacted copy of text for the event log
+func (g *chatGuard) excerpt(text string) string {
+	redacted, _ := guardrails.RedactPII(text)
+	return guardrails.Excerpt(redacted, guardExcerptLength)
+}
+
+// screenMessage returns the error for the student when their message is a
+// prompt-injection attempt, or ""
+func (g *chatGuard) screenMessage(ctx context.Context, message string) string {
+	if !g.injection {
+		return ""
+	}
+	rule, found := guardrails.DetectInjection(message)
+	if !found {
+		return ""
+	}
+	log.Printf("[AI Guard] Blocked message from user %s (%s)", g.userID, rule)
+	g.record(ctx, guardEventPromptInjection, "blocked", rule, g.excerpt(message), "")
+	return "Pesan tidak dapat diproses karena berisi instruksi yang mencoba mengubah perilaku AI Tutor. Silakan ajukan pertanyaan tentang materi kursus."
+}
+
+// redact removes personal data from text sent to the provider
+func (g *chatGuard) redact(text string) string {
+	if !g.piiRedaction {
+		return text
+	}
+	redacted, _ := guardrails.RedactPII(text)
+	return redacted
+}
+
+// redactMessage redacts the student's new message and records what was removed
+func (g *chatGuard) redactMessage(ctx context.Context, message string) string {
+	if !g.piiRedaction {
+		return message
+	}
+	redacted, found := guardrails.RedactPII(message)
+	if len(found) > 0 {
+		kinds := make([]string, 0, len(found))
+		for kind, n := range found {
+			kinds = append(kinds, fmt.Sprintf("%s:%d", kind, n))
+		}
+		sort.Strings(kinds)
+		g.record(ctx, guardEventPIIRedacted, "redacted", strings.Join(kinds, ","), guardrails.Excerpt(redacted, guardExcerptLength), "")
+	}
+	return redacted
+}
+
+// filterChunks drops retrieved chunks that carry prompt-injection text, so
+// uploaded material can't instruct the model
+func (g *chatGuard) filterChunks(ctx context.Context, chunks []rag.RetrievedChunk) []rag.RetrievedChunk {
+	if !g.injection {
+		return chunks
+	}
+	kept := chunks[:0]
+	for _, chunk := range chunks {
+		if rule, found := guardrails.DetectInjection(chunk.Text); found {
+			log.Printf("[AI Guard] Dropped chunk %s of lesson %s (%s)", chunk.ID, chunk.LessonID, rule)
+			g.record(ctx, guardEventContextInjection, "filtered", rule, g.excerpt(chunk.Text), chunk.LessonID)
+			continue
+		}
+		kept = append(kept, chunk)
+	}
+	return kept
+}
+
+// refuseOffTopic returns the refusal for a question that retrieval found no course
+// material for, or "". Greetings and thanks are let through.
+func (g *chatGuard) refuseOffTopic(ctx context.Context, message string, retrieved bool) string {
+	if !g.offTopic || retrieved || guardrails.IsSmallTalk(message) {
+		return ""
+	}
+	g.record(ctx, guardEventOffTopic, "blocked", "", g.excerpt(message), "")
+	return "Maaf, pertanyaan ini di luar materi kursus. AI Tutor hanya dapat membantu pertanyaan seputar materi kursus ini."
+}
+
+// optionalString returns nil for an empty string
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// ListAIGuardrailEvents returns guardrail events for admin review
+// GET /api/admin/ai/guardrails/events?type=&course_id=&limit=&offset=
+func ListAIGuardrailEvents(c echo.Context) error {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	events, total, err := getAIRepo().ListGuardrailEvents(c.Request().Context(), postgres.GuardrailEventFilter{
+		EventType: c.QueryParam("type"),
+		CourseID:  c.QueryParam("course_id"),
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		log.Printf("[AI Guard] Failed to list events: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch guardrail events"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"events": events,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
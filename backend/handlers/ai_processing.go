@@ -2,6 +2,9 @@ package handlers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -9,9 +12,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/labstack/echo/v4"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/domain"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/embeddings"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/processor"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/rag"
@@ -19,7 +24,16 @@ import (
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/storage"
 )
 
-// ProcessCourseContent triggers AI content processing for a course
+// embeddingProviders are the providers with an embedding API
+// Currently OpenAI, Gemini and self-hosted servers
+var embeddingProviders = map[string]bool{"openai": true, "gemini": true, "selfhosted": true}
+
+// chunkerVersion is mixed into lesson content hashes; bump it when chunking
+// changes so existing lessons are re-embedded on the next run
+const chunkerVersion = "structured-v1"
+
+// ProcessCourseContent triggers AI content processing for a course.
+// Only changed lessons are re-embedded; ?force=true rebuilds every lesson.
 func ProcessCourseContent(c echo.Context) error {
 	courseID := c.Param("id")
 
@@ -34,22 +48,26 @@ func ProcessCourseContent(c echo.Context) error {
 	aiProvider := getSettingValue("ai_provider", "openai")
 	
 	// Check if provider supports embeddings
-	// Currently OpenAI and Gemini have embedding APIs
-	supportedProviders := map[string]bool{"openai": true, "gemini": true}
-	if !supportedProviders[aiProvider] {
+	if !embeddingProviders[aiProvider] {
 		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Provider " + aiProvider + " tidak mendukung embedding. Pilih OpenAI atau Gemini di Settings AI Tutor untuk menggunakan fitur RAG.",
+			"error": "Provider " + aiProvider + " tidak mendukung embedding. Pilih OpenAI, Gemini, atau Self-hosted di Settings AI Tutor untuk menggunakan fitur RAG.",
 		})
 	}
 
 	// Get API key from the selected AI provider
-	apiKey, _ := GetDecryptedAPIKey(aiProvider)
-	if apiKey == "" {
+	apiKey, ok := aiProviderKey(aiProvider)
+	if !ok {
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": "API key belum dikonfigurasi untuk provider " + aiProvider,
 		})
 	}
 
+	// Indexing is billed to the budget like any other AI usage
+	userID := getUserIDFromToken(c)
+	if blocked, _ := checkAIBudget(c.Request().Context(), userID, courseID); blocked != nil {
+		return aiBudgetExceededResponse(c, blocked)
+	}
+
 	// Create processing status BEFORE starting goroutine (to avoid race condition)
 	// This ensures frontend sees "processing" status immediately when polling
 	repo := postgres.NewAIRepository(db.DB)
@@ -68,8 +86,8 @@ func ProcessCourseContent(c echo.Context) error {
 		log.Printf("[AI Processing] Created status ID: %s with status=processing", status.ID)
 	}
 
-	// Start processing in background with NEW context (not request context)
-	go processCourseContentAsync(context.Background(), courseID, apiKey, aiProvider, status.ID)
+	// Process in background through the job queue (API key is re-read by the worker, never stored in the job)
+	enqueueCourseAIProcessing(courseID, aiProvider, status.ID, apiKey, userID, c.QueryParam("force") == "true")
 
 	return c.JSON(http.StatusAccepted, map[string]string{
 		"message": "Pemrosesan konten dimulai",
@@ -77,11 +95,81 @@ func ProcessCourseContent(c echo.Context) error {
 	})
 }
 
-// processCourseContentAsync processes course content in background
-// statusID is passed from caller (created before goroutine to avoid race condition)
-func processCourseContentAsync(ctx context.Context, courseID, apiKey, provider, statusID string) {
-	log.Printf("[AI Processing] Starting for course %s with provider %s, statusID: %s", courseID, provider, statusID)
-	
+// RebuildAIIndex re-indexes every processed course with the configured embedding
+// model. This is the only way stored embeddings of another size are deleted: the
+// index is cleared for all courses before they are queued again.
+// POST /api/admin/ai/rebuild-index
+func RebuildAIIndex(c echo.Context) error {
+	if !getSettingBool("ai_enabled", false) {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{
+			"error": "AI Tutor belum diaktifkan",
+		})
+	}
+	aiProvider := getSettingValue("ai_provider", "openai")
+	if !embeddingProviders[aiProvider] {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Provider " + aiProvider + " tidak mendukung embedding",
+		})
+	}
+	apiKey, ok := aiProviderKey(aiProvider)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "API key belum dikonfigurasi untuk provider " + aiProvider,
+		})
+	}
+
+	ctx := c.Request().Context()
+	repo := postgres.NewAIRepository(db.DB)
+
+	// Read before the rebuild, which clears the processing state
+	courseIDs, err := repo.ListProcessedCourses(ctx)
+	if err != nil {
+		log.Printf("[AI Processing] Failed to list processed courses: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Gagal memuat daftar kursus",
+		})
+	}
+
+	if err := ensureEmbeddingSchema(ctx, repo, newEmbedder(aiProvider, apiKey), true); err != nil {
+		log.Printf("[AI Processing] Failed to rebuild embedding index: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Gagal membangun ulang indeks: " + err.Error(),
+		})
+	}
+
+	userID := getUserIDFromToken(c)
+	for _, courseID := range courseIDs {
+		status, err := repo.CreateProcessingStatus(ctx, courseID, "", "all")
+		if err != nil {
+			log.Printf("[AI Processing] Failed to create rebuild status for course %s: %v", courseID, err)
+			continue
+		}
+		repo.UpdateProcessingStatus(ctx, status.ID, "processing", 0, 0, nil)
+		enqueueCourseAIProcessing(courseID, aiProvider, status.ID, apiKey, userID, true)
+	}
+
+	log.Printf("[AI Processing] Index rebuild queued for %d courses", len(courseIDs))
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"message": "Pembangunan ulang indeks dimulai",
+		"courses": len(courseIDs),
+	})
+}
+
+// courseProcessingLocks serializes processing runs per course, so an automatic
+// re-index can't interleave with a manual one on the same lessons
+var courseProcessingLocks sync.Map
+
+// processCourseContentAsync indexes course content in background. Only lessons
+// whose content hash changed since they were last indexed are re-embedded,
+// unless force is set. Embedding usage is logged for userID, who started the run.
+// statusID is passed from caller (created before enqueueing to avoid race condition)
+func processCourseContentAsync(ctx context.Context, courseID, userID, apiKey, provider, statusID string, force bool) error {
+	log.Printf("[AI Processing] Starting for course %s with provider %s, statusID: %s, force: %v", courseID, provider, statusID, force)
+
+	lock, _ := courseProcessingLocks.LoadOrStore(courseID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
 	repo := postgres.NewAIRepository(db.DB)
 	lessonRepo := postgres.NewLessonRepository(db.DB)
 
@@ -91,179 +179,221 @@ func processCourseContentAsync(ctx context.Context, courseID, apiKey, provider,
 		log.Printf("[AI Processing] Failed to get lessons: %v", err)
 		errMsg := err.Error()
 		repo.UpdateProcessingStatus(ctx, statusID, "failed", 0, 0, &errMsg)
-		return
+		return err
 	}
 	log.Printf("[AI Processing] Found %d lessons", len(lessons))
 
-	// Clear existing embeddings
-	repo.DeleteByCourse(ctx, courseID)
+	// Jobs queued before runs recorded who started them are billed to the instructor
+	if userID == "" {
+		if course, err := postgres.NewCourseRepository(db.DB).GetByID(courseID); err == nil && course != nil && course.InstructorID != nil {
+			userID = *course.InstructorID
+		}
+	}
+
+	// Don't start embedding once the month's budget is used up; retrying won't
+	// help before it resets, so the job ends here
+	if blocked, _ := checkAIBudget(ctx, userID, courseID); blocked != nil {
+		log.Printf("[AI Processing] Course %s not processed: %s AI budget exceeded", courseID, blocked.Scope)
+		errMsg := "Kuota AI bulan ini telah habis"
+		repo.UpdateProcessingStatus(ctx, statusID, "failed", 0, 0, &errMsg)
+		return nil
+	}
 
 	// Setup processor and embedder based on provider
 	proc := processor.NewContentProcessor()
-	embConfig := embeddings.EmbedderConfig{
-		APIKey: apiKey,
-	}
+	embedder := newMeteredEmbedder(provider, apiKey)
 
-	// Create appropriate embedder with embedding model from settings
-	// Note: embedding models are different from chat models!
-	var embedder embeddings.Embedder
-	embeddingModel := getSettingValue("ai_embedding_model", "")
-	
-	switch provider {
-	case "gemini":
-		if embeddingModel == "" {
-			embeddingModel = "gemini-embedding-001" // Default Gemini embedding model
-		}
-		embConfig.Model = embeddingModel
-		embedder = embeddings.NewGeminiEmbedder(embConfig)
-	default: // openai
-		if embeddingModel == "" {
-			embeddingModel = "text-embedding-ada-002" // Default OpenAI embedding model
+	// Make sure the vector column fits this embedder before storing anything
+	if err := ensureEmbeddingSchema(ctx, repo, embedder.Embedder, false); err != nil {
+		log.Printf("[AI Processing] Failed to prepare embedding schema: %v", err)
+		errMsg := err.Error()
+		var dimErr *postgres.EmbeddingDimensionsError
+		if errors.As(err, &dimErr) {
+			// Needs an admin to rebuild the index; retrying won't help
+			errMsg = fmt.Sprintf("Model embedding menghasilkan %d dimensi, indeks AI berisi %d dimensi. Bangun ulang indeks AI di Pengaturan AI Tutor.", dimErr.Required, dimErr.Current)
+			repo.UpdateProcessingStatus(ctx, statusID, "failed", 0, 0, &errMsg)
+			return nil
 		}
-		embConfig.Model = embeddingModel
-		embedder = embeddings.NewOpenAIEmbedder(embConfig)
+		repo.UpdateProcessingStatus(ctx, statusID, "failed", 0, 0, &errMsg)
+		return err
+	}
+
+	// Hashes of lessons indexed by earlier runs (read after the schema check,
+	// which clears them when the index is rebuilt)
+	indexed, err := repo.GetLessonContentHashes(ctx, courseID)
+	if err != nil {
+		log.Printf("[AI Processing] Failed to load content hashes: %v", err)
+		indexed = map[string]string{}
 	}
 
 	totalChunks := 0
 	processedChunks := 0
-	var allChunks []rag.ChunkData
+	skipped := 0
+	var failed []string
 
 	// Process each lesson
 	for _, lesson := range lessons {
-		var chunks []rag.Chunk
+		hash := lessonContentHash(lesson, embedder)
+		if !force && indexed[lesson.ID] == hash {
+			skipped++
+			continue
+		}
 		log.Printf("[AI Processing] Processing lesson %s, ContentType: %s", lesson.ID, lesson.ContentType)
 
-		switch lesson.ContentType {
-		case "text":
-			if lesson.Content != nil && *lesson.Content != "" {
-				log.Printf("[AI Processing] Lesson %s has text content, length: %d", lesson.ID, len(*lesson.Content))
-				chunks = proc.ProcessHTML(*lesson.Content)
-			} else {
-				log.Printf("[AI Processing] Lesson %s has empty text content", lesson.ID)
-			}
-		case "pdf":
-			if lesson.VideoURL != nil && *lesson.VideoURL != "" {
-				pdfPath := *lesson.VideoURL
-				log.Printf("[AI Processing] Lesson %s has PDF: %s", lesson.ID, pdfPath)
-				
-				var pdfChunks []rag.Chunk
-				var err error
-				
-				// Case 1: External URL (http:// or https://)
-				if strings.HasPrefix(pdfPath, "http://") || strings.HasPrefix(pdfPath, "https://") {
-					log.Printf("[AI Processing] Downloading PDF from URL: %s", pdfPath)
-					pdfChunks, err = proc.ProcessPDFFromURL(ctx, pdfPath)
-				// Case 2: Legacy local uploads (/uploads/...)
-				} else if strings.HasPrefix(pdfPath, "/uploads/") {
-					// In Docker, files are at /app/uploads/...
-					localPath := "/app" + pdfPath
-					log.Printf("[AI Processing] Processing legacy local PDF: %s", localPath)
-					pdfChunks, err = proc.ProcessPDFFromFile(localPath)
-				// Case 3: MinIO object key (no prefix - e.g., "courses/xxx/file.pdf")
-				} else {
-					// Download from MinIO to temp file
-					minioStorage := storage.GetStorage()
-					if minioStorage != nil {
-						log.Printf("[AI Processing] Downloading PDF from MinIO: %s", pdfPath)
-						tempPath, downloadErr := downloadMinIOToTemp(ctx, minioStorage, pdfPath)
-						if downloadErr != nil {
-							log.Printf("[AI Processing] MinIO download error: %v", downloadErr)
-							err = downloadErr
-						} else {
-							defer os.Remove(tempPath) // Clean up temp file
-							log.Printf("[AI Processing] Processing MinIO PDF from temp: %s", tempPath)
-							pdfChunks, err = proc.ProcessPDFFromFile(tempPath)
-						}
-					} else {
-						log.Printf("[AI Processing] MinIO not configured, cannot process object key: %s", pdfPath)
-						err = fmt.Errorf("MinIO not configured")
-					}
-				}
-				
-				if err == nil {
-					chunks = pdfChunks
-				} else {
-					log.Printf("[AI Processing] PDF processing error: %v", err)
-				}
-			}
-		case "video":
-			// Try to get YouTube transcript
-			if lesson.VideoURL != nil && *lesson.VideoURL != "" {
-				log.Printf("[AI Processing] Lesson %s has video: %s", lesson.ID, *lesson.VideoURL)
-				vidChunks, err := proc.ProcessYouTubeTranscript(ctx, *lesson.VideoURL)
-				if err == nil {
-					chunks = vidChunks
-				} else {
-					log.Printf("[AI Processing] Video transcript error: %v", err)
-				}
-			}
-		default:
-			log.Printf("[AI Processing] Unknown content type: %s for lesson %s", lesson.ContentType, lesson.ID)
+		// On failure the lesson keeps its previous embeddings and no hash, so the
+		// next run (or the job retry) picks it up again
+		fail := func(err error) {
+			log.Printf("[AI Processing] Lesson %s failed: %v", lesson.ID, err)
+			errMsg := err.Error()
+			repo.SaveLessonProcessingStatus(ctx, courseID, lesson.ID, string(lesson.ContentType), "failed", "", 0, &errMsg)
+			failed = append(failed, lesson.ID)
 		}
 
-		log.Printf("[AI Processing] Lesson %s generated %d chunks", lesson.ID, len(chunks))
-
-		// Skip if no content
-		if len(chunks) == 0 {
+		chunks, err := extractLessonChunks(ctx, proc, lesson)
+		if err != nil {
+			fail(err)
 			continue
 		}
-
+		log.Printf("[AI Processing] Lesson %s generated %d chunks", lesson.ID, len(chunks))
 		totalChunks += len(chunks)
 
 		// Generate embeddings for chunks
-		log.Printf("[AI Processing] Generating embeddings for %d chunks...", len(chunks))
-		for i, chunk := range chunks {
-			log.Printf("[AI Processing] Processing chunk %d/%d, text length: %d", i+1, len(chunks), len(chunk.Text))
-			
-			chunkData := rag.ChunkData{
+		lessonChunks := make([]rag.ChunkData, 0, len(chunks))
+		var embedErr error
+		for _, chunk := range chunks {
+			result, err := embedder.Embed(ctx, chunk.Text)
+			if err != nil {
+				embedErr = err
+				break
+			}
+			lessonChunks = append(lessonChunks, rag.ChunkData{
 				CourseID:    courseID,
 				LessonID:    lesson.ID,
 				ContentType: string(lesson.ContentType),
 				ChunkIndex:  chunk.Index,
 				ChunkText:   chunk.Text,
-			}
-
-			// Generate embedding
-			log.Printf("[AI Processing] Calling embedder.Embed()...")
-			result, err := embedder.Embed(ctx, chunk.Text)
-			if err != nil {
-				log.Printf("[AI Processing] Embedding error: %v", err)
-				continue
-			}
-			log.Printf("[AI Processing] Embedding generated with %d dimensions", len(result.Embedding))
-			chunkData.Embedding = result.Embedding
-
-			allChunks = append(allChunks, chunkData)
+				Embedding:   result.Embedding,
+				// Page, heading or timestamp so tutor citations can deep-link
+				SourceReference: chunk.Locator(),
+				Metadata:        chunk.Metadata(),
+			})
 			processedChunks++
 
 			// Update progress periodically
 			if processedChunks%10 == 0 {
-			repo.UpdateProcessingStatus(ctx, statusID, "processing", totalChunks, processedChunks, nil)
+				repo.UpdateProcessingStatus(ctx, statusID, "processing", totalChunks, processedChunks, nil)
 			}
 		}
+		embedder.logUsage(userID, courseID)
+		if embedErr != nil {
+			fail(fmt.Errorf("embedding failed after %d of %d chunks: %w", len(lessonChunks), len(chunks), embedErr))
+			continue
+		}
+
+		// Replace the lesson's embeddings
+		if err := repo.DeleteByLesson(ctx, lesson.ID); err != nil {
+			fail(err)
+			continue
+		}
+		if len(lessonChunks) > 0 {
+			if err := repo.InsertBatch(ctx, lessonChunks); err != nil {
+				fail(err)
+				continue
+			}
+		}
+		repo.SaveLessonProcessingStatus(ctx, courseID, lesson.ID, string(lesson.ContentType), "completed", hash, len(lessonChunks), nil)
 	}
 
-	log.Printf("[AI Processing] Total chunks to save: %d, processed: %d", len(allChunks), processedChunks)
+	log.Printf("[AI Processing] Course %s: %d lessons re-indexed, %d unchanged, %d failed", courseID, len(lessons)-skipped-len(failed), skipped, len(failed))
 
-	// Batch insert all chunks
-	if len(allChunks) > 0 {
-		log.Printf("[AI Processing] Inserting %d chunks into database...", len(allChunks))
-		err = repo.InsertBatch(ctx, allChunks)
+	if len(failed) > 0 {
+		errMsg := fmt.Sprintf("%d materi gagal diproses", len(failed))
+		repo.UpdateProcessingStatus(ctx, statusID, "failed", totalChunks, processedChunks, &errMsg)
+		return fmt.Errorf("%d lessons failed to process", len(failed))
+	}
+
+	// Report the size of the whole index, not just what this run touched
+	embeddingCount, _ := repo.GetEmbeddingCount(ctx, courseID)
+	repo.UpdateProcessingStatus(ctx, statusID, "completed", embeddingCount, embeddingCount, nil)
+	log.Printf("[AI Processing] Processing complete!")
+	return nil
+}
+
+// extractLessonChunks turns a lesson's text, PDF or video transcript into chunks
+func extractLessonChunks(ctx context.Context, proc *processor.ContentProcessor, lesson *domain.Lesson) ([]rag.Chunk, error) {
+	switch lesson.ContentType {
+	case "text":
+		if lesson.Content == nil || *lesson.Content == "" {
+			return nil, nil
+		}
+		return proc.ProcessHTML(*lesson.Content), nil
+	case "pdf":
+		if lesson.VideoURL == nil || *lesson.VideoURL == "" {
+			return nil, nil
+		}
+		pdfPath := *lesson.VideoURL
+
+		// Case 1: External URL (http:// or https://)
+		if strings.HasPrefix(pdfPath, "http://") || strings.HasPrefix(pdfPath, "https://") {
+			log.Printf("[AI Processing] Downloading PDF from URL: %s", pdfPath)
+			return proc.ProcessPDFFromURL(ctx, pdfPath)
+		}
+		// Case 2: Legacy local uploads (/uploads/...)
+		if strings.HasPrefix(pdfPath, "/uploads/") {
+			// In Docker, files are at /app/uploads/...
+			return proc.ProcessPDFFromFile("/app" + pdfPath)
+		}
+		// Case 3: Storage object key (no prefix - e.g., "courses/xxx/file.pdf")
+		store := storage.GetStorage()
+		if store == nil {
+			return nil, fmt.Errorf("storage not configured, cannot process object key: %s", pdfPath)
+		}
+		tempPath, err := downloadObjectToTemp(ctx, store, pdfPath)
 		if err != nil {
-			log.Printf("[AI Processing] InsertBatch error: %v", err)
-			errMsg := err.Error()
-			repo.UpdateProcessingStatus(ctx, statusID, "failed", totalChunks, processedChunks, &errMsg)
-			return
+			return nil, err
 		}
-		log.Printf("[AI Processing] Chunks inserted successfully!")
-	} else {
-		log.Printf("[AI Processing] No chunks to insert")
+		defer os.Remove(tempPath) // Clean up temp file
+		return proc.ProcessPDFFromFile(tempPath)
+	case "video":
+		// Prefer the lesson's own subtitle track, then try the YouTube transcript
+		if subChunks := lessonSubtitleChunks(ctx, proc, lesson.ID); len(subChunks) > 0 {
+			log.Printf("[AI Processing] Lesson %s using subtitle track", lesson.ID)
+			return subChunks, nil
+		}
+		if lesson.VideoURL == nil || *lesson.VideoURL == "" {
+			return nil, nil
+		}
+		chunks, err := proc.ProcessYouTubeTranscript(ctx, *lesson.VideoURL)
+		if err != nil {
+			// Most uploaded videos simply have no transcript; don't retry forever
+			log.Printf("[AI Processing] Video transcript error for lesson %s: %v", lesson.ID, err)
+			return nil, nil
+		}
+		return chunks, nil
+	default:
+		return nil, nil
 	}
+}
 
-	// Mark as completed
-	log.Printf("[AI Processing] Marking status as completed")
-	repo.UpdateProcessingStatus(ctx, statusID, "completed", totalChunks, processedChunks, nil)
-	log.Printf("[AI Processing] Processing complete!")
+// lessonContentHash fingerprints everything a lesson's embeddings are built
+// from: content type, text, source file, subtitle track and the embedding model
+func lessonContentHash(lesson *domain.Lesson, embedder embeddings.Embedder) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s/%s/%s/%d\n%s\n", chunkerVersion, embedder.Name(), embedder.GetModel(), embedder.Dimensions(), lesson.ContentType)
+	if lesson.Content != nil {
+		io.WriteString(h, *lesson.Content)
+	}
+	h.Write([]byte{0})
+	if lesson.VideoURL != nil {
+		io.WriteString(h, *lesson.VideoURL)
+	}
+	h.Write([]byte{0})
+	if lesson.ContentType == "video" {
+		if subtitles, err := postgres.NewSubtitleRepository(db.DB).ListByLesson(lesson.ID); err == nil && len(subtitles) > 0 {
+			fmt.Fprintf(h, "%s:%s:%d", subtitles[0].ID, subtitles[0].ObjectPath, subtitles[0].UpdatedAt.Unix())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 // GetProcessingStatus returns content processing status for a course
@@ -288,8 +418,15 @@ func GetProcessingStatus(c echo.Context) error {
 	
 	// PRIORITY 1: Check if there's an active processing status
 	// This must be checked FIRST because during processing, old embeddings may still exist
-	if len(statuses) > 0 {
-		latest := statuses[0]
+	// Per-lesson rows only carry content hashes; the run status is the latest course-level row
+	var runs []postgres.ProcessingStatus
+	for _, s := range statuses {
+		if s.LessonID == nil {
+			runs = append(runs, s)
+		}
+	}
+	if len(runs) > 0 {
+		latest := runs[0]
 		// If status is "processing", always show it (even if embeddings exist from previous run)
 		if latest.Status == "processing" {
 			latestStatus = "processing"
@@ -337,9 +474,24 @@ func ClearCourseEmbeddings(c echo.Context) error {
 	})
 }
 
-// downloadMinIOToTemp downloads a MinIO object to a temporary file
+// lessonSubtitleChunks chunks the lesson's default (or first) subtitle track, if it has one
+func lessonSubtitleChunks(ctx context.Context, proc *processor.ContentProcessor, lessonID string) []rag.Chunk {
+	subtitles, err := postgres.NewSubtitleRepository(db.DB).ListByLesson(lessonID)
+	if err != nil || len(subtitles) == 0 {
+		return nil
+	}
+
+	vtt, err := loadHLSPlaylist(ctx, subtitles[0].ObjectPath)
+	if err != nil {
+		log.Printf("[AI Processing] Failed to load subtitle for lesson %s: %v", lessonID, err)
+		return nil
+	}
+	return proc.ProcessSubtitles(vtt)
+}
+
+// downloadObjectToTemp downloads a storage object to a temporary file
 // Returns the temp file path (caller is responsible for cleanup with os.Remove)
-func downloadMinIOToTemp(ctx context.Context, minioStorage *storage.MinioStorage, objectKey string) (string, error) {
+func downloadObjectToTemp(ctx context.Context, minioStorage storage.Storage, objectKey string) (string, error) {
 	// Get object from MinIO
 	obj, err := minioStorage.GetObject(ctx, "", objectKey)
 	if err != nil {
@@ -371,3 +523,100 @@ func downloadMinIOToTemp(ctx context.Context, minioStorage *storage.MinioStorage
 	log.Printf("[AI Processing] Downloaded MinIO object %s to temp file %s", objectKey, tempPath)
 	return tempPath, nil
 }
+
+// newEmbedder creates the embedder for a provider with the embedding model from settings.
+// Note: embedding models are different from chat models!
+func newEmbedder(provider, apiKey string) embeddings.Embedder {
+	embConfig := embeddings.EmbedderConfig{
+		APIKey: apiKey,
+		Model:  getSettingValue("ai_embedding_model", ""),
+	}
+
+	switch provider {
+	case "gemini":
+		if embConfig.Model == "" {
+			embConfig.Model = "gemini-embedding-001" // Default Gemini embedding model
+		}
+		return embeddings.NewGeminiEmbedder(embConfig)
+	case "selfhosted":
+		embConfig.BaseURL = getSettingValue("ai_selfhosted_base_url", "")
+		embConfig.Dimensions = getSettingInt("ai_selfhosted_embedding_dimensions", 0)
+		return embeddings.NewSelfHostedEmbedder(embConfig)
+	default: // openai
+		if embConfig.Model == "" {
+			embConfig.Model = "text-embedding-ada-002" // Default OpenAI embedding model
+		}
+		return embeddings.NewOpenAIEmbedder(embConfig)
+	}
+}
+
+// ensureEmbeddingSchema resizes the pgvector column to the embedder's dimensions.
+// Stored embeddings of another size are only cleared when rebuild is set.
+// Self-hosted embedders may only learn their size from a first request.
+func ensureEmbeddingSchema(ctx context.Context, repo *postgres.AIRepository, embedder embeddings.Embedder, rebuild bool) error {
+	dims := embedder.Dimensions()
+	if detector, ok := embedder.(interface {
+		DetectDimensions(ctx context.Context) (int, error)
+	}); ok {
+		var err error
+		if dims, err = detector.DetectDimensions(ctx); err != nil {
+			return fmt.Errorf("failed to detect embedding dimensions: %w", err)
+		}
+	}
+
+	changed, err := repo.EnsureEmbeddingDimensions(ctx, dims, rebuild)
+	if err != nil {
+		return err
+	}
+	if changed {
+		log.Printf("[AI Processing] Embedding column resized to %d dimensions for %s/%s", dims, embedder.Name(), embedder.GetModel())
+	}
+	return nil
+}
+
+// lessonSourceKey identifies the inputs a lesson's embeddings are built from,
+// to detect content changes in lesson updates
+func lessonSourceKey(lesson *domain.Lesson) string {
+	key := string(lesson.ContentType) + "\x00"
+	if lesson.Content != nil {
+		key += *lesson.Content
+	}
+	key += "\x00"
+	if lesson.VideoURL != nil {
+		key += *lesson.VideoURL
+	}
+	return key
+}
+
+// queueCourseReindex re-runs incremental AI processing after a lesson's content
+// changed. Only courses that were processed before are re-indexed automatically,
+// so editing a course never starts spending on embeddings by itself.
+func queueCourseReindex(courseID, userID string) {
+	if !getSettingBool("ai_enabled", false) {
+		return
+	}
+	provider := getSettingValue("ai_provider", "openai")
+	if !embeddingProviders[provider] {
+		return
+	}
+	apiKey, ok := aiProviderKey(provider)
+	if !ok {
+		return
+	}
+
+	ctx := context.Background()
+	repo := postgres.NewAIRepository(db.DB)
+	if processed, err := repo.HasProcessedContent(ctx, courseID); err != nil || !processed {
+		return
+	}
+
+	status, err := repo.CreateProcessingStatus(ctx, courseID, "", "all")
+	if err != nil {
+		log.Printf("[AI Processing] Failed to create re-index status for course %s: %v", courseID, err)
+		return
+	}
+	repo.UpdateProcessingStatus(ctx, status.ID, "processing", 0, 0, nil)
+
+	log.Printf("[AI Processing] Lesson content changed, re-indexing course %s", courseID)
+	enqueueCourseAIProcessing(courseID, provider, status.ID, apiKey, userID, false)
+}
@@ -0,0 +1,519 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/providers"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/domain"
+	customMiddleware "github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/middleware"
+)
+
+const (
+	quizDraftDefaultQuestions = 5
+	quizDraftMaxQuestions     = 20
+	quizDraftMaxChunks        = 200
+	quizDraftMaxContextChars  = 12000
+	quizDraftMaxTokens        = 4096
+	quizDraftTemperature      = 0.4
+)
+
+// QuizDraftRequest asks the AI provider for draft questions for a quiz
+type QuizDraftRequest struct {
+	LessonIDs     []string              `json:"lesson_ids"`     // Defaults to the other lessons in the quiz's module
+	Count         int                   `json:"count"`          // Defaults to 5, at most 20
+	QuestionTypes []domain.QuestionType `json:"question_types"` // Defaults to all types
+	Instructions  string                `json:"instructions"`   // Optional guidance, e.g. difficulty
+}
+
+// QuizDraft is a set of generated questions for the instructor to review.
+// Nothing is saved until the draft is accepted.
+type QuizDraft struct {
+	QuizID    string                         `json:"quiz_id"`
+	Questions []domain.CreateQuestionRequest `json:"questions"`
+	Rejected  []QuizDraftRejection           `json:"rejected,omitempty"`
+	Provider  string                         `json:"provider"`
+	Model     string                         `json:"model"`
+}
+
+// QuizDraftRejection is a generated question that failed validation
+type QuizDraftRejection struct {
+	Index        int    `json:"index"`
+	QuestionText string `json:"question_text"`
+	Reason       string `json:"reason"`
+}
+
+// AcceptQuizDraftRequest saves reviewed draft questions into a quiz
+type AcceptQuizDraftRequest struct {
+	Questions []domain.CreateQuestionRequest `json:"questions"`
+}
+
+// quizTarget is a quiz the caller may edit, with the lesson and course it belongs to
+type quizTarget struct {
+	userID   string
+	quizID   string
+	lessonID string
+	courseID string
+}
+
+// resolveQuizTarget loads the quiz and checks the caller may edit it:
+// admins may edit any quiz, instructors only quizzes in their own courses.
+// On failure the error response has already been written and (nil, err) is returned.
+func resolveQuizTarget(c echo.Context) (*quizTarget, error) {
+	userID, role, err := customMiddleware.GetUserFromContext(c)
+	if err != nil {
+		return nil, err
+	}
+
+	target := &quizTarget{userID: userID, quizID: c.Param("quizId")}
+	var instructorID sql.NullString
+	err = db.DB.QueryRow(`
+		SELECT l.id, c.id, c.instructor_id
+		FROM quizzes q
+		JOIN lessons l ON q.lesson_id = l.id
+		JOIN courses c ON l.course_id = c.id
+		WHERE q.id = $1
+	`, target.quizID).Scan(&target.lessonID, &target.courseID, &instructorID)
+	if err == sql.ErrNoRows {
+		return nil, c.JSON(http.StatusNotFound, map[string]string{"error": "Quiz tidak ditemukan"})
+	}
+	if err != nil {
+		return nil, c.JSON(http.StatusInternalServerError, map[string]string{"error": "Gagal memuat quiz"})
+	}
+	if role != customMiddleware.RoleAdmin && instructorID.String != userID {
+		return nil, c.JSON(http.StatusForbidden, map[string]string{"error": "Akses ditolak"})
+	}
+
+	return target, nil
+}
+
+// quizSiblingLessonIDs returns the content lessons sharing the quiz lesson's
+// module, which is what a quiz usually covers
+func quizSiblingLessonIDs(target *quizTarget) ([]string, error) {
+	var ids []string
+	err := db.DB.Select(&ids, `
+		SELECT l.id FROM lessons l
+		JOIN lessons q ON q.id = $1
+		WHERE l.course_id = q.course_id
+		AND l.parent_id IS NOT DISTINCT FROM q.parent_id
+		AND l.id <> q.id
+		AND l.content_type <> 'quiz'
+		AND COALESCE(l.is_container, false) = false
+		ORDER BY l.order_index
+	`, target.lessonID)
+	return ids, err
+}
+
+// GenerateQuizDraft generates draft questions from the indexed content of one
+// or more lessons. The draft is returned for review, not saved.
+// POST /api/instructor/quizzes/:quizId/ai-draft
+func GenerateQuizDraft(c echo.Context) error {
+	if !getSettingBool("ai_enabled", false) {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Fitur AI belum diaktifkan"})
+	}
+
+	target, err := resolveQuizTarget(c)
+	if target == nil {
+		return err
+	}
+
+	var req QuizDraftRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Data tidak valid"})
+	}
+
+	if req.Count <= 0 {
+		req.Count = quizDraftDefaultQuestions
+	}
+	if req.Count > quizDraftMaxQuestions {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("Maksimal %d soal per permintaan", quizDraftMaxQuestions),
+		})
+	}
+
+	allowedTypes := map[domain.QuestionType]bool{}
+	for _, t := range req.QuestionTypes {
+		if !isQuestionType(t) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Tipe soal tidak dikenal: " + string(t)})
+		}
+		allowedTypes[t] = true
+	}
+	if len(allowedTypes) == 0 {
+		for _, t := range quizQuestionTypes {
+			allowedTypes[t] = true
+		}
+	}
+
+	lessonIDs := uniqueStrings(req.LessonIDs)
+	if len(lessonIDs) == 0 {
+		lessonIDs, err = quizSiblingLessonIDs(target)
+		if err != nil || len(lessonIDs) == 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Pilih materi yang akan dijadikan sumber soal"})
+		}
+	}
+
+	// Lessons must belong to the quiz's course
+	var lessonCount int
+	err = db.DB.QueryRow(`SELECT COUNT(*) FROM lessons WHERE course_id = $1 AND id = ANY($2)`,
+		target.courseID, pq.Array(lessonIDs)).Scan(&lessonCount)
+	if err != nil || lessonCount != len(lessonIDs) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Materi tidak ditemukan pada kursus ini"})
+	}
+
+	ctx := c.Request().Context()
+	repo := getAIRepo()
+
+	chunks, err := repo.GetLessonChunks(ctx, target.courseID, lessonIDs, quizDraftMaxChunks)
+	if err != nil {
+		log.Printf("[AI Quiz] Failed to load chunks for quiz %s: %v", target.quizID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Gagal memuat materi"})
+	}
+	if len(chunks) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Materi belum diproses AI. Jalankan pemrosesan konten AI terlebih dahulu.",
+		})
+	}
+
+	var content strings.Builder
+	for _, chunk := range chunks {
+		if content.Len()+len(chunk.Text) > quizDraftMaxContextChars {
+			break
+		}
+		label := chunk.LessonTitle
+		if locator := chunk.Locator(); locator != "" {
+			label += " — " + locator
+		}
+		fmt.Fprintf(&content, "[Materi: %s]\n%s\n\n", label, chunk.Text)
+	}
+
+	if exceeded, _ := checkAIBudget(ctx, target.userID, target.courseID); exceeded != nil {
+		return aiBudgetExceededResponse(c, exceeded)
+	}
+
+	provider, providerName, err := newChatProvider(quizDraftMaxTokens, quizDraftTemperature)
+	if err != nil {
+		if errors.Is(err, errAIProviderNotConfigured) {
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Unknown provider"})
+	}
+
+	response, err := provider.Chat(ctx, providers.ChatRequest{
+		Messages: []providers.Message{
+			{Role: "system", Content: quizDraftSystemPrompt},
+			{Role: "user", Content: buildQuizDraftPrompt(content.String(), req.Count, allowedTypes, req.Instructions)},
+		},
+		MaxTokens:   quizDraftMaxTokens,
+		Temperature: quizDraftTemperature,
+	})
+	if err != nil {
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": "Gagal mendapatkan respons AI: " + err.Error()})
+	}
+
+	answeredBy := providerName
+	if response.Provider != "" {
+		answeredBy = response.Provider
+	}
+	repo.LogUsage(ctx, target.userID, target.courseID, "quiz_generation", answeredBy, response.Model, response.TokensInput, response.TokensOutput)
+
+	generated, err := parseQuizDraft(response.Content)
+	if err != nil {
+		log.Printf("[AI Quiz] Unparseable draft for quiz %s: %v", target.quizID, err)
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": "Respons AI tidak dalam format yang valid. Silakan coba lagi."})
+	}
+
+	draft := QuizDraft{
+		QuizID:    target.quizID,
+		Questions: []domain.CreateQuestionRequest{},
+		Provider:  answeredBy,
+		Model:     response.Model,
+	}
+	for i := range generated {
+		q := generated[i]
+		reason := ""
+		if !allowedTypes[q.Type] {
+			reason = "tipe soal tidak diminta"
+		} else if err := validateQuizQuestion(&q); err != nil {
+			reason = err.Error()
+		} else if len(draft.Questions) >= req.Count {
+			reason = "melebihi jumlah soal yang diminta"
+		}
+		if reason != "" {
+			draft.Rejected = append(draft.Rejected, QuizDraftRejection{Index: i, QuestionText: q.QuestionText, Reason: reason})
+			continue
+		}
+		q.QuizID = target.quizID
+		draft.Questions = append(draft.Questions, q)
+	}
+
+	if len(draft.Questions) == 0 {
+		return c.JSON(http.StatusBadGateway, map[string]interface{}{
+			"error":    "AI tidak menghasilkan soal yang valid. Silakan coba lagi.",
+			"rejected": draft.Rejected,
+		})
+	}
+
+	return c.JSON(http.StatusOK, draft)
+}
+
+// AcceptQuizDraft saves reviewed draft questions into the quiz in one transaction
+// POST /api/instructor/quizzes/:quizId/ai-draft/accept
+func AcceptQuizDraft(c echo.Context) error {
+	initQuizRepos()
+
+	target, err := resolveQuizTarget(c)
+	if target == nil {
+		return err
+	}
+
+	var req AcceptQuizDraftRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Data tidak valid"})
+	}
+	if len(req.Questions) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Tidak ada soal untuk disimpan"})
+	}
+
+	// Drafts may have been edited during review, so validate again
+	questions := make([]domain.Question, 0, len(req.Questions))
+	for i := range req.Questions {
+		q := req.Questions[i]
+		if err := validateQuizQuestion(&q); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("Soal #%d tidak valid: %s", i+1, err.Error()),
+			})
+		}
+
+		question := domain.Question{
+			Type:         q.Type,
+			QuestionText: q.QuestionText,
+			Explanation:  q.Explanation,
+			Points:       q.Points,
+			Required:     q.Required,
+			GradingMode:  q.GradingMode,
+			Rubric:       q.Rubric,
+		}
+		for _, opt := range q.Options {
+			question.Options = append(question.Options, domain.Option{
+				OptionText: opt.OptionText,
+				IsCorrect:  opt.IsCorrect,
+			})
+		}
+		questions = append(questions, question)
+	}
+
+	if err := quizRepo.CreateQuestions(target.quizID, questions); err != nil {
+		log.Printf("[AI Quiz] Failed to save draft for quiz %s: %v", target.quizID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Gagal menyimpan soal"})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"questions": questions,
+		"message":   fmt.Sprintf("%d soal berhasil ditambahkan", len(questions)),
+	})
+}
+
+// quizQuestionTypes lists the question types in prompt order
+var quizQuestionTypes = []domain.QuestionType{
+	domain.QuestionMultipleChoice,
+	domain.QuestionMultipleAnswer,
+	domain.QuestionTrueFalse,
+	domain.QuestionShortAnswer,
+}
+
+func isQuestionType(t domain.QuestionType) bool {
+	for _, known := range quizQuestionTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+const quizDraftSystemPrompt = `Anda adalah asisten pembuat soal untuk instruktur kursus online.
+Buat soal HANYA berdasarkan materi yang diberikan. Jangan menambahkan fakta dari luar materi.
+Gunakan bahasa yang sama dengan materi.
+
+Balas HANYA dengan JSON berikut, tanpa teks lain:
+{"questions": [{
+  "question_type": "multiple_choice" | "multiple_answer" | "true_false" | "short_answer",
+  "question_text": "teks soal",
+  "explanation": "penjelasan jawaban benar, merujuk ke materi",
+  "points": 1,
+  "options": [{"option_text": "teks pilihan", "is_correct": true}]
+}]}
+
+Aturan per tipe:
+- multiple_choice: 4 pilihan, tepat satu is_correct true.
+- multiple_answer: 4-6 pilihan, minimal dua is_correct true dan minimal satu false.
+- true_false: tepat dua pilihan "Benar" dan "Salah", tepat satu is_correct true.
+- short_answer: satu atau lebih jawaban singkat yang diterima (1-5 kata), semuanya is_correct true.`
+
+// buildQuizDraftPrompt builds the user prompt for quiz generation
+func buildQuizDraftPrompt(content string, count int, types map[domain.QuestionType]bool, instructions string) string {
+	var names []string
+	for _, t := range quizQuestionTypes {
+		if types[t] {
+			names = append(names, string(t))
+		}
+	}
+
+	prompt := fmt.Sprintf("Materi:\n%s\nBuat %d soal dengan tipe: %s.\nVariasikan tipe soal dan cakup bagian materi yang berbeda.",
+		content, count, strings.Join(names, ", "))
+	if instructions = strings.TrimSpace(instructions); instructions != "" {
+		prompt += "\nInstruksi tambahan dari instruktur: " + instructions
+	}
+	return prompt
+}
+
+// parseQuizDraft extracts generated questions from a model reply. Both
+// {"questions": [...]} and a bare array are accepted, with or without a
+// markdown code fence around them.
+func parseQuizDraft(content string) ([]domain.CreateQuestionRequest, error) {
+	start := strings.IndexAny(content, "{[")
+	end := strings.LastIndexAny(content, "}]")
+	if start < 0 || end < start {
+		return nil, errors.New("no JSON found in reply")
+	}
+	raw := content[start : end+1]
+
+	if raw[0] == '[' {
+		var questions []domain.CreateQuestionRequest
+		if err := json.Unmarshal([]byte(raw), &questions); err != nil {
+			return nil, err
+		}
+		return questions, nil
+	}
+
+	var wrapped struct {
+		Questions []domain.CreateQuestionRequest `json:"questions"`
+	}
+	if err := json.Unmarshal([]byte(raw), &wrapped); err != nil {
+		return nil, err
+	}
+	if wrapped.Questions == nil {
+		return nil, errors.New(`reply has no "questions" field`)
+	}
+	return wrapped.Questions, nil
+}
+
+// validateQuizQuestion checks a question is structurally sound for its type and
+// normalizes it in place (trimmed text, default points, canonical true/false options)
+func validateQuizQuestion(q *domain.CreateQuestionRequest) error {
+	q.QuestionText = strings.TrimSpace(q.QuestionText)
+	q.Explanation = strings.TrimSpace(q.Explanation)
+
+	if !isQuestionType(q.Type) {
+		return fmt.Errorf("tipe soal tidak dikenal: %q", q.Type)
+	}
+	if q.QuestionText == "" {
+		return errors.New("teks soal kosong")
+	}
+	if len(q.QuestionText) > 2000 {
+		return errors.New("teks soal terlalu panjang")
+	}
+	if q.Points <= 0 {
+		q.Points = 1
+	}
+	if q.Points > 100 {
+		return errors.New("poin maksimal 100")
+	}
+
+	correct := 0
+	seen := map[string]bool{}
+	for i := range q.Options {
+		q.Options[i].OptionText = strings.TrimSpace(q.Options[i].OptionText)
+		text := strings.ToLower(q.Options[i].OptionText)
+		if text == "" {
+			return fmt.Errorf("pilihan #%d kosong", i+1)
+		}
+		if seen[text] {
+			return fmt.Errorf("pilihan %q duplikat", q.Options[i].OptionText)
+		}
+		seen[text] = true
+		if q.Options[i].IsCorrect {
+			correct++
+		}
+	}
+
+	switch q.Type {
+	case domain.QuestionMultipleChoice:
+		if len(q.Options) < 2 || len(q.Options) > 6 {
+			return errors.New("pilihan ganda harus memiliki 2-6 pilihan")
+		}
+		if correct != 1 {
+			return errors.New("pilihan ganda harus memiliki tepat satu jawaban benar")
+		}
+
+	case domain.QuestionMultipleAnswer:
+		if len(q.Options) < 3 || len(q.Options) > 8 {
+			return errors.New("pilihan jamak harus memiliki 3-8 pilihan")
+		}
+		if correct < 2 || correct == len(q.Options) {
+			return errors.New("pilihan jamak harus memiliki minimal dua jawaban benar dan satu jawaban salah")
+		}
+
+	case domain.QuestionTrueFalse:
+		if len(q.Options) != 2 || correct != 1 {
+			return errors.New("soal benar/salah harus memiliki dua pilihan dengan tepat satu jawaban benar")
+		}
+		// Canonical option texts, as the quiz editor produces
+		trueIsCorrect := false
+		for _, opt := range q.Options {
+			switch strings.ToLower(opt.OptionText) {
+			case "benar", "true":
+				trueIsCorrect = opt.IsCorrect
+			case "salah", "false":
+				trueIsCorrect = !opt.IsCorrect
+			default:
+				return errors.New(`pilihan soal benar/salah harus "Benar" dan "Salah"`)
+			}
+		}
+		q.Options = []domain.CreateOptionRequest{
+			{OptionText: "Benar", IsCorrect: trueIsCorrect},
+			{OptionText: "Salah", IsCorrect: !trueIsCorrect},
+		}
+
+	case domain.QuestionShortAnswer:
+		if len(q.Options) == 0 {
+			return errors.New("jawaban singkat harus memiliki minimal satu jawaban yang diterima")
+		}
+		for i := range q.Options {
+			if len(q.Options[i].OptionText) > 200 {
+				return errors.New("jawaban singkat terlalu panjang")
+			}
+			// Every option is an accepted answer
+			q.Options[i].IsCorrect = true
+		}
+		// Generated accepted answers are rarely exhaustive, so tolerate variations
+		if q.GradingMode == "" {
+			q.GradingMode = domain.GradingNormalized
+		}
+		if !isGradingMode(q.GradingMode) {
+			return errors.New("mode penilaian tidak valid")
+		}
+	}
+
+	return nil
+}
+
+// uniqueStrings returns the non-empty values of s without duplicates, in order
+func uniqueStrings(s []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, v := range s {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
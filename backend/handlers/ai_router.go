@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/providers"
+)
+
+// AIFallbackTarget is one provider+model entry of the fallback chain
+type AIFallbackTarget struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// getAIFallbackChain returns the fallback chain tried after the primary provider
+func getAIFallbackChain() []AIFallbackTarget {
+	raw := getSettingValue("ai_fallback_chain", "")
+	if raw == "" {
+		return []AIFallbackTarget{}
+	}
+	var chain []AIFallbackTarget
+	if err := json.Unmarshal([]byte(raw), &chain); err != nil {
+		log.Printf("[AI Router] Invalid ai_fallback_chain setting: %v", err)
+		return []AIFallbackTarget{}
+	}
+	return chain
+}
+
+// validateAIFallbackChain checks that every entry names a known provider
+func validateAIFallbackChain(chain []AIFallbackTarget) string {
+	for _, target := range chain {
+		if _, err := providers.NewProvider(target.Provider, providers.ProviderConfig{}); err != nil {
+			return "Unknown provider in fallback chain: " + target.Provider
+		}
+	}
+	return ""
+}
+
+// getAIRouterConfig reads breaker and timeout settings
+func getAIRouterConfig() providers.RouterConfig {
+	config := providers.DefaultRouterConfig()
+	config.FailureThreshold = getSettingInt("ai_breaker_failure_threshold", config.FailureThreshold)
+	config.Cooldown = time.Duration(getSettingInt("ai_breaker_cooldown_seconds", int(config.Cooldown/time.Second))) * time.Second
+	config.AttemptTimeout = time.Duration(getSettingInt("ai_provider_timeout_seconds", int(config.AttemptTimeout/time.Second))) * time.Second
+	return config
+}
+
+// buildChatProvider returns a router over the primary provider followed by the
+// fallback chain. Fallback entries without an API key are skipped.
+func buildChatProvider(primary providers.Provider, base providers.ProviderConfig) providers.Provider {
+	chain := []providers.Provider{primary}
+	seen := map[string]bool{providers.BreakerKey(primary): true}
+
+	for _, target := range getAIFallbackChain() {
+		apiKey, ok := aiProviderKey(target.Provider)
+		if !ok {
+			continue
+		}
+		config := withProviderEndpoint(target.Provider, base)
+		config.APIKey = apiKey
+		config.Model = target.Model
+		p, err := providers.NewProvider(target.Provider, config)
+		if err != nil {
+			continue
+		}
+		if key := providers.BreakerKey(p); !seen[key] {
+			seen[key] = true
+			chain = append(chain, p)
+		}
+	}
+
+	return providers.NewRouter(chain, getAIRouterConfig())
+}
+
+// errAIProviderNotConfigured means the primary provider has no API key
+var errAIProviderNotConfigured = errors.New("AI provider belum dikonfigurasi")
+
+// newChatProvider builds the configured primary provider behind the fallback
+// router, for AI features outside the tutor chat. maxTokens and temperature
+// override the tutor settings when non-zero. Returns the primary provider name.
+func newChatProvider(maxTokens int, temperature float64) (providers.Provider, string, error) {
+	providerName := getSettingValue("ai_provider", "openai")
+	apiKey, ok := aiProviderKey(providerName)
+	if !ok {
+		return nil, providerName, errAIProviderNotConfigured
+	}
+
+	config := withProviderEndpoint(providerName, providers.ProviderConfig{
+		APIKey:      apiKey,
+		Model:       getSettingValue("ai_model", "gpt-4-turbo"),
+		MaxTokens:   getSettingInt("ai_max_tokens", 2048),
+		Temperature: getSettingFloat("ai_temperature", 0.7),
+	})
+	if maxTokens > 0 {
+		config.MaxTokens = maxTokens
+	}
+	if temperature > 0 {
+		config.Temperature = temperature
+	}
+
+	primary, err := providers.NewProvider(providerName, config)
+	if err != nil {
+		return nil, providerName, err
+	}
+	return buildChatProvider(primary, config), providerName, nil
+}
+
+// GetAIProviderHealth returns circuit breaker state per provider/model (admin only)
+// GET /api/admin/ai/providers/health
+func GetAIProviderHealth(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"breakers": providers.BreakerStatuses(),
+	})
+}
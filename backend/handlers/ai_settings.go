@@ -10,7 +10,10 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
@@ -26,18 +29,38 @@ type AISettings struct {
 	APIKeyClaude        string  `json:"api_key_claude,omitempty"`
 	APIKeyGroq          string  `json:"api_key_groq,omitempty"`
 	APIKeyGemini        string  `json:"api_key_gemini,omitempty"`
+	APIKeySelfHosted    string  `json:"api_key_selfhosted,omitempty"`
 	EmbeddingProvider   string  `json:"embedding_provider"`
 	EmbeddingModel      string  `json:"embedding_model"`
 	MaxTokens           int     `json:"max_tokens"`
 	Temperature         float64 `json:"temperature"`
 	RateLimitPerDay     int     `json:"rate_limit_per_day"`
+	PromptTokenLimit    int     `json:"prompt_token_limit"`
+	HistoryTokenBudget  int     `json:"history_token_budget"`
+
+	// Guardrails
+	GuardInjection    bool `json:"guard_injection"`
+	GuardPIIRedaction bool `json:"guard_pii_redaction"`
+	GuardOffTopic     bool `json:"guard_off_topic"`
 	SystemPrompt        string  `json:"system_prompt"`
+
+	// Failover: providers tried in order after the primary one
+	FallbackChain           []AIFallbackTarget `json:"fallback_chain"`
+	BreakerFailureThreshold int                `json:"breaker_failure_threshold"`
+	BreakerCooldownSeconds  int                `json:"breaker_cooldown_seconds"`
+	ProviderTimeoutSeconds  int                `json:"provider_timeout_seconds"`
+
+	// Self-hosted OpenAI-compatible server (vLLM, llama.cpp, Ollama)
+	SelfHostedBaseURL             string   `json:"selfhosted_base_url"`
+	SelfHostedModels              []string `json:"selfhosted_models"`
+	SelfHostedEmbeddingDimensions int      `json:"selfhosted_embedding_dimensions"`
 	
 	// Read-only status fields
 	OpenAIConfigured    bool    `json:"openai_configured"`
 	ClaudeConfigured    bool    `json:"claude_configured"`
 	GroqConfigured      bool    `json:"groq_configured"`
 	GeminiConfigured    bool    `json:"gemini_configured"`
+	SelfHostedConfigured bool    `json:"selfhosted_configured"`
 }
 
 // AIUpdateSettingsRequest for updating AI settings
@@ -49,12 +72,28 @@ type AIUpdateSettingsRequest struct {
 	APIKeyClaude        *string  `json:"api_key_claude,omitempty"`
 	APIKeyGroq          *string  `json:"api_key_groq,omitempty"`
 	APIKeyGemini        *string  `json:"api_key_gemini,omitempty"`
+	APIKeySelfHosted    *string  `json:"api_key_selfhosted,omitempty"`
 	EmbeddingProvider   *string  `json:"embedding_provider,omitempty"`
 	EmbeddingModel      *string  `json:"embedding_model,omitempty"`
 	MaxTokens           *int     `json:"max_tokens,omitempty"`
 	Temperature         *float64 `json:"temperature,omitempty"`
 	RateLimitPerDay     *int     `json:"rate_limit_per_day,omitempty"`
+	PromptTokenLimit    *int     `json:"prompt_token_limit,omitempty"`
+	HistoryTokenBudget  *int     `json:"history_token_budget,omitempty"`
+
+	GuardInjection    *bool `json:"guard_injection,omitempty"`
+	GuardPIIRedaction *bool `json:"guard_pii_redaction,omitempty"`
+	GuardOffTopic     *bool `json:"guard_off_topic,omitempty"`
 	SystemPrompt        *string  `json:"system_prompt,omitempty"`
+
+	FallbackChain           *[]AIFallbackTarget `json:"fallback_chain,omitempty"`
+	BreakerFailureThreshold *int                `json:"breaker_failure_threshold,omitempty"`
+	BreakerCooldownSeconds  *int                `json:"breaker_cooldown_seconds,omitempty"`
+	ProviderTimeoutSeconds  *int                `json:"provider_timeout_seconds,omitempty"`
+
+	SelfHostedBaseURL             *string   `json:"selfhosted_base_url,omitempty"`
+	SelfHostedModels              *[]string `json:"selfhosted_models,omitempty"`
+	SelfHostedEmbeddingDimensions *int      `json:"selfhosted_embedding_dimensions,omitempty"`
 }
 
 // Simple encryption key - MUST be exactly 32 bytes for AES-256
@@ -71,14 +110,29 @@ func GetAISettings(c echo.Context) error {
 		MaxTokens:         getSettingInt("ai_max_tokens", 2048),
 		Temperature:       getSettingFloat("ai_temperature", 0.7),
 		RateLimitPerDay:   getSettingInt("ai_rate_limit_per_day", 50),
+		PromptTokenLimit:  getSettingInt("ai_prompt_token_limit", 6000),
+		HistoryTokenBudget: getSettingInt("ai_history_token_budget", 2000),
+		GuardInjection:    getSettingBool("ai_guard_injection", true),
+		GuardPIIRedaction: getSettingBool("ai_guard_pii_redaction", true),
+		GuardOffTopic:     getSettingBool("ai_guard_off_topic", false),
 		SystemPrompt:      getSettingValue("ai_system_prompt", defaultAISystemPrompt),
+		FallbackChain:     getAIFallbackChain(),
+		SelfHostedBaseURL: getSettingValue("ai_selfhosted_base_url", ""),
+		SelfHostedModels:  getSelfHostedModels(),
+		SelfHostedEmbeddingDimensions: getSettingInt("ai_selfhosted_embedding_dimensions", 0),
 	}
 
+	routerConfig := getAIRouterConfig()
+	settings.BreakerFailureThreshold = routerConfig.FailureThreshold
+	settings.BreakerCooldownSeconds = int(routerConfig.Cooldown.Seconds())
+	settings.ProviderTimeoutSeconds = int(routerConfig.AttemptTimeout.Seconds())
+
 	// Check which providers are configured (have API keys)
 	settings.OpenAIConfigured = getSettingValue("ai_api_key_openai", "") != ""
 	settings.ClaudeConfigured = getSettingValue("ai_api_key_claude", "") != ""
 	settings.GroqConfigured = getSettingValue("ai_api_key_groq", "") != ""
 	settings.GeminiConfigured = getSettingValue("ai_api_key_gemini", "") != ""
+	settings.SelfHostedConfigured = settings.SelfHostedBaseURL != ""
 
 	// Don't return actual API keys, just masked versions
 	if settings.OpenAIConfigured {
@@ -93,6 +147,9 @@ func GetAISettings(c echo.Context) error {
 	if settings.GeminiConfigured {
 		settings.APIKeyGemini = "****" + maskKey(getSettingValue("ai_api_key_gemini", ""))
 	}
+	if getSettingValue("ai_api_key_selfhosted", "") != "" {
+		settings.APIKeySelfHosted = "****" + maskKey(getSettingValue("ai_api_key_selfhosted", ""))
+	}
 
 	return c.JSON(http.StatusOK, settings)
 }
@@ -112,6 +169,18 @@ func UpdateAISettings(c echo.Context) error {
 		log.Printf("[AI Settings] Received OpenAI API key: length=%d", len(*req.APIKeyOpenAI))
 	}
 
+	if req.FallbackChain != nil {
+		if msg := validateAIFallbackChain(*req.FallbackChain); msg != "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+		}
+	}
+
+	if req.SelfHostedBaseURL != nil && *req.SelfHostedBaseURL != "" {
+		if u, err := url.Parse(*req.SelfHostedBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Self-hosted base URL must be an http(s) URL"})
+		}
+	}
+
 	// Update each setting if provided
 	if req.Enabled != nil {
 		setSettingValue("ai_enabled", boolToString(*req.Enabled))
@@ -137,9 +206,46 @@ func UpdateAISettings(c echo.Context) error {
 	if req.RateLimitPerDay != nil {
 		setSettingValue("ai_rate_limit_per_day", strconv.Itoa(*req.RateLimitPerDay))
 	}
+	if req.PromptTokenLimit != nil && *req.PromptTokenLimit >= 0 {
+		setSettingValue("ai_prompt_token_limit", strconv.Itoa(*req.PromptTokenLimit))
+	}
+	if req.HistoryTokenBudget != nil && *req.HistoryTokenBudget >= 0 {
+		setSettingValue("ai_history_token_budget", strconv.Itoa(*req.HistoryTokenBudget))
+	}
+	if req.GuardInjection != nil {
+		setSettingValue("ai_guard_injection", boolToString(*req.GuardInjection))
+	}
+	if req.GuardPIIRedaction != nil {
+		setSettingValue("ai_guard_pii_redaction", boolToString(*req.GuardPIIRedaction))
+	}
+	if req.GuardOffTopic != nil {
+		setSettingValue("ai_guard_off_topic", boolToString(*req.GuardOffTopic))
+	}
 	if req.SystemPrompt != nil {
 		setSettingValue("ai_system_prompt", *req.SystemPrompt)
 	}
+	if req.FallbackChain != nil {
+		chain, _ := json.Marshal(*req.FallbackChain)
+		setSettingValue("ai_fallback_chain", string(chain))
+	}
+	if req.BreakerFailureThreshold != nil && *req.BreakerFailureThreshold > 0 {
+		setSettingValue("ai_breaker_failure_threshold", strconv.Itoa(*req.BreakerFailureThreshold))
+	}
+	if req.BreakerCooldownSeconds != nil && *req.BreakerCooldownSeconds > 0 {
+		setSettingValue("ai_breaker_cooldown_seconds", strconv.Itoa(*req.BreakerCooldownSeconds))
+	}
+	if req.ProviderTimeoutSeconds != nil && *req.ProviderTimeoutSeconds >= 0 {
+		setSettingValue("ai_provider_timeout_seconds", strconv.Itoa(*req.ProviderTimeoutSeconds))
+	}
+	if req.SelfHostedBaseURL != nil {
+		setSettingValue("ai_selfhosted_base_url", strings.TrimRight(strings.TrimSpace(*req.SelfHostedBaseURL), "/"))
+	}
+	if req.SelfHostedModels != nil {
+		setSettingValue("ai_selfhosted_models", strings.Join(*req.SelfHostedModels, ","))
+	}
+	if req.SelfHostedEmbeddingDimensions != nil && *req.SelfHostedEmbeddingDimensions >= 0 {
+		setSettingValue("ai_selfhosted_embedding_dimensions", strconv.Itoa(*req.SelfHostedEmbeddingDimensions))
+	}
 
 	// Update API keys (encrypt before storing)
 	if req.APIKeyOpenAI != nil && *req.APIKeyOpenAI != "" && !isPlaceholder(*req.APIKeyOpenAI) {
@@ -177,6 +283,12 @@ func UpdateAISettings(c echo.Context) error {
 			setSettingValue("ai_api_key_gemini", encrypted)
 		}
 	}
+	if req.APIKeySelfHosted != nil && *req.APIKeySelfHosted != "" && !isPlaceholder(*req.APIKeySelfHosted) {
+		encrypted, err := encrypt(*req.APIKeySelfHosted)
+		if err == nil {
+			setSettingValue("ai_api_key_selfhosted", encrypted)
+		}
+	}
 
 	return GetAISettings(c)
 }
@@ -186,31 +298,27 @@ func ValidateAIKey(c echo.Context) error {
 	var req struct {
 		Provider string `json:"provider"`
 		APIKey   string `json:"api_key"`
+		BaseURL  string `json:"base_url"` // Self-hosted only; defaults to the saved base URL
 	}
 
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
 	}
 
-	if req.Provider == "" || req.APIKey == "" {
+	// Self-hosted servers may run without a key
+	if req.Provider == "" || (req.APIKey == "" && req.Provider != "selfhosted") {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Provider and API key required"})
 	}
 
-	config := providers.ProviderConfig{
+	config := withProviderEndpoint(req.Provider, providers.ProviderConfig{
 		APIKey: req.APIKey,
+	})
+	if req.BaseURL != "" {
+		config.BaseURL = strings.TrimRight(req.BaseURL, "/")
 	}
 
-	var provider providers.Provider
-	switch req.Provider {
-	case "openai":
-		provider = providers.NewOpenAIProvider(config)
-	case "claude":
-		provider = providers.NewClaudeProvider(config)
-	case "groq":
-		provider = providers.NewGroqProvider(config)
-	case "gemini":
-		provider = providers.NewGeminiProvider(config)
-	default:
+	provider, err := providers.NewProvider(req.Provider, config)
+	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Unknown provider"})
 	}
 
@@ -230,6 +338,10 @@ func ValidateAIKey(c echo.Context) error {
 // GetAIProviders returns available AI providers and their models
 func GetAIProviders(c echo.Context) error {
 	factory := providers.NewProviderFactory()
+	for _, name := range providers.AvailableProviders() {
+		apiKey, _ := GetDecryptedAPIKey(name)
+		factory.SetConfig(name, withProviderEndpoint(name, providers.ProviderConfig{APIKey: apiKey}))
+	}
 	infos := factory.GetProviderInfo()
 	return c.JSON(http.StatusOK, infos)
 }
@@ -248,6 +360,8 @@ func ClearAPIKey(c echo.Context) error {
 		key = "ai_api_key_groq"
 	case "gemini":
 		key = "ai_api_key_gemini"
+	case "selfhosted":
+		key = "ai_api_key_selfhosted"
 	default:
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Unknown provider"})
 	}
@@ -384,6 +498,8 @@ func GetDecryptedAPIKey(provider string) (string, error) {
 		key = getSettingValue("ai_api_key_groq", "")
 	case "gemini":
 		key = getSettingValue("ai_api_key_gemini", "")
+	case "selfhosted":
+		key = getSettingValue("ai_api_key_selfhosted", "")
 	default:
 		return "", nil
 	}
@@ -395,6 +511,40 @@ func GetDecryptedAPIKey(provider string) (string, error) {
 	return decrypt(key)
 }
 
+// aiProviderKey returns the decrypted API key for a provider and whether the
+// provider can be used. Self-hosted servers often run without a key, so they
+// only need a base URL.
+func aiProviderKey(provider string) (string, bool) {
+	apiKey, err := GetDecryptedAPIKey(provider)
+	if err != nil {
+		return "", false
+	}
+	if provider == "selfhosted" {
+		return apiKey, getSettingValue("ai_selfhosted_base_url", "") != ""
+	}
+	return apiKey, apiKey != ""
+}
+
+// withProviderEndpoint fills in the base URL and model list for providers that need them
+func withProviderEndpoint(provider string, config providers.ProviderConfig) providers.ProviderConfig {
+	if provider == "selfhosted" {
+		config.BaseURL = getSettingValue("ai_selfhosted_base_url", "")
+		config.Models = getSelfHostedModels()
+	}
+	return config
+}
+
+// getSelfHostedModels returns the chat models served by the self-hosted server
+func getSelfHostedModels() []string {
+	models := []string{}
+	for _, m := range strings.Split(getSettingValue("ai_selfhosted_models", ""), ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			models = append(models, m)
+		}
+	}
+	return models
+}
+
 const defaultAISystemPrompt = `Kamu adalah AI Tutor yang membantu siswa memahami materi kursus. 
 Tugasmu adalah:
 1. Menjawab pertanyaan siswa berdasarkan materi yang diberikan
@@ -419,8 +569,8 @@ func FetchProviderModels(c echo.Context) error {
 		provider = "gemini"
 	}
 
-	apiKey, err := GetDecryptedAPIKey(provider)
-	if err != nil || apiKey == "" {
+	apiKey, ok := aiProviderKey(provider)
+	if !ok {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"error":  "API key tidak ditemukan untuk provider " + provider,
 			"models": []string{},
@@ -494,6 +644,57 @@ func FetchProviderModels(c echo.Context) error {
 				"supportsEmbedContent":   supportsEmbedContent,
 			})
 		}
+	case "selfhosted":
+		// Any OpenAI-compatible server lists its models at GET /models
+		req, err := http.NewRequest("GET", getSettingValue("ai_selfhosted_base_url", "")+"/models", nil)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error":  "Base URL tidak valid: " + err.Error(),
+				"models": []string{},
+			})
+		}
+		if apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+		}
+		resp, err := (&http.Client{Timeout: 15 * time.Second}).Do(req)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+				"error":  "Gagal menghubungi server self-hosted: " + err.Error(),
+				"models": []string{},
+			})
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		rawResponse = string(body)
+
+		if resp.StatusCode != 200 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error":        "Self-hosted API error",
+				"raw_response": rawResponse,
+				"models":       []string{},
+			})
+		}
+
+		var result struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+				"error":        "Gagal parse response: " + err.Error(),
+				"raw_response": rawResponse,
+				"models":       []string{},
+			})
+		}
+
+		for _, m := range result.Data {
+			models = append(models, map[string]interface{}{
+				"name":        m.ID,
+				"displayName": m.ID,
+			})
+		}
 	default:
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"error":  "Provider tidak didukung untuk fetch models: " + provider,
@@ -7,7 +7,6 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
-	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/embeddings"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/providers"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/rag"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
@@ -26,6 +25,8 @@ func getAIRepo() *postgres.AIRepository {
 // ChatRequest represents a chat message request
 type ChatRequest struct {
 	Message string `json:"message"`
+	// SessionID picks the conversation; the most recently used one when empty
+	SessionID string `json:"session_id"`
 }
 
 // ChatResponse represents a chat response
@@ -42,13 +43,42 @@ type QuotaInfo struct {
 	Used      int `json:"used"`
 	Limit     int `json:"limit"`
 	Remaining int `json:"remaining"`
+	// BudgetWarning is set when a monthly AI budget is nearly used up
+	BudgetWarning string `json:"budget_warning,omitempty"`
 }
 
-// SendChatMessage handles chat messages from students
-func SendChatMessage(c echo.Context) error {
+// chatTurn is a prepared tutor exchange: the validated request, the session it
+// belongs to and the provider call to make
+type chatTurn struct {
+	userID       string
+	courseID     string
+	session      *postgres.ChatSession
+	provider     providers.Provider
+	providerName string
+	request      providers.ChatRequest
+	sources      []rag.Source
+	todayUsage   int
+	rateLimit    int
+	budgetNotice string
+}
+
+// quota returns the quota after this turn
+func (t *chatTurn) quota() QuotaInfo {
+	return QuotaInfo{
+		Used:          t.todayUsage + 1,
+		Limit:         t.rateLimit,
+		Remaining:     t.rateLimit - t.todayUsage - 1,
+		BudgetWarning: t.budgetNotice,
+	}
+}
+
+// prepareChatTurn validates a chat request, saves the user message and builds the
+// RAG prompt. On failure the error response has already been written and
+// (nil, err) is returned.
+func prepareChatTurn(c echo.Context) (*chatTurn, error) {
 	// Check if AI is enabled
 	if !getSettingBool("ai_enabled", false) {
-		return c.JSON(http.StatusServiceUnavailable, map[string]string{
+		return nil, c.JSON(http.StatusServiceUnavailable, map[string]string{
 			"error": "AI Tutor belum diaktifkan",
 		})
 	}
@@ -57,16 +87,16 @@ func SendChatMessage(c echo.Context) error {
 	userID := getUserIDFromToken(c)
 
 	if userID == "" {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return nil, c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
 	}
 
 	var req ChatRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return nil, c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
 	}
 
 	if req.Message == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Message is required"})
+		return nil, c.JSON(http.StatusBadRequest, map[string]string{"error": "Message is required"})
 	}
 
 	ctx := c.Request().Context()
@@ -77,40 +107,47 @@ func SendChatMessage(c echo.Context) error {
 	todayUsage, _ := repo.GetTodayUsageCount(ctx, userID)
 	
 	if todayUsage >= rateLimit {
-		return c.JSON(http.StatusTooManyRequests, map[string]string{
+		return nil, c.JSON(http.StatusTooManyRequests, map[string]string{
 			"error": "Batas harian tercapai. Silakan coba lagi besok.",
 		})
 	}
 
+	// Check monthly token/cost budgets
+	exceeded, budgetWarnings := checkAIBudget(ctx, userID, courseID)
+	if exceeded != nil {
+		return nil, aiBudgetExceededResponse(c, exceeded)
+	}
+
 	// Get or create session
-	session, err := repo.GetOrCreateActiveSession(ctx, userID, courseID)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get session"})
+	session, err := chatSessionFor(c, req.SessionID, userID, courseID)
+	if session == nil {
+		return nil, err
+	}
+	if !session.IsActive {
+		return nil, c.JSON(http.StatusBadRequest, map[string]string{"error": "Percakapan sudah diarsipkan"})
+	}
+
+	// Block prompt-injection attempts before anything reaches the provider
+	guard := newChatGuard(userID, courseID)
+	guard.sessionID = session.ID
+	if msg := guard.screenMessage(ctx, req.Message); msg != "" {
+		return nil, c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
 	}
 
 	// Get AI provider
 	providerName := getSettingValue("ai_provider", "openai")
-	apiKey, err := GetDecryptedAPIKey(providerName)
-	if err != nil || apiKey == "" {
-		return c.JSON(http.StatusServiceUnavailable, map[string]string{
+	apiKey, ok := aiProviderKey(providerName)
+	if !ok {
+		return nil, c.JSON(http.StatusServiceUnavailable, map[string]string{
 			"error": "AI provider belum dikonfigurasi",
 		})
 	}
 
-	// Get chat history
-	history, _ := repo.GetChatHistory(ctx, session.ID, 20)
-	
-	// Convert to provider messages
-	var chatHistory []providers.Message
-	for _, msg := range history {
-		chatHistory = append(chatHistory, providers.Message{
-			Role:    msg.Role,
-			Content: msg.Content,
-		})
-	}
+	// Running summary plus the recent turns it doesn't cover yet
+	summary, chatHistory := loadChatMemory(ctx, repo, session, guard)
 
-	// Save user message
-	repo.AddChatMessage(ctx, session.ID, "user", req.Message, nil, 0, "", "")
+	// Personal data is redacted from everything sent to the providers
+	question := guard.redactMessage(ctx, req.Message)
 
 	// Build prompt with RAG context
 	var contextStr string
@@ -120,13 +157,31 @@ func SendChatMessage(c echo.Context) error {
 	embeddingCount, _ := repo.GetEmbeddingCount(ctx, courseID)
 	log.Printf("[AI Chat] Course %s has %d embeddings", courseID, embeddingCount)
 	if embeddingCount > 0 {
-		log.Printf("[AI Chat] Retrieving RAG context for query: %s", req.Message)
-		contextStr, sources = getRAGContext(ctx, courseID, req.Message, apiKey)
+		log.Printf("[AI Chat] Retrieving RAG context for query: %s", question)
+		contextStr, sources, err = getRAGContext(ctx, courseID, question, apiKey, guard)
 		log.Printf("[AI Chat] Got context length: %d, sources: %d", len(contextStr), len(sources))
+
+		// Only refuse when retrieval worked and found nothing relevant
+		if err == nil {
+			if msg := guard.refuseOffTopic(ctx, req.Message, contextStr != ""); msg != "" {
+				return nil, c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": msg})
+			}
+		}
 	} else {
 		log.Printf("[AI Chat] No embeddings found, skipping RAG")
 	}
 
+	// Name a new conversation after its first question
+	if session.MessageCount == 0 && session.Title == postgres.DefaultChatTitle {
+		title := chatTitleFromMessage(question)
+		if err := repo.RenameChatSession(ctx, session.ID, title); err == nil {
+			session.Title = title
+		}
+	}
+
+	// Save user message
+	repo.AddChatMessage(ctx, session.ID, "user", req.Message, nil, 0, "", "")
+
 	// Build messages
 	promptBuilder := rag.NewPromptBuilder(rag.DefaultPromptTemplate())
 	
@@ -140,40 +195,57 @@ func SendChatMessage(c echo.Context) error {
 		})
 	}
 
-	var messages []providers.Message
-	if contextStr != "" {
-		messages = promptBuilder.BuildMessages(contextStr, req.Message, chatHistory)
-	} else {
-		messages = promptBuilder.BuildSimpleMessages(req.Message, chatHistory)
-	}
+	messages := promptBuilder.BuildConversation(rag.Conversation{
+		Context:    contextStr,
+		Summary:    summary,
+		History:    chatHistory,
+		Question:   question,
+		TokenLimit: getSettingInt("ai_prompt_token_limit", 6000),
+	})
 
-	// Call AI provider
-	config := providers.ProviderConfig{
+	// Configure AI provider
+	config := withProviderEndpoint(providerName, providers.ProviderConfig{
 		APIKey:      apiKey,
 		Model:       getSettingValue("ai_model", "gpt-4-turbo"),
 		MaxTokens:   getSettingInt("ai_max_tokens", 2048),
 		Temperature: getSettingFloat("ai_temperature", 0.7),
+	})
+
+	primary, err := providers.NewProvider(providerName, config)
+	if err != nil {
+		return nil, c.JSON(http.StatusBadRequest, map[string]string{"error": "Unknown provider"})
 	}
+	provider := buildChatProvider(primary, config)
+
+	return &chatTurn{
+		userID:       userID,
+		courseID:     courseID,
+		session:      session,
+		provider:     provider,
+		providerName: providerName,
+		request: providers.ChatRequest{
+			Messages:    messages,
+			MaxTokens:   config.MaxTokens,
+			Temperature: config.Temperature,
+		},
+		sources:      sources,
+		todayUsage:   todayUsage,
+		rateLimit:    rateLimit,
+		budgetNotice: aiBudgetWarningMessage(budgetWarnings),
+	}, nil
+}
 
-	var provider providers.Provider
-	switch providerName {
-	case "openai":
-		provider = providers.NewOpenAIProvider(config)
-	case "claude":
-		provider = providers.NewClaudeProvider(config)
-	case "groq":
-		provider = providers.NewGroqProvider(config)
-	case "gemini":
-		provider = providers.NewGeminiProvider(config)
-	default:
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Unknown provider"})
+// SendChatMessage handles chat messages from students
+func SendChatMessage(c echo.Context) error {
+	turn, err := prepareChatTurn(c)
+	if turn == nil {
+		return err
 	}
 
-	response, err := provider.Chat(ctx, providers.ChatRequest{
-		Messages:    messages,
-		MaxTokens:   config.MaxTokens,
-		Temperature: config.Temperature,
-	})
+	ctx := c.Request().Context()
+	repo := getAIRepo()
+
+	response, err := turn.provider.Chat(ctx, turn.request)
 
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
@@ -181,27 +253,44 @@ func SendChatMessage(c echo.Context) error {
 		})
 	}
 
+	// The router reports which provider in the chain actually answered
+	answeredBy := turn.providerName
+	if response.Provider != "" {
+		answeredBy = response.Provider
+	}
+
 	// Save assistant message
 	totalTokens := response.TokensInput + response.TokensOutput
-	repo.AddChatMessage(ctx, session.ID, "assistant", response.Content, sources, totalTokens, providerName, response.Model)
+	repo.AddChatMessage(ctx, turn.session.ID, "assistant", response.Content, turn.sources, totalTokens, answeredBy, response.Model)
 
 	// Log usage
-	repo.LogUsage(ctx, userID, courseID, "chat", providerName, response.Model, response.TokensInput, response.TokensOutput)
+	repo.LogUsage(ctx, turn.userID, turn.courseID, "chat", answeredBy, response.Model, response.TokensInput, response.TokensOutput)
 
 	return c.JSON(http.StatusOK, ChatResponse{
 		Message:    response.Content,
-		Sources:    sources,
-		SessionID:  session.ID,
+		Sources:    turn.sources,
+		SessionID:  turn.session.ID,
 		TokensUsed: totalTokens,
-		Quota: QuotaInfo{
-			Used:      todayUsage + 1,
-			Limit:     rateLimit,
-			Remaining: rateLimit - todayUsage - 1,
-		},
+		Quota:      turn.quota(),
 	})
 }
 
-// GetChatSession returns the current chat session
+// chatSessionFor returns the student's session with the given ID, or the most
+// recently used open one when sessionID is empty. On failure the error response
+// has already been written and (nil, err) is returned.
+func chatSessionFor(c echo.Context, sessionID, userID, courseID string) (*postgres.ChatSession, error) {
+	if sessionID != "" {
+		return loadOwnedChatSession(c, sessionID, userID, courseID)
+	}
+	session, err := getAIRepo().GetOrCreateActiveSession(c.Request().Context(), userID, courseID)
+	if err != nil {
+		return nil, c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get session"})
+	}
+	return session, nil
+}
+
+// GetChatSession returns a chat session with its messages: the one given by
+// ?session_id, or the most recently used one
 func GetChatSession(c echo.Context) error {
 	courseID := c.Param("id")
 	userID := getUserIDFromToken(c)
@@ -213,9 +302,9 @@ func GetChatSession(c echo.Context) error {
 	ctx := c.Request().Context()
 	repo := getAIRepo()
 
-	session, err := repo.GetOrCreateActiveSession(ctx, userID, courseID)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get session"})
+	session, err := chatSessionFor(c, c.QueryParam("session_id"), userID, courseID)
+	if session == nil {
+		return err
 	}
 
 	messages, _ := repo.GetChatHistory(ctx, session.ID, 100)
@@ -236,7 +325,8 @@ func GetChatSession(c echo.Context) error {
 	})
 }
 
-// ClearChatSession clears the current chat session
+// ClearChatSession archives a chat session: the one given by ?session_id, or the
+// most recently used one
 func ClearChatSession(c echo.Context) error {
 	courseID := c.Param("id")
 	userID := getUserIDFromToken(c)
@@ -248,9 +338,9 @@ func ClearChatSession(c echo.Context) error {
 	ctx := c.Request().Context()
 	repo := getAIRepo()
 
-	session, err := repo.GetOrCreateActiveSession(ctx, userID, courseID)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get session"})
+	session, err := chatSessionFor(c, c.QueryParam("session_id"), userID, courseID)
+	if session == nil {
+		return err
 	}
 
 	if err := repo.ClearChatSession(ctx, session.ID); err != nil {
@@ -299,35 +389,13 @@ func GetAIStatus(c echo.Context) error {
 	})
 }
 
-// Helper to get RAG context
-func getRAGContext(ctx context.Context, courseID, query, apiKey string) (string, []rag.Source) {
+// getRAGContext retrieves course material for a query; chunks carrying prompt
+// injection are dropped by the guard. The error is the retrieval failure, if any.
+func getRAGContext(ctx context.Context, courseID, query, apiKey string, guard *chatGuard) (string, []rag.Source, error) {
 	repo := getAIRepo()
-	
-	// Get the AI provider to use correct embedder
-	providerName := getSettingValue("ai_provider", "openai")
-	embeddingModel := getSettingValue("ai_embedding_model", "")
-	
-	// Create embedder config
-	embConfig := embeddings.EmbedderConfig{
-		APIKey: apiKey,
-		Model:  embeddingModel,
-	}
-	
-	// Create correct embedder based on provider
-	var embedder embeddings.Embedder
-	switch providerName {
-	case "gemini":
-		if embConfig.Model == "" {
-			embConfig.Model = "gemini-embedding-001"
 
-		}
-		embedder = embeddings.NewGeminiEmbedder(embConfig)
-	default: // openai
-		if embConfig.Model == "" {
-			embConfig.Model = "text-embedding-ada-002"
-		}
-		embedder = embeddings.NewOpenAIEmbedder(embConfig)
-	}
+	// Embeddings come from the same provider as chat
+	embedder := newMeteredEmbedder(getSettingValue("ai_provider", "openai"), apiKey)
 
 	// Create retriever
 	retrieverConfig := rag.DefaultRetrieverConfig()
@@ -335,11 +403,17 @@ func getRAGContext(ctx context.Context, courseID, query, apiKey string) (string,
 
 	// Retrieve relevant chunks
 	chunks, err := retriever.Retrieve(ctx, courseID, query)
-	if err != nil || len(chunks) == 0 {
-		return "", nil
+	embedder.logUsage(guard.userID, courseID)
+	if err != nil {
+		return "", nil, err
+	}
+	chunks = guard.filterChunks(ctx, chunks)
+	if len(chunks) == 0 {
+		return "", nil, nil
 	}
 
 	// Build context
-	return retriever.BuildContext(chunks)
+	contextStr, sources := retriever.BuildContext(chunks)
+	return contextStr, sources, nil
 }
 
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/providers"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/rag"
+)
+
+// ChatStreamDone is the final event of a streamed chat reply
+type ChatStreamDone struct {
+	MessageID    string       `json:"message_id,omitempty"`
+	Message      string       `json:"message"`
+	Sources      []rag.Source `json:"sources,omitempty"`
+	SessionID    string       `json:"session_id"`
+	TokensInput  int          `json:"tokens_input"`
+	TokensOutput int          `json:"tokens_output"`
+	TokensUsed   int          `json:"tokens_used"`
+	Model        string       `json:"model"`
+	Quota        QuotaInfo    `json:"quota"`
+}
+
+// writeSSE writes one server-sent event and flushes it to the client
+func writeSSE(c echo.Context, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	w := c.Response()
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	w.Flush()
+	return nil
+}
+
+// StreamChatMessage is the streaming variant of SendChatMessage. The reply is sent
+// as server-sent events:
+//
+//	event: token  data: {"content": "..."}           (repeated)
+//	event: done   data: ChatStreamDone               (sources and token usage)
+//	event: error  data: {"error": "..."}
+//
+// If the client disconnects, the upstream provider request is cancelled and the
+// partial reply is not saved.
+// POST /api/courses/:id/chat/stream
+func StreamChatMessage(c echo.Context) error {
+	turn, err := prepareChatTurn(c)
+	if turn == nil {
+		return err
+	}
+
+	// The request context is cancelled when the client goes away, which aborts
+	// the upstream HTTP request inside the provider. A failed write cancels it too.
+	ctx, cancel := context.WithCancel(c.Request().Context())
+	defer cancel()
+	repo := getAIRepo()
+
+	chunks, err := turn.provider.ChatStream(ctx, turn.request)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Gagal mendapatkan respons AI: " + err.Error(),
+		})
+	}
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx response buffering
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	// The router stamps every chunk with the provider that is answering
+	answeredBy, answeredModel := turn.providerName, turn.provider.GetModel()
+	var reply strings.Builder
+	var final *providers.StreamChunk
+	for chunk := range chunks {
+		if chunk.Provider != "" {
+			answeredBy = chunk.Provider
+		}
+		if chunk.Model != "" {
+			answeredModel = chunk.Model
+		}
+		if chunk.Done {
+			chunk := chunk
+			final = &chunk
+			break
+		}
+		reply.WriteString(chunk.Content)
+		if err := writeSSE(c, "token", map[string]string{"content": chunk.Content}); err != nil {
+			cancel()
+			break
+		}
+	}
+
+	// Client disconnected: the provider stops on ctx cancellation. Usage is still
+	// logged so abandoning a reply doesn't bypass the daily limit.
+	if final == nil || ctx.Err() != nil {
+		log.Printf("[AI Chat] Client disconnected from stream for session %s", turn.session.ID)
+		tokensInput := rag.MessagesTokens(turn.request.Messages)
+		repo.LogUsage(context.Background(), turn.userID, turn.courseID, "chat", answeredBy, answeredModel, tokensInput, rag.EstimateTokens(reply.String()))
+		return nil
+	}
+
+	if final.Error != nil {
+		log.Printf("[AI Chat] Stream error for session %s: %v", turn.session.ID, final.Error)
+		return writeSSE(c, "error", map[string]string{"error": "Gagal mendapatkan respons AI: " + final.Error.Error()})
+	}
+
+	content := reply.String()
+	tokensInput, tokensOutput := final.TokensInput, final.TokensOutput
+	if tokensInput == 0 && tokensOutput == 0 {
+		tokensInput = rag.MessagesTokens(turn.request.Messages)
+		tokensOutput = rag.EstimateTokens(content)
+	}
+	model := answeredModel
+	totalTokens := tokensInput + tokensOutput
+
+	// Save the complete assistant message
+	done := ChatStreamDone{
+		Message:      content,
+		Sources:      turn.sources,
+		SessionID:    turn.session.ID,
+		TokensInput:  tokensInput,
+		TokensOutput: tokensOutput,
+		TokensUsed:   totalTokens,
+		Model:        model,
+		Quota:        turn.quota(),
+	}
+	if msg, err := repo.AddChatMessage(ctx, turn.session.ID, "assistant", content, turn.sources, totalTokens, answeredBy, model); err == nil {
+		done.MessageID = msg.ID
+	} else {
+		log.Printf("[AI Chat] Failed to save streamed reply for session %s: %v", turn.session.ID, err)
+	}
+
+	// Log usage
+	repo.LogUsage(ctx, turn.userID, turn.courseID, "chat", answeredBy, model, tokensInput, tokensOutput)
+
+	return writeSSE(c, "done", done)
+}
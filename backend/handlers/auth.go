@@ -3,7 +3,6 @@ package handlers
 import (
 	"database/sql"
 	"net/http"
-	"os"
 	"regexp"
 	"strings"
 	"time"
@@ -11,6 +10,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/config"
 	customMiddleware "github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/middleware"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/models"
 	"golang.org/x/crypto/bcrypt"
@@ -296,10 +296,7 @@ func InstructorLogin(c echo.Context) error {
 
 // generateInstructorToken creates a JWT token for instructor users
 func generateInstructorToken(userID, email, role string) (string, error) {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "secret"
-	}
+	jwtSecret := config.JWTSecret()
 
 	claims := jwt.MapClaims{
 		"user_id":       userID,
@@ -317,10 +314,7 @@ func generateInstructorToken(userID, email, role string) (string, error) {
 
 // generateToken creates a JWT token for regular users
 func generateToken(userID, email, role string) (string, error) {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "secret" // Default for development only
-	}
+	jwtSecret := config.JWTSecret()
 
 	claims := jwt.MapClaims{
 		"user_id":     userID,
@@ -337,10 +331,7 @@ func generateToken(userID, email, role string) (string, error) {
 
 // generateAdminToken creates a JWT token for admin users with longer expiry
 func generateAdminToken(userID, email, role string) (string, error) {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "secret" // Default for development only
-	}
+	jwtSecret := config.JWTSecret()
 
 	claims := jwt.MapClaims{
 		"user_id":     userID,
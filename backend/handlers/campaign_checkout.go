@@ -339,7 +339,7 @@ func CampaignCheckout(c echo.Context) error {
 		case domain.CampaignTypeWebinarOnly:
 			// Webinar only - register to webinar without course enrollment
 			// Use direct webinar_id if available, otherwise fall back to course-based webinar lookup
-			go handleWebinarOnlyNotificationDirect(user.ID, campaign.WebinarID, campaign.CourseID)
+			enqueueWebinarOnlyFollowUp(user.ID, campaign.WebinarID, campaign.CourseID)
 			
 			return c.JSON(http.StatusOK, CampaignCheckoutResponse{
 				IsFree:    true,
@@ -362,7 +362,7 @@ func CampaignCheckout(c echo.Context) error {
 				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enroll"})
 			}
 
-			go handleEcourseOnlyNotification(user.ID, *campaign.CourseID)
+			enqueueEcourseOnlyFollowUp(user.ID, *campaign.CourseID)
 
 			return c.JSON(http.StatusOK, CampaignCheckoutResponse{
 				IsFree:    true,
@@ -386,7 +386,7 @@ func CampaignCheckout(c echo.Context) error {
 			}
 
 			// This will handle both webinar registration and combined notification
-			go handlePaymentSuccessNotification(user.ID, *campaign.CourseID)
+			enqueuePaymentFollowUp(user.ID, *campaign.CourseID)
 
 			return c.JSON(http.StatusOK, CampaignCheckoutResponse{
 				IsFree:    true,
@@ -409,7 +409,7 @@ func CampaignCheckout(c echo.Context) error {
 				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enroll"})
 			}
 
-			go handlePaymentSuccessNotification(user.ID, *campaign.CourseID)
+			enqueuePaymentFollowUp(user.ID, *campaign.CourseID)
 
 			return c.JSON(http.StatusOK, CampaignCheckoutResponse{
 				IsFree:    true,
@@ -487,7 +487,7 @@ func CampaignCheckout(c echo.Context) error {
 		}
 
 		// Register to webinar if exists (for free courses)
-		go handlePaymentSuccessNotification(user.ID, *campaign.CourseID)
+		enqueuePaymentFollowUp(user.ID, *campaign.CourseID)
 
 		return c.JSON(http.StatusOK, CampaignCheckoutResponse{
 			IsFree:         true,
@@ -511,7 +511,7 @@ func CampaignCheckout(c echo.Context) error {
 
 		// Send payment success notification and handle webinar registration
 		if user != nil {
-			go handlePaymentSuccessNotification(user.ID, *campaign.CourseID)
+			enqueuePaymentFollowUp(user.ID, *campaign.CourseID)
 		}
 		return c.JSON(http.StatusOK, CampaignCheckoutResponse{
 			IsFree:    true,
@@ -756,7 +756,7 @@ func handleWebinarOnlyNotification(userID, courseID string) {
 
 // handleEcourseOnlyNotification handles course-only registration (no webinar)
 // Sends course access notification without webinar info
-func handleEcourseOnlyNotification(userID, courseID string) {
+func handleEcourseOnlyNotification(userID, courseID string) error {
 	userRepo := postgres.NewUserRepository(db.DB)
 	courseRepo := postgres.NewCourseRepository(db.DB)
 
@@ -764,7 +764,7 @@ func handleEcourseOnlyNotification(userID, courseID string) {
 	user, err := userRepo.GetByID(userID)
 	if err != nil || user == nil {
 		log.Printf("[EcourseOnly] Failed to get user %s: %v", userID, err)
-		return
+		return fmt.Errorf("failed to get user %s: %v", userID, err)
 	}
 
 	// Get LMS URL
@@ -780,22 +780,20 @@ func handleEcourseOnlyNotification(userID, courseID string) {
 	course, err := courseRepo.GetByID(courseID)
 	if err != nil || course == nil {
 		log.Printf("[EcourseOnly] Failed to get course %s: %v", courseID, err)
-		return
+		return fmt.Errorf("failed to get course %s: %v", courseID, err)
 	}
 
 	// Send Course Access WA
 	if user.Phone != nil && *user.Phone != "" {
-		if err := service.GetWhatsAppService().SendPaymentSuccess(*user.Phone, user.FullName, course.Title, lmsURL); err != nil {
-			log.Printf("[EcourseOnly] Failed to send course access WA to %s: %v", *user.Phone, err)
-		} else {
-			log.Printf("[EcourseOnly] Course access WA sent to %s", *user.Phone)
-		}
+		service.SendPaymentSuccessAsync(*user.Phone, user.FullName, course.Title, lmsURL)
+		log.Printf("[EcourseOnly] Course access WA queued for %s", *user.Phone)
 	}
+	return nil
 }
 
 // handleWebinarOnlyNotificationDirect handles webinar-only registration with direct webinar_id
 // Supports both direct webinar link and course-based webinar lookup (fallback)
-func handleWebinarOnlyNotificationDirect(userID string, webinarID *string, courseID *string) {
+func handleWebinarOnlyNotificationDirect(userID string, webinarID *string, courseID *string) error {
 	webinarRepo := postgres.NewWebinarRepository(db.DB)
 	userRepo := postgres.NewUserRepository(db.DB)
 
@@ -803,7 +801,7 @@ func handleWebinarOnlyNotificationDirect(userID string, webinarID *string, cours
 	user, err := userRepo.GetByID(userID)
 	if err != nil || user == nil {
 		log.Printf("[WebinarOnlyDirect] Failed to get user %s: %v", userID, err)
-		return
+		return fmt.Errorf("failed to get user %s: %v", userID, err)
 	}
 
 	// Get LMS URL
@@ -822,7 +820,7 @@ func handleWebinarOnlyNotificationDirect(userID string, webinarID *string, cours
 		webinar, err = webinarRepo.GetByID(*webinarID)
 		if err != nil || webinar == nil {
 			log.Printf("[WebinarOnlyDirect] Failed to get webinar %s: %v", *webinarID, err)
-			return
+			return fmt.Errorf("failed to get webinar %s: %v", *webinarID, err)
 		}
 
 		// Register user to this specific webinar
@@ -837,7 +835,7 @@ func handleWebinarOnlyNotificationDirect(userID string, webinarID *string, cours
 		webinars, err := webinarRepo.GetUpcomingByCourse(*courseID)
 		if err != nil || len(webinars) == 0 {
 			log.Printf("[WebinarOnlyDirect] No webinars found for course %s", *courseID)
-			return
+			return nil
 		}
 
 		// Register to all upcoming webinars for the course
@@ -852,7 +850,7 @@ func handleWebinarOnlyNotificationDirect(userID string, webinarID *string, cours
 		webinar = webinars[0]
 	} else {
 		log.Printf("[WebinarOnlyDirect] No webinar_id or course_id provided")
-		return
+		return nil
 	}
 
 	// Send Webinar Confirmation WA
@@ -881,4 +879,5 @@ func handleWebinarOnlyNotificationDirect(userID string, webinarID *string, cours
 		service.SendWebinarOnlyConfirmationAsync(*user.Phone, data)
 		log.Printf("[WebinarOnlyDirect] Webinar confirmation sent to %s", *user.Phone)
 	}
+	return nil
 }
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/video"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/middleware"
+)
+
+// contentTokenParam is the query parameter carrying the content token on HLS URIs
+const contentTokenParam = "token"
+
+// ContentTokenResponse is a freshly minted content token
+type ContentTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	ExpiresIn int       `json:"expires_in"` // seconds
+}
+
+// contentTokenTTL is how long HLS content tokens stay valid. Players refresh them
+// through GetHLSContentToken, so this can be a few minutes.
+func contentTokenTTL() time.Duration {
+	seconds := getSettingInt("hls_content_token_ttl_seconds", 300)
+	if seconds < 30 {
+		seconds = 30
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// mintContentToken issues a content token for a lesson's HLS files, bound to the
+// requesting client as configured
+func mintContentToken(c echo.Context, userID, role, lessonID, hlsPath string) (string, time.Time, error) {
+	claims := video.ContentTokenClaims{
+		LessonID: lessonID,
+		Role:     role,
+		HLSPath:  hlsPath,
+	}
+	if getSettingBool("hls_content_token_bind_ip", false) {
+		claims.IPHash = video.ClientFingerprint(c.RealIP())
+	}
+	if getSettingBool("hls_content_token_bind_user_agent", true) {
+		claims.UAHash = video.ClientFingerprint(c.Request().UserAgent())
+	}
+
+	return video.IssueContentToken(hlsSigningSecret(), userID, claims, contentTokenTTL())
+}
+
+// verifyContentToken validates the content token of an HLS request for a lesson
+func verifyContentToken(c echo.Context, lessonID string) (*video.ContentTokenClaims, error) {
+	return video.ParseContentToken(hlsSigningSecret(), c.QueryParam(contentTokenParam),
+		lessonID, c.RealIP(), c.Request().UserAgent())
+}
+
+// withContentToken appends a content token to an HLS URI
+func withContentToken(uri, token string) string {
+	return uri + "?" + contentTokenParam + "=" + token
+}
+
+// GetHLSContentToken mints a fresh content token so a player can keep fetching
+// segments and keys after the token in its playlist expires
+// GET /api/content/:lessonId/hls/token
+func GetHLSContentToken(c echo.Context) error {
+	lessonID := c.Param("lessonId")
+
+	userID, role, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	if err := verifyContentAccess(userID, role, lessonID); err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+
+	var hlsPath string
+	err = db.DB.Get(&hlsPath, `
+		SELECT hls_path FROM video_encryption_keys 
+		WHERE lesson_id = $1 AND status = 'ready'
+	`, lessonID)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "HLS content not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch HLS info"})
+	}
+
+	token, expiresAt, err := mintContentToken(c, userID, role, lessonID, hlsPath)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to issue content token"})
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, ContentTokenResponse{
+		Token:     token,
+		ExpiresAt: expiresAt,
+		ExpiresIn: int(time.Until(expiresAt).Seconds()),
+	})
+}
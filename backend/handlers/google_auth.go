@@ -10,6 +10,7 @@ import (
 	"os"
 	"time"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/config"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/models"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -106,10 +107,7 @@ func GoogleAuthCallback(c echo.Context) error {
 	claims["role"] = user.Role
 	claims["exp"] = time.Now().Add(time.Hour * 72).Unix()
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "secret"
-	}
+	jwtSecret := config.JWTSecret()
 
 	t, err := jwtToken.SignedString([]byte(jwtSecret))
 	if err != nil {
@@ -8,12 +8,17 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/config"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/domain"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/storage"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/video"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/middleware"
 )
 
@@ -24,10 +29,12 @@ type VideoEncryptionKey struct {
 	EncryptionKey []byte `db:"encryption_key" json:"-"`
 	IV            []byte `db:"iv" json:"-"`
 	HLSPath       string `db:"hls_path" json:"hls_path"`
+	ManifestFile  string `db:"manifest_file" json:"manifest_file"`
 	Status        string `db:"status" json:"status"`
 }
 
-// GetHLSManifest serves the HLS manifest (.m3u8) for a lesson
+// GetHLSManifest serves the HLS manifest (.m3u8) for a lesson.
+// For adaptive videos this is the master playlist pointing at the variant endpoint.
 // GET /api/content/:lessonId/hls/manifest
 func GetHLSManifest(c echo.Context) error {
 	lessonID := c.Param("lessonId")
@@ -49,7 +56,8 @@ func GetHLSManifest(c echo.Context) error {
 	// Get encryption key record
 	var encKey VideoEncryptionKey
 	err = db.DB.Get(&encKey, `
-		SELECT id, lesson_id, hls_path, status 
+		SELECT id, lesson_id, COALESCE(hls_path, '') as hls_path, status,
+		       COALESCE(manifest_file, 'playlist.m3u8') as manifest_file
 		FROM video_encryption_keys 
 		WHERE lesson_id = $1
 	`, lessonID)
@@ -68,43 +76,159 @@ func GetHLSManifest(c echo.Context) error {
 		})
 	}
 
-	// Get manifest from MinIO
+	content, err := loadHLSPlaylist(c.Request().Context(), encKey.HLSPath+"/"+encKey.ManifestFile)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Manifest not found"})
+	}
+
+	// Variants, segments and the key are authorized by a short-lived content token
+	// scoped to this lesson instead of the application JWT
+	token, _, err := mintContentToken(c, userID, role, lessonID, encKey.HLSPath)
+	if err != nil {
+		log.Printf("Failed to issue content token for lesson %s: %v", lessonID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to issue content token"})
+	}
+
+	subtitles := lessonSubtitleTracks(lessonID)
+	variantBase := fmt.Sprintf("/api/content/%s/hls/variant/", lessonID)
+
+	var modifiedContent string
+	isMaster := true
+	if encKey.ManifestFile == video.MasterPlaylistName {
+		// Point every variant at the token-authorized variant endpoint
+		modifiedContent = rewritePlaylistURIs(content, func(uri string) string {
+			return withContentToken(variantBase+uri, token)
+		})
+	} else if len(subtitles) > 0 {
+		// Legacy single-rendition playlist: wrap it so subtitle renditions can be attached
+		modifiedContent = video.WrapMediaPlaylist(withContentToken(variantBase+encKey.ManifestFile, token))
+	} else {
+		// Legacy single-rendition playlist
+		modifiedContent = rewriteMediaPlaylist(content, lessonID, token)
+		isMaster = false
+	}
+
+	if isMaster {
+		modifiedContent = video.AddSubtitleRenditions(modifiedContent, subtitles)
+	}
+
+	// Forensic watermark for the highest protection level
+	mark, err := issueWatermark(c, userID, role, lessonID)
+	if err != nil {
+		log.Printf("[Watermark] Failed to issue watermark for lesson %s: %v", lessonID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to issue watermark"})
+	}
+	if mark != nil {
+		c.Response().Header().Set(watermarkHeader, mark.Code)
+		if isMaster {
+			modifiedContent = embedWatermarkInPlaylist(modifiedContent, mark)
+		}
+	}
+
+	return servePlaylist(c, modifiedContent)
+}
+
+// GetHLSVariant serves a per-rendition media playlist whose segment and key URIs
+// carry the same content token
+// GET /api/content/:lessonId/hls/variant/:filename?token=...
+func GetHLSVariant(c echo.Context) error {
+	lessonID := c.Param("lessonId")
+	filename := c.Param("filename")
+
+	if lessonID == "" || filename == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Missing parameters"})
+	}
+
+	// Security: Validate filename to prevent path traversal
+	if strings.Contains(filename, "..") || strings.Contains(filename, "/") {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid filename"})
+	}
+
+	// Only allow variant playlists
+	if !strings.HasSuffix(filename, ".m3u8") || filename == video.MasterPlaylistName {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid playlist"})
+	}
+
+	claims, err := verifyContentToken(c, lessonID)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+
+	content, err := loadHLSPlaylist(c.Request().Context(), claims.HLSPath+"/"+filename)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Playlist not found"})
+	}
+
+	return servePlaylist(c, rewriteMediaPlaylist(content, lessonID, c.QueryParam(contentTokenParam)))
+}
+
+// loadHLSPlaylist reads a playlist object from storage
+func loadHLSPlaylist(ctx context.Context, objectPath string) (string, error) {
 	minioStorage := storage.GetStorage()
 	if minioStorage == nil {
-		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Storage not available"})
+		return "", fmt.Errorf("storage not available")
 	}
 
-	manifestPath := encKey.HLSPath + "/playlist.m3u8"
-	obj, err := minioStorage.GetObject(context.Background(), "", manifestPath)
+	obj, err := minioStorage.GetObject(ctx, "", objectPath)
 	if err != nil {
-		log.Printf("Failed to get manifest %s: %v", manifestPath, err)
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Manifest not found"})
+		log.Printf("Failed to get playlist %s: %v", objectPath, err)
+		return "", err
 	}
 	defer obj.Close()
 
-	// Read manifest content
 	content, err := io.ReadAll(obj)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to read manifest"})
+		log.Printf("Failed to read playlist %s: %v", objectPath, err)
+		return "", err
 	}
+	return string(content), nil
+}
+
+// servePlaylist writes an m3u8 response with no-cache headers
+func servePlaylist(c echo.Context, content string) error {
+	c.Response().Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	c.Response().Header().Set("X-Content-Type-Options", "nosniff")
 
-	// Rewrite key URI to point to our authenticated endpoint
-	keyURI := fmt.Sprintf("/api/content/%s/hls/key", lessonID)
-	modifiedContent := strings.Replace(string(content), 
+	return c.String(http.StatusOK, content)
+}
+
+// rewriteMediaPlaylist points the key URI and every segment at our token-authorized endpoints
+func rewriteMediaPlaylist(content, lessonID, token string) string {
+	// Rewrite key URI to point to our authorized endpoint
+	keyURI := withContentToken(fmt.Sprintf("/api/content/%s/hls/key", lessonID), token)
+	modifiedContent := strings.Replace(content, 
 		`URI="key.bin"`, 
 		fmt.Sprintf(`URI="%s"`, keyURI), 
 		-1)
 
-	// Set headers
-	c.Response().Header().Set("Content-Type", "application/vnd.apple.mpegurl")
-	c.Response().Header().Set("Cache-Control", "no-cache")
-	c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+	// Rewrite segment filenames to segment endpoint URLs
+	segmentBase := fmt.Sprintf("/api/content/%s/hls/segment/", lessonID)
+	return rewritePlaylistURIs(modifiedContent, func(uri string) string {
+		return withContentToken(segmentBase+uri, token)
+	})
+}
 
-	return c.String(http.StatusOK, modifiedContent)
+// rewritePlaylistURIs rewrites every relative URI line in a playlist
+func rewritePlaylistURIs(manifest string, rewrite func(uri string) string) string {
+	lines := strings.Split(manifest, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.Contains(trimmed, "/") {
+			continue
+		}
+		lines[i] = rewrite(trimmed)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hlsSigningSecret returns the secret used to sign HLS content tokens
+func hlsSigningSecret() []byte {
+	return []byte("hls-segment:" + config.JWTSecret())
 }
 
 // GetHLSSegment serves an HLS segment (.ts file)
-// GET /api/content/:lessonId/hls/segment/:filename
+// GET /api/content/:lessonId/hls/segment/:filename?token=...
 func GetHLSSegment(c echo.Context) error {
 	lessonID := c.Param("lessonId")
 	filename := c.Param("filename")
@@ -123,44 +247,33 @@ func GetHLSSegment(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid segment type"})
 	}
 
-	// Verify user access
-	userID, role, err := middleware.GetUserFromContext(c)
+	// Content tokens are only minted after verifyContentAccess and carry the HLS
+	// path, so segments are served without any database query
+	claims, err := verifyContentToken(c, lessonID)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
-	}
-
-	if err := verifyContentAccess(userID, role, lessonID); err != nil {
 		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
 	}
+	hlsPath := claims.HLSPath
 
-	// Get HLS path
-	var hlsPath string
-	err = db.DB.Get(&hlsPath, `
-		SELECT hls_path FROM video_encryption_keys 
-		WHERE lesson_id = $1 AND status = 'ready'
-	`, lessonID)
-	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "HLS content not found"})
-	}
-
-	// Get segment from MinIO
+	// Get segment from storage
 	minioStorage := storage.GetStorage()
 	if minioStorage == nil {
 		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Storage not available"})
 	}
 
 	segmentPath := filepath.Join(hlsPath, filename)
-	obj, err := minioStorage.GetObject(context.Background(), "", segmentPath)
+	info, err := minioStorage.GetObjectInfo(context.Background(), "", segmentPath)
 	if err != nil {
 		log.Printf("Failed to get segment %s: %v", segmentPath, err)
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Segment not found"})
 	}
-	defer obj.Close()
 
-	info, err := obj.Stat()
+	obj, err := minioStorage.GetObject(context.Background(), "", segmentPath)
 	if err != nil {
+		log.Printf("Failed to get segment %s: %v", segmentPath, err)
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Segment not found"})
 	}
+	defer obj.Close()
 
 	// Set headers
 	c.Response().Header().Set("Content-Type", "video/MP2T")
@@ -172,21 +285,24 @@ func GetHLSSegment(c echo.Context) error {
 }
 
 // GetHLSKey serves the decryption key for HLS content
-// GET /api/content/:lessonId/hls/key
+// GET /api/content/:lessonId/hls/key?token=...
 func GetHLSKey(c echo.Context) error {
 	lessonID := c.Param("lessonId")
 	if lessonID == "" {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Lesson ID is required"})
 	}
 
-	// Verify user access - this MUST be authenticated
-	userID, role, err := middleware.GetUserFromContext(c)
+	// Verify access - the content token was minted for this user after verifyContentAccess
+	claims, err := verifyContentToken(c, lessonID)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
 	}
+	userID, role := claims.Subject, claims.Role
 
-	if err := verifyContentAccess(userID, role, lessonID); err != nil {
-		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	// The key is only served within the device's playback session, started by
+	// StartPlayback. Starting one here would re-register a revoked device.
+	if limits, err := requirePlaybackSession(c, userID, role, lessonID); err != nil {
+		return playbackErrorResponse(c, userID, limits, err)
 	}
 
 	// Get encryption key from database
@@ -219,12 +335,15 @@ func GetHLSStatus(c echo.Context) error {
 	}
 
 	var status struct {
-		Status  string `db:"status" json:"status"`
-		HLSPath string `db:"hls_path" json:"hls_path"`
+		Status       string  `db:"status" json:"status"`
+		HLSPath      string  `db:"hls_path" json:"hls_path"`
+		Duration     int     `db:"duration_seconds" json:"duration_seconds"`
+		ErrorMessage *string `db:"error_message" json:"error_message,omitempty"`
 	}
 
 	err := db.DB.Get(&status, `
-		SELECT status, COALESCE(hls_path, '') as hls_path
+		SELECT status, COALESCE(hls_path, '') as hls_path,
+		       COALESCE(duration_seconds, 0) as duration_seconds, error_message
 		FROM video_encryption_keys 
 		WHERE lesson_id = $1
 	`, lessonID)
@@ -278,7 +397,7 @@ func verifyContentAccess(userID, role, lessonID string) error {
 	err = db.DB.Get(&enrolled, `
 		SELECT EXISTS(
 			SELECT 1 FROM enrollments 
-			WHERE user_id = $1 AND course_id = $2 AND status = 'active'
+			WHERE user_id = $1 AND course_id = $2
 		)
 	`, userID, lesson.CourseID)
 	if err != nil || !enrolled {
@@ -288,28 +407,218 @@ func verifyContentAccess(userID, role, lessonID string) error {
 	return nil
 }
 
-// ProcessVideoToHLS is called after video upload to start HLS conversion
-// This should be called asynchronously (in a goroutine or job queue)
-func ProcessVideoToHLS(lessonID, videoPath string) error {
-	log.Printf("Starting HLS processing for lesson %s, video: %s", lessonID, videoPath)
+// hlsObjectPrefix returns the storage prefix where HLS output for a lesson is stored
+func hlsObjectPrefix(lessonID string) string {
+	return "hls/" + lessonID
+}
+
+// ProcessVideoToHLS converts an uploaded video into AES-128 encrypted HLS.
+// It blocks until processing finishes or ctx is done, which stops ffmpeg; use
+// enqueueHLSProcessing to run it on the job queue.
+func ProcessVideoToHLS(ctx context.Context, lessonID, videoPath string) error {
+	log.Printf("[HLS] Starting processing for lesson %s, video: %s", lessonID, videoPath)
 
-	// Insert or update processing status
+	// Insert or update processing status (key/IV are placeholders until conversion succeeds)
 	_, err := db.DB.Exec(`
-		INSERT INTO video_encryption_keys (lesson_id, encryption_key, iv, status)
-		VALUES ($1, decode($2, 'hex'), decode($3, 'hex'), 'processing')
+		INSERT INTO video_encryption_keys (lesson_id, encryption_key, iv, source_path, status)
+		VALUES ($1, decode($2, 'hex'), decode($3, 'hex'), $4, 'processing')
 		ON CONFLICT (lesson_id) 
-		DO UPDATE SET status = 'processing', updated_at = NOW()
-	`, lessonID, hex.EncodeToString(make([]byte, 16)), hex.EncodeToString(make([]byte, 16)))
+		DO UPDATE SET status = 'processing', source_path = $4, error_message = NULL, updated_at = NOW()
+	`, lessonID, hex.EncodeToString(make([]byte, 16)), hex.EncodeToString(make([]byte, 16)), videoPath)
 	if err != nil {
 		return fmt.Errorf("failed to update processing status: %w", err)
 	}
 
-	// Actual processing would happen here
-	// For now, return - the actual implementation would:
+	if err := runHLSPipeline(ctx, lessonID, videoPath); err != nil {
+		if ctx.Err() != nil {
+			// Interrupted (shutdown or job timeout): the job queue runs it again
+			log.Printf("[HLS] Processing interrupted for lesson %s: %v", lessonID, err)
+			db.DB.Exec(`UPDATE video_encryption_keys SET status = 'pending', updated_at = NOW() WHERE lesson_id = $1`, lessonID)
+			return err
+		}
+		log.Printf("[HLS] Processing failed for lesson %s: %v", lessonID, err)
+		markHLSFailed(lessonID, err)
+		return err
+	}
+
+	log.Printf("[HLS] Processing complete for lesson %s", lessonID)
+	return nil
+}
+
+// runHLSPipeline downloads the source video, converts it and uploads the output
+func runHLSPipeline(ctx context.Context, lessonID, videoPath string) error {
+	minioStorage := storage.GetStorage()
+	if minioStorage == nil {
+		return fmt.Errorf("storage not configured")
+	}
+
+	if err := video.ValidateFFmpeg(); err != nil {
+		return err
+	}
+
 	// 1. Download video from MinIO to temp
-	// 2. Run FFmpeg to create HLS segments
-	// 3. Upload segments back to MinIO
-	// 4. Update database with ready status
+	tempPath, err := downloadObjectToTemp(ctx, minioStorage, videoPath)
+	if err != nil {
+		return fmt.Errorf("failed to download source video: %w", err)
+	}
+	defer os.Remove(tempPath)
+
+	// 2. Run FFmpeg to create encrypted HLS segments for every rendition
+	processor, err := video.NewProcessorWithConfig(hlsConfigFromSettings())
+	if err != nil {
+		return err
+	}
+	defer processor.Cleanup()
+
+	encKey, err := video.GenerateEncryptionKey()
+	if err != nil {
+		return err
+	}
+
+	result, err := processor.ConvertToHLS(ctx, tempPath, encKey, "output")
+	if err != nil {
+		return err
+	}
+
+	// 3. Upload playlists and segments back to MinIO (the key file never leaves the server).
+	// Output of an earlier run goes first: a smaller rendition ladder or fewer
+	// segments would otherwise leave stale files, encrypted with the old key.
+	hlsPath := hlsObjectPrefix(lessonID)
+	if err := minioStorage.DeleteFolder(ctx, "", hlsPath); err != nil {
+		return fmt.Errorf("failed to delete previous HLS output: %w", err)
+	}
+	if err := uploadHLSFile(ctx, minioStorage, result.ManifestPath, hlsPath, "application/vnd.apple.mpegurl"); err != nil {
+		return err
+	}
+	for _, variant := range result.Variants {
+		if err := uploadHLSFile(ctx, minioStorage, variant.PlaylistPath, hlsPath, "application/vnd.apple.mpegurl"); err != nil {
+			return err
+		}
+	}
+	for _, segmentPath := range result.SegmentPaths {
+		if err := uploadHLSFile(ctx, minioStorage, segmentPath, hlsPath, "video/MP2T"); err != nil {
+			return err
+		}
+	}
+
+	// 4. Store the real key and flip status to ready
+	_, err = db.DB.Exec(`
+		UPDATE video_encryption_keys 
+		SET encryption_key = $2, iv = $3, hls_path = $4, duration_seconds = $5, manifest_file = $6,
+		    status = 'ready', error_message = NULL, updated_at = NOW()
+		WHERE lesson_id = $1
+	`, lessonID, encKey.Key, encKey.IV, hlsPath, int(result.Duration), filepath.Base(result.ManifestPath))
+	if err != nil {
+		return fmt.Errorf("failed to save encryption key: %w", err)
+	}
+
+	return nil
+}
+
+// hlsConfigFromSettings builds the HLS config, using the "hls_renditions" setting
+// (comma separated, e.g. "360p,480p,720p") to choose the bitrate ladder
+func hlsConfigFromSettings() video.HLSConfig {
+	cfg := video.DefaultHLSConfig()
+	cfg.SegmentDuration = getSettingInt("hls_segment_duration", cfg.SegmentDuration)
+
+	if names := getSettingValue("hls_renditions", ""); names != "" {
+		if renditions := video.RenditionsByName(strings.Split(names, ",")); len(renditions) > 0 {
+			cfg.Renditions = renditions
+		}
+	}
+	return cfg
+}
+
+// uploadHLSFile uploads a single local HLS file under the given storage prefix
+func uploadHLSFile(ctx context.Context, s storage.Storage, localPath, prefix, contentType string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", localPath, err)
+	}
+	defer f.Close()
 
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", localPath, err)
+	}
+
+	objectName := prefix + "/" + filepath.Base(localPath)
+	if err := s.Upload(ctx, "", objectName, f, info.Size(), contentType); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", objectName, err)
+	}
 	return nil
 }
+
+// markHLSFailed records a processing failure for a lesson
+func markHLSFailed(lessonID string, cause error) {
+	_, err := db.DB.Exec(`
+		UPDATE video_encryption_keys 
+		SET status = 'failed', error_message = $2, updated_at = NOW()
+		WHERE lesson_id = $1
+	`, lessonID, cause.Error())
+	if err != nil {
+		log.Printf("[HLS] Failed to mark lesson %s as failed: %v", lessonID, err)
+	}
+}
+
+// isHLSSourceObject reports whether a video URL points to a storage object we can transcode
+func isHLSSourceObject(videoURL string) bool {
+	if videoURL == "" {
+		return false
+	}
+	if strings.HasPrefix(videoURL, "http://") || strings.HasPrefix(videoURL, "https://") || strings.HasPrefix(videoURL, "/uploads/") {
+		return false
+	}
+	return getFileType(strings.ToLower(filepath.Ext(videoURL))) == "video"
+}
+
+// queueHLSProcessing starts HLS conversion for an aes_128 lesson when its video changed
+func queueHLSProcessing(lesson *domain.Lesson, previousVideoURL string) {
+	if lesson == nil || lesson.SecurityLevel != domain.SecurityAES128 || lesson.VideoURL == nil {
+		return
+	}
+	if !isHLSSourceObject(*lesson.VideoURL) {
+		return
+	}
+
+	// Skip if the same video is already converted or being converted
+	if *lesson.VideoURL == previousVideoURL {
+		var status string
+		err := db.DB.Get(&status, `SELECT status FROM video_encryption_keys WHERE lesson_id = $1`, lesson.ID)
+		if err == nil && (status == "ready" || status == "processing" || status == "pending") {
+			return
+		}
+	}
+
+	enqueueHLSProcessing(lesson.ID, *lesson.VideoURL)
+}
+
+// ProcessLessonHLS manually (re)starts HLS conversion for a lesson video
+// POST /api/admin/lessons/:id/hls
+func ProcessLessonHLS(c echo.Context) error {
+	lessonID := c.Param("id")
+
+	lessonRepo := postgres.NewLessonRepository(db.DB)
+	lesson, err := lessonRepo.GetByID(lessonID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch lesson"})
+	}
+	if lesson == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Lesson not found"})
+	}
+
+	if lesson.VideoURL == nil || !isHLSSourceObject(*lesson.VideoURL) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Lesson has no uploaded video to convert"})
+	}
+
+	if !storage.IsConfigured() {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Storage not available"})
+	}
+
+	enqueueHLSProcessing(lesson.ID, *lesson.VideoURL)
+
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "HLS processing queued",
+		"status":  "pending",
+	})
+}
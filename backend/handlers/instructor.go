@@ -980,7 +980,8 @@ func InstructorGetQuiz(c echo.Context) error {
 
 	// Get questions
 	rows, err := db.DB.Query(`
-		SELECT id, question_type, question_text, COALESCE(explanation, ''), points, required, order_index
+		SELECT id, question_type, question_text, COALESCE(explanation, ''), points, required, order_index,
+		       COALESCE(grading_mode, 'exact'), COALESCE(rubric, '')
 		FROM quiz_questions
 		WHERE quiz_id = $1
 		ORDER BY order_index
@@ -992,11 +993,11 @@ func InstructorGetQuiz(c echo.Context) error {
 
 	var questions []map[string]interface{}
 	for rows.Next() {
-		var qID, qType, qText, explanation string
+		var qID, qType, qText, explanation, gradingMode, rubric string
 		var points, orderIndex int
 		var required bool
 
-		rows.Scan(&qID, &qType, &qText, &explanation, &points, &required, &orderIndex)
+		rows.Scan(&qID, &qType, &qText, &explanation, &points, &required, &orderIndex, &gradingMode, &rubric)
 
 		// Get options for this question
 		optRows, _ := db.DB.Query(`
@@ -1029,6 +1030,8 @@ func InstructorGetQuiz(c echo.Context) error {
 			"points":        points,
 			"required":      required,
 			"order_index":   orderIndex,
+			"grading_mode":  gradingMode,
+			"rubric":        rubric,
 			"options":       options,
 		})
 	}
@@ -1139,6 +1142,8 @@ func InstructorAddQuestion(c echo.Context) error {
 		Explanation  string `json:"explanation"`
 		Points       int    `json:"points"`
 		Required     bool   `json:"required"`
+		GradingMode  string `json:"grading_mode"`
+		Rubric       string `json:"rubric"`
 		Options      []struct {
 			OptionText string `json:"option_text"`
 			IsCorrect  bool   `json:"is_correct"`
@@ -1147,6 +1152,12 @@ func InstructorAddQuestion(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Data tidak valid"})
 	}
+	if !isGradingMode(domain.GradingMode(req.GradingMode)) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Mode penilaian tidak valid"})
+	}
+	if req.GradingMode == "" {
+		req.GradingMode = string(domain.GradingExact)
+	}
 
 	// Get next order index
 	var maxOrder int
@@ -1155,10 +1166,10 @@ func InstructorAddQuestion(c echo.Context) error {
 	// Insert question
 	var questionID string
 	err = db.DB.QueryRow(`
-		INSERT INTO quiz_questions (quiz_id, question_type, question_text, explanation, points, required, order_index)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO quiz_questions (quiz_id, question_type, question_text, explanation, points, required, order_index, grading_mode, rubric)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING id
-	`, quizID, req.QuestionType, req.QuestionText, req.Explanation, req.Points, req.Required, maxOrder+1).Scan(&questionID)
+	`, quizID, req.QuestionType, req.QuestionText, req.Explanation, req.Points, req.Required, maxOrder+1, req.GradingMode, req.Rubric).Scan(&questionID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Gagal menambah soal"})
 	}
@@ -1211,6 +1222,8 @@ func InstructorUpdateQuestion(c echo.Context) error {
 		Explanation  string `json:"explanation"`
 		Points       int    `json:"points"`
 		Required     bool   `json:"required"`
+		GradingMode  string `json:"grading_mode"`
+		Rubric       string `json:"rubric"`
 		Options      []struct {
 			ID         string `json:"id"`
 			OptionText string `json:"option_text"`
@@ -1220,13 +1233,20 @@ func InstructorUpdateQuestion(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Data tidak valid"})
 	}
+	if !isGradingMode(domain.GradingMode(req.GradingMode)) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Mode penilaian tidak valid"})
+	}
+	if req.GradingMode == "" {
+		req.GradingMode = string(domain.GradingExact)
+	}
 
 	// Update question
 	_, err = db.DB.Exec(`
 		UPDATE quiz_questions 
-		SET question_type = $1, question_text = $2, explanation = $3, points = $4, required = $5
-		WHERE id = $6
-	`, req.QuestionType, req.QuestionText, req.Explanation, req.Points, req.Required, questionID)
+		SET question_type = $1, question_text = $2, explanation = $3, points = $4, required = $5,
+		    grading_mode = $6, rubric = $7
+		WHERE id = $8
+	`, req.QuestionType, req.QuestionText, req.Explanation, req.Points, req.Required, req.GradingMode, req.Rubric, questionID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Gagal update soal"})
 	}
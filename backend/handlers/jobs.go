@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/jobs"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
+)
+
+// Job types handled by this package
+const (
+	JobProcessVideoHLS        = "video.process_hls"
+	JobExtractVideoMetadata   = "video.extract_metadata"
+	JobProcessCourseAI        = "ai.process_course"
+	JobPaymentFollowUp        = "payment.followup"
+	JobWebinarPaymentFollowUp = "payment.webinar_followup"
+	JobEcourseOnlyFollowUp    = "campaign.ecourse_only_followup"
+	JobWebinarOnlyFollowUp    = "campaign.webinar_only_followup"
+	JobGradeQuizAttempt       = "quiz.grade_attempt"
+)
+
+type hlsJobPayload struct {
+	LessonID  string `json:"lesson_id"`
+	VideoPath string `json:"video_path"`
+}
+
+type courseAIJobPayload struct {
+	CourseID string `json:"course_id"`
+	Provider string `json:"provider"`
+	StatusID string `json:"status_id"`
+	UserID   string `json:"user_id,omitempty"` // Who started the run; embedding usage is logged for them
+	Force    bool   `json:"force,omitempty"`   // Re-embed unchanged lessons too
+}
+
+type quizGradingJobPayload struct {
+	AttemptID string `json:"attempt_id"`
+}
+
+type followUpJobPayload struct {
+	UserID    string  `json:"user_id"`
+	CourseID  *string `json:"course_id,omitempty"`
+	WebinarID *string `json:"webinar_id,omitempty"`
+}
+
+// RegisterJobHandlers registers the background job handlers owned by the handlers package
+func RegisterJobHandlers() {
+	jobs.Register(JobProcessVideoHLS, func(ctx context.Context, job *jobs.Job) error {
+		var p hlsJobPayload
+		if err := job.Decode(&p); err != nil {
+			return err
+		}
+		return ProcessVideoToHLS(ctx, p.LessonID, p.VideoPath)
+	})
+
+	jobs.Register(JobExtractVideoMetadata, func(ctx context.Context, job *jobs.Job) error {
+		var p hlsJobPayload
+		if err := job.Decode(&p); err != nil {
+			return err
+		}
+		return ExtractVideoMetadata(ctx, p.LessonID, p.VideoPath)
+	})
+
+	jobs.Register(JobProcessCourseAI, func(ctx context.Context, job *jobs.Job) error {
+		var p courseAIJobPayload
+		if err := job.Decode(&p); err != nil {
+			return err
+		}
+
+		apiKey, ok := aiProviderKey(p.Provider)
+		if !ok {
+			return fmt.Errorf("API key not configured for provider %s", p.Provider)
+		}
+
+		if job.Attempts > 1 {
+			// Retrying after a failure: show the status as processing again
+			repo := postgres.NewAIRepository(db.DB)
+			repo.UpdateProcessingStatus(ctx, p.StatusID, "processing", 0, 0, nil)
+		}
+		return processCourseContentAsync(ctx, p.CourseID, p.UserID, apiKey, p.Provider, p.StatusID, p.Force)
+	})
+
+	jobs.Register(JobGradeQuizAttempt, func(ctx context.Context, job *jobs.Job) error {
+		var p quizGradingJobPayload
+		if err := job.Decode(&p); err != nil {
+			return err
+		}
+		// The last try hands what the model couldn't grade to the instructor
+		return gradePendingAnswers(ctx, p.AttemptID, job.Attempts >= job.MaxAttempts)
+	})
+
+	jobs.Register(JobPaymentFollowUp, func(ctx context.Context, job *jobs.Job) error {
+		var p followUpJobPayload
+		if err := job.Decode(&p); err != nil || p.CourseID == nil {
+			return fmt.Errorf("invalid payment follow-up payload: %v", err)
+		}
+		return handlePaymentSuccessNotification(p.UserID, *p.CourseID)
+	})
+
+	jobs.Register(JobWebinarPaymentFollowUp, func(ctx context.Context, job *jobs.Job) error {
+		var p followUpJobPayload
+		if err := job.Decode(&p); err != nil || p.WebinarID == nil {
+			return fmt.Errorf("invalid webinar payment follow-up payload: %v", err)
+		}
+		return handleWebinarPaymentSuccess(p.UserID, *p.WebinarID)
+	})
+
+	jobs.Register(JobEcourseOnlyFollowUp, func(ctx context.Context, job *jobs.Job) error {
+		var p followUpJobPayload
+		if err := job.Decode(&p); err != nil || p.CourseID == nil {
+			return fmt.Errorf("invalid e-course follow-up payload: %v", err)
+		}
+		return handleEcourseOnlyNotification(p.UserID, *p.CourseID)
+	})
+
+	jobs.Register(JobWebinarOnlyFollowUp, func(ctx context.Context, job *jobs.Job) error {
+		var p followUpJobPayload
+		if err := job.Decode(&p); err != nil {
+			return err
+		}
+		return handleWebinarOnlyNotificationDirect(p.UserID, p.WebinarID, p.CourseID)
+	})
+}
+
+// enqueueJob adds a job to the queue. If the queue is unavailable the work runs
+// in a goroutine instead, so nothing is dropped (but it won't survive a restart).
+func enqueueJob(queue, jobType string, payload interface{}, opts *jobs.EnqueueOptions, fallback func()) {
+	job, err := jobs.Enqueue(context.Background(), queue, jobType, payload, opts)
+	if err != nil {
+		log.Printf("[Jobs] Failed to enqueue %s, running inline: %v", jobType, err)
+		go fallback()
+		return
+	}
+	log.Printf("[Jobs] Enqueued %s job %s on queue %s", jobType, job.ID, queue)
+}
+
+// enqueueHLSProcessing marks a lesson video as pending and queues its HLS conversion
+func enqueueHLSProcessing(lessonID, videoPath string) {
+	_, err := db.DB.Exec(`
+		INSERT INTO video_encryption_keys (lesson_id, encryption_key, iv, source_path, status)
+		VALUES ($1, decode($2, 'hex'), decode($3, 'hex'), $4, 'pending')
+		ON CONFLICT (lesson_id) 
+		DO UPDATE SET status = 'pending', source_path = $4, error_message = NULL, updated_at = NOW()
+	`, lessonID, hex.EncodeToString(make([]byte, 16)), hex.EncodeToString(make([]byte, 16)), videoPath)
+	if err != nil {
+		log.Printf("[HLS] Failed to mark lesson %s as pending: %v", lessonID, err)
+	}
+
+	payload := hlsJobPayload{LessonID: lessonID, VideoPath: videoPath}
+	enqueueJob(jobs.QueueVideo, JobProcessVideoHLS, payload, &jobs.EnqueueOptions{MaxAttempts: 3}, func() {
+		ProcessVideoToHLS(context.Background(), lessonID, videoPath)
+	})
+}
+
+// enqueueCourseAIProcessing queues embedding generation for a course
+// With force unset only lessons whose content changed are re-embedded
+func enqueueCourseAIProcessing(courseID, provider, statusID, apiKey, userID string, force bool) {
+	payload := courseAIJobPayload{CourseID: courseID, Provider: provider, StatusID: statusID, UserID: userID, Force: force}
+	enqueueJob(jobs.QueueAI, JobProcessCourseAI, payload, &jobs.EnqueueOptions{MaxAttempts: 3}, func() {
+		processCourseContentAsync(context.Background(), courseID, userID, apiKey, provider, statusID, force)
+	})
+}
+
+// enqueueQuizGrading queues AI grading of a submitted attempt's pending answers
+func enqueueQuizGrading(attemptID string) {
+	payload := quizGradingJobPayload{AttemptID: attemptID}
+	enqueueJob(jobs.QueueDefault, JobGradeQuizAttempt, payload, &jobs.EnqueueOptions{MaxAttempts: 3}, func() {
+		gradePendingAnswers(context.Background(), attemptID, true)
+	})
+}
+
+// enqueuePaymentFollowUp queues webinar registration and notifications after a course purchase
+func enqueuePaymentFollowUp(userID, courseID string) {
+	payload := followUpJobPayload{UserID: userID, CourseID: &courseID}
+	enqueueJob(jobs.QueueNotifications, JobPaymentFollowUp, payload, nil, func() {
+		handlePaymentSuccessNotification(userID, courseID)
+	})
+}
+
+// enqueueWebinarPaymentFollowUp queues registration and notification after a webinar-only purchase
+func enqueueWebinarPaymentFollowUp(userID, webinarID string) {
+	payload := followUpJobPayload{UserID: userID, WebinarID: &webinarID}
+	enqueueJob(jobs.QueueNotifications, JobWebinarPaymentFollowUp, payload, nil, func() {
+		handleWebinarPaymentSuccess(userID, webinarID)
+	})
+}
+
+// enqueueEcourseOnlyFollowUp queues the course access notification for e-course campaigns
+func enqueueEcourseOnlyFollowUp(userID, courseID string) {
+	payload := followUpJobPayload{UserID: userID, CourseID: &courseID}
+	enqueueJob(jobs.QueueNotifications, JobEcourseOnlyFollowUp, payload, nil, func() {
+		handleEcourseOnlyNotification(userID, courseID)
+	})
+}
+
+// enqueueWebinarOnlyFollowUp queues webinar registration and confirmation for webinar campaigns
+func enqueueWebinarOnlyFollowUp(userID string, webinarID, courseID *string) {
+	payload := followUpJobPayload{UserID: userID, CourseID: courseID, WebinarID: webinarID}
+	enqueueJob(jobs.QueueNotifications, JobWebinarOnlyFollowUp, payload, nil, func() {
+		handleWebinarOnlyNotificationDirect(userID, webinarID, courseID)
+	})
+}
+
+// ========================================
+// ADMIN JOB ENDPOINTS
+// ========================================
+
+// ListJobs lists background jobs with optional filters
+// GET /api/admin/jobs?queue=&status=&type=&limit=&offset=
+func ListJobs(c echo.Context) error {
+	queue := jobs.GetQueue()
+	if queue == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Job queue not initialized"})
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	filter := jobs.ListFilter{
+		Queue:  c.QueryParam("queue"),
+		Status: c.QueryParam("status"),
+		Type:   c.QueryParam("type"),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	list, total, err := queue.List(c.Request().Context(), filter)
+	if err != nil {
+		log.Printf("[ListJobs] Error: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch jobs"})
+	}
+
+	for i := range list {
+		list[i] = list[i].Redacted()
+	}
+
+	stats, err := queue.Stats(c.Request().Context())
+	if err != nil {
+		log.Printf("[ListJobs] Stats error: %v", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"jobs":   list,
+		"stats":  stats,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GetJob returns a single background job
+// GET /api/admin/jobs/:id
+func GetJob(c echo.Context) error {
+	queue := jobs.GetQueue()
+	if queue == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Job queue not initialized"})
+	}
+
+	job, err := queue.GetByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch job"})
+	}
+	if job == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Job not found"})
+	}
+
+	return c.JSON(http.StatusOK, job.Redacted())
+}
+
+// RetryJob puts a failed (dead) job back into its queue
+// POST /api/admin/jobs/:id/retry
+func RetryJob(c echo.Context) error {
+	queue := jobs.GetQueue()
+	if queue == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Job queue not initialized"})
+	}
+
+	existing, err := queue.GetByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		log.Printf("[RetryJob] Error: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retry job"})
+	}
+	if existing == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Job not found"})
+	}
+
+	job, err := queue.Retry(c.Request().Context(), existing.ID)
+	if err != nil {
+		log.Printf("[RetryJob] Error: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retry job"})
+	}
+	if job == nil {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Only dead jobs whose payload is still stored can be retried"})
+	}
+
+	return c.JSON(http.StatusOK, job.Redacted())
+}
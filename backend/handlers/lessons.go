@@ -133,6 +133,9 @@ func CreateLesson(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create lesson: " + err.Error()})
 	}
 
+	queueHLSProcessing(lesson, "")
+	queueVideoMetadata(lesson, "")
+
 	return c.JSON(http.StatusCreated, lesson)
 }
 
@@ -155,6 +158,12 @@ func UpdateLesson(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 	}
 
+	previousVideoURL := ""
+	if lesson.VideoURL != nil {
+		previousVideoURL = *lesson.VideoURL
+	}
+	previousSource := lessonSourceKey(lesson)
+
 	// Apply updates
 	if req.ParentID != nil {
 		lesson.ParentID = req.ParentID
@@ -192,6 +201,12 @@ func UpdateLesson(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update lesson"})
 	}
 
+	queueHLSProcessing(lesson, previousVideoURL)
+	queueVideoMetadata(lesson, previousVideoURL)
+	if lessonSourceKey(lesson) != previousSource {
+		queueCourseReindex(lesson.CourseID, getUserIDFromToken(c))
+	}
+
 	return c.JSON(http.StatusOK, lesson)
 }
 
@@ -321,6 +336,9 @@ func createLessonForCourse(c echo.Context, courseID string) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Gagal membuat materi: " + err.Error()})
 	}
 
+	queueHLSProcessing(lesson, "")
+	queueVideoMetadata(lesson, "")
+
 	// Update lessons_count in course
 	updateCourseLessonsCount(courseID)
 
@@ -344,6 +362,12 @@ func updateLessonByID(c echo.Context, lessonID string) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Format request tidak valid"})
 	}
 
+	previousVideoURL := ""
+	if lesson.VideoURL != nil {
+		previousVideoURL = *lesson.VideoURL
+	}
+	previousSource := lessonSourceKey(lesson)
+
 	// Apply updates
 	if req.ParentID != nil {
 		lesson.ParentID = req.ParentID
@@ -381,6 +405,12 @@ func updateLessonByID(c echo.Context, lessonID string) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Gagal update materi"})
 	}
 
+	queueHLSProcessing(lesson, previousVideoURL)
+	queueVideoMetadata(lesson, previousVideoURL)
+	if lessonSourceKey(lesson) != previousSource {
+		queueCourseReindex(lesson.CourseID, getUserIDFromToken(c))
+	}
+
 	return c.JSON(http.StatusOK, lesson)
 }
 
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/storage"
+)
+
+// ServeSignedObject serves a local storage object behind an HMAC-signed URL, standing
+// in for a MinIO/S3 pre-signed URL when STORAGE_DRIVER=local
+// GET /api/storage/:bucket/*
+func ServeSignedObject(c echo.Context) error {
+	local, ok := storage.GetStorage().(*storage.LocalStorage)
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Not found"})
+	}
+
+	bucket := c.Param("bucket")
+	objectName, err := url.PathUnescape(c.Param("*"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid object name"})
+	}
+
+	if err := local.VerifySignedURL(bucket, objectName, c.QueryParam("expires"), c.QueryParam("signature")); err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "URL " + err.Error()})
+	}
+
+	f, info, err := local.Open(bucket, objectName)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Content not found"})
+	}
+	defer f.Close()
+
+	// Same headers a MinIO pre-signed URL would produce; ServeContent handles range requests
+	c.Response().Header().Set("Content-Type", info.ContentType)
+	c.Response().Header().Set("Content-Disposition", "inline")
+	c.Response().Header().Set("Cache-Control", "private, no-store")
+	c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Response(), c.Request(), "", info.LastModified, f)
+	return nil
+}
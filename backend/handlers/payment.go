@@ -55,42 +55,77 @@ func InitPaymentProvider() {
 
 // initMidtransProvider initializes Midtrans payment provider
 func initMidtransProvider() {
+	provider := newMidtransProvider()
+	if provider == nil {
+		log.Println("Midtrans not configured: server key is empty")
+		return
+	}
+
+	paymentProvider = provider
+	log.Printf("Midtrans payment provider initialized (production: %v)", provider.IsProduction())
+}
+
+// initDuitkuProvider initializes Duitku payment provider
+func initDuitkuProvider() {
+	provider := newDuitkuProvider()
+	if provider == nil {
+		log.Println("Duitku not configured: merchant code or key is empty")
+		return
+	}
+
+	paymentProvider = provider
+	log.Printf("Duitku payment provider initialized (production: %v)", provider.IsProduction())
+}
+
+// newMidtransProvider builds the Midtrans provider from settings, or nil when unconfigured
+func newMidtransProvider() *payment.MidtransProvider {
 	serverKey := getSettingValue("payment_midtrans_server_key", "")
 	clientKey := getSettingValue("payment_midtrans_client_key", "")
 	isProduction := getSettingValue("payment_midtrans_is_production", "false") == "true"
 
 	if serverKey == "" {
-		log.Println("Midtrans not configured: server key is empty")
-		return
+		return nil
 	}
 
-	paymentProvider = payment.NewMidtransProvider(payment.MidtransConfig{
+	return payment.NewMidtransProvider(payment.MidtransConfig{
 		ServerKey:    serverKey,
 		ClientKey:    clientKey,
 		IsProduction: isProduction,
 	})
-
-	log.Printf("Midtrans payment provider initialized (production: %v)", isProduction)
 }
 
-// initDuitkuProvider initializes Duitku payment provider
-func initDuitkuProvider() {
+// newDuitkuProvider builds the Duitku provider from settings, or nil when unconfigured
+func newDuitkuProvider() *payment.DuitkuProvider {
 	merchantCode := getSettingValue("payment_duitku_merchant_code", "")
 	merchantKey := getSettingValue("payment_duitku_merchant_key", "")
 	isProduction := getSettingValue("payment_duitku_is_production", "false") == "true"
 
 	if merchantCode == "" || merchantKey == "" {
-		log.Println("Duitku not configured: merchant code or key is empty")
-		return
+		return nil
 	}
 
-	paymentProvider = payment.NewDuitkuProvider(payment.DuitkuConfig{
+	return payment.NewDuitkuProvider(payment.DuitkuConfig{
 		MerchantCode: merchantCode,
 		MerchantKey:  merchantKey,
 		IsProduction: isProduction,
 	})
+}
 
-	log.Printf("Duitku payment provider initialized (production: %v)", isProduction)
+// paymentProviderFor returns the provider a transaction was paid through, which may
+// differ from the provider currently selected for checkout. Returns nil when that
+// gateway isn't configured.
+func paymentProviderFor(gateway string) payment.PaymentProvider {
+	switch gateway {
+	case "midtrans":
+		if provider := newMidtransProvider(); provider != nil {
+			return provider
+		}
+	case "duitku":
+		if provider := newDuitkuProvider(); provider != nil {
+			return provider
+		}
+	}
+	return nil
 }
 
 // CheckoutRequest represents the checkout request body
@@ -364,8 +399,6 @@ func CreateCheckout(c echo.Context) error {
 // MidtransWebhook handles Midtrans payment notifications
 // POST /api/webhooks/midtrans
 func MidtransWebhook(c echo.Context) error {
-	initPaymentRepos()
-
 	// Read request body
 	body, err := io.ReadAll(c.Request().Body)
 	if err != nil {
@@ -375,89 +408,9 @@ func MidtransWebhook(c echo.Context) error {
 
 	log.Printf("[Midtrans Webhook] Received: %s", string(body))
 
-	// Initialize provider if needed
-	if paymentProvider == nil {
-		InitPaymentProvider()
-	}
-
-	if paymentProvider == nil {
-		log.Printf("[Midtrans Webhook] Payment provider not configured")
-		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Provider not configured"})
-	}
-
-	// Process notification
-	ctx := c.Request().Context()
-	result, err := paymentProvider.HandleNotification(ctx, body)
-	if err != nil {
-		log.Printf("[Midtrans Webhook] Failed to handle notification: %v", err)
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
-	}
-
-	log.Printf("[Midtrans Webhook] Processed: order_id=%s, status=%s, is_success=%v",
-		result.OrderID, result.TransactionStatus, result.IsSuccess)
-
-	// Get transaction by order ID
-	tx, err := paymentTxRepo.GetByOrderID(result.OrderID)
-	if err != nil || tx == nil {
-		log.Printf("[Midtrans Webhook] Transaction not found: %s", result.OrderID)
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Transaction not found"})
-	}
-
-	// Update transaction
-	paymentType := result.PaymentType
-	fraudStatus := result.FraudStatus
-	transactionID := result.TransactionID
-	
-	err = paymentTxRepo.UpdateFromCallback(
-		result.OrderID,
-		result.TransactionStatus,
-		&paymentType,
-		&fraudStatus,
-		result.TransactionTime,
-		result.SettlementTime,
-		&transactionID,
-	)
-	if err != nil {
-		log.Printf("[Midtrans Webhook] Failed to update transaction: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update transaction"})
-	}
-
-	// If payment successful, create enrollment and record coupon usage
-	if result.IsSuccess && tx.CourseID != nil {
-		// Check if already enrolled (idempotency)
-		enrolled, _ := enrollmentRepoCheckout.IsEnrolled(tx.UserID, *tx.CourseID)
-		if !enrolled {
-			enrollment := &postgres.Enrollment{
-				UserID:        tx.UserID,
-				CourseID:      *tx.CourseID,
-				TransactionID: &tx.ID,
-			}
-			if err := enrollmentRepoCheckout.Create(enrollment); err != nil {
-				log.Printf("[Midtrans Webhook] Failed to create enrollment: %v", err)
-			} else {
-				log.Printf("[Midtrans Webhook] Enrollment created for user %s, course %s", tx.UserID, *tx.CourseID)
-			}
-		}
-		
-		// Record coupon usage if a coupon was applied
-		if tx.CouponID != nil && tx.DiscountAmount != nil {
-			initCouponRepo()
-			usage := &domain.CouponUsage{
-				CouponID:        *tx.CouponID,
-				UserID:          tx.UserID,
-				TransactionID:   &tx.ID,
-				DiscountApplied: *tx.DiscountAmount,
-			}
-			if err := couponRepo.RecordUsage(usage); err != nil {
-				log.Printf("[Midtrans Webhook] Failed to record coupon usage: %v", err)
-			} else {
-				couponRepo.IncrementUsage(*tx.CouponID)
-				log.Printf("[Midtrans Webhook] Coupon usage recorded for coupon %s", *tx.CouponID)
-			}
-		}
-		
-		// Send WhatsApp notification (async)
-		go handlePaymentSuccessNotification(tx.UserID, *tx.CourseID)
+	status, message := receiveWebhook(c, "midtrans", body)
+	if status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": message})
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
@@ -466,8 +419,6 @@ func MidtransWebhook(c echo.Context) error {
 // DuitkuWebhook handles Duitku payment callback notifications
 // POST /api/webhooks/duitku
 func DuitkuWebhook(c echo.Context) error {
-	initPaymentRepos()
-
 	// Read request body
 	body, err := io.ReadAll(c.Request().Body)
 	if err != nil {
@@ -477,110 +428,83 @@ func DuitkuWebhook(c echo.Context) error {
 
 	log.Printf("[Duitku Webhook] Received: %s", string(body))
 
-	// Initialize provider if needed
-	if paymentProvider == nil {
-		InitPaymentProvider()
+	status, message := receiveWebhook(c, "duitku", body)
+	if status != http.StatusOK {
+		return c.String(status, message)
 	}
 
-	if paymentProvider == nil || paymentProvider.GetName() != "duitku" {
-		log.Printf("[Duitku Webhook] Payment provider not configured for Duitku")
-		return c.String(http.StatusServiceUnavailable, "Provider not configured")
-	}
-
-	// Process notification
-	ctx := c.Request().Context()
-	result, err := paymentProvider.HandleNotification(ctx, body)
-	if err != nil {
-		log.Printf("[Duitku Webhook] Failed to handle notification: %v", err)
-		return c.String(http.StatusBadRequest, err.Error())
-	}
-
-	log.Printf("[Duitku Webhook] Processed: order_id=%s, status=%s, is_success=%v",
-		result.OrderID, result.TransactionStatus, result.IsSuccess)
-
-	// Get transaction by order ID
-	tx, err := paymentTxRepo.GetByOrderID(result.OrderID)
-	if err != nil || tx == nil {
-		log.Printf("[Duitku Webhook] Transaction not found: %s", result.OrderID)
-		return c.String(http.StatusNotFound, "Transaction not found")
-	}
+	// Duitku expects "SUCCESS" response
+	return c.String(http.StatusOK, "SUCCESS")
+}
 
-	// Update transaction
-	paymentType := result.PaymentType
-	transactionID := result.TransactionID
+// fulfillPayment grants what a paid transaction bought: the course enrollment or
+// the webinar registration, with its WhatsApp follow-up, and records the coupon
+// usage. Webhooks and payment reconciliation share it. The enrollment, webinar
+// follow-up and coupon usage are recorded once per transaction however often it
+// runs.
+func fulfillPayment(tx *postgres.Transaction, logPrefix string) {
+	initPaymentRepos()
 
-	err = paymentTxRepo.UpdateFromCallback(
-		result.OrderID,
-		result.TransactionStatus,
-		&paymentType,
-		nil, // no fraud status for Duitku
-		result.TransactionTime,
-		result.SettlementTime,
-		&transactionID,
-	)
-	if err != nil {
-		log.Printf("[Duitku Webhook] Failed to update transaction: %v", err)
-		return c.String(http.StatusInternalServerError, "Failed to update transaction")
+	// Check for webinar_only via metadata
+	var metadata map[string]string
+	if len(tx.Metadata) > 0 {
+		json.Unmarshal(tx.Metadata, &metadata)
 	}
+	
+	webinarID := metadata["webinar_id"]
 
-	// If payment successful
-	if result.IsSuccess {
-		// Check for webinar_only via metadata
-		var metadata map[string]string
-		if len(tx.Metadata) > 0 {
-			json.Unmarshal(tx.Metadata, &metadata)
-		}
-		
-		webinarID := metadata["webinar_id"]
-
-		if tx.CourseID != nil {
-			// Normal course enrollment logic
-			enrolled, _ := enrollmentRepoCheckout.IsEnrolled(tx.UserID, *tx.CourseID)
-			if !enrolled {
-				enrollment := &postgres.Enrollment{
-					UserID:        tx.UserID,
-					CourseID:      *tx.CourseID,
-					TransactionID: &tx.ID,
-				}
-				if err := enrollmentRepoCheckout.Create(enrollment); err != nil {
-					log.Printf("[Duitku Webhook] Failed to create enrollment: %v", err)
-				} else {
-					log.Printf("[Duitku Webhook] Enrollment created for user %s, course %s", tx.UserID, *tx.CourseID)
-					
-					// Send payment success notification and handle webinar registration
-					go handlePaymentSuccessNotification(tx.UserID, *tx.CourseID)
-				}
-			}
-		} else if webinarID != "" {
-			// Webinar Only logic (no course enrollment)
-			log.Printf("[Duitku Webhook] Webinar Only payment for webinar %s", webinarID)
-			go handleWebinarPaymentSuccess(tx.UserID, webinarID)
-		}
-		
-		// Record coupon usage if a coupon was applied
-		if tx.CouponID != nil && tx.DiscountAmount != nil {
-			initCouponRepo()
-			usage := &domain.CouponUsage{
-				CouponID:        *tx.CouponID,
-				UserID:          tx.UserID,
-				TransactionID:   &tx.ID,
-				DiscountApplied: *tx.DiscountAmount,
+	if tx.CourseID != nil {
+		// Check if already enrolled (idempotency)
+		enrolled, _ := enrollmentRepoCheckout.IsEnrolled(tx.UserID, *tx.CourseID)
+		if !enrolled {
+			enrollment := &postgres.Enrollment{
+				UserID:        tx.UserID,
+				CourseID:      *tx.CourseID,
+				TransactionID: &tx.ID,
 			}
-			if err := couponRepo.RecordUsage(usage); err != nil {
-				log.Printf("[Duitku Webhook] Failed to record coupon usage: %v", err)
+			if err := enrollmentRepoCheckout.Create(enrollment); err != nil {
+				log.Printf("[%s] Failed to create enrollment: %v", logPrefix, err)
 			} else {
-				couponRepo.IncrementUsage(*tx.CouponID)
-				log.Printf("[Duitku Webhook] Coupon usage recorded for coupon %s", *tx.CouponID)
+				log.Printf("[%s] Enrollment created for user %s, course %s", logPrefix, tx.UserID, *tx.CourseID)
+				
+				// Send payment success notification and handle webinar registration
+				enqueuePaymentFollowUp(tx.UserID, *tx.CourseID)
 			}
 		}
+	} else if webinarID != "" {
+		// Webinar Only logic (no course enrollment)
+		// Nothing else marks the transaction as fulfilled, so record the follow-up
+		// on it (idempotency)
+		queued, err := paymentTxRepo.MarkWebinarFollowUp(tx.ID)
+		if err != nil {
+			log.Printf("[%s] Failed to mark webinar follow-up: %v", logPrefix, err)
+		} else if queued {
+			log.Printf("[%s] Webinar Only payment for webinar %s", logPrefix, webinarID)
+			enqueueWebinarPaymentFollowUp(tx.UserID, webinarID)
+		}
+	}
+	
+	// Record coupon usage if a coupon was applied. A transaction has at most one
+	// usage, so a repeated call doesn't count the coupon twice.
+	if tx.CouponID != nil && tx.DiscountAmount != nil {
+		initCouponRepo()
+		usage := &domain.CouponUsage{
+			CouponID:        *tx.CouponID,
+			UserID:          tx.UserID,
+			TransactionID:   &tx.ID,
+			DiscountApplied: *tx.DiscountAmount,
+		}
+		recorded, err := couponRepo.RecordUsageOnce(usage)
+		if err != nil {
+			log.Printf("[%s] Failed to record coupon usage: %v", logPrefix, err)
+		} else if recorded {
+			log.Printf("[%s] Coupon usage recorded for coupon %s", logPrefix, *tx.CouponID)
+		}
 	}
-
-	// Duitku expects "SUCCESS" response
-	return c.String(http.StatusOK, "SUCCESS")
 }
 
 // handlePaymentSuccessNotification handles post-payment notifications (Webinar or General)
-func handlePaymentSuccessNotification(userID, courseID string) {
+func handlePaymentSuccessNotification(userID, courseID string) error {
 	webinarRepo := postgres.NewWebinarRepository(db.DB)
 	userRepo := postgres.NewUserRepository(db.DB)
 	courseRepo := postgres.NewCourseRepository(db.DB)
@@ -589,7 +513,7 @@ func handlePaymentSuccessNotification(userID, courseID string) {
 	user, err := userRepo.GetByID(userID)
 	if err != nil || user == nil {
 		log.Printf("[Notification] Failed to get user %s: %v", userID, err)
-		return
+		return fmt.Errorf("failed to get user %s: %v", userID, err)
 	}
 	
 	log.Printf("[Notification] Processing for user %s (%s), Phone: %v", user.ID, user.FullName, user.Phone)
@@ -650,7 +574,7 @@ func handlePaymentSuccessNotification(userID, courseID string) {
 			service.SendWebinarConfirmationAsync(*user.Phone, data)
 			log.Printf("[Notification] Webinar confirmation sent to %s", *user.Phone)
 		}
-		return
+		return nil
 	}
 
 	// CASE B: Regular Course (No webinars) -> Send General Payment Success
@@ -658,17 +582,13 @@ func handlePaymentSuccessNotification(userID, courseID string) {
 		course, err := courseRepo.GetByID(courseID)
 		if err != nil || course == nil {
 			log.Printf("[Notification] Failed to get course %s: %v", courseID, err)
-			return
+			return fmt.Errorf("failed to get course %s: %v", courseID, err)
 		}
 
-		go func() {
-			if err := service.GetWhatsAppService().SendPaymentSuccess(*user.Phone, user.FullName, course.Title, lmsURL); err != nil {
-				log.Printf("[Notification] Failed to send payment success WA to %s: %v", *user.Phone, err)
-			} else {
-				log.Printf("[Notification] General payment success WA sent to %s", *user.Phone)
-			}
-		}()
+		service.SendPaymentSuccessAsync(*user.Phone, user.FullName, course.Title, lmsURL)
+		log.Printf("[Notification] General payment success WA queued for %s", *user.Phone)
 	}
+	return nil
 }
 
 // SimulatePaymentSuccess simulates a successful payment callback (FOR TESTING ONLY)
@@ -805,6 +725,11 @@ func GetPaymentSettings(c echo.Context) error {
 		"duitku_merchant_code":   getSettingValue("payment_duitku_merchant_code", ""),
 		"duitku_merchant_key":    maskString(getSettingValue("payment_duitku_merchant_key", "")),
 		"duitku_is_production":   getSettingValue("payment_duitku_is_production", "false") == "true",
+		// Refunds
+		"refund_enrollment_policy": getSettingValue("refund_enrollment_policy", refundPolicyRevoke),
+		// Reconciliation
+		"reconcile_enabled":           getSettingBool("payment_reconcile_enabled", true),
+		"pending_expiry_hours":        getSettingInt("payment_pending_expiry_hours", 24),
 	}
 
 	return c.JSON(http.StatusOK, settings)
@@ -824,6 +749,11 @@ func UpdatePaymentSettings(c echo.Context) error {
 		DuitkuMerchantCode    string `json:"duitku_merchant_code,omitempty"`
 		DuitkuMerchantKey     string `json:"duitku_merchant_key,omitempty"`
 		DuitkuIsProduction    *bool  `json:"duitku_is_production,omitempty"`
+		// Refunds
+		RefundEnrollmentPolicy string `json:"refund_enrollment_policy,omitempty"`
+		// Reconciliation
+		ReconcileEnabled      *bool  `json:"reconcile_enabled,omitempty"`
+		PendingExpiryHours    *int   `json:"pending_expiry_hours,omitempty"`
 	}
 
 	if err := c.Bind(&req); err != nil {
@@ -860,6 +790,25 @@ func UpdatePaymentSettings(c echo.Context) error {
 		setSettingValue("payment_duitku_is_production", fmt.Sprintf("%v", *req.DuitkuIsProduction))
 	}
 
+	// Refund settings
+	if req.RefundEnrollmentPolicy != "" {
+		if req.RefundEnrollmentPolicy != refundPolicyRevoke && req.RefundEnrollmentPolicy != refundPolicyRetain {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Refund enrollment policy must be revoke or retain"})
+		}
+		setSettingValue("refund_enrollment_policy", req.RefundEnrollmentPolicy)
+	}
+
+	// Reconciliation settings
+	if req.ReconcileEnabled != nil {
+		setSettingValue("payment_reconcile_enabled", fmt.Sprintf("%v", *req.ReconcileEnabled))
+	}
+	if req.PendingExpiryHours != nil {
+		if *req.PendingExpiryHours < 1 || *req.PendingExpiryHours > 720 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Pending expiry must be between 1 and 720 hours"})
+		}
+		setSettingValue("payment_pending_expiry_hours", strconv.Itoa(*req.PendingExpiryHours))
+	}
+
 	// Reinitialize payment provider
 	InitPaymentProvider()
 
@@ -970,7 +919,7 @@ func GetPaymentMethods(c echo.Context) error {
 	})
 }
 
-func handleWebinarPaymentSuccess(userID, webinarID string) {
+func handleWebinarPaymentSuccess(userID, webinarID string) error {
 	// Initialize repos
 	userRepo := postgres.NewUserRepository(db.DB)
 	webinarRepo := postgres.NewWebinarRepository(db.DB)
@@ -979,14 +928,14 @@ func handleWebinarPaymentSuccess(userID, webinarID string) {
 	user, err := userRepo.GetByID(userID)
 	if err != nil {
 		log.Printf("[WebinarOnlyPayment] Failed to get user: %v", err)
-		return
+		return fmt.Errorf("failed to get user %s: %w", userID, err)
 	}
 
 	// 2. Get Webinar
 	webinar, err := webinarRepo.GetByID(webinarID)
 	if err != nil {
 		log.Printf("[WebinarOnlyPayment] Failed to get webinar: %v", err)
-		return
+		return fmt.Errorf("failed to get webinar %s: %w", webinarID, err)
 	}
 
 	// 3. Register user to webinar
@@ -1024,4 +973,5 @@ func handleWebinarPaymentSuccess(userID, webinarID string) {
 		service.SendWebinarOnlyConfirmationAsync(*user.Phone, data)
 		log.Printf("[WebinarOnlyPayment] Confirmation sent to %s", *user.Phone)
 	}
+	return nil
 }
@@ -0,0 +1,410 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/payment"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
+)
+
+const (
+	reconcileBatchSize     = 200
+	reconcileMinAge        = 10 * time.Minute // Leave the webhook time to arrive
+	reconcileReportLimit   = 1000             // Settled payments re-checked per daily report
+	reconcileMaxReportRows = 200              // Discrepancies kept in a report
+	reconcileDateLayout    = "2006-01-02"
+)
+
+// reconcileMu keeps scheduled and manual runs from overlapping
+var reconcileMu sync.Mutex
+
+// ReconcileSummary counts the outcome of one reconciliation run
+type ReconcileSummary struct {
+	Checked    int `json:"checked"`
+	Settled    int `json:"settled"`
+	Failed     int `json:"failed"`
+	Expired    int `json:"expired"`
+	Unchanged  int `json:"unchanged"`
+	Mismatched int `json:"mismatched"` // Paid a different amount; left pending for manual review
+	Errors     int `json:"errors"`
+}
+
+// PaymentReportSummary is the content of a daily discrepancy report
+type PaymentReportSummary struct {
+	Date            string                            `json:"date"`
+	Actions         map[string]int                    `json:"actions"`
+	RecoveredAmount float64                           `json:"recovered_amount"` // Paid orders whose webhook was lost
+	Verified        int                               `json:"verified"`         // Settled payments re-checked at the gateway
+	Discrepancies   []*postgres.PaymentReconciliation `json:"discrepancies"`
+}
+
+// isReconcileDiscrepancy reports whether finance should look at an action.
+// Failed and expired orders are routine.
+func isReconcileDiscrepancy(action string) bool {
+	switch action {
+	case postgres.ReconcileSettled, postgres.ReconcileStatusMismatch, postgres.ReconcileAmountMismatch, postgres.ReconcileError:
+		return true
+	}
+	return false
+}
+
+// newReconciliation fills an entry from the transaction and the gateway status
+func newReconciliation(tx *postgres.Transaction, status *payment.TransactionStatus, action, note string) *postgres.PaymentReconciliation {
+	entry := &postgres.PaymentReconciliation{
+		TransactionID:  &tx.ID,
+		OrderID:        tx.OrderID,
+		PaymentGateway: optionalString(tx.PaymentGateway),
+		LocalStatus:    optionalString(tx.Status),
+		Action:         action,
+		LocalAmount:    &tx.Amount,
+		Note:           optionalString(note),
+	}
+	if status != nil {
+		entry.GatewayStatus = optionalString(status.TransactionStatus)
+		if status.GrossAmount > 0 {
+			entry.GatewayAmount = &status.GrossAmount
+		}
+	}
+	return entry
+}
+
+// amountMismatch reports whether the gateway charged a different amount
+func amountMismatch(tx *postgres.Transaction, status *payment.TransactionStatus) bool {
+	return status.GrossAmount > 0 && math.Abs(status.GrossAmount-tx.Amount) >= 1
+}
+
+// logReconciliation records an entry; failures are only logged
+func logReconciliation(entry *postgres.PaymentReconciliation) {
+	if err := paymentTxRepo.LogReconciliation(entry); err != nil {
+		log.Printf("[Reconcile] Failed to log %s for transaction %v: %v", entry.Action, entry.TransactionID, err)
+	}
+}
+
+// ReconcilePendingPayments checks pending orders against their payment gateway, so
+// a lost webhook doesn't leave a paid order pending. Paid orders go through the
+// webhook success path unless the gateway charged a different amount, which is
+// left pending for review; failed ones take the gateway status, and orders past
+// payment_pending_expiry_hours with no payment are expired.
+func ReconcilePendingPayments(ctx context.Context) {
+	if !getSettingBool("payment_reconcile_enabled", true) {
+		return
+	}
+	summary := reconcilePendingPayments(ctx)
+	if summary.Checked > 0 {
+		log.Printf("[Reconcile] Checked %d pending orders: %d settled, %d failed, %d expired, %d unchanged, %d amount mismatches, %d errors",
+			summary.Checked, summary.Settled, summary.Failed, summary.Expired, summary.Unchanged, summary.Mismatched, summary.Errors)
+	}
+}
+
+func reconcilePendingPayments(ctx context.Context) ReconcileSummary {
+	initPaymentRepos()
+
+	var summary ReconcileSummary
+	if !reconcileMu.TryLock() {
+		log.Println("[Reconcile] A reconciliation run is already in progress")
+		return summary
+	}
+	defer reconcileMu.Unlock()
+
+	pending, err := paymentTxRepo.ListPendingForReconcile(time.Now().Add(-reconcileMinAge), reconcileBatchSize)
+	if err != nil {
+		log.Printf("[Reconcile] Failed to list pending transactions: %v", err)
+		return summary
+	}
+
+	expiry := time.Duration(getSettingInt("payment_pending_expiry_hours", 24)) * time.Hour
+	providers := make(map[string]payment.PaymentProvider)
+
+	for _, tx := range pending {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := paymentTxRepo.MarkReconcileChecked(tx.ID); err != nil {
+			log.Printf("[Reconcile] Failed to mark order %s as checked: %v", *tx.OrderID, err)
+		}
+
+		provider, ok := providers[tx.PaymentGateway]
+		if !ok {
+			provider = paymentProviderFor(tx.PaymentGateway)
+			providers[tx.PaymentGateway] = provider
+		}
+		if provider == nil {
+			// Gateway no longer configured; nothing to ask
+			continue
+		}
+
+		summary.Checked++
+		switch reconcileTransaction(ctx, provider, tx, expiry) {
+		case postgres.ReconcileSettled:
+			summary.Settled++
+		case postgres.ReconcileFailed:
+			summary.Failed++
+		case postgres.ReconcileExpired:
+			summary.Expired++
+		case postgres.ReconcileAmountMismatch:
+			summary.Mismatched++
+		case postgres.ReconcileError:
+			summary.Errors++
+		default:
+			summary.Unchanged++
+		}
+	}
+
+	return summary
+}
+
+// reconcileTransaction brings one pending transaction in line with the gateway and
+// returns the action taken, or "" when it stays pending unremarked
+func reconcileTransaction(ctx context.Context, provider payment.PaymentProvider, tx *postgres.Transaction, expiry time.Duration) string {
+	status, err := provider.GetTransactionStatus(ctx, *tx.OrderID)
+	if err != nil {
+		// Never expire an order we couldn't check; it may have been paid
+		log.Printf("[Reconcile] Failed to get status of order %s: %v", *tx.OrderID, err)
+		logReconciliation(newReconciliation(tx, nil, postgres.ReconcileError, err.Error()))
+		return postgres.ReconcileError
+	}
+
+	now := time.Now()
+	switch {
+	case payment.IsSuccessStatus(status.TransactionStatus):
+		if amountMismatch(tx, status) {
+			// Don't grant anything for a payment of the wrong amount: the order
+			// stays pending and is logged for finance to settle or refund by hand
+			log.Printf("[Reconcile] Order %s was paid %.0f at the gateway instead of %.0f; left for review", *tx.OrderID, status.GrossAmount, tx.Amount)
+			logReconciliation(newReconciliation(tx, status, postgres.ReconcileAmountMismatch, "Paid at the gateway with a different amount; not fulfilled"))
+			return postgres.ReconcileAmountMismatch
+		}
+
+		resolved, err := paymentTxRepo.ResolvePending(tx.ID, status.TransactionStatus,
+			optionalString(status.PaymentType), optionalString(status.TransactionID), &now)
+		if err != nil {
+			log.Printf("[Reconcile] Failed to settle order %s: %v", *tx.OrderID, err)
+			return postgres.ReconcileError
+		}
+		if !resolved {
+			return "" // The webhook got there first
+		}
+
+		log.Printf("[Reconcile] Order %s was paid but its webhook never arrived", *tx.OrderID)
+		fulfillPayment(tx, "Reconcile")
+		logReconciliation(newReconciliation(tx, status, postgres.ReconcileSettled, "Paid at the gateway; webhook not received"))
+		return postgres.ReconcileSettled
+
+	case payment.IsFailedStatus(status.TransactionStatus):
+		resolved, err := paymentTxRepo.ResolvePending(tx.ID, status.TransactionStatus, optionalString(status.PaymentType), nil, nil)
+		if err != nil || !resolved {
+			return ""
+		}
+		logReconciliation(newReconciliation(tx, status, postgres.ReconcileFailed, ""))
+		return postgres.ReconcileFailed
+	}
+
+	// Still pending, or the gateway has never seen the order
+	stale := tx.CreatedAt.Before(now.Add(-expiry)) || (tx.ExpiredAt != nil && tx.ExpiredAt.Before(now))
+	if !stale {
+		return ""
+	}
+
+	resolved, err := paymentTxRepo.ResolvePending(tx.ID, "expire", nil, nil, nil)
+	if err != nil || !resolved {
+		return ""
+	}
+	logReconciliation(newReconciliation(tx, status, postgres.ReconcileExpired, "No payment before the pending window closed"))
+	return postgres.ReconcileExpired
+}
+
+// WriteDailyPaymentReport writes yesterday's discrepancy report unless a complete one
+// exists. A report refreshed from the admin panel during the day is incomplete and
+// gets replaced.
+func WriteDailyPaymentReport(ctx context.Context) {
+	if !getSettingBool("payment_reconcile_enabled", true) {
+		return
+	}
+	initPaymentRepos()
+
+	now := time.Now()
+	day := now.AddDate(0, 0, -1)
+	existing, err := paymentTxRepo.GetReconciliationReport(day.Format(reconcileDateLayout))
+	if err != nil {
+		return
+	}
+	endOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if existing != nil && !existing.CreatedAt.Before(endOfDay) {
+		return
+	}
+
+	report, err := buildPaymentReport(ctx, day)
+	if err != nil {
+		log.Printf("[Reconcile] Failed to write report for %s: %v", day.Format(reconcileDateLayout), err)
+		return
+	}
+	log.Printf("[Reconcile] Report for %s written with %d discrepancies", report.ReportDate, report.DiscrepancyCount)
+}
+
+// buildPaymentReport summarizes a day's reconciliation and re-checks that payments
+// settled that day are still paid at the gateway, then stores the report
+func buildPaymentReport(ctx context.Context, day time.Time) (*postgres.PaymentReconciliationReport, error) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	to := from.AddDate(0, 0, 1)
+
+	summary := PaymentReportSummary{
+		Date:          from.Format(reconcileDateLayout),
+		Actions:       make(map[string]int),
+		Discrepancies: []*postgres.PaymentReconciliation{},
+	}
+
+	entries, err := paymentTxRepo.ListReconciliations(from, to)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		// An order the gateway can't answer for is retried every run; count it once
+		if entry.TransactionID != nil {
+			key := *entry.TransactionID + "/" + entry.Action
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+
+		summary.Actions[entry.Action]++
+		if entry.Action == postgres.ReconcileSettled && entry.LocalAmount != nil {
+			summary.RecoveredAmount += *entry.LocalAmount
+		}
+		if isReconcileDiscrepancy(entry.Action) {
+			summary.Discrepancies = append(summary.Discrepancies, entry)
+		}
+	}
+
+	// Findings of the re-check only go into the report, so they aren't counted
+	// again in the report of the day they were found
+	settled, err := paymentTxRepo.ListSettledBetween(from, to, reconcileReportLimit)
+	if err != nil {
+		return nil, err
+	}
+	for _, tx := range settled {
+		if ctx.Err() != nil {
+			break
+		}
+		provider := paymentProviderFor(tx.PaymentGateway)
+		if provider == nil {
+			continue
+		}
+
+		summary.Verified++
+		status, err := provider.GetTransactionStatus(ctx, *tx.OrderID)
+		var finding *postgres.PaymentReconciliation
+		switch {
+		case err != nil:
+			finding = newReconciliation(tx, nil, postgres.ReconcileError, err.Error())
+		case !payment.IsSuccessStatus(status.TransactionStatus) && status.TransactionStatus != "refund":
+			finding = newReconciliation(tx, status, postgres.ReconcileStatusMismatch, "Paid in the LMS but not at the gateway")
+		case amountMismatch(tx, status):
+			finding = newReconciliation(tx, status, postgres.ReconcileAmountMismatch, "Gateway amount differs from the order amount")
+		}
+		if finding != nil {
+			finding.CreatedAt = time.Now()
+			summary.Actions[finding.Action]++
+			summary.Discrepancies = append(summary.Discrepancies, finding)
+		}
+	}
+
+	discrepancies := len(summary.Discrepancies)
+	if len(summary.Discrepancies) > reconcileMaxReportRows {
+		summary.Discrepancies = summary.Discrepancies[:reconcileMaxReportRows]
+	}
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &postgres.PaymentReconciliationReport{
+		ReportDate:       summary.Date,
+		Summary:          data,
+		DiscrepancyCount: discrepancies,
+	}
+	if err := paymentTxRepo.SaveReconciliationReport(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// RunPaymentReconciliation reconciles pending payments now
+// POST /api/admin/payment/reconcile
+func RunPaymentReconciliation(c echo.Context) error {
+	summary := reconcilePendingPayments(c.Request().Context())
+	return c.JSON(http.StatusOK, summary)
+}
+
+// ListPaymentReconciliationReports returns the daily discrepancy reports
+// GET /api/admin/payment/reconcile/reports?limit=&offset=
+func ListPaymentReconciliationReports(c echo.Context) error {
+	initPaymentRepos()
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 30
+	}
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	reports, total, err := paymentTxRepo.ListReconciliationReports(limit, offset)
+	if err != nil {
+		log.Printf("[Reconcile] Failed to list reports: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch reports"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"reports": reports,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// GetPaymentReconciliationReport returns the report for a day. With ?refresh=true,
+// or for today, the report is rebuilt first.
+// GET /api/admin/payment/reconcile/reports/:date
+func GetPaymentReconciliationReport(c echo.Context) error {
+	initPaymentRepos()
+
+	day, err := time.ParseInLocation(reconcileDateLayout, c.Param("date"), time.Local)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Date must be YYYY-MM-DD"})
+	}
+	if day.After(time.Now()) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Date is in the future"})
+	}
+
+	date := day.Format(reconcileDateLayout)
+	if c.QueryParam("refresh") == "true" || date == time.Now().Format(reconcileDateLayout) {
+		report, err := buildPaymentReport(c.Request().Context(), day)
+		if err != nil {
+			log.Printf("[Reconcile] Failed to build report for %s: %v", date, err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to build report"})
+		}
+		return c.JSON(http.StatusOK, report)
+	}
+
+	report, err := paymentTxRepo.GetReconciliationReport(date)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch report"})
+	}
+	if report == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Report not found"})
+	}
+	return c.JSON(http.StatusOK, report)
+}
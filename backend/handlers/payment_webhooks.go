@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/payment"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
+)
+
+// errWebhookProviderNotConfigured is returned when a webhook arrives for a gateway
+// that has no credentials configured
+var errWebhookProviderNotConfigured = errors.New("provider not configured")
+
+// webhookLogPrefixes are the log prefixes of each gateway's webhook
+var webhookLogPrefixes = map[string]string{
+	"midtrans": "Midtrans Webhook",
+	"duitku":   "Duitku Webhook",
+}
+
+// redactedWebhookHeaders are not stored with webhook events
+var redactedWebhookHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+}
+
+// receiveWebhook stores a gateway notification and processes it, unless the same
+// event was already handled. It returns the HTTP status and message to answer the
+// gateway with; gateways retry notifications that aren't answered with 200.
+// The endpoint is public, so notifications that fail signature verification or
+// can't be parsed are only logged, never stored.
+func receiveWebhook(c echo.Context, provider string, body []byte) (int, string) {
+	initPaymentRepos()
+	logPrefix := webhookLogPrefixes[provider]
+
+	result, err := parseWebhook(c.Request().Context(), provider, body)
+	switch {
+	case errors.Is(err, errWebhookProviderNotConfigured):
+		log.Printf("[%s] Payment provider not configured", logPrefix)
+		return http.StatusServiceUnavailable, "Provider not configured"
+	case err != nil:
+		log.Printf("[%s] Rejected notification from %s: %v", logPrefix, c.RealIP(), err)
+		return http.StatusBadRequest, err.Error()
+	}
+
+	event := &postgres.WebhookEvent{
+		Provider:      provider,
+		EventKey:      webhookEventKey(body, result),
+		Headers:       webhookHeaders(c.Request().Header),
+		Body:          string(body),
+		OrderID:       &result.OrderID,
+		GatewayStatus: &result.TransactionStatus,
+	}
+
+	inserted, err := paymentTxRepo.RecordWebhookEvent(event)
+	if err != nil {
+		log.Printf("[%s] Failed to store event: %v", logPrefix, err)
+		return http.StatusInternalServerError, "Failed to store event"
+	}
+	if !inserted {
+		log.Printf("[%s] Duplicate event %s (%s)", logPrefix, event.ID, event.Status)
+	}
+
+	claimed, err := paymentTxRepo.ClaimWebhookEvent(event.ID, false)
+	if err != nil {
+		log.Printf("[%s] Failed to claim event %s: %v", logPrefix, event.ID, err)
+		return http.StatusInternalServerError, "Failed to store event"
+	}
+	if !claimed {
+		// Already handled, or being handled by a concurrent delivery whose claim
+		// hasn't expired yet
+		switch event.Status {
+		case postgres.WebhookProcessing:
+			return http.StatusConflict, "Event is being processed"
+		case postgres.WebhookRejected:
+			return http.StatusBadRequest, webhookEventError(event)
+		}
+		return http.StatusOK, ""
+	}
+
+	return processWebhookEvent(event, result, nil)
+}
+
+// parseWebhook verifies and parses a notification with its gateway
+func parseWebhook(ctx context.Context, provider string, body []byte) (*payment.NotificationResult, error) {
+	gateway := paymentProviderFor(provider)
+	if gateway == nil {
+		return nil, errWebhookProviderNotConfigured
+	}
+	return gateway.HandleNotification(ctx, body)
+}
+
+// webhookEventKey identifies a gateway event, so a retried notification is
+// recognized. Gateways don't send an event ID, but an order only reaches each
+// status once per gateway transaction. Refund notifications repeat the status for
+// every partial refund, so the body tells them apart.
+func webhookEventKey(body []byte, result *payment.NotificationResult) string {
+	sum := sha256.Sum256(body)
+	bodyHash := hex.EncodeToString(sum[:])
+
+	key := strings.Join([]string{result.OrderID, result.TransactionID, result.TransactionStatus, result.FraudStatus}, ":")
+	if result.TransactionStatus == "refund" {
+		key += ":" + bodyHash[:16]
+	}
+	if len(key) > 255 {
+		key = "body:" + bodyHash
+	}
+	return key
+}
+
+// webhookHeaders returns the request headers to store with an event
+func webhookHeaders(header http.Header) json.RawMessage {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		if redactedWebhookHeaders[name] {
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+
+	data, err := json.Marshal(headers)
+	if err != nil {
+		return json.RawMessage("{}")
+	}
+	return data
+}
+
+// webhookEventError returns the stored error of an event
+func webhookEventError(event *postgres.WebhookEvent) string {
+	if event.Error != nil {
+		return *event.Error
+	}
+	return event.Status
+}
+
+// processWebhookEvent applies a claimed event to its transaction through the
+// transaction status state machine, records the outcome on the event, and returns
+// the HTTP status and message for the gateway. Webhook deliveries and admin
+// replays both go through here.
+func processWebhookEvent(event *postgres.WebhookEvent, result *payment.NotificationResult, parseErr error) (int, string) {
+	logPrefix := webhookLogPrefixes[event.Provider]
+
+	finish := func(status string, httpStatus int, message string) (int, string) {
+		event.Status = status
+		event.Error = nil
+		if message != "" {
+			event.Error = &message
+		}
+		if err := paymentTxRepo.FinishWebhookEvent(event); err != nil {
+			log.Printf("[%s] Failed to record outcome of event %s: %v", logPrefix, event.ID, err)
+		}
+		return httpStatus, message
+	}
+
+	event.SignatureValid = nil
+	event.FromStatus = nil
+	event.ToStatus = nil
+
+	switch {
+	case errors.Is(parseErr, errWebhookProviderNotConfigured):
+		log.Printf("[%s] Payment provider not configured", logPrefix)
+		return finish(postgres.WebhookFailed, http.StatusServiceUnavailable, "Provider not configured")
+	case errors.Is(parseErr, payment.ErrInvalidSignature):
+		signatureValid := false
+		event.SignatureValid = &signatureValid
+		log.Printf("[%s] Failed to handle notification: %v", logPrefix, parseErr)
+		return finish(postgres.WebhookRejected, http.StatusBadRequest, parseErr.Error())
+	case parseErr != nil:
+		log.Printf("[%s] Failed to handle notification: %v", logPrefix, parseErr)
+		return finish(postgres.WebhookRejected, http.StatusBadRequest, parseErr.Error())
+	}
+
+	signatureValid := true
+	event.SignatureValid = &signatureValid
+	event.OrderID = &result.OrderID
+	event.GatewayStatus = &result.TransactionStatus
+
+	log.Printf("[%s] Processed: order_id=%s, status=%s, is_success=%v",
+		logPrefix, result.OrderID, result.TransactionStatus, result.IsSuccess)
+
+	// Get transaction by order ID
+	tx, err := paymentTxRepo.GetByOrderID(result.OrderID)
+	if err != nil || tx == nil {
+		log.Printf("[%s] Transaction not found: %s", logPrefix, result.OrderID)
+		return finish(postgres.WebhookFailed, http.StatusNotFound, "Transaction not found")
+	}
+	event.TransactionID = &tx.ID
+
+	// Midtrans reports full and partial refunds alike as "refund"; refunds issued
+	// from the admin panel are already in the ledger and keep the status set there.
+	fromStatus := tx.Status
+	toStatus := result.TransactionStatus
+	if toStatus == "refund" && tx.RefundedAmount > 0 {
+		toStatus = tx.Status
+	}
+	event.FromStatus = &fromStatus
+	event.ToStatus = &toStatus
+
+	if !payment.CanTransition(fromStatus, toStatus) {
+		// Acknowledged so the gateway stops retrying; the event stays replayable
+		log.Printf("[%s] Ignored illegal transition %s -> %s for order %s", logPrefix, fromStatus, toStatus, result.OrderID)
+		return finish(postgres.WebhookIgnored, http.StatusOK, fmt.Sprintf("Illegal status transition %s -> %s", fromStatus, toStatus))
+	}
+
+	if toStatus != fromStatus {
+		var fraudStatus *string
+		if event.Provider == "midtrans" {
+			fraudStatus = &result.FraudStatus
+		}
+		paymentType := result.PaymentType
+		transactionID := result.TransactionID
+
+		applied, err := paymentTxRepo.TransitionFromCallback(
+			result.OrderID,
+			fromStatus,
+			toStatus,
+			&paymentType,
+			fraudStatus,
+			result.TransactionTime,
+			result.SettlementTime,
+			&transactionID,
+		)
+		if err != nil {
+			log.Printf("[%s] Failed to update transaction: %v", logPrefix, err)
+			return finish(postgres.WebhookFailed, http.StatusInternalServerError, "Failed to update transaction")
+		}
+		if !applied {
+			return finish(postgres.WebhookFailed, http.StatusConflict, "Transaction status changed during processing")
+		}
+		tx.Status = toStatus
+	}
+
+	// If payment successful, create enrollment and record coupon usage. Fulfillment
+	// is idempotent, so a replay also repairs a fulfillment that failed before.
+	if result.IsSuccess && tx.Status == result.TransactionStatus {
+		fulfillPayment(tx, logPrefix)
+	}
+
+	return finish(postgres.WebhookProcessed, http.StatusOK, "")
+}
+
+// ListWebhookEvents lists stored payment webhook events
+// GET /api/admin/payment/webhooks
+func ListWebhookEvents(c echo.Context) error {
+	initPaymentRepos()
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	filter := postgres.WebhookEventFilter{
+		Provider: c.QueryParam("provider"),
+		Status:   c.QueryParam("status"),
+		OrderID:  strings.TrimSpace(c.QueryParam("order_id")),
+	}
+
+	events, total, err := paymentTxRepo.ListWebhookEvents(filter, limit, offset)
+	if err != nil {
+		log.Printf("[Webhook Events] Failed to list events: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch webhook events"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"events": events,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GetWebhookEvent returns a stored payment webhook event with its raw payload
+// GET /api/admin/payment/webhooks/:id
+func GetWebhookEvent(c echo.Context) error {
+	initPaymentRepos()
+
+	event, err := paymentTxRepo.GetWebhookEvent(c.Param("id"))
+	if err != nil {
+		log.Printf("[Webhook Events] Failed to get event: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch webhook event"})
+	}
+	if event == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Webhook event not found"})
+	}
+
+	return c.JSON(http.StatusOK, event)
+}
+
+// ReplayWebhookEvent processes a stored webhook event again. The status state
+// machine keeps a replay from undoing later progress, and an event that a
+// delivery is still processing can't be replayed alongside it.
+// POST /api/admin/payment/webhooks/:id/replay
+func ReplayWebhookEvent(c echo.Context) error {
+	initPaymentRepos()
+
+	event, err := paymentTxRepo.GetWebhookEvent(c.Param("id"))
+	if err != nil {
+		log.Printf("[Webhook Events] Failed to get event: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch webhook event"})
+	}
+	if event == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Webhook event not found"})
+	}
+
+	claimed, err := paymentTxRepo.ClaimWebhookEvent(event.ID, true)
+	if err != nil {
+		log.Printf("[Webhook Events] Failed to claim event %s: %v", event.ID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to replay webhook event"})
+	}
+	if !claimed {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Webhook event is being processed"})
+	}
+
+	log.Printf("[Webhook Events] Replaying event %s (%s)", event.ID, event.Provider)
+	result, parseErr := parseWebhook(c.Request().Context(), event.Provider, []byte(event.Body))
+	processWebhookEvent(event, result, parseErr)
+
+	replayed, err := paymentTxRepo.GetWebhookEvent(event.ID)
+	if err != nil || replayed == nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch webhook event"})
+	}
+
+	return c.JSON(http.StatusOK, replayed)
+}
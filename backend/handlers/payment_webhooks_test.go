@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/payment"
+)
+
+func TestWebhookEventKey(t *testing.T) {
+	settlement := &payment.NotificationResult{OrderID: "ORD-1", TransactionID: "trx-1", TransactionStatus: "settlement", FraudStatus: "accept"}
+
+	t.Run("retried notification has the same key", func(t *testing.T) {
+		first := webhookEventKey([]byte(`{"transaction_status":"settlement"}`), settlement)
+		retry := webhookEventKey([]byte(`{"transaction_status":"settlement","signature_key":"x"}`), settlement)
+		if first != retry {
+			t.Errorf("keys differ for the same event: %q, %q", first, retry)
+		}
+		if first != "ORD-1:trx-1:settlement:accept" {
+			t.Errorf("key = %q", first)
+		}
+	})
+
+	t.Run("status change has a new key", func(t *testing.T) {
+		pending := *settlement
+		pending.TransactionStatus = "pending"
+		body := []byte(`{}`)
+		if webhookEventKey(body, settlement) == webhookEventKey(body, &pending) {
+			t.Error("pending and settlement share a key")
+		}
+	})
+
+	t.Run("partial refunds are told apart by body", func(t *testing.T) {
+		refund := *settlement
+		refund.TransactionStatus = "refund"
+		first := webhookEventKey([]byte(`{"refund_amount":"50000"}`), &refund)
+		second := webhookEventKey([]byte(`{"refund_amount":"25000"}`), &refund)
+		if first == second {
+			t.Errorf("two refunds share key %q", first)
+		}
+		if again := webhookEventKey([]byte(`{"refund_amount":"50000"}`), &refund); again != first {
+			t.Errorf("retried refund key = %q, want %q", again, first)
+		}
+	})
+
+	t.Run("overlong key falls back to body hash", func(t *testing.T) {
+		long := &payment.NotificationResult{OrderID: strings.Repeat("x", 300), TransactionStatus: "settlement"}
+		key := webhookEventKey([]byte(`{}`), long)
+		if !strings.HasPrefix(key, "body:") || len(key) > 255 {
+			t.Errorf("key = %q", key)
+		}
+	})
+}
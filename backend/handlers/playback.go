@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/domain"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/middleware"
+)
+
+// deviceIDHeader carries a stable per-install identifier generated by the player
+const deviceIDHeader = "X-Device-ID"
+
+// playbackSessionHeader carries the session the player got from StartPlayback
+const playbackSessionHeader = "X-Playback-Session"
+
+// deviceFingerprint derives a device fingerprint from request headers
+func deviceFingerprint(c echo.Context) string {
+	req := c.Request()
+	sum := sha256.Sum256([]byte(req.Header.Get(deviceIDHeader) + "|" +
+		req.UserAgent() + "|" +
+		req.Header.Get("Accept-Language")))
+	return hex.EncodeToString(sum[:])
+}
+
+// deviceLabel is a human-readable device description for the admin view
+func deviceLabel(c echo.Context) string {
+	ua := c.Request().UserAgent()
+	if ua == "" {
+		return "Unknown device"
+	}
+	if len(ua) > 255 {
+		ua = ua[:255]
+	}
+	return ua
+}
+
+// playbackLimitsFor returns the effective limits for a user: the tenant's
+// feature_config overrides the platform-wide settings
+func playbackLimitsFor(repo *postgres.PlaybackRepository, userID string) domain.PlaybackLimits {
+	limits := domain.PlaybackLimits{
+		MaxConcurrentStreams: getSettingInt("playback_max_concurrent_streams", 2),
+		MaxDevices:           getSettingInt("playback_max_devices", 3),
+		SessionTimeout:       time.Duration(getSettingInt("playback_session_timeout_seconds", 90)) * time.Second,
+	}
+
+	maxStreams, maxDevices, err := repo.GetTenantLimits(userID)
+	if err != nil {
+		log.Printf("[Playback] Failed to load tenant limits for user %s: %v", userID, err)
+		return limits
+	}
+	if maxStreams != nil {
+		limits.MaxConcurrentStreams = *maxStreams
+	}
+	if maxDevices != nil {
+		limits.MaxDevices = *maxDevices
+	}
+	return limits
+}
+
+// deviceInactiveAfter is how long an unused device keeps occupying a device slot
+func deviceInactiveAfter() time.Duration {
+	return time.Duration(getSettingInt("playback_device_inactive_days", 30)) * 24 * time.Hour
+}
+
+// startPlaybackSession registers the requesting device and starts or resumes its session.
+// Admins are not subject to playback limits and get a nil session.
+func startPlaybackSession(c echo.Context, userID, role, lessonID string) (*domain.PlaybackSession, domain.PlaybackLimits, error) {
+	repo := postgres.NewPlaybackRepository(db.DB)
+	limits := playbackLimitsFor(repo, userID)
+	if role == "admin" {
+		return nil, limits, nil
+	}
+
+	session, err := repo.StartSession(userID, lessonID, deviceFingerprint(c), deviceLabel(c), c.RealIP(), limits, deviceInactiveAfter())
+	return session, limits, err
+}
+
+// requirePlaybackSession checks that a content request belongs to an active playback
+// session of the requesting device, started with StartPlayback and kept alive by
+// heartbeats, and counts the request as activity. Admins are not subject to
+// playback limits.
+func requirePlaybackSession(c echo.Context, userID, role, lessonID string) (domain.PlaybackLimits, error) {
+	repo := postgres.NewPlaybackRepository(db.DB)
+	limits := playbackLimitsFor(repo, userID)
+	if role == "admin" {
+		return limits, nil
+	}
+
+	sessionID := c.Request().Header.Get(playbackSessionHeader)
+	if sessionID == "" {
+		return limits, domain.ErrPlaybackSessionRequired
+	}
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return limits, domain.ErrPlaybackSessionEnded
+	}
+
+	_, err := repo.TouchSession(sessionID, userID, deviceFingerprint(c), lessonID, limits.SessionTimeout)
+	return limits, err
+}
+
+// playbackErrorResponse converts a playback limit error into a 409 response, or a 500 otherwise
+func playbackErrorResponse(c echo.Context, userID string, limits domain.PlaybackLimits, err error) error {
+	switch err {
+	case domain.ErrPlaybackDeviceLimit:
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":       "This account has reached its limit of registered devices. Ask an administrator to remove an old device.",
+			"code":        "device_limit",
+			"max_devices": limits.MaxDevices,
+		})
+	case domain.ErrPlaybackStreamLimit:
+		resp := map[string]interface{}{
+			"error":                  "This account is already playing on the maximum number of devices. Stop playback on another device and try again.",
+			"code":                   "stream_limit",
+			"max_concurrent_streams": limits.MaxConcurrentStreams,
+		}
+		repo := postgres.NewPlaybackRepository(db.DB)
+		if sessions, err := repo.ListActiveSessions(userID, limits.SessionTimeout); err == nil {
+			resp["active_sessions"] = sessions
+		}
+		return c.JSON(http.StatusConflict, resp)
+	case domain.ErrPlaybackSessionRequired:
+		return c.JSON(http.StatusPreconditionRequired, map[string]string{
+			"error": "Start a playback session before requesting the video.",
+			"code":  "session_required",
+		})
+	case domain.ErrPlaybackSessionEnded:
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "Playback session has ended. Start playback again.",
+			"code":  "session_ended",
+		})
+	}
+
+	log.Printf("[Playback] Session error for user %s: %v", userID, err)
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start playback session"})
+}
+
+// StartPlayback starts (or resumes) a playback session for the requesting device
+// POST /api/content/:lessonId/playback/start
+func StartPlayback(c echo.Context) error {
+	lessonID := c.Param("lessonId")
+	if lessonID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Lesson ID is required"})
+	}
+
+	userID, role, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	if err := verifyContentAccess(userID, role, lessonID); err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+
+	session, limits, err := startPlaybackSession(c, userID, role, lessonID)
+	if err != nil {
+		return playbackErrorResponse(c, userID, limits, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"session":                    session,
+		"limits":                     limits,
+		"heartbeat_interval_seconds": int(limits.SessionTimeout.Seconds() / 3),
+	})
+}
+
+// PlaybackHeartbeat keeps a playback session alive
+// POST /api/content/playback/:sessionId/heartbeat
+func PlaybackHeartbeat(c echo.Context) error {
+	sessionID := c.Param("sessionId")
+
+	userID, _, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	repo := postgres.NewPlaybackRepository(db.DB)
+	limits := playbackLimitsFor(repo, userID)
+	session, err := repo.Heartbeat(sessionID, userID, limits.SessionTimeout)
+	if err != nil {
+		return playbackErrorResponse(c, userID, limits, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"session": session})
+}
+
+// EndPlayback ends a playback session, freeing its stream slot immediately
+// POST /api/content/playback/:sessionId/end
+func EndPlayback(c echo.Context) error {
+	sessionID := c.Param("sessionId")
+
+	userID, _, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	repo := postgres.NewPlaybackRepository(db.DB)
+	if err := repo.EndSession(sessionID, userID); err != nil {
+		log.Printf("[Playback] Failed to end session %s: %v", sessionID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to end playback session"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Playback session ended"})
+}
+
+// AdminListUserDevices lists a user's registered devices and active playback sessions
+// GET /api/admin/users/:id/devices
+func AdminListUserDevices(c echo.Context) error {
+	userID := c.Param("id")
+
+	repo := postgres.NewPlaybackRepository(db.DB)
+	limits := playbackLimitsFor(repo, userID)
+
+	devices, err := repo.ListDevices(userID)
+	if err != nil {
+		log.Printf("[Playback] Failed to list devices for user %s: %v", userID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch devices"})
+	}
+
+	sessions, err := repo.ListActiveSessions(userID, limits.SessionTimeout)
+	if err != nil {
+		log.Printf("[Playback] Failed to list sessions for user %s: %v", userID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch sessions"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"devices":         devices,
+		"active_sessions": sessions,
+		"limits":          limits,
+	})
+}
+
+// AdminRevokeUserDevice revokes a single device and stops its playback
+// DELETE /api/admin/users/:id/devices/:deviceId
+func AdminRevokeUserDevice(c echo.Context) error {
+	userID := c.Param("id")
+	deviceID := c.Param("deviceId")
+
+	repo := postgres.NewPlaybackRepository(db.DB)
+	found, err := repo.RevokeDevice(userID, deviceID)
+	if err != nil {
+		log.Printf("[Playback] Failed to revoke device %s: %v", deviceID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke device"})
+	}
+	if !found {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Device not found"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Device revoked"})
+}
+
+// AdminRevokeAllUserDevices revokes every device of a user and stops all their playback
+// DELETE /api/admin/users/:id/devices
+func AdminRevokeAllUserDevices(c echo.Context) error {
+	userID := c.Param("id")
+
+	repo := postgres.NewPlaybackRepository(db.DB)
+	count, err := repo.RevokeAllDevices(userID)
+	if err != nil {
+		log.Printf("[Playback] Failed to revoke devices for user %s: %v", userID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke devices"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Devices revoked",
+		"revoked": count,
+	})
+}
+
+// GetPlaybackLimits returns the platform-wide playback limits
+// GET /api/admin/playback/limits
+func GetPlaybackLimits(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"max_concurrent_streams":  getSettingInt("playback_max_concurrent_streams", 2),
+		"max_devices":             getSettingInt("playback_max_devices", 3),
+		"session_timeout_seconds": getSettingInt("playback_session_timeout_seconds", 90),
+		"device_inactive_days":    getSettingInt("playback_device_inactive_days", 30),
+	})
+}
+
+// UpdatePlaybackLimitsRequest updates playback limits; with a tenant_id only that tenant is changed
+type UpdatePlaybackLimitsRequest struct {
+	TenantID             string `json:"tenant_id"`
+	MaxConcurrentStreams *int   `json:"max_concurrent_streams"`
+	MaxDevices           *int   `json:"max_devices"`
+}
+
+// UpdatePlaybackLimits updates the platform defaults or a tenant's override
+// PUT /api/admin/playback/limits
+func UpdatePlaybackLimits(c echo.Context) error {
+	var req UpdatePlaybackLimitsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if (req.MaxConcurrentStreams != nil && *req.MaxConcurrentStreams < 0) ||
+		(req.MaxDevices != nil && *req.MaxDevices < 0) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Limits must be 0 (unlimited) or greater"})
+	}
+
+	if req.TenantID == "" {
+		if req.MaxConcurrentStreams != nil {
+			setSettingValue("playback_max_concurrent_streams", strconv.Itoa(*req.MaxConcurrentStreams))
+		}
+		if req.MaxDevices != nil {
+			setSettingValue("playback_max_devices", strconv.Itoa(*req.MaxDevices))
+		}
+		return GetPlaybackLimits(c)
+	}
+
+	updates := map[string]*int{
+		"max_concurrent_streams": req.MaxConcurrentStreams,
+		"max_devices":            req.MaxDevices,
+	}
+	for key, value := range updates {
+		if value == nil {
+			continue
+		}
+		result, err := db.DB.Exec(`
+			UPDATE tenants
+			SET feature_config = jsonb_set(COALESCE(feature_config, '{}'::jsonb), $2, to_jsonb($3::int)),
+			    updated_at = NOW()
+			WHERE id = $1
+		`, req.TenantID, "{"+key+"}", *value)
+		if err != nil {
+			log.Printf("[Playback] Failed to update tenant %s limits: %v", req.TenantID, err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update tenant limits"})
+		}
+		if count, _ := result.RowsAffected(); count == 0 {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Tenant not found"})
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Tenant playback limits updated"})
+}
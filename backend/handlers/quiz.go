@@ -3,7 +3,6 @@ package handlers
 import (
 	"net/http"
 	"sort"
-	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -208,6 +207,10 @@ func CreateQuestion(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Question text is required"})
 	}
 	
+	if !isGradingMode(req.GradingMode) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid grading mode"})
+	}
+	
 	question := &domain.Question{
 		QuizID:       quizID,
 		Type:         req.Type,
@@ -215,6 +218,8 @@ func CreateQuestion(c echo.Context) error {
 		Explanation:  req.Explanation,
 		Points:       req.Points,
 		Required:     req.Required,
+		GradingMode:  req.GradingMode,
+		Rubric:       req.Rubric,
 	}
 	
 	if question.Points == 0 {
@@ -283,6 +288,15 @@ func UpdateQuestion(c echo.Context) error {
 	if req.Required != nil {
 		question.Required = *req.Required
 	}
+	if req.GradingMode != nil {
+		if !isGradingMode(*req.GradingMode) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid grading mode"})
+		}
+		question.GradingMode = *req.GradingMode
+	}
+	if req.Rubric != nil {
+		question.Rubric = *req.Rubric
+	}
 	
 	err = quizRepo.UpdateQuestion(question)
 	if err != nil {
@@ -524,6 +538,7 @@ func SubmitQuizAttempt(c echo.Context) error {
 	totalPoints := 0
 	earnedPoints := 0
 	correctCount := 0
+	gradingPending := false
 	
 	var answers []domain.Answer
 	
@@ -536,6 +551,8 @@ func SubmitQuizAttempt(c echo.Context) error {
 		totalPoints += q.Points
 		isCorrect := false
 		pointsEarned := 0
+		feedback := ""
+		gradedBy := domain.GradedByAuto
 		
 		switch q.Type {
 		case domain.QuestionMultipleChoice, domain.QuestionTrueFalse:
@@ -575,21 +592,23 @@ func SubmitQuizAttempt(c echo.Context) error {
 			}
 			
 		case domain.QuestionShortAnswer:
-			// Simple exact match (case insensitive, trimmed)
-			for _, opt := range q.Options {
-				if strings.TrimSpace(strings.ToLower(ans.TextAnswer)) == 
-				   strings.TrimSpace(strings.ToLower(opt.OptionText)) {
-					isCorrect = true
-					break
-				}
+			// Graded by the question's grading mode; AI grading happens after
+			// submission and may award partial points
+			grade := gradeShortAnswer(q, ans.TextAnswer)
+			isCorrect = grade.correct
+			pointsEarned = grade.points
+			feedback = grade.feedback
+			gradedBy = grade.gradedBy
+			if gradedBy == domain.GradedByPending {
+				gradingPending = true
 			}
 		}
 		
 		if isCorrect {
 			pointsEarned = q.Points
-			earnedPoints += pointsEarned
 			correctCount++
 		}
+		earnedPoints += pointsEarned
 		
 		answers = append(answers, domain.Answer{
 			AttemptID:         attemptID,
@@ -598,6 +617,8 @@ func SubmitQuizAttempt(c echo.Context) error {
 			TextAnswer:        ans.TextAnswer,
 			IsCorrect:         &isCorrect,
 			PointsEarned:      pointsEarned,
+			Feedback:          feedback,
+			GradedBy:          gradedBy,
 		})
 	}
 	
@@ -627,6 +648,11 @@ func SubmitQuizAttempt(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update attempt"})
 	}
 	
+	// The score is recomputed once the model has graded the pending answers
+	if gradingPending {
+		enqueueQuizGrading(attemptID)
+	}
+	
 	result := domain.QuizResult{
 		AttemptID:      attemptID,
 		QuizID:         attempt.QuizID,
@@ -637,6 +663,7 @@ func SubmitQuizAttempt(c echo.Context) error {
 		EarnedPoints:   earnedPoints,
 		CorrectCount:   correctCount,
 		TotalQuestions: len(questions),
+		GradingPending: gradingPending,
 	}
 	
 	// Add answer details if quiz allows showing correct answers
@@ -660,6 +687,7 @@ func SubmitQuizAttempt(c echo.Context) error {
 				PointsEarned:  ans.PointsEarned,
 				MaxPoints:     q.Points,
 				Explanation:   q.Explanation,
+				Feedback:      ans.Feedback,
 			})
 		}
 	}
@@ -699,6 +727,16 @@ func GetQuizAttemptResult(c echo.Context) error {
 		TimeSpent:      *attempt.TimeSpent,
 		TotalQuestions: len(questions),
 	}
+	for _, ans := range answers {
+		result.TotalPoints += questionMap[ans.QuestionID].Points
+		result.EarnedPoints += ans.PointsEarned
+		if ans.IsCorrect != nil && *ans.IsCorrect {
+			result.CorrectCount++
+		}
+		if ans.GradedBy == domain.GradedByPending {
+			result.GradingPending = true
+		}
+	}
 	
 	if quiz.ShowCorrectAnswers {
 		for _, ans := range answers {
@@ -720,6 +758,7 @@ func GetQuizAttemptResult(c echo.Context) error {
 				PointsEarned:  ans.PointsEarned,
 				MaxPoints:     q.Points,
 				Explanation:   q.Explanation,
+				Feedback:      ans.Feedback,
 			})
 		}
 	}
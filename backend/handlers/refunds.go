@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/payment"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
+)
+
+const (
+	maxRefundReasonLength = 500
+	refundTimeout         = 60 * time.Second
+)
+
+// Enrollment policies applied when a transaction is fully refunded
+const (
+	refundPolicyRevoke = "revoke"
+	refundPolicyRetain = "retain"
+)
+
+// RefundRequest is the admin request to refund a transaction
+type RefundRequest struct {
+	Amount     float64 `json:"amount"` // 0 refunds everything not yet refunded
+	Reason     string  `json:"reason"`
+	Manual     bool    `json:"manual"`     // Money was returned outside the payment gateway
+	Enrollment string  `json:"enrollment"` // revoke or retain; defaults to refund_enrollment_policy
+}
+
+// LedgerEntry is one money movement of a transaction
+type LedgerEntry struct {
+	RefundID  string    `json:"refund_id,omitempty"`
+	Type      string    `json:"type"`   // payment, refund
+	Amount    float64   `json:"amount"` // Negative for refunds
+	Status    string    `json:"status"`
+	Provider  string    `json:"provider,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Reference string    `json:"reference,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// isPaidStatus reports whether money was received for a transaction in this status
+func isPaidStatus(status string) bool {
+	switch status {
+	case "success", "settlement", "capture", "partially_refunded", "refunded":
+		return true
+	}
+	return false
+}
+
+// RefundTransaction refunds all or part of a paid transaction through its payment
+// gateway, or records a refund made outside it. A full refund also revokes the
+// enrollment (unless the policy retains it) and gives the coupon use back.
+// POST /api/admin/transactions/:id/refund
+func RefundTransaction(c echo.Context) error {
+	initAdminRepos()
+	initCouponRepo()
+
+	var req RefundRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Reason is required"})
+	}
+	if len(req.Reason) > maxRefundReasonLength {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Reason must be at most %d characters", maxRefundReasonLength)})
+	}
+
+	policy := req.Enrollment
+	if policy == "" {
+		policy = getSettingValue("refund_enrollment_policy", refundPolicyRevoke)
+	}
+	if policy != refundPolicyRevoke && policy != refundPolicyRetain {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Enrollment must be revoke or retain"})
+	}
+
+	id := c.Param("id")
+	tx, err := transactionRepo.GetByID(id)
+	if err != nil || tx == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Transaction not found"})
+	}
+
+	if !isPaidStatus(tx.Status) || tx.Status == "refunded" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Only paid transactions can be refunded"})
+	}
+
+	remaining := tx.Amount - tx.RefundedAmount
+	if remaining <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Transaction has been fully refunded"})
+	}
+
+	amount := req.Amount
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount < 0 || amount > remaining {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Amount must be between 0 and %.0f", remaining)})
+	}
+
+	adminID := getUserIDFromToken(c)
+	refund := &postgres.TransactionRefund{
+		TransactionID:    tx.ID,
+		Amount:           amount,
+		Reason:           req.Reason,
+		RefundKey:        "rf-" + uuid.New().String(),
+		EnrollmentPolicy: policy,
+		CreatedBy:        optionalString(adminID),
+	}
+
+	var provider payment.PaymentProvider
+	if !req.Manual {
+		provider = paymentProviderFor(tx.PaymentGateway)
+		if provider == nil || tx.OrderID == nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Payment gateway %q is not configured; record the refund as manual instead", tx.PaymentGateway)})
+		}
+		name := provider.GetName()
+		refund.Provider = &name
+	}
+
+	// Reserve the amount in the ledger before any money moves, so concurrent
+	// requests can't both refund the same balance
+	if err := transactionRepo.ReserveRefund(refund); err != nil {
+		if errors.Is(err, postgres.ErrRefundExceedsBalance) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Refund exceeds the amount left to refund"})
+		}
+		log.Printf("[Refund] Failed to reserve refund of transaction %s: %v", tx.ID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record refund"})
+	}
+
+	return settleRefund(c, tx, refund, provider, policy, adminID)
+}
+
+// RetryRefund sends a refund left pending, e.g. because the gateway didn't answer
+// in time, to the gateway again. The refund keeps its key, so the gateway doesn't
+// refund it twice, and the enrollment policy the admin chose when issuing it.
+// POST /api/admin/transactions/:id/refunds/:refund_id/retry
+func RetryRefund(c echo.Context) error {
+	initAdminRepos()
+	initCouponRepo()
+
+	tx, err := transactionRepo.GetByID(c.Param("id"))
+	if err != nil || tx == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Transaction not found"})
+	}
+
+	refund, err := transactionRepo.GetRefund(tx.ID, c.Param("refund_id"))
+	if err != nil {
+		log.Printf("[Refund] Failed to get refund of transaction %s: %v", tx.ID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch refund"})
+	}
+	if refund == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Refund not found"})
+	}
+	if refund.Status != payment.RefundStatusPending {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Only pending refunds can be retried"})
+	}
+
+	var provider payment.PaymentProvider
+	if refund.Provider != nil {
+		provider = paymentProviderFor(*refund.Provider)
+		if provider == nil || tx.OrderID == nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Payment gateway %q is not configured", *refund.Provider)})
+		}
+	}
+
+	policy := refund.EnrollmentPolicy
+	if policy == "" {
+		// Reserved before refunds stored their policy
+		policy = getSettingValue("refund_enrollment_policy", refundPolicyRevoke)
+	}
+	return settleRefund(c, tx, refund, provider, policy, getUserIDFromToken(c))
+}
+
+// settleRefund sends a reserved refund to the gateway, or records it as manual when
+// provider is nil, and settles it in the ledger. A refund whose gateway outcome
+// is unknown, or that couldn't be settled after the gateway accepted it, stays
+// pending: it keeps its amount reserved and can be retried.
+func settleRefund(c echo.Context, tx *postgres.Transaction, refund *postgres.TransactionRefund, provider payment.PaymentProvider, policy, adminID string) error {
+	var gatewayErr error
+	if provider == nil {
+		refund.Status = payment.RefundStatusManual
+	} else {
+		// The refund must reach its outcome even if the admin's request goes away
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request().Context()), refundTimeout)
+		defer cancel()
+
+		var known bool
+		known, gatewayErr = requestGatewayRefund(ctx, provider, *tx.OrderID, refund)
+		if !known {
+			log.Printf("[Refund] Gateway didn't answer refund %s of transaction %s; it stays pending: %v", refund.RefundKey, tx.ID, gatewayErr)
+			return c.JSON(http.StatusGatewayTimeout, map[string]string{"error": "Payment gateway did not answer; the refund stays pending and can be retried"})
+		}
+	}
+
+	status, err := transactionRepo.CompleteRefund(refund)
+	if err != nil {
+		if errors.Is(err, postgres.ErrRefundNotPending) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Refund was already processed"})
+		}
+		log.Printf("[Refund] Failed to settle %s refund %s of transaction %s; it stays pending: %v", refund.Status, refund.RefundKey, tx.ID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Refund was processed but could not be recorded; it stays pending in the ledger"})
+	}
+
+	if gatewayErr != nil {
+		log.Printf("[Refund] Gateway refund of transaction %s failed: %v", tx.ID, gatewayErr)
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": "Refund rejected by payment gateway: " + gatewayErr.Error()})
+	}
+
+	log.Printf("[Refund] Transaction %s refunded %.0f (%s, %s) by %s", tx.ID, refund.Amount, refund.Status, status, adminID)
+
+	if status == "refunded" {
+		refund.EnrollmentRevoked, refund.CouponReleased = applyFullRefund(tx, policy)
+		if err := transactionRepo.UpdateRefundEffects(refund.ID, refund.EnrollmentRevoked, refund.CouponReleased); err != nil {
+			log.Printf("[Refund] Failed to save effects of refund %s: %v", refund.ID, err)
+		}
+	}
+
+	tx, _ = transactionRepo.GetByID(tx.ID)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"refund":      refund,
+		"transaction": enrichTransaction(tx),
+	})
+}
+
+// requestGatewayRefund asks the gateway to refund a reserved refund and sets its
+// outcome on it. It reports false when the outcome is unknown because the gateway
+// didn't answer in time.
+func requestGatewayRefund(ctx context.Context, provider payment.PaymentProvider, orderID string, refund *postgres.TransactionRefund) (bool, error) {
+	result, err := provider.Refund(ctx, &payment.RefundRequest{
+		OrderID:   orderID,
+		RefundKey: refund.RefundKey,
+		Amount:    refund.Amount,
+		Reason:    refund.Reason,
+	})
+	if err != nil {
+		message := err.Error()
+		refund.Message = &message
+
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			return false, err
+		}
+		refund.Status = payment.RefundStatusFailed
+		return true, err
+	}
+
+	refund.Status = result.Status
+	refund.GatewayRef = optionalString(result.GatewayRef)
+	refund.Message = optionalString(result.Message)
+	return true, nil
+}
+
+// applyFullRefund revokes the enrollment or webinar registration the transaction
+// paid for, unless the policy retains it, and releases the coupon use. An
+// enrollment paid by another transaction, or a webinar registration that didn't
+// come from a payment, is left alone. It reports what was done.
+func applyFullRefund(tx *postgres.Transaction, policy string) (enrollmentRevoked, couponReleased bool) {
+	var metadata map[string]string
+	if len(tx.Metadata) > 0 {
+		json.Unmarshal(tx.Metadata, &metadata)
+	}
+
+	if policy == refundPolicyRevoke && tx.CourseID == nil && metadata["webinar_id"] != "" {
+		webinarID := metadata["webinar_id"]
+		revoked, err := postgres.NewWebinarRepository(db.DB).UnregisterPurchase(webinarID, tx.UserID)
+		if err != nil {
+			log.Printf("[Refund] Failed to revoke webinar %s registration of transaction %s: %v", webinarID, tx.ID, err)
+		}
+		enrollmentRevoked = revoked
+	}
+
+	if policy == refundPolicyRevoke && tx.CourseID != nil {
+		enrollment, err := enrollmentRepo.GetByUserAndCourse(tx.UserID, *tx.CourseID)
+		if err != nil {
+			log.Printf("[Refund] Failed to get enrollment for transaction %s: %v", tx.ID, err)
+		} else if enrollment != nil && (enrollment.TransactionID == nil || *enrollment.TransactionID == tx.ID) {
+			if err := enrollmentRepo.Delete(enrollment.ID); err != nil {
+				log.Printf("[Refund] Failed to revoke enrollment %s: %v", enrollment.ID, err)
+			} else {
+				enrollmentRevoked = true
+			}
+		}
+	}
+
+	released, err := couponRepo.ReleaseUsage(tx.ID)
+	if err != nil {
+		log.Printf("[Refund] Failed to release coupon usage of transaction %s: %v", tx.ID, err)
+	}
+
+	return enrollmentRevoked, released
+}
+
+// pendingRefunds returns the amount reserved by pending refunds
+func pendingRefunds(refunds []*postgres.TransactionRefund) float64 {
+	total := 0.0
+	for _, refund := range refunds {
+		if refund.Status == payment.RefundStatusPending {
+			total += refund.Amount
+		}
+	}
+	return total
+}
+
+// GetTransactionLedger returns every money movement of a transaction: the payment
+// and each refund, with the running totals
+// GET /api/admin/transactions/:id/ledger
+func GetTransactionLedger(c echo.Context) error {
+	initAdminRepos()
+
+	tx, err := transactionRepo.GetByID(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch transaction"})
+	}
+	if tx == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Transaction not found"})
+	}
+
+	refunds, err := transactionRepo.ListRefunds(tx.ID)
+	if err != nil {
+		log.Printf("[Refund] Failed to list refunds of transaction %s: %v", tx.ID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch refunds"})
+	}
+
+	entries := []LedgerEntry{}
+	paid, refundable := 0.0, 0.0
+	if isPaidStatus(tx.Status) {
+		paid = tx.Amount
+		if tx.Status != "refunded" {
+			refundable = math.Max(tx.Amount-tx.RefundedAmount-pendingRefunds(refunds), 0)
+		}
+		entry := LedgerEntry{
+			Type:      "payment",
+			Amount:    tx.Amount,
+			Status:    "succeeded",
+			Provider:  tx.PaymentGateway,
+			CreatedAt: tx.CreatedAt,
+		}
+		if tx.OrderID != nil {
+			entry.Reference = *tx.OrderID
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, refund := range refunds {
+		entry := LedgerEntry{
+			RefundID:  refund.ID,
+			Type:      "refund",
+			Amount:    -refund.Amount,
+			Status:    refund.Status,
+			Reason:    refund.Reason,
+			Reference: refund.RefundKey,
+			CreatedAt: refund.CreatedAt,
+		}
+		if refund.Provider != nil {
+			entry.Provider = *refund.Provider
+		}
+		entries = append(entries, entry)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"entries":    entries,
+		"refunds":    refunds,
+		"paid":       paid,
+		"refunded":   tx.RefundedAmount,
+		"refundable": refundable,
+		"policy":     getSettingValue("refund_enrollment_policy", refundPolicyRevoke),
+	})
+}
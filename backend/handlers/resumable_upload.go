@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/domain"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/storage"
+	customMiddleware "github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/middleware"
+)
+
+// Multipart upload limits. S3 requires parts of at least 5MB (except the last) and at
+// most 10,000 parts per upload, so the part size grows for very large files.
+const (
+	MinUploadPartSize = 8 * 1024 * 1024 // 8MB
+	MaxUploadParts    = 10000
+)
+
+// CreateMultipartUploadRequest starts a resumable upload
+type CreateMultipartUploadRequest struct {
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+}
+
+// CompleteMultipartUploadRequest optionally carries a checksum of the whole file:
+// the hex SHA-256 of the concatenated raw SHA-256 digests of every part, in order
+type CompleteMultipartUploadRequest struct {
+	ChecksumSHA256 string `json:"checksum_sha256"`
+}
+
+// MultipartUploadResponse describes an upload session and the parts already received
+type MultipartUploadResponse struct {
+	domain.UploadSession
+	Parts         []domain.UploadSessionPart `json:"parts"`
+	UploadedBytes int64                      `json:"uploaded_bytes"`
+	MissingParts  []int                      `json:"missing_parts"`
+}
+
+// resumableMaxSize returns the largest file accepted by resumable uploads. Videos get
+// a configurable (much larger) limit than the single-request upload.
+func resumableMaxSize(fileType string) int64 {
+	if fileType == "video" {
+		return int64(getSettingInt("upload_max_video_size_mb", 5120)) * 1024 * 1024
+	}
+	return getMaxSize(fileType)
+}
+
+// uploadSessionTTL is how long an upload may sit idle before it is cleaned up
+func uploadSessionTTL() time.Duration {
+	return time.Duration(getSettingInt("upload_session_ttl_hours", 24)) * time.Hour
+}
+
+// uploadPartSize picks a part size that keeps the upload under MaxUploadParts
+func uploadPartSize(totalSize int64) int64 {
+	partSize := int64(MinUploadPartSize)
+	if needed := (totalSize + MaxUploadParts - 1) / MaxUploadParts; needed > partSize {
+		// Round up to a whole MB
+		partSize = (needed + 1024*1024 - 1) / (1024 * 1024) * (1024 * 1024)
+	}
+	return partSize
+}
+
+// loadUploadSession fetches an upload session owned by the current user
+func loadUploadSession(c echo.Context) (*domain.UploadSession, error) {
+	userID, _, err := customMiddleware.GetUserFromContext(c)
+	if err != nil {
+		return nil, c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	session, err := postgres.NewUploadSessionRepository(db.DB).GetByID(c.Param("uploadId"))
+	if err != nil {
+		log.Printf("[Upload] Failed to load upload session %s: %v", c.Param("uploadId"), err)
+		return nil, c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load upload"})
+	}
+	if session == nil || session.UserID != userID {
+		return nil, c.JSON(http.StatusNotFound, map[string]string{"error": "Upload not found"})
+	}
+	return session, nil
+}
+
+// requireUploading rejects requests against sessions that are no longer accepting parts
+func requireUploading(c echo.Context, session *domain.UploadSession) error {
+	if session.Status != domain.UploadStatusUploading {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Upload is " + session.Status})
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Upload has expired"})
+	}
+	return nil
+}
+
+// multipartUploadResponse builds the resume view of an upload session
+func multipartUploadResponse(session *domain.UploadSession, parts []domain.UploadSessionPart) MultipartUploadResponse {
+	received := make(map[int]bool, len(parts))
+	var uploaded int64
+	for _, p := range parts {
+		received[p.PartNumber] = true
+		uploaded += p.Size
+	}
+
+	missing := []int{}
+	for n := 1; n <= session.TotalParts; n++ {
+		if !received[n] {
+			missing = append(missing, n)
+		}
+	}
+
+	return MultipartUploadResponse{
+		UploadSession: *session,
+		Parts:         parts,
+		UploadedBytes: uploaded,
+		MissingParts:  missing,
+	}
+}
+
+// CreateMultipartUpload starts a resumable upload
+// POST /api/admin/uploads/multipart
+func CreateMultipartUpload(c echo.Context) error {
+	userID, _, err := customMiddleware.GetUserFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	var req CreateMultipartUploadRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if req.Filename == "" || req.Size <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "filename and size are required"})
+	}
+
+	fileType := getFileType(strings.ToLower(filepath.Ext(req.Filename)))
+	if fileType == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "File type not allowed. Allowed: video (.mp4, .webm, .mov), document (.pdf, .doc, .docx), image (.jpg, .png)",
+		})
+	}
+	if maxSize := resumableMaxSize(fileType); req.Size > maxSize {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("File too large. Max size for %s: %dMB", fileType, maxSize/(1024*1024)),
+		})
+	}
+
+	store := storage.GetStorage()
+	if store == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Storage not configured"})
+	}
+
+	objectKey := fmt.Sprintf("%d_%s", time.Now().UnixNano(), sanitizeFilename(req.Filename))
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = getContentType(req.Filename)
+	}
+
+	ctx := c.Request().Context()
+	storageUploadID, err := store.NewMultipartUpload(ctx, "", objectKey, contentType)
+	if err != nil {
+		log.Printf("[Upload] Failed to start multipart upload for %s: %v", objectKey, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start upload"})
+	}
+
+	partSize := uploadPartSize(req.Size)
+	session := &domain.UploadSession{
+		UserID:          userID,
+		ObjectKey:       objectKey,
+		StorageUploadID: storageUploadID,
+		Filename:        req.Filename,
+		ContentType:     contentType,
+		FileType:        fileType,
+		TotalSize:       req.Size,
+		PartSize:        partSize,
+		TotalParts:      int((req.Size + partSize - 1) / partSize),
+		Status:          domain.UploadStatusUploading,
+		ExpiresAt:       time.Now().Add(uploadSessionTTL()),
+	}
+	if err := postgres.NewUploadSessionRepository(db.DB).Create(session); err != nil {
+		log.Printf("[Upload] Failed to save upload session for %s: %v", objectKey, err)
+		store.AbortMultipartUpload(ctx, "", objectKey, storageUploadID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start upload"})
+	}
+
+	log.Printf("[Upload] Started multipart upload %s (%s, %d bytes, %d parts)", session.ID, objectKey, req.Size, session.TotalParts)
+	return c.JSON(http.StatusCreated, multipartUploadResponse(session, nil))
+}
+
+// GetMultipartUpload returns an upload session and the parts received so far, so a
+// client can resume after a dropped connection
+// GET /api/admin/uploads/multipart/:uploadId
+func GetMultipartUpload(c echo.Context) error {
+	session, err := loadUploadSession(c)
+	if session == nil {
+		return err
+	}
+
+	parts, err := postgres.NewUploadSessionRepository(db.DB).ListParts(session.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list parts"})
+	}
+	return c.JSON(http.StatusOK, multipartUploadResponse(session, parts))
+}
+
+// UploadMultipartPart receives one part as the raw request body. The part is hashed
+// while it streams to storage; an optional X-Checksum-SHA256 (hex) is verified and a
+// Content-MD5 header is enforced by storage itself. Re-sending a part replaces it, and
+// a re-sent part that fails verification leaves the part missing.
+// PUT /api/admin/uploads/multipart/:uploadId/parts/:partNumber
+func UploadMultipartPart(c echo.Context) error {
+	session, err := loadUploadSession(c)
+	if session == nil {
+		return err
+	}
+	if err := requireUploading(c, session); err != nil {
+		return err
+	}
+
+	partNumber, err := strconv.Atoi(c.Param("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > session.TotalParts {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("Part number must be between 1 and %d", session.TotalParts),
+		})
+	}
+
+	expected := session.ExpectedPartSize(partNumber)
+	req := c.Request()
+	if req.ContentLength >= 0 && req.ContentLength != expected {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("Part %d must be exactly %d bytes", partNumber, expected),
+		})
+	}
+
+	store := storage.GetStorage()
+	if store == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Storage not configured"})
+	}
+
+	repo := postgres.NewUploadSessionRepository(db.DB)
+
+	// Storage may already hold the bytes of a part that fails below, replacing an
+	// earlier good copy, so the recorded part is dropped and the client re-sends it
+	rejectPart := func(message string) error {
+		if err := repo.DeletePart(session.ID, partNumber); err != nil {
+			log.Printf("[Upload] Failed to forget part %d of %s: %v", partNumber, session.ID, err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record part"})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": message})
+	}
+
+	hasher := sha256.New()
+	body := io.TeeReader(io.LimitReader(req.Body, expected), hasher)
+	part, err := store.PutPart(req.Context(), "", session.ObjectKey, session.StorageUploadID,
+		partNumber, body, expected, req.Header.Get("Content-MD5"))
+	if err != nil {
+		log.Printf("[Upload] Failed to store part %d of %s: %v", partNumber, session.ID, err)
+		return rejectPart("Failed to store part, please retry")
+	}
+	if part.Size != expected {
+		return rejectPart(fmt.Sprintf("Part %d incomplete: received %d of %d bytes", partNumber, part.Size, expected))
+	}
+
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	if want := req.Header.Get("X-Checksum-SHA256"); want != "" && !strings.EqualFold(want, checksum) {
+		return rejectPart("Checksum mismatch, please retry this part")
+	}
+
+	saved := &domain.UploadSessionPart{
+		UploadID:   session.ID,
+		PartNumber: partNumber,
+		Size:       part.Size,
+		ETag:       part.ETag,
+		SHA256:     checksum,
+	}
+	if err := repo.SavePart(saved, time.Now().Add(uploadSessionTTL())); err != nil {
+		log.Printf("[Upload] Failed to record part %d of %s: %v", partNumber, session.ID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record part"})
+	}
+
+	return c.JSON(http.StatusOK, saved)
+}
+
+// compositeChecksum returns the whole-file checksum of verified parts: the SHA-256 of
+// their raw SHA-256 digests in part order. Browsers can't hash a multi-gigabyte file
+// in one go, but they can hash each part, and each part's digest was verified as it
+// streamed to storage.
+func compositeChecksum(parts []domain.UploadSessionPart) (string, error) {
+	hasher := sha256.New()
+	for _, p := range parts {
+		digest, err := hex.DecodeString(p.SHA256)
+		if err != nil {
+			return "", fmt.Errorf("part %d has an invalid checksum: %w", p.PartNumber, err)
+		}
+		hasher.Write(digest)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// CompleteMultipartUpload assembles the parts into the final object. When the client
+// sends the whole-file checksum, the recorded parts must match it.
+// POST /api/admin/uploads/multipart/:uploadId/complete
+func CompleteMultipartUpload(c echo.Context) error {
+	session, err := loadUploadSession(c)
+	if session == nil {
+		return err
+	}
+	if err := requireUploading(c, session); err != nil {
+		return err
+	}
+
+	repo := postgres.NewUploadSessionRepository(db.DB)
+	parts, err := repo.ListParts(session.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list parts"})
+	}
+
+	resume := multipartUploadResponse(session, parts)
+	if len(resume.MissingParts) > 0 || resume.UploadedBytes != session.TotalSize {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":          "Upload is incomplete",
+			"missing_parts":  resume.MissingParts,
+			"uploaded_bytes": resume.UploadedBytes,
+		})
+	}
+
+	var req CompleteMultipartUploadRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if req.ChecksumSHA256 != "" {
+		checksum, err := compositeChecksum(parts)
+		if err != nil {
+			log.Printf("[Upload] Failed to compute checksum of %s: %v", session.ID, err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify upload"})
+		}
+		if !strings.EqualFold(req.ChecksumSHA256, checksum) {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error": "File checksum mismatch, please compare each part's sha256 and re-send the parts that differ",
+				"parts": parts,
+			})
+		}
+	}
+
+	store := storage.GetStorage()
+	if store == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Storage not configured"})
+	}
+
+	storedParts := make([]storage.UploadedPart, 0, len(parts))
+	for _, p := range parts {
+		storedParts = append(storedParts, storage.UploadedPart{PartNumber: p.PartNumber, ETag: p.ETag, Size: p.Size})
+	}
+
+	ctx := c.Request().Context()
+	if err := store.CompleteMultipartUpload(ctx, "", session.ObjectKey, session.StorageUploadID, storedParts); err != nil {
+		log.Printf("[Upload] Failed to complete upload %s: %v", session.ID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to complete upload"})
+	}
+	if _, err := repo.UpdateStatus(session.ID, domain.UploadStatusCompleted); err != nil {
+		log.Printf("[Upload] Failed to mark upload %s completed: %v", session.ID, err)
+	}
+
+	log.Printf("[Upload] Completed multipart upload %s (%s)", session.ID, session.ObjectKey)
+	return c.JSON(http.StatusOK, UploadResponse{
+		URL:       session.ObjectKey,
+		Filename:  session.Filename,
+		Size:      session.TotalSize,
+		Type:      session.FileType,
+		ObjectKey: session.ObjectKey,
+		// Duration is filled in by the metadata pipeline once the video is attached to
+		// a lesson; probing here would mean downloading the whole file again.
+	})
+}
+
+// AbortMultipartUpload cancels an upload and discards its parts
+// DELETE /api/admin/uploads/multipart/:uploadId
+func AbortMultipartUpload(c echo.Context) error {
+	session, err := loadUploadSession(c)
+	if session == nil {
+		return err
+	}
+	if session.Status != domain.UploadStatusUploading {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Upload is " + session.Status})
+	}
+
+	if store := storage.GetStorage(); store != nil {
+		if err := store.AbortMultipartUpload(c.Request().Context(), "", session.ObjectKey, session.StorageUploadID); err != nil {
+			log.Printf("[Upload] Failed to abort storage upload for %s: %v", session.ID, err)
+		}
+	}
+	if _, err := postgres.NewUploadSessionRepository(db.DB).UpdateStatus(session.ID, domain.UploadStatusAborted); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to abort upload"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Upload aborted"})
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/domain"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/storage"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/middleware"
@@ -17,9 +18,10 @@ import (
 
 // SecureContentURLResponse represents the response for secure content URL
 type SecureContentURLResponse struct {
-	URL       string    `json:"url"`
-	ExpiresAt time.Time `json:"expires_at"`
-	Type      string    `json:"type"`
+	URL       string         `json:"url"`
+	ExpiresAt time.Time      `json:"expires_at"`
+	Type      string         `json:"type"`
+	Watermark *WatermarkInfo `json:"watermark,omitempty"`
 }
 
 // GetSecureContentURL generates a pre-signed URL for authorized users to access lesson content
@@ -79,6 +81,18 @@ func GetSecureContentURL(c echo.Context) error {
 
 	videoURL := *lesson.VideoURL
 
+	// Enforce concurrent stream and device limits
+	if limits, err := requirePlaybackSession(c, userID, role, lessonID); err != nil {
+		return playbackErrorResponse(c, userID, limits, err)
+	}
+
+	// Protected lessons must be played with the viewer's forensic watermark overlay
+	watermark, err := issueWatermark(c, userID, role, lessonID)
+	if err != nil {
+		log.Printf("[Watermark] Failed to issue watermark for lesson %s: %v", lessonID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to issue watermark"})
+	}
+
 	// Check if this is a MinIO object (not a legacy local file)
 	// MinIO objects are stored without /uploads/ prefix
 	if strings.HasPrefix(videoURL, "/uploads/") {
@@ -88,6 +102,7 @@ func GetSecureContentURL(c echo.Context) error {
 			URL:       videoURL,
 			ExpiresAt: time.Now().Add(24 * time.Hour), // Long expiry for local files
 			Type:      getContentTypeFromURL(videoURL),
+			Watermark: watermark,
 		})
 	}
 
@@ -105,6 +120,7 @@ func GetSecureContentURL(c echo.Context) error {
 		URL:       presignedURL,
 		ExpiresAt: expiresAt,
 		Type:      getContentTypeFromURL(videoURL),
+		Watermark: watermark,
 	})
 }
 
@@ -270,6 +286,23 @@ func StreamContent(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "No content found"})
 	}
 
+	// Video playback: apply stream limits and tag with the viewer's watermark code.
+	// Documents are stored in video_url too but aren't playback.
+	if lesson.ContentType == domain.ContentVideo && lesson.VideoURL != nil && *lesson.VideoURL != "" {
+		if limits, err := requirePlaybackSession(c, userID, role, lessonID); err != nil {
+			return playbackErrorResponse(c, userID, limits, err)
+		}
+
+		watermark, err := issueWatermark(c, userID, role, lessonID)
+		if err != nil {
+			log.Printf("[Watermark] Failed to issue watermark for lesson %s: %v", lessonID, err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to issue watermark"})
+		}
+		if watermark != nil {
+			c.Response().Header().Set(watermarkHeader, watermark.Code)
+		}
+	}
+
 	// Check if this is a legacy local file (not MinIO)
 	if strings.HasPrefix(objectName, "/uploads/") {
 		// Redirect to local file (if still served)
@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/domain"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/storage"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/video"
+	customMiddleware "github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/middleware"
+)
+
+// MaxSubtitleSize is the largest subtitle file accepted
+const MaxSubtitleSize = 2 * 1024 * 1024 // 2MB
+
+// languageCodePattern accepts BCP 47 style codes such as "id", "en", "pt-BR" or "zh-Hant"
+var languageCodePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// subtitleObjectPath returns where a lesson's track for a language is stored
+func subtitleObjectPath(lessonID, language string) string {
+	return fmt.Sprintf("subtitles/%s/%s.vtt", lessonID, language)
+}
+
+// subtitleTrackURL is the authenticated WebVTT URL of a track
+func subtitleTrackURL(lessonID, subtitleID string) string {
+	return fmt.Sprintf("/api/content/%s/subtitles/%s", lessonID, subtitleID)
+}
+
+// SubtitleTrackResponse is a track with its playback URL
+type SubtitleTrackResponse struct {
+	domain.LessonSubtitle
+	URL string `json:"url"`
+}
+
+// lessonSubtitleTracks returns the HLS subtitle renditions for a lesson
+func lessonSubtitleTracks(lessonID string) []video.SubtitleTrack {
+	subtitles, err := postgres.NewSubtitleRepository(db.DB).ListByLesson(lessonID)
+	if err != nil {
+		log.Printf("[Subtitles] Failed to list subtitles for lesson %s: %v", lessonID, err)
+		return nil
+	}
+
+	tracks := make([]video.SubtitleTrack, 0, len(subtitles))
+	for _, s := range subtitles {
+		tracks = append(tracks, video.SubtitleTrack{
+			Name:      s.Label,
+			Language:  s.Language,
+			IsDefault: s.IsDefault,
+			URI:       subtitleTrackURL(lessonID, s.ID) + "/playlist.m3u8",
+		})
+	}
+	return tracks
+}
+
+// ========================================
+// STUDENT ENDPOINTS
+// ========================================
+
+// ListLessonSubtitles lists the subtitle tracks available for a lesson
+// GET /api/content/:lessonId/subtitles
+func ListLessonSubtitles(c echo.Context) error {
+	lessonID := c.Param("lessonId")
+
+	userID, role, err := customMiddleware.GetUserFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	if err := verifyContentAccess(userID, role, lessonID); err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+
+	return listLessonSubtitles(c, lessonID)
+}
+
+// GetLessonSubtitle serves a subtitle track as WebVTT
+// GET /api/content/:lessonId/subtitles/:subtitleId
+func GetLessonSubtitle(c echo.Context) error {
+	lessonID := c.Param("lessonId")
+
+	userID, role, err := customMiddleware.GetUserFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	if err := verifyContentAccess(userID, role, lessonID); err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+
+	subtitle, err := postgres.NewSubtitleRepository(db.DB).GetByID(lessonID, c.Param("subtitleId"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch subtitle"})
+	}
+	if subtitle == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Subtitle not found"})
+	}
+
+	content, err := loadHLSPlaylist(c.Request().Context(), subtitle.ObjectPath)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Subtitle file not found"})
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	return c.Blob(http.StatusOK, "text/vtt; charset=utf-8", []byte(content))
+}
+
+// GetLessonSubtitlePlaylist serves the HLS media playlist for a subtitle rendition
+// GET /api/content/:lessonId/subtitles/:subtitleId/playlist.m3u8
+func GetLessonSubtitlePlaylist(c echo.Context) error {
+	lessonID := c.Param("lessonId")
+	subtitleID := c.Param("subtitleId")
+
+	userID, role, err := customMiddleware.GetUserFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	if err := verifyContentAccess(userID, role, lessonID); err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+
+	subtitle, err := postgres.NewSubtitleRepository(db.DB).GetByID(lessonID, subtitleID)
+	if err != nil || subtitle == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Subtitle not found"})
+	}
+
+	// The subtitle segment must span the whole video
+	var duration int
+	db.DB.Get(&duration, `
+		SELECT COALESCE(NULLIF(k.duration_seconds, 0), l.video_duration, 0)
+		FROM lessons l
+		LEFT JOIN video_encryption_keys k ON k.lesson_id = l.id
+		WHERE l.id = $1
+	`, lessonID)
+
+	return servePlaylist(c, video.BuildSubtitlePlaylist(subtitleTrackURL(lessonID, subtitleID), float64(duration)))
+}
+
+// ========================================
+// ADMIN ENDPOINTS
+// ========================================
+
+// AdminListLessonSubtitles lists a lesson's subtitle tracks
+// GET /api/admin/lessons/:id/subtitles
+func AdminListLessonSubtitles(c echo.Context) error {
+	return listLessonSubtitles(c, c.Param("id"))
+}
+
+// AdminUploadLessonSubtitle uploads (or replaces) a subtitle track
+// POST /api/admin/lessons/:id/subtitles (multipart: file, language, label, is_default)
+func AdminUploadLessonSubtitle(c echo.Context) error {
+	return uploadLessonSubtitle(c, c.Param("id"))
+}
+
+// AdminUpdateLessonSubtitle changes a track's label or default flag
+// PUT /api/admin/lessons/:id/subtitles/:subtitleId
+func AdminUpdateLessonSubtitle(c echo.Context) error {
+	return updateLessonSubtitle(c, c.Param("id"), c.Param("subtitleId"))
+}
+
+// AdminDeleteLessonSubtitle deletes a subtitle track
+// DELETE /api/admin/lessons/:id/subtitles/:subtitleId
+func AdminDeleteLessonSubtitle(c echo.Context) error {
+	return deleteLessonSubtitle(c, c.Param("id"), c.Param("subtitleId"))
+}
+
+// ========================================
+// INSTRUCTOR ENDPOINTS
+// ========================================
+
+// instructorOwnsLesson reports whether the lesson belongs to one of the instructor's courses
+func instructorOwnsLesson(userID, lessonID string) (bool, error) {
+	var owns bool
+	err := db.DB.Get(&owns, `
+		SELECT EXISTS(
+			SELECT 1 FROM lessons l
+			JOIN courses c ON l.course_id = c.id
+			WHERE l.id = $1 AND c.instructor_id = $2
+		)
+	`, lessonID, userID)
+	return owns, err
+}
+
+// requireLessonOwnership writes an error response and returns false if the instructor
+// doesn't own the lesson
+func requireLessonOwnership(c echo.Context, lessonID string) (bool, error) {
+	userID, _, err := customMiddleware.GetUserFromContext(c)
+	if err != nil {
+		return false, err
+	}
+
+	owns, err := instructorOwnsLesson(userID, lessonID)
+	if err != nil {
+		return false, c.JSON(http.StatusInternalServerError, map[string]string{"error": "Gagal memeriksa materi"})
+	}
+	if !owns {
+		return false, c.JSON(http.StatusNotFound, map[string]string{"error": "Materi tidak ditemukan"})
+	}
+	return true, nil
+}
+
+// InstructorListLessonSubtitles lists subtitle tracks of the instructor's lesson
+func InstructorListLessonSubtitles(c echo.Context) error {
+	if ok, err := requireLessonOwnership(c, c.Param("id")); !ok {
+		return err
+	}
+	return listLessonSubtitles(c, c.Param("id"))
+}
+
+// InstructorUploadLessonSubtitle uploads a subtitle track to the instructor's lesson
+func InstructorUploadLessonSubtitle(c echo.Context) error {
+	if ok, err := requireLessonOwnership(c, c.Param("id")); !ok {
+		return err
+	}
+	return uploadLessonSubtitle(c, c.Param("id"))
+}
+
+// InstructorUpdateLessonSubtitle updates a subtitle track of the instructor's lesson
+func InstructorUpdateLessonSubtitle(c echo.Context) error {
+	if ok, err := requireLessonOwnership(c, c.Param("id")); !ok {
+		return err
+	}
+	return updateLessonSubtitle(c, c.Param("id"), c.Param("subtitleId"))
+}
+
+// InstructorDeleteLessonSubtitle deletes a subtitle track of the instructor's lesson
+func InstructorDeleteLessonSubtitle(c echo.Context) error {
+	if ok, err := requireLessonOwnership(c, c.Param("id")); !ok {
+		return err
+	}
+	return deleteLessonSubtitle(c, c.Param("id"), c.Param("subtitleId"))
+}
+
+// ========================================
+// SHARED LOGIC
+// ========================================
+
+func listLessonSubtitles(c echo.Context, lessonID string) error {
+	subtitles, err := postgres.NewSubtitleRepository(db.DB).ListByLesson(lessonID)
+	if err != nil {
+		log.Printf("[Subtitles] Failed to list subtitles for lesson %s: %v", lessonID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch subtitles"})
+	}
+
+	tracks := make([]SubtitleTrackResponse, 0, len(subtitles))
+	for _, s := range subtitles {
+		tracks = append(tracks, SubtitleTrackResponse{LessonSubtitle: s, URL: subtitleTrackURL(lessonID, s.ID)})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"subtitles": tracks})
+}
+
+func uploadLessonSubtitle(c echo.Context, lessonID string) error {
+	var contentType string
+	err := db.DB.Get(&contentType, `SELECT COALESCE(content_type, '') FROM lessons WHERE id = $1`, lessonID)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Lesson not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch lesson"})
+	}
+	if contentType != string(domain.ContentVideo) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Subtitles can only be added to video lessons"})
+	}
+
+	language := strings.TrimSpace(c.FormValue("language"))
+	if !languageCodePattern.MatchString(language) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "language must be a language code such as 'id' or 'en'"})
+	}
+	label := strings.TrimSpace(c.FormValue("label"))
+	if label == "" {
+		label = language
+	}
+	isDefault := c.FormValue("is_default") == "true" || c.FormValue("is_default") == "1"
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No file uploaded"})
+	}
+	if file.Size > MaxSubtitleSize {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("File too large. Max size for subtitles: %dMB", MaxSubtitleSize/(1024*1024)),
+		})
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to read file"})
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, MaxSubtitleSize+1))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to read file"})
+	}
+
+	vtt, err := video.ConvertSubtitle(file.Filename, data)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	minioStorage := storage.GetStorage()
+	if minioStorage == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Storage service not configured"})
+	}
+
+	objectPath := subtitleObjectPath(lessonID, language)
+	if err := minioStorage.Upload(c.Request().Context(), "", objectPath, strings.NewReader(vtt), int64(len(vtt)), "text/vtt"); err != nil {
+		log.Printf("[Subtitles] Failed to upload %s: %v", objectPath, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to upload subtitle"})
+	}
+
+	originalFilename := file.Filename
+	subtitle := &domain.LessonSubtitle{
+		LessonID:         lessonID,
+		Language:         language,
+		Label:            label,
+		IsDefault:        isDefault,
+		ObjectPath:       objectPath,
+		OriginalFilename: &originalFilename,
+	}
+	if err := postgres.NewSubtitleRepository(db.DB).Upsert(subtitle); err != nil {
+		log.Printf("[Subtitles] Failed to save subtitle for lesson %s: %v", lessonID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save subtitle"})
+	}
+
+	return c.JSON(http.StatusCreated, SubtitleTrackResponse{LessonSubtitle: *subtitle, URL: subtitleTrackURL(lessonID, subtitle.ID)})
+}
+
+// UpdateSubtitleRequest represents a subtitle track update
+type UpdateSubtitleRequest struct {
+	Label     *string `json:"label"`
+	IsDefault *bool   `json:"is_default"`
+}
+
+func updateLessonSubtitle(c echo.Context, lessonID, subtitleID string) error {
+	var req UpdateSubtitleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	repo := postgres.NewSubtitleRepository(db.DB)
+	subtitle, err := repo.GetByID(lessonID, subtitleID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch subtitle"})
+	}
+	if subtitle == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Subtitle not found"})
+	}
+
+	if req.Label != nil && strings.TrimSpace(*req.Label) != "" {
+		subtitle.Label = strings.TrimSpace(*req.Label)
+	}
+	if req.IsDefault != nil {
+		subtitle.IsDefault = *req.IsDefault
+	}
+
+	if err := repo.Update(subtitle); err != nil {
+		log.Printf("[Subtitles] Failed to update subtitle %s: %v", subtitleID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update subtitle"})
+	}
+
+	return c.JSON(http.StatusOK, SubtitleTrackResponse{LessonSubtitle: *subtitle, URL: subtitleTrackURL(lessonID, subtitle.ID)})
+}
+
+func deleteLessonSubtitle(c echo.Context, lessonID, subtitleID string) error {
+	repo := postgres.NewSubtitleRepository(db.DB)
+	subtitle, err := repo.GetByID(lessonID, subtitleID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch subtitle"})
+	}
+	if subtitle == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Subtitle not found"})
+	}
+
+	if err := repo.Delete(subtitleID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete subtitle"})
+	}
+
+	if minioStorage := storage.GetStorage(); minioStorage != nil {
+		if err := minioStorage.Delete(c.Request().Context(), "", subtitle.ObjectPath); err != nil {
+			log.Printf("[Subtitles] Failed to delete %s: %v", subtitle.ObjectPath, err)
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Subtitle deleted"})
+}
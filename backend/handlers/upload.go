@@ -4,13 +4,17 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log"
+	"mime/multipart"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/storage"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/video"
 )
 
 // Allowed file extensions by type
@@ -35,9 +39,10 @@ type UploadResponse struct {
 	Size     int64  `json:"size"`
 	Type     string `json:"type"`
 	ObjectKey string `json:"object_key,omitempty"` // MinIO object key
+	Duration  int    `json:"duration,omitempty"`   // Video duration in seconds (from ffprobe)
 }
 
-// UploadFile handles file upload - uploads to MinIO if configured, otherwise local
+// UploadFile handles file upload through the configured storage driver
 func UploadFile(c echo.Context) error {
 	// Get uploaded file
 	file, err := c.FormFile("file")
@@ -76,24 +81,35 @@ func UploadFile(c echo.Context) error {
 	safeFilename := sanitizeFilename(file.Filename)
 	newFilename := fmt.Sprintf("%d_%s", timestamp, safeFilename)
 
-	// Try to upload to MinIO if configured
-	minioStorage := storage.GetStorage()
-	if minioStorage != nil {
-		return uploadToMinio(c, minioStorage, src, newFilename, file.Size, fileType, file.Filename)
+	// Read the real duration so the lesson form doesn't rely on a typed value.
+	// Thumbnails and sprites are generated once the video is attached to a lesson.
+	duration := 0
+	if fileType == "video" {
+		duration = probeUploadedVideoDuration(c.Request().Context(), src)
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to read file"})
+		}
+	}
+
+	// Upload through the configured storage driver (MinIO, S3 or local disk)
+	store := storage.GetStorage()
+	if store == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{
+			"error": "Storage not configured. Set STORAGE_DRIVER to minio, s3 or local.",
+		})
 	}
 
-	// Fallback to local storage (legacy behavior)
-	return uploadToLocal(c, src, newFilename, file.Size, fileType, file.Filename)
+	return uploadToStorage(c, store, src, newFilename, file.Size, fileType, file.Filename, duration)
 }
 
-// uploadToMinio handles upload to MinIO storage
-func uploadToMinio(c echo.Context, s *storage.MinioStorage, src io.Reader, objectName string, size int64, fileType, originalFilename string) error {
+// uploadToStorage handles upload to the configured storage
+func uploadToStorage(c echo.Context, s storage.Storage, src io.Reader, objectName string, size int64, fileType, originalFilename string, duration int) error {
 	ctx := context.Background()
 	
 	// Determine content type
 	contentType := getContentType(objectName)
 	
-	// Upload to MinIO
+	// Upload to storage
 	err := s.Upload(ctx, "", objectName, src, size, contentType)
 	if err != nil {
 		// Log detailed error for debugging
@@ -110,15 +126,39 @@ func uploadToMinio(c echo.Context, s *storage.MinioStorage, src io.Reader, objec
 		Size:      size,
 		Type:      fileType,
 		ObjectKey: objectName,
+		Duration:  duration,
 	})
 }
 
-// uploadToLocal handles upload to local filesystem (legacy - deprecated)
-func uploadToLocal(c echo.Context, src io.Reader, newFilename string, size int64, fileType, originalFilename string) error {
-	// Local storage is deprecated - MinIO must be configured
-	return c.JSON(http.StatusServiceUnavailable, map[string]string{
-		"error": "Local storage is deprecated. Please configure MinIO for file storage.",
-	})
+// probeUploadedVideoDuration returns the duration in seconds of an uploaded video, or 0 if
+// it can't be determined. Large uploads are already spooled to disk and probed in place.
+func probeUploadedVideoDuration(ctx context.Context, src multipart.File) int {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	path := ""
+	if f, ok := src.(*os.File); ok {
+		path = f.Name()
+	} else {
+		tmp, err := os.CreateTemp("", "upload-probe-*")
+		if err != nil {
+			return 0
+		}
+		defer os.Remove(tmp.Name())
+		_, err = io.Copy(tmp, src)
+		tmp.Close()
+		if err != nil {
+			return 0
+		}
+		path = tmp.Name()
+	}
+
+	meta, err := video.ProbeVideo(ctx, path)
+	if err != nil {
+		log.Printf("[Upload] Could not probe video duration: %v", err)
+		return 0
+	}
+	return int(meta.Duration + 0.5)
 }
 
 // getContentType returns MIME type based on file extension
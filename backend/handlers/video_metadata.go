@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/config"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/domain"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/jobs"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/storage"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/video"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/middleware"
+)
+
+// posterWidth is the width of generated poster thumbnails
+const posterWidth = 1280
+
+// videoMediaURLTTL is how long signed poster and sprite URLs stay valid
+const videoMediaURLTTL = 6 * time.Hour
+
+// spriteFilenamePattern matches sprite sheet filenames produced by video.GenerateSprites
+var spriteFilenamePattern = regexp.MustCompile(`^sprite_\d{3,}\.jpg$`)
+
+// LessonVideoMetadata is the extracted metadata for a lesson video
+type LessonVideoMetadata struct {
+	LessonID        string    `json:"lesson_id" db:"lesson_id"`
+	SourcePath      string    `json:"-" db:"source_path"`
+	Status          string    `json:"status" db:"status"`
+	DurationSeconds *int      `json:"duration_seconds,omitempty" db:"duration_seconds"`
+	Width           *int      `json:"width,omitempty" db:"width"`
+	Height          *int      `json:"height,omitempty" db:"height"`
+	VideoCodec      *string   `json:"video_codec,omitempty" db:"video_codec"`
+	AudioCodec      *string   `json:"audio_codec,omitempty" db:"audio_codec"`
+	FrameRate       *float64  `json:"frame_rate,omitempty" db:"frame_rate"`
+	Bitrate         *int64    `json:"bitrate,omitempty" db:"bitrate"`
+	SizeBytes       *int64    `json:"size_bytes,omitempty" db:"size_bytes"`
+	PosterPath      *string   `json:"-" db:"poster_path"`
+	SpriteVTTPath   *string   `json:"-" db:"sprite_vtt_path"`
+	ErrorMessage    *string   `json:"error_message,omitempty" db:"error_message"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// queueVideoMetadata starts metadata extraction for a lesson when its video changed
+func queueVideoMetadata(lesson *domain.Lesson, previousVideoURL string) {
+	if lesson == nil || lesson.VideoURL == nil || !isHLSSourceObject(*lesson.VideoURL) {
+		return
+	}
+
+	// Skip if the same video is already extracted or being extracted
+	if *lesson.VideoURL == previousVideoURL {
+		var status string
+		err := db.DB.Get(&status, `SELECT status FROM lesson_video_metadata WHERE lesson_id = $1`, lesson.ID)
+		if err == nil && (status == "ready" || status == "processing" || status == "pending") {
+			return
+		}
+	}
+
+	enqueueVideoMetadata(lesson.ID, *lesson.VideoURL)
+}
+
+// enqueueVideoMetadata marks a lesson's metadata as pending and queues the extraction
+func enqueueVideoMetadata(lessonID, videoPath string) {
+	_, err := db.DB.Exec(`
+		INSERT INTO lesson_video_metadata (lesson_id, source_path, status)
+		VALUES ($1, $2, 'pending')
+		ON CONFLICT (lesson_id)
+		DO UPDATE SET source_path = $2, status = 'pending', error_message = NULL, updated_at = NOW()
+	`, lessonID, videoPath)
+	if err != nil {
+		log.Printf("[Video Metadata] Failed to mark lesson %s as pending: %v", lessonID, err)
+	}
+
+	payload := hlsJobPayload{LessonID: lessonID, VideoPath: videoPath}
+	enqueueJob(jobs.QueueVideo, JobExtractVideoMetadata, payload, &jobs.EnqueueOptions{MaxAttempts: 3}, func() {
+		ExtractVideoMetadata(context.Background(), lessonID, videoPath)
+	})
+}
+
+// ExtractVideoMetadata probes a lesson video, stores its duration and renders the poster
+// and seek-preview sprites next to the lesson's HLS output. ffprobe and ffmpeg are
+// stopped when ctx is done.
+func ExtractVideoMetadata(ctx context.Context, lessonID, videoPath string) error {
+	log.Printf("[Video Metadata] Extracting metadata for lesson %s, video: %s", lessonID, videoPath)
+
+	_, err := db.DB.Exec(`
+		INSERT INTO lesson_video_metadata (lesson_id, source_path, status)
+		VALUES ($1, $2, 'processing')
+		ON CONFLICT (lesson_id)
+		DO UPDATE SET source_path = $2, status = 'processing', error_message = NULL, updated_at = NOW()
+	`, lessonID, videoPath)
+	if err != nil {
+		return fmt.Errorf("failed to update metadata status: %w", err)
+	}
+
+	if err := runMetadataPipeline(ctx, lessonID, videoPath); err != nil {
+		if ctx.Err() != nil {
+			// Interrupted (shutdown or job timeout): the job queue runs it again
+			log.Printf("[Video Metadata] Extraction interrupted for lesson %s: %v", lessonID, err)
+			db.DB.Exec(`UPDATE lesson_video_metadata SET status = 'pending', updated_at = NOW() WHERE lesson_id = $1`, lessonID)
+			return err
+		}
+		log.Printf("[Video Metadata] Extraction failed for lesson %s: %v", lessonID, err)
+		db.DB.Exec(`
+			UPDATE lesson_video_metadata SET status = 'failed', error_message = $2, updated_at = NOW()
+			WHERE lesson_id = $1
+		`, lessonID, err.Error())
+		return err
+	}
+
+	log.Printf("[Video Metadata] Extraction complete for lesson %s", lessonID)
+	return nil
+}
+
+// runMetadataPipeline downloads the video, probes it and uploads the generated images
+func runMetadataPipeline(ctx context.Context, lessonID, videoPath string) error {
+	minioStorage := storage.GetStorage()
+	if minioStorage == nil {
+		return fmt.Errorf("storage not configured")
+	}
+
+	if err := video.ValidateFFmpeg(); err != nil {
+		return err
+	}
+
+	tempPath, err := downloadObjectToTemp(ctx, minioStorage, videoPath)
+	if err != nil {
+		return fmt.Errorf("failed to download source video: %w", err)
+	}
+	defer os.Remove(tempPath)
+
+	// 1. Probe and store the real duration
+	meta, err := video.ProbeVideo(ctx, tempPath)
+	if err != nil {
+		return err
+	}
+
+	durationSeconds := int(meta.Duration + 0.5)
+	_, err = db.DB.Exec(`
+		UPDATE lesson_video_metadata
+		SET duration_seconds = $2, width = $3, height = $4, video_codec = $5, audio_codec = $6,
+		    frame_rate = $7, bitrate = $8, size_bytes = $9, updated_at = NOW()
+		WHERE lesson_id = $1
+	`, lessonID, durationSeconds, meta.Width, meta.Height, meta.VideoCodec, meta.AudioCodec,
+		meta.FrameRate, meta.Bitrate, meta.SizeBytes)
+	if err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+
+	var courseID string
+	err = db.DB.Get(&courseID, `
+		UPDATE lessons SET video_duration = $2, updated_at = NOW() WHERE id = $1 RETURNING course_id
+	`, lessonID, durationSeconds)
+	if err != nil {
+		return fmt.Errorf("failed to update lesson duration: %w", err)
+	}
+	if err := recomputeCourseDuration(courseID); err != nil {
+		log.Printf("[Video Metadata] Failed to recompute duration for course %s: %v", courseID, err)
+	}
+
+	// 2. Render poster and sprites
+	workDir, err := os.MkdirTemp("", "video-meta-*")
+	if err != nil {
+		return fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	posterLocal := filepath.Join(workDir, video.PosterFilename)
+	if err := video.GeneratePoster(ctx, tempPath, posterLocal, meta.Duration, posterWidth); err != nil {
+		return err
+	}
+
+	spriteCfg := video.DefaultSpriteConfig()
+	spriteCfg.Interval = getSettingInt("video_sprite_interval_seconds", spriteCfg.Interval)
+	if spriteCfg.Interval <= 0 {
+		spriteCfg.Interval = video.DefaultSpriteConfig().Interval
+	}
+	sprites, err := video.GenerateSprites(ctx, tempPath, filepath.Join(workDir, "sprites"), meta, spriteCfg)
+	if err != nil {
+		return err
+	}
+
+	// 3. Upload alongside the HLS output
+	prefix := hlsObjectPrefix(lessonID)
+	spritePrefix := prefix + "/sprites"
+	if err := uploadHLSFile(ctx, minioStorage, posterLocal, prefix, "image/jpeg"); err != nil {
+		return err
+	}
+	for _, sheet := range sprites.SheetPaths {
+		if err := uploadHLSFile(ctx, minioStorage, sheet, spritePrefix, "image/jpeg"); err != nil {
+			return err
+		}
+	}
+	if err := uploadHLSFile(ctx, minioStorage, sprites.VTTPath, spritePrefix, "text/vtt"); err != nil {
+		return err
+	}
+
+	_, err = db.DB.Exec(`
+		UPDATE lesson_video_metadata
+		SET poster_path = $2, sprite_vtt_path = $3, status = 'ready', error_message = NULL, updated_at = NOW()
+		WHERE lesson_id = $1
+	`, lessonID, prefix+"/"+video.PosterFilename, spritePrefix+"/"+video.SpriteVTTFilename)
+	if err != nil {
+		return fmt.Errorf("failed to save metadata status: %w", err)
+	}
+
+	return nil
+}
+
+// recomputeCourseDuration totals a course's lesson video durations into courses.duration
+func recomputeCourseDuration(courseID string) error {
+	var total int
+	err := db.DB.Get(&total, `
+		SELECT COALESCE(SUM(video_duration), 0) FROM lessons WHERE course_id = $1
+	`, courseID)
+	if err != nil {
+		return err
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	_, err = db.DB.Exec(`
+		UPDATE courses SET duration_seconds = $2, duration = $3, updated_at = NOW() WHERE id = $1
+	`, courseID, total, formatCourseDuration(total))
+	return err
+}
+
+// formatCourseDuration formats seconds the way course durations are displayed, e.g. "2 Jam 15 Menit"
+func formatCourseDuration(seconds int) string {
+	minutes := (seconds + 59) / 60
+	hours := minutes / 60
+	minutes = minutes % 60
+
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%d Menit", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%d Jam", hours)
+	default:
+		return fmt.Sprintf("%d Jam %d Menit", hours, minutes)
+	}
+}
+
+// videoMediaSigningSecret is the HMAC secret for poster and sprite URLs
+func videoMediaSigningSecret() []byte {
+	return []byte("video-media:" + config.JWTSecret())
+}
+
+// signedVideoMediaURL returns a signed URL for a poster or sprite image of a lesson
+func signedVideoMediaURL(lessonID, filename string) string {
+	return fmt.Sprintf("/api/media/lessons/%s/%s?%s", lessonID, filename,
+		video.SignedSegmentQuery(videoMediaSigningSecret(), lessonID, filename, videoMediaURLTTL))
+}
+
+// GetLessonVideoMetadata returns the extracted metadata, poster and thumbnail track of a lesson video
+// GET /api/content/:lessonId/video-metadata
+func GetLessonVideoMetadata(c echo.Context) error {
+	lessonID := c.Param("lessonId")
+
+	userID, role, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	if err := verifyContentAccess(userID, role, lessonID); err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+
+	var meta LessonVideoMetadata
+	err = db.DB.Get(&meta, `
+		SELECT lesson_id, source_path, status, duration_seconds, width, height, video_codec,
+		       audio_codec, frame_rate, bitrate, size_bytes, poster_path, sprite_vtt_path,
+		       error_message, updated_at
+		FROM lesson_video_metadata WHERE lesson_id = $1
+	`, lessonID)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "No video metadata for this lesson"})
+	}
+	if err != nil {
+		log.Printf("[Video Metadata] Failed to fetch metadata for lesson %s: %v", lessonID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch video metadata"})
+	}
+
+	resp := map[string]interface{}{"metadata": meta}
+	if meta.PosterPath != nil {
+		resp["poster_url"] = signedVideoMediaURL(lessonID, video.PosterFilename)
+	}
+	if meta.SpriteVTTPath != nil {
+		resp["thumbnails_url"] = fmt.Sprintf("/api/content/%s/thumbnails.vtt", lessonID)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GetLessonThumbnailTrack serves the seek-preview WebVTT track with signed sprite URLs
+// GET /api/content/:lessonId/thumbnails.vtt
+func GetLessonThumbnailTrack(c echo.Context) error {
+	lessonID := c.Param("lessonId")
+
+	userID, role, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+	if err := verifyContentAccess(userID, role, lessonID); err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+
+	var vttPath sql.NullString
+	err = db.DB.Get(&vttPath, `SELECT sprite_vtt_path FROM lesson_video_metadata WHERE lesson_id = $1`, lessonID)
+	if err != nil || !vttPath.Valid {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Thumbnail track not available"})
+	}
+
+	content, err := loadHLSPlaylist(c.Request().Context(), vttPath.String)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Thumbnail track not found"})
+	}
+
+	// Point every cue at a signed sprite URL, keeping the #xywh fragment
+	signed := make(map[string]string)
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		filename, fragment, found := strings.Cut(strings.TrimSpace(line), "#xywh=")
+		if !found || !spriteFilenamePattern.MatchString(filename) {
+			continue
+		}
+		url, ok := signed[filename]
+		if !ok {
+			url = signedVideoMediaURL(lessonID, filename)
+			signed[filename] = url
+		}
+		lines[i] = url + "#xywh=" + fragment
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	return c.Blob(http.StatusOK, "text/vtt; charset=utf-8", []byte(strings.Join(lines, "\n")))
+}
+
+// GetVideoMediaImage serves a poster or sprite image; the signed URL is the credential
+// so it works in plain <img> and CSS backgrounds
+// GET /api/media/lessons/:lessonId/:filename?expires=...&sig=...
+func GetVideoMediaImage(c echo.Context) error {
+	lessonID := c.Param("lessonId")
+	filename := c.Param("filename")
+
+	var objectPath string
+	switch {
+	case filename == video.PosterFilename:
+		objectPath = hlsObjectPrefix(lessonID) + "/" + filename
+	case spriteFilenamePattern.MatchString(filename):
+		objectPath = hlsObjectPrefix(lessonID) + "/sprites/" + filename
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid filename"})
+	}
+
+	if err := video.VerifySegmentSignature(videoMediaSigningSecret(), lessonID, filename, c.QueryParam("expires"), c.QueryParam("sig")); err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Image URL " + err.Error()})
+	}
+
+	minioStorage := storage.GetStorage()
+	if minioStorage == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Storage not available"})
+	}
+
+	ctx := c.Request().Context()
+	obj, err := minioStorage.GetObject(ctx, "", objectPath)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Image not found"})
+	}
+	defer obj.Close()
+
+	c.Response().Header().Set("Cache-Control", "private, max-age=3600")
+	c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+	return c.Stream(http.StatusOK, "image/jpeg", obj)
+}
+
+// ProcessLessonVideoMetadata manually (re)runs metadata extraction for a lesson video
+// POST /api/admin/lessons/:id/metadata
+func ProcessLessonVideoMetadata(c echo.Context) error {
+	lessonID := c.Param("id")
+
+	var videoURL sql.NullString
+	err := db.DB.Get(&videoURL, `SELECT video_url FROM lessons WHERE id = $1`, lessonID)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Lesson not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch lesson"})
+	}
+
+	if !videoURL.Valid || !isHLSSourceObject(videoURL.String) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Lesson has no uploaded video"})
+	}
+
+	if !storage.IsConfigured() {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Storage not available"})
+	}
+
+	enqueueVideoMetadata(lessonID, videoURL.String)
+
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "Video metadata extraction started",
+		"status":  "pending",
+	})
+}
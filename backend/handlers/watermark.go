@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/domain"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/video"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/middleware"
+)
+
+// Watermarks are a deterrent overlay: the player draws the viewer's code over the
+// video, and the code is recorded so a leaked screen recording can be traced with
+// LookupWatermark. The served stream itself is not marked, so a viewer who strips
+// the overlay from their client (or downloads the segments) leaks an unmarked copy.
+// The code also travels in the manifest and the X-Watermark-Code header, which
+// identifies whose session a leaked manifest came from but not a re-encoded video.
+
+// watermarkReuseWindow is how long a viewer keeps the same code for a lesson,
+// so manifest reloads and seeks don't create a new record each time
+const watermarkReuseWindow = 1 * time.Hour
+
+// watermarkHeader exposes the viewer's watermark code on protected playback responses
+const watermarkHeader = "X-Watermark-Code"
+
+// WatermarkInfo describes the overlay the player must render on top of a protected video
+type WatermarkInfo struct {
+	Code                string    `json:"code"`
+	Text                string    `json:"text"`
+	Opacity             float64   `json:"opacity"`
+	MoveIntervalSeconds int       `json:"move_interval_seconds"`
+	IssuedAt            time.Time `json:"issued_at"`
+}
+
+// watermarkRequired reports whether playback of a lesson by this user must be watermarked.
+// Lessons at full_drm are always watermarked; tenants on the "advanced" DRM protection
+// level also watermark their aes_128 lessons.
+func watermarkRequired(userID, lessonID string) (bool, error) {
+	var required bool
+	err := db.DB.Get(&required, `
+		SELECT COALESCE(l.security_level, '') = 'full_drm'
+		    OR (COALESCE(t.feature_config->>'drm_protection_level', '') = 'advanced'
+		        AND COALESCE(l.security_level, '') = 'aes_128')
+		FROM lessons l
+		LEFT JOIN users u ON u.id = $2
+		LEFT JOIN tenants t ON t.id = u.tenant_id
+		WHERE l.id = $1
+	`, lessonID, userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return required, err
+}
+
+// issueWatermark returns the watermark a viewer must see for a lesson, or nil when the
+// lesson doesn't need one. Admins are never watermarked.
+func issueWatermark(c echo.Context, userID, role, lessonID string) (*WatermarkInfo, error) {
+	if role == "admin" {
+		return nil, nil
+	}
+
+	required, err := watermarkRequired(userID, lessonID)
+	if err != nil || !required {
+		return nil, err
+	}
+
+	var viewer struct {
+		Email        string  `db:"email"`
+		CourseID     string  `db:"course_id"`
+		EnrollmentID *string `db:"enrollment_id"`
+	}
+	err = db.DB.Get(&viewer, `
+		SELECT u.email, l.course_id, e.id as enrollment_id
+		FROM users u
+		JOIN lessons l ON l.id = $2
+		LEFT JOIN enrollments e ON e.user_id = u.id AND e.course_id = l.course_id
+		WHERE u.id = $1
+	`, userID, lessonID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load viewer: %w", err)
+	}
+
+	repo := postgres.NewWatermarkRepository(db.DB)
+	mark, err := repo.GetRecent(userID, lessonID, time.Now().Add(-watermarkReuseWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	if mark == nil {
+		code, err := video.GenerateWatermarkCode()
+		if err != nil {
+			return nil, err
+		}
+		ip := c.RealIP()
+		ua := c.Request().UserAgent()
+		mark = &domain.VideoWatermark{
+			Code:         code,
+			UserID:       userID,
+			LessonID:     lessonID,
+			CourseID:     viewer.CourseID,
+			EnrollmentID: viewer.EnrollmentID,
+			IPAddress:    &ip,
+			UserAgent:    &ua,
+		}
+		if err := repo.Create(mark); err != nil {
+			return nil, fmt.Errorf("failed to record watermark: %w", err)
+		}
+	}
+
+	return &WatermarkInfo{
+		Code:                mark.Code,
+		Text:                video.MaskEmail(viewer.Email) + " · " + mark.Code,
+		Opacity:             getSettingFloat("watermark_opacity", 0.35),
+		MoveIntervalSeconds: getSettingInt("watermark_move_interval_seconds", 20),
+		IssuedAt:            mark.IssuedAt,
+	}, nil
+}
+
+// embedWatermarkInPlaylist adds the watermark code to a master playlist as session data,
+// so it travels with the manifest even if the player overlay is stripped
+func embedWatermarkInPlaylist(content string, mark *WatermarkInfo) string {
+	sessionData := fmt.Sprintf(`#EXT-X-SESSION-DATA:DATA-ID="com.edukra.watermark",VALUE="%s"`, mark.Code)
+	if strings.HasPrefix(content, "#EXTM3U\n") {
+		return "#EXTM3U\n" + sessionData + "\n" + strings.TrimPrefix(content, "#EXTM3U\n")
+	}
+	return content
+}
+
+// GetLessonWatermark returns the overlay a player must draw on a protected lesson
+// GET /api/content/:lessonId/watermark
+func GetLessonWatermark(c echo.Context) error {
+	lessonID := c.Param("lessonId")
+	if lessonID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Lesson ID is required"})
+	}
+
+	userID, role, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	if err := verifyContentAccess(userID, role, lessonID); err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+
+	mark, err := issueWatermark(c, userID, role, lessonID)
+	if err != nil {
+		log.Printf("[Watermark] Failed to issue watermark for lesson %s: %v", lessonID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to issue watermark"})
+	}
+
+	if mark == nil {
+		return c.JSON(http.StatusOK, map[string]interface{}{"enabled": false})
+	}
+
+	c.Response().Header().Set(watermarkHeader, mark.Code)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"enabled":   true,
+		"watermark": mark,
+	})
+}
+
+// LookupWatermark resolves a code read off a leaked frame to the viewer and enrollment
+// GET /api/admin/watermarks/:code
+func LookupWatermark(c echo.Context) error {
+	code := video.NormalizeWatermarkCode(c.Param("code"))
+	if code == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Watermark code is required"})
+	}
+
+	repo := postgres.NewWatermarkRepository(db.DB)
+	trace, err := repo.GetTraceByCode(code)
+	if err != nil {
+		log.Printf("[Watermark] Lookup failed for %s: %v", code, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to look up watermark"})
+	}
+	if trace == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Watermark code not found"})
+	}
+
+	return c.JSON(http.StatusOK, trace)
+}
@@ -40,9 +40,10 @@ type Embedder interface {
 
 // EmbedderConfig holds configuration for embedders
 type EmbedderConfig struct {
-	APIKey  string
-	Model   string
-	BaseURL string
+	APIKey     string
+	Model      string
+	BaseURL    string
+	Dimensions int // Optional; 0 lets self-hosted embedders detect it from the first response
 }
 
 // OpenAIEmbedder implements Embedder using OpenAI
@@ -160,7 +161,9 @@ func (e *OpenAIEmbedder) EmbedBatch(ctx context.Context, texts []string) ([]Embe
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+e.config.APIKey)
+	if e.config.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+e.config.APIKey)
+	}
 
 	resp, err := e.httpClient.Do(httpReq)
 	if err != nil {
@@ -191,6 +194,9 @@ func (e *OpenAIEmbedder) EmbedBatch(ctx context.Context, texts []string) ([]Embe
 	tokensPerItem := embResp.Usage.TotalTokens / len(texts)
 	
 	for _, data := range embResp.Data {
+		if data.Index < 0 || data.Index >= len(results) {
+			return nil, fmt.Errorf("embedding index %d out of range", data.Index)
+		}
 		results[data.Index] = EmbeddingResult{
 			Embedding:  data.Embedding,
 			TokensUsed: tokensPerItem,
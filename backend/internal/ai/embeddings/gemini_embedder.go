@@ -84,8 +84,13 @@ func (e *GeminiEmbedder) GetModel() string {
 
 // Dimensions returns embedding dimensions based on model
 func (e *GeminiEmbedder) Dimensions() int {
-	// Gemini text-embedding-004 returns 768-dimensional embeddings
-	return 768
+	switch e.GetModel() {
+	case "gemini-embedding-001":
+		return 3072
+	default:
+		// text-embedding-004 returns 768-dimensional embeddings
+		return 768
+	}
 }
 
 // Embed generates embedding for a single text
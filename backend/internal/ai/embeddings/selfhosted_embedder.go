@@ -0,0 +1,89 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// SelfHostedEmbedder implements Embedder against an OpenAI-compatible
+// /embeddings endpoint (vLLM, llama.cpp server, Ollama). Model names and
+// dimensions vary per server, so dimensions are either configured or detected
+// from the first response.
+type SelfHostedEmbedder struct {
+	*OpenAIEmbedder
+
+	mu   sync.Mutex
+	dims int
+}
+
+// NewSelfHostedEmbedder creates a new self-hosted embedder
+func NewSelfHostedEmbedder(config EmbedderConfig) *SelfHostedEmbedder {
+	if config.Model == "" {
+		config.Model = "nomic-embed-text"
+	}
+	return &SelfHostedEmbedder{
+		OpenAIEmbedder: NewOpenAIEmbedder(config),
+		dims:           config.Dimensions,
+	}
+}
+
+// Name returns the embedder name
+func (e *SelfHostedEmbedder) Name() string {
+	return "selfhosted"
+}
+
+// GetModel returns the current model
+func (e *SelfHostedEmbedder) GetModel() string {
+	return e.config.Model
+}
+
+// Dimensions returns the configured or detected dimensions, 0 if not yet known
+func (e *SelfHostedEmbedder) Dimensions() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.dims
+}
+
+// DetectDimensions embeds a probe text when dimensions are not known yet
+func (e *SelfHostedEmbedder) DetectDimensions(ctx context.Context) (int, error) {
+	if dims := e.Dimensions(); dims > 0 {
+		return dims, nil
+	}
+	if _, err := e.Embed(ctx, "dimension probe"); err != nil {
+		return 0, err
+	}
+	return e.Dimensions(), nil
+}
+
+// Embed generates embedding for a single text
+func (e *SelfHostedEmbedder) Embed(ctx context.Context, text string) (*EmbeddingResult, error) {
+	results, err := e.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no embedding returned")
+	}
+	return &results[0], nil
+}
+
+// EmbedBatch generates embeddings and checks they all have the expected size
+func (e *SelfHostedEmbedder) EmbedBatch(ctx context.Context, texts []string) ([]EmbeddingResult, error) {
+	results, err := e.OpenAIEmbedder.EmbedBatch(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range results {
+		if e.dims == 0 {
+			e.dims = len(r.Embedding)
+		}
+		if len(r.Embedding) != e.dims {
+			return nil, fmt.Errorf("embedding has %d dimensions, expected %d", len(r.Embedding), e.dims)
+		}
+	}
+	return results, nil
+}
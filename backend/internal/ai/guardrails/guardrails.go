@@ -0,0 +1,94 @@
+// Package guardrails screens AI tutor traffic: prompt-injection attempts in
+// student messages and course material, and personal data that must not reach
+// external providers.
+package guardrails
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// injectionRule is a named pattern of a prompt-injection attempt
+type injectionRule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// injectionRules cover English and Indonesian phrasings. They target instructions
+// aimed at the model, not topics, so course material about security still passes.
+var injectionRules = []injectionRule{
+	{"ignore_instructions", regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\s+(all\s+|any\s+|the\s+|your\s+)*(previous|prior|above|earlier|system|original)\s+(instructions?|prompts?|rules|directions)`)},
+	// Like the English rule, only instructions aimed at the model: "semua instruksi",
+	// "instruksi sebelumnya", "aturanmu"; not "abaikan perintah tersebut" in course text
+	{"ignore_instructions", regexp.MustCompile(`(?i)\b(abaikan|lupakan|hiraukan|langgar)\s+((semua|seluruh|setiap)\s+(instruksi|perintah|aturan|prompt)|(instruksi|perintah|aturan|prompt)(mu\b|\s+(sebelumnya|di\s+atas|sistem|awal|tadi|kamu|anda|yang\s+(diberikan|sebelumnya|di\s+atas))))`)},
+	{"reveal_prompt", regexp.MustCompile(`(?i)\b(reveal|show|print|repeat|output|leak)\s+(me\s+)?(your|the)\s+(system\s+prompt|hidden\s+instructions|initial\s+instructions|instructions\s+above)`)},
+	{"reveal_prompt", regexp.MustCompile(`(?i)\b(tampilkan|tunjukkan|bocorkan|ulangi|sebutkan)\s+(isi\s+)?(prompt|instruksi)\s+(sistem|awal|rahasia)`)},
+	{"role_override", regexp.MustCompile(`(?i)\b(you\s+are\s+no\s+longer|from\s+now\s+on\s+you\s+are|pretend\s+(that\s+)?you\s+have\s+no\s+(rules|restrictions))`)},
+	{"role_override", regexp.MustCompile(`(?i)\b(mulai\s+sekarang\s+kamu\s+(adalah|bukan)|kamu\s+bukan\s+lagi|berpura-pura\s+tidak\s+punya\s+aturan)`)},
+	{"jailbreak", regexp.MustCompile(`(?i)\b(jailbreak|developer\s+mode|do\s+anything\s+now|DAN\s+mode)\b`)},
+	{"chat_markup", regexp.MustCompile(`(?im)(<\|im_start\|>|<\|system\|>|\[/?INST\]|<<SYS>>|^\s*#{2,}\s*system\b|^\s*system\s*:)`)},
+}
+
+// DetectInjection reports whether text contains a prompt-injection attempt and
+// which rule matched
+func DetectInjection(text string) (string, bool) {
+	for _, rule := range injectionRules {
+		if rule.pattern.MatchString(text) {
+			return rule.name, true
+		}
+	}
+	return "", false
+}
+
+// piiRule replaces one kind of personal data. Order matters: NIK runs before
+// phone numbers so a 16-digit NIK isn't partly taken for a phone number.
+type piiRule struct {
+	kind        string
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+var piiRules = []piiRule{
+	{"email", regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[EMAIL]"},
+	// Nomor Induk Kependudukan: 16 digits, sometimes grouped 6-6-4
+	{"nik", regexp.MustCompile(`\b\d{6}[ .-]?\d{6}[ .-]?\d{4}\b`), "[NIK]"},
+	// Indonesian mobile numbers: 08xx, 628xx or +628xx
+	{"phone", regexp.MustCompile(`(\+62|\b62|\b0)[ -]?8\d{1,2}[ .-]?\d{3,4}[ .-]?\d{3,5}\b`), "[NOMOR TELEPON]"},
+}
+
+// RedactPII replaces emails, phone numbers and NIKs in text with placeholders.
+// It returns the redacted text and how many of each kind were found.
+func RedactPII(text string) (string, map[string]int) {
+	var found map[string]int
+	for _, rule := range piiRules {
+		matches := rule.pattern.FindAllStringIndex(text, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		if found == nil {
+			found = make(map[string]int)
+		}
+		found[rule.kind] += len(matches)
+		text = rule.pattern.ReplaceAllString(text, rule.replacement)
+	}
+	return text, found
+}
+
+var smallTalk = regexp.MustCompile(`(?i)^(h(a|e)llo|hai|hi|hey|halo|pagi|siang|sore|malam|selamat\s+\w+|terima\s*kasih|makasih|thanks?(\s+you)?|ok(e|ay)?|baik|siap|sip|mantap)\b`)
+
+// IsSmallTalk reports whether a message is a greeting or acknowledgement that
+// needs no course material to answer
+func IsSmallTalk(text string) bool {
+	text = strings.TrimSpace(text)
+	return smallTalk.MatchString(text) && len(strings.Fields(text)) <= 4
+}
+
+// Excerpt shortens text for event logs
+func Excerpt(text string, maxRunes int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= maxRunes {
+		return text
+	}
+	return string([]rune(text)[:maxRunes]) + "…"
+}
@@ -33,9 +33,17 @@ func (p *ContentProcessor) ProcessText(content string) []rag.Chunk {
 	return p.chunker.ChunkText(content)
 }
 
-// ProcessHTML processes HTML content (strips tags first)
+// ProcessHTML processes HTML or Markdown content, chunking along its headings
 func (p *ContentProcessor) ProcessHTML(html string) []rag.Chunk {
-	return p.chunker.ChunkHTML(html)
+	return p.chunker.ChunkHTMLStructured(html)
+}
+
+// ProcessSubtitles processes a WebVTT subtitle track, keeping cue timestamps on each chunk
+func (p *ContentProcessor) ProcessSubtitles(vtt string) []rag.Chunk {
+	if cues := rag.ParseVTTCues(vtt); len(cues) > 0 {
+		return p.chunker.ChunkCues(cues)
+	}
+	return p.chunker.ChunkText(parseVTTToText(vtt))
 }
 
 // ProcessPDFFromURL downloads and processes a PDF from URL
@@ -61,7 +69,8 @@ func (p *ContentProcessor) ProcessPDFFromFile(filePath string) ([]rag.Chunk, err
 	}
 	fmt.Printf("[PDF] Extracted %d characters of text\n", len(text))
 
-	chunks := p.chunker.ChunkText(text)
+	// Pages are separated by form feeds, so chunks can cite their page
+	chunks := p.chunker.ChunkPages(text)
 	fmt.Printf("[PDF] Created %d chunks\n", len(chunks))
 	return chunks, nil
 }
@@ -107,7 +116,7 @@ except ImportError:
             reader = PyPDF2.PdfReader(f)
             text = ''
             for page in reader.pages:
-                text += page.extract_text() + '\n'
+                text += (page.extract_text() or '') + '\f'
             print(text)
     except ImportError:
         print("ERROR: No PDF library available")
@@ -164,16 +173,16 @@ func (p *ContentProcessor) ProcessYouTubeTranscript(ctx context.Context, videoUR
 	}
 
 	// Try to get transcript using yt-dlp
-	text, err := p.getYouTubeTranscriptWithYTDLP(ctx, videoID)
+	vtt, err := p.getYouTubeTranscriptWithYTDLP(ctx, videoID)
 	if err != nil {
 		// Fallback to YouTube transcript API
 		return nil, fmt.Errorf("could not get YouTube transcript: %w", err)
 	}
 
-	return p.chunker.ChunkText(text), nil
+	return p.ProcessSubtitles(vtt), nil
 }
 
-// getYouTubeTranscriptWithYTDLP uses yt-dlp to get subtitles
+// getYouTubeTranscriptWithYTDLP uses yt-dlp to get subtitles as raw WebVTT
 func (p *ContentProcessor) getYouTubeTranscriptWithYTDLP(ctx context.Context, videoID string) (string, error) {
 	tempFile := filepath.Join(p.tempDir, fmt.Sprintf("yt_%s", videoID))
 	defer os.Remove(tempFile + ".id.vtt")
@@ -207,8 +216,7 @@ func (p *ContentProcessor) getYouTubeTranscriptWithYTDLP(ctx context.Context, vi
 		return "", err
 	}
 
-	// Parse VTT to plain text
-	return parseVTTToText(string(content)), nil
+	return string(content), nil
 }
 
 // parseVTTToText converts VTT format to plain text
@@ -0,0 +1,149 @@
+package providers
+
+import (
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// CircuitBreaker stops sending requests to a provider after consecutive failures.
+// Once the cooldown passes a single trial request is let through (half-open);
+// success closes the breaker, failure opens it again.
+type CircuitBreaker struct {
+	mu               sync.Mutex
+	name             string
+	state            string
+	consecutiveFails int
+	openedAt         time.Time
+	trialInFlight    bool
+	lastError        string
+}
+
+// BreakerStatus is a snapshot of a circuit breaker
+type BreakerStatus struct {
+	Name             string     `json:"name"`
+	State            string     `json:"state"`
+	ConsecutiveFails int        `json:"consecutive_failures"`
+	OpenedAt         *time.Time `json:"opened_at,omitempty"`
+	LastError        string     `json:"last_error,omitempty"`
+}
+
+// Allow reports whether a request may be sent, given the cooldown after opening
+func (b *CircuitBreaker) Allow(cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.trialInFlight = true
+		return true
+	case BreakerHalfOpen:
+		if b.trialInFlight {
+			return false
+		}
+		b.trialInFlight = true
+		return true
+	default:
+		return true
+	}
+}
+
+// RecordSuccess closes the breaker
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.consecutiveFails = 0
+	b.trialInFlight = false
+}
+
+// RecordFailure counts a failure and opens the breaker at the threshold, or
+// immediately when the half-open trial fails
+func (b *CircuitBreaker) RecordFailure(err error, threshold int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.consecutiveFails++
+	b.trialInFlight = false
+	if err != nil {
+		b.lastError = err.Error()
+	}
+	if b.state == BreakerHalfOpen || b.consecutiveFails >= threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Release gives back a half-open trial slot without counting the outcome, for
+// requests that were cancelled by the caller rather than failed by the provider
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialInFlight = false
+}
+
+// Status returns a snapshot of the breaker
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		Name:             b.name,
+		State:            b.state,
+		ConsecutiveFails: b.consecutiveFails,
+		LastError:        b.lastError,
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// Breakers are shared across requests so failures seen by one student's chat
+// protect everyone else's
+var (
+	breakersMu sync.Mutex
+	breakers   = make(map[string]*CircuitBreaker)
+)
+
+// GetBreaker returns the process-wide breaker for a key (provider/model)
+func GetBreaker(key string) *CircuitBreaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+
+	b, ok := breakers[key]
+	if !ok {
+		b = &CircuitBreaker{name: key, state: BreakerClosed}
+		breakers[key] = b
+	}
+	return b
+}
+
+// BreakerStatuses returns a snapshot of every breaker seen so far
+func BreakerStatuses() []BreakerStatus {
+	breakersMu.Lock()
+	list := make([]*CircuitBreaker, 0, len(breakers))
+	for _, b := range breakers {
+		list = append(list, b)
+	}
+	breakersMu.Unlock()
+
+	statuses := make([]BreakerStatus, 0, len(list))
+	for _, b := range list {
+		statuses = append(statuses, b.Status())
+	}
+	return statuses
+}
@@ -58,10 +58,14 @@ type claudeStreamEvent struct {
 		Text string `json:"text"`
 	} `json:"content_block,omitempty"`
 	Delta *struct {
-		Type string `json:"type"`
-		Text string `json:"text,omitempty"`
+		Type       string `json:"type"`
+		Text       string `json:"text,omitempty"`
+		StopReason string `json:"stop_reason,omitempty"`
 	} `json:"delta,omitempty"`
 	Message *claudeChatResponse `json:"message,omitempty"`
+	Usage   *struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage,omitempty"`
 }
 
 type claudeErrorResponse struct {
@@ -312,55 +316,44 @@ func (p *ClaudeProvider) ChatStream(ctx context.Context, req ChatRequest) (<-cha
 		defer close(ch)
 		defer resp.Body.Close()
 
-		reader := resp.Body
-		buffer := make([]byte, 4096)
-
-		for {
-			select {
-			case <-ctx.Done():
-				ch <- StreamChunk{Done: true, Error: ctx.Err()}
-				return
-			default:
-			}
-
-			n, err := reader.Read(buffer)
-			if err != nil {
-				if err == io.EOF {
-					ch <- StreamChunk{Done: true}
-					return
-				}
-				ch <- StreamChunk{Done: true, Error: err}
-				return
+		final := StreamChunk{Done: true, Model: p.GetModel()}
+		err := readSSE(ctx, resp.Body, func(data string) bool {
+			var event claudeStreamEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				return false
 			}
 
-			data := string(buffer[:n])
-			lines := strings.Split(data, "\n")
-
-			for _, line := range lines {
-				line = strings.TrimSpace(line)
-				if line == "" {
-					continue
-				}
-
-				if strings.HasPrefix(line, "data: ") {
-					jsonData := strings.TrimPrefix(line, "data: ")
-					var event claudeStreamEvent
-					if err := json.Unmarshal([]byte(jsonData), &event); err != nil {
-						continue
+			switch event.Type {
+			case "message_start":
+				// Input tokens are reported up front
+				if event.Message != nil {
+					final.TokensInput = event.Message.Usage.InputTokens
+					if event.Message.Model != "" {
+						final.Model = event.Message.Model
 					}
-
-					switch event.Type {
-					case "content_block_delta":
-						if event.Delta != nil && event.Delta.Text != "" {
-							ch <- StreamChunk{Content: event.Delta.Text}
-						}
-					case "message_stop":
-						ch <- StreamChunk{Done: true}
-						return
+				}
+			case "content_block_delta":
+				if event.Delta != nil && event.Delta.Text != "" {
+					if !sendChunk(ctx, ch, StreamChunk{Content: event.Delta.Text}) {
+						return true
 					}
 				}
+			case "message_delta":
+				// Output tokens and the stop reason arrive just before message_stop
+				if event.Usage != nil {
+					final.TokensOutput = event.Usage.OutputTokens
+				}
+				if event.Delta != nil && event.Delta.StopReason != "" {
+					final.FinishReason = event.Delta.StopReason
+				}
+			case "message_stop":
+				return true
 			}
-		}
+			return false
+		})
+
+		final.Error = err
+		sendChunk(ctx, ch, final)
 	}()
 
 	return ch, nil
@@ -322,58 +322,38 @@ func (p *GeminiProvider) ChatStream(ctx context.Context, req ChatRequest) (<-cha
 		defer close(ch)
 		defer resp.Body.Close()
 
-		reader := resp.Body
-		buffer := make([]byte, 4096)
-
-		for {
-			select {
-			case <-ctx.Done():
-				ch <- StreamChunk{Done: true, Error: ctx.Err()}
-				return
-			default:
+		final := StreamChunk{Done: true, Model: p.GetModel()}
+		err := readSSE(ctx, resp.Body, func(data string) bool {
+			var geminiResp geminiGenerateResponse
+			if err := json.Unmarshal([]byte(data), &geminiResp); err != nil {
+				return false
 			}
 
-			n, err := reader.Read(buffer)
-			if err != nil {
-				if err == io.EOF {
-					ch <- StreamChunk{Done: true}
-					return
-				}
-				ch <- StreamChunk{Done: true, Error: err}
-				return
+			// Usage metadata is cumulative, the last chunk has the totals
+			if geminiResp.UsageMetadata.TotalTokenCount > 0 {
+				final.TokensInput = geminiResp.UsageMetadata.PromptTokenCount
+				final.TokensOutput = geminiResp.UsageMetadata.CandidatesTokenCount
 			}
 
-			data := string(buffer[:n])
-			lines := strings.Split(data, "\n")
-
-			for _, line := range lines {
-				line = strings.TrimSpace(line)
-				if line == "" {
-					continue
-				}
-
-				if strings.HasPrefix(line, "data: ") {
-					jsonData := strings.TrimPrefix(line, "data: ")
-					var geminiResp geminiGenerateResponse
-					if err := json.Unmarshal([]byte(jsonData), &geminiResp); err != nil {
-						continue
-					}
-
-					if len(geminiResp.Candidates) > 0 {
-						for _, part := range geminiResp.Candidates[0].Content.Parts {
-							if part.Text != "" {
-								ch <- StreamChunk{Content: part.Text}
-							}
-						}
-
-						if geminiResp.Candidates[0].FinishReason != "" {
-							ch <- StreamChunk{Done: true}
-							return
+			if len(geminiResp.Candidates) > 0 {
+				for _, part := range geminiResp.Candidates[0].Content.Parts {
+					if part.Text != "" {
+						if !sendChunk(ctx, ch, StreamChunk{Content: part.Text}) {
+							return true
 						}
 					}
 				}
+
+				if geminiResp.Candidates[0].FinishReason != "" {
+					final.FinishReason = geminiResp.Candidates[0].FinishReason
+					return true
+				}
 			}
-		}
+			return false
+		})
+
+		final.Error = err
+		sendChunk(ctx, ch, final)
 	}()
 
 	return ch, nil
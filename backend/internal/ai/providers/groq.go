@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 	"time"
 )
 
@@ -62,6 +61,12 @@ type groqStreamChunk struct {
 		} `json:"delta"`
 		FinishReason *string `json:"finish_reason"`
 	} `json:"choices"`
+	XGroq *struct {
+		Usage *struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage,omitempty"`
+	} `json:"x_groq,omitempty"`
 }
 
 type groqErrorResponse struct {
@@ -286,57 +291,37 @@ func (p *GroqProvider) ChatStream(ctx context.Context, req ChatRequest) (<-chan
 		defer close(ch)
 		defer resp.Body.Close()
 
-		reader := resp.Body
-		buffer := make([]byte, 4096)
-
-		for {
-			select {
-			case <-ctx.Done():
-				ch <- StreamChunk{Done: true, Error: ctx.Err()}
-				return
-			default:
+		final := StreamChunk{Done: true, Model: p.GetModel()}
+		err := readSSE(ctx, resp.Body, func(data string) bool {
+			var chunk groqStreamChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return false
 			}
-
-			n, err := reader.Read(buffer)
-			if err != nil {
-				if err == io.EOF {
-					ch <- StreamChunk{Done: true}
-					return
-				}
-				ch <- StreamChunk{Done: true, Error: err}
-				return
+			if chunk.Model != "" {
+				final.Model = chunk.Model
 			}
 
-			data := string(buffer[:n])
-			lines := strings.Split(data, "\n")
-
-			for _, line := range lines {
-				line = strings.TrimSpace(line)
-				if line == "" || line == "data: [DONE]" {
-					continue
-				}
-
-				if strings.HasPrefix(line, "data: ") {
-					jsonData := strings.TrimPrefix(line, "data: ")
-					var chunk groqStreamChunk
-					if err := json.Unmarshal([]byte(jsonData), &chunk); err != nil {
-						continue
-					}
-
-					if len(chunk.Choices) > 0 {
-						content := chunk.Choices[0].Delta.Content
-						if content != "" {
-							ch <- StreamChunk{Content: content}
-						}
+			// Groq reports usage on the last chunk under x_groq
+			if chunk.XGroq != nil && chunk.XGroq.Usage != nil {
+				final.TokensInput = chunk.XGroq.Usage.PromptTokens
+				final.TokensOutput = chunk.XGroq.Usage.CompletionTokens
+			}
 
-						if chunk.Choices[0].FinishReason != nil {
-							ch <- StreamChunk{Done: true}
-							return
-						}
+			if len(chunk.Choices) > 0 {
+				if content := chunk.Choices[0].Delta.Content; content != "" {
+					if !sendChunk(ctx, ch, StreamChunk{Content: content}) {
+						return true
 					}
 				}
+				if chunk.Choices[0].FinishReason != nil {
+					final.FinishReason = *chunk.Choices[0].FinishReason
+				}
 			}
-		}
+			return false
+		})
+
+		final.Error = err
+		sendChunk(ctx, ch, final)
 	}()
 
 	return ch, nil
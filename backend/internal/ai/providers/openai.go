@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 	"time"
 )
 
@@ -25,6 +24,12 @@ type openAIChatRequest struct {
 	MaxTokens   int              `json:"max_tokens,omitempty"`
 	Temperature float64          `json:"temperature,omitempty"`
 	Stream      bool             `json:"stream,omitempty"`
+	// StreamOptions asks for token usage in the final stream chunk
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type openAIMessage struct {
@@ -62,6 +67,10 @@ type openAIStreamChunk struct {
 		} `json:"delta"`
 		FinishReason *string `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage,omitempty"`
 }
 
 type openAIErrorResponse struct {
@@ -119,7 +128,9 @@ func (p *OpenAIProvider) ValidateAPIKey() error {
 		return err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	if p.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	}
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
@@ -184,7 +195,9 @@ func (p *OpenAIProvider) Chat(ctx context.Context, req ChatRequest) (*ChatRespon
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	if p.config.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	}
 
 	resp, err := p.httpClient.Do(httpReq)
 	if err != nil {
@@ -236,11 +249,12 @@ func (p *OpenAIProvider) ChatStream(ctx context.Context, req ChatRequest) (<-cha
 
 	// Prepare request
 	openAIReq := openAIChatRequest{
-		Model:       p.GetModel(),
-		Messages:    messages,
-		MaxTokens:   req.MaxTokens,
-		Temperature: req.Temperature,
-		Stream:      true,
+		Model:         p.GetModel(),
+		Messages:      messages,
+		MaxTokens:     req.MaxTokens,
+		Temperature:   req.Temperature,
+		Stream:        true,
+		StreamOptions: &openAIStreamOptions{IncludeUsage: true},
 	}
 
 	// Set defaults
@@ -268,7 +282,9 @@ func (p *OpenAIProvider) ChatStream(ctx context.Context, req ChatRequest) (<-cha
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	if p.config.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	}
 	httpReq.Header.Set("Accept", "text/event-stream")
 
 	resp, err := p.httpClient.Do(httpReq)
@@ -292,57 +308,37 @@ func (p *OpenAIProvider) ChatStream(ctx context.Context, req ChatRequest) (<-cha
 		defer close(ch)
 		defer resp.Body.Close()
 
-		reader := resp.Body
-		buffer := make([]byte, 4096)
-
-		for {
-			select {
-			case <-ctx.Done():
-				ch <- StreamChunk{Done: true, Error: ctx.Err()}
-				return
-			default:
+		final := StreamChunk{Done: true, Model: p.GetModel()}
+		err := readSSE(ctx, resp.Body, func(data string) bool {
+			var chunk openAIStreamChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return false
 			}
-
-			n, err := reader.Read(buffer)
-			if err != nil {
-				if err == io.EOF {
-					ch <- StreamChunk{Done: true}
-					return
-				}
-				ch <- StreamChunk{Done: true, Error: err}
-				return
+			if chunk.Model != "" {
+				final.Model = chunk.Model
 			}
 
-			data := string(buffer[:n])
-			lines := strings.Split(data, "\n")
-
-			for _, line := range lines {
-				line = strings.TrimSpace(line)
-				if line == "" || line == "data: [DONE]" {
-					continue
-				}
-
-				if strings.HasPrefix(line, "data: ") {
-					jsonData := strings.TrimPrefix(line, "data: ")
-					var chunk openAIStreamChunk
-					if err := json.Unmarshal([]byte(jsonData), &chunk); err != nil {
-						continue
-					}
-
-					if len(chunk.Choices) > 0 {
-						content := chunk.Choices[0].Delta.Content
-						if content != "" {
-							ch <- StreamChunk{Content: content}
-						}
+			// With include_usage the last chunk has no choices, only usage
+			if chunk.Usage != nil {
+				final.TokensInput = chunk.Usage.PromptTokens
+				final.TokensOutput = chunk.Usage.CompletionTokens
+			}
 
-						if chunk.Choices[0].FinishReason != nil {
-							ch <- StreamChunk{Done: true}
-							return
-						}
+			if len(chunk.Choices) > 0 {
+				if content := chunk.Choices[0].Delta.Content; content != "" {
+					if !sendChunk(ctx, ch, StreamChunk{Content: content}) {
+						return true
 					}
 				}
+				if chunk.Choices[0].FinishReason != nil {
+					final.FinishReason = *chunk.Choices[0].FinishReason
+				}
 			}
-		}
+			return false
+		})
+
+		final.Error = err
+		sendChunk(ctx, ch, final)
 	}()
 
 	return ch, nil
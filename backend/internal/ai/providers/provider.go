@@ -26,13 +26,20 @@ type ChatResponse struct {
 	TokensOutput int    `json:"tokens_output"`
 	Model        string `json:"model"`
 	FinishReason string `json:"finish_reason"`
+	Provider     string `json:"provider,omitempty"` // Set by Router to the provider that answered
 }
 
-// StreamChunk for streaming responses
+// StreamChunk for streaming responses. The final chunk has Done set and carries
+// token usage when the provider reports it.
 type StreamChunk struct {
-	Content string `json:"content"`
-	Done    bool   `json:"done"`
-	Error   error  `json:"error,omitempty"`
+	Content      string `json:"content"`
+	Done         bool   `json:"done"`
+	Error        error  `json:"error,omitempty"`
+	TokensInput  int    `json:"tokens_input,omitempty"`
+	TokensOutput int    `json:"tokens_output,omitempty"`
+	Model        string `json:"model,omitempty"`
+	FinishReason string `json:"finish_reason,omitempty"`
+	Provider     string `json:"provider,omitempty"` // Set by Router on every chunk
 }
 
 // Provider interface for AI chat providers
@@ -62,7 +69,8 @@ type ProviderConfig struct {
 	Model       string
 	MaxTokens   int
 	Temperature float64
-	BaseURL     string // Optional custom base URL
+	BaseURL     string   // Optional custom base URL
+	Models      []string // Optional model list, for self-hosted servers
 }
 
 // DefaultConfig returns a ProviderConfig with sensible defaults
@@ -107,6 +115,11 @@ func (f *ProviderFactory) GetProvider(name string) (Provider, error) {
 		return nil, fmt.Errorf("API key not set for provider %s", name)
 	}
 
+	return NewProvider(name, config)
+}
+
+// NewProvider creates a provider instance by name
+func NewProvider(name string, config ProviderConfig) (Provider, error) {
 	switch name {
 	case "openai":
 		return NewOpenAIProvider(config), nil
@@ -116,6 +129,8 @@ func (f *ProviderFactory) GetProvider(name string) (Provider, error) {
 		return NewGroqProvider(config), nil
 	case "gemini":
 		return NewGeminiProvider(config), nil
+	case "selfhosted":
+		return NewSelfHostedProvider(config), nil
 	default:
 		return nil, fmt.Errorf("unknown provider: %s", name)
 	}
@@ -123,7 +138,7 @@ func (f *ProviderFactory) GetProvider(name string) (Provider, error) {
 
 // AvailableProviders returns list of supported provider names
 func AvailableProviders() []string {
-	return []string{"openai", "claude", "groq", "gemini"}
+	return []string{"openai", "claude", "groq", "gemini", "selfhosted"}
 }
 
 // ProviderInfo provides information about a provider
@@ -161,6 +176,12 @@ func (f *ProviderFactory) GetProviderInfo() []ProviderInfo {
 			Models:      []string{"gemini-2.0-flash-exp", "gemini-1.5-pro", "gemini-1.5-flash"},
 			Configured:  f.configs["gemini"].APIKey != "",
 		},
+		{
+			Name:        "selfhosted",
+			DisplayName: "Self-hosted (OpenAI-compatible)",
+			Models:      f.configs["selfhosted"].Models,
+			Configured:  f.configs["selfhosted"].BaseURL != "",
+		},
 	}
 	return infos
 }
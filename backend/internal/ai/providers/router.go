@@ -0,0 +1,263 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrAllProvidersFailed is returned when every provider in the chain failed or was skipped
+var ErrAllProvidersFailed = errors.New("all AI providers failed")
+
+// RouterConfig controls failover and circuit breaking
+type RouterConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens a breaker
+	FailureThreshold int
+	// Cooldown is how long an open breaker rejects requests before a trial request
+	Cooldown time.Duration
+	// AttemptTimeout bounds the wait for the first chunk of a stream. Zero disables it.
+	AttemptTimeout time.Duration
+	// ChatTimeout bounds a whole non-streaming Chat attempt, which takes longer the
+	// more tokens the caller asks for. Zero disables it.
+	ChatTimeout time.Duration
+}
+
+// DefaultRouterConfig returns a RouterConfig with sensible defaults
+func DefaultRouterConfig() RouterConfig {
+	return RouterConfig{
+		FailureThreshold: 3,
+		Cooldown:         60 * time.Second,
+		AttemptTimeout:   30 * time.Second,
+		ChatTimeout:      120 * time.Second,
+	}
+}
+
+// Router is a Provider that tries an ordered chain of providers, skipping those
+// whose circuit breaker is open. Breakers are keyed by provider name and model,
+// so the same provider can appear in the chain with different models.
+type Router struct {
+	chain  []Provider
+	config RouterConfig
+}
+
+// NewRouter creates a router over the given providers, in priority order
+func NewRouter(chain []Provider, config RouterConfig) *Router {
+	if config.FailureThreshold < 1 {
+		config.FailureThreshold = 1
+	}
+	return &Router{chain: chain, config: config}
+}
+
+// BreakerKey returns the circuit breaker key for a provider
+func BreakerKey(p Provider) string {
+	return p.Name() + "/" + p.GetModel()
+}
+
+// Name returns the name of the primary provider
+func (r *Router) Name() string {
+	if len(r.chain) == 0 {
+		return "router"
+	}
+	return r.chain[0].Name()
+}
+
+// GetModel returns the model of the primary provider
+func (r *Router) GetModel() string {
+	if len(r.chain) == 0 {
+		return ""
+	}
+	return r.chain[0].GetModel()
+}
+
+// AvailableModels returns the models of the primary provider
+func (r *Router) AvailableModels() []string {
+	if len(r.chain) == 0 {
+		return nil
+	}
+	return r.chain[0].AvailableModels()
+}
+
+// ValidateAPIKey validates the primary provider's key
+func (r *Router) ValidateAPIKey() error {
+	if len(r.chain) == 0 {
+		return ErrAllProvidersFailed
+	}
+	return r.chain[0].ValidateAPIKey()
+}
+
+// Chat tries each provider in order until one answers. A timed-out attempt fails
+// over to the next provider but isn't counted against the breaker: a non-streaming
+// call only returns once the whole answer is generated, so running out of time on a
+// long generation says nothing about the provider's health.
+func (r *Router) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	var failures []string
+	for _, p := range r.chain {
+		key := BreakerKey(p)
+		breaker := GetBreaker(key)
+		if !breaker.Allow(r.config.Cooldown) {
+			failures = append(failures, key+": circuit open")
+			continue
+		}
+
+		attemptCtx, cancel := r.attemptContext(ctx)
+		resp, err := p.Chat(attemptCtx, req)
+		timedOut := attemptCtx.Err() == context.DeadlineExceeded
+		cancel()
+
+		if err == nil {
+			breaker.RecordSuccess()
+			resp.Provider = p.Name()
+			if resp.Model == "" {
+				resp.Model = p.GetModel()
+			}
+			return resp, nil
+		}
+
+		// The caller gave up; that says nothing about the provider
+		if ctx.Err() != nil {
+			breaker.Release()
+			return nil, ctx.Err()
+		}
+
+		if timedOut || isTimeout(err) {
+			breaker.Release()
+			failures = append(failures, fmt.Sprintf("%s: timed out: %v", key, err))
+			continue
+		}
+		breaker.RecordFailure(err, r.config.FailureThreshold)
+		failures = append(failures, fmt.Sprintf("%s: %v", key, err))
+	}
+
+	return nil, r.chainError(failures)
+}
+
+// isTimeout reports whether err is a deadline or network timeout
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// ChatStream tries each provider in order until one starts streaming. Failover
+// only happens before the first chunk; once tokens have been forwarded a
+// mid-stream error is passed through to the caller and counted against the
+// provider.
+func (r *Router) ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error) {
+	var failures []string
+	for _, p := range r.chain {
+		key := BreakerKey(p)
+		breaker := GetBreaker(key)
+		if !breaker.Allow(r.config.Cooldown) {
+			failures = append(failures, key+": circuit open")
+			continue
+		}
+
+		streamCtx, cancel := context.WithCancel(ctx)
+		upstream, err := p.ChatStream(streamCtx, req)
+		if err == nil {
+			var first StreamChunk
+			first, err = r.firstChunk(ctx, upstream)
+			if err == nil {
+				out := make(chan StreamChunk)
+				go r.forward(streamCtx, cancel, p, breaker, first, upstream, out)
+				return out, nil
+			}
+		}
+		cancel()
+
+		if ctx.Err() != nil {
+			breaker.Release()
+			return nil, ctx.Err()
+		}
+		breaker.RecordFailure(err, r.config.FailureThreshold)
+		failures = append(failures, fmt.Sprintf("%s: %v", key, err))
+	}
+
+	return nil, r.chainError(failures)
+}
+
+// firstChunk waits for the first chunk of a stream, bounded by AttemptTimeout
+func (r *Router) firstChunk(ctx context.Context, upstream <-chan StreamChunk) (StreamChunk, error) {
+	var timeout <-chan time.Time
+	if r.config.AttemptTimeout > 0 {
+		timer := time.NewTimer(r.config.AttemptTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case chunk, ok := <-upstream:
+		if !ok {
+			return StreamChunk{}, errors.New("stream closed before first chunk")
+		}
+		if chunk.Error != nil {
+			return StreamChunk{}, chunk.Error
+		}
+		return chunk, nil
+	case <-timeout:
+		return StreamChunk{}, fmt.Errorf("no response after %s", r.config.AttemptTimeout)
+	case <-ctx.Done():
+		return StreamChunk{}, ctx.Err()
+	}
+}
+
+// forward relays an upstream stream, stamping the answering provider on each
+// chunk and recording the outcome on its breaker
+func (r *Router) forward(ctx context.Context, cancel context.CancelFunc, p Provider, breaker *CircuitBreaker, first StreamChunk, upstream <-chan StreamChunk, out chan<- StreamChunk) {
+	defer close(out)
+	defer cancel()
+
+	chunk, ok := first, true
+	for ok {
+		chunk.Provider = p.Name()
+		if chunk.Done {
+			if chunk.Model == "" {
+				chunk.Model = p.GetModel()
+			}
+			if chunk.Error != nil {
+				if ctx.Err() != nil {
+					breaker.Release()
+				} else {
+					breaker.RecordFailure(chunk.Error, r.config.FailureThreshold)
+				}
+			} else {
+				breaker.RecordSuccess()
+			}
+			sendChunk(ctx, out, chunk)
+			return
+		}
+		if !sendChunk(ctx, out, chunk) {
+			breaker.Release()
+			return
+		}
+		chunk, ok = <-upstream
+	}
+
+	// Upstream closed without a final chunk
+	if ctx.Err() != nil {
+		breaker.Release()
+		return
+	}
+	err := errors.New("stream ended unexpectedly")
+	breaker.RecordFailure(err, r.config.FailureThreshold)
+	sendChunk(ctx, out, StreamChunk{Done: true, Error: err, Provider: p.Name(), Model: p.GetModel()})
+}
+
+func (r *Router) attemptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.config.ChatTimeout > 0 {
+		return context.WithTimeout(ctx, r.config.ChatTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (r *Router) chainError(failures []string) error {
+	if len(failures) == 0 {
+		return ErrAllProvidersFailed
+	}
+	return fmt.Errorf("%w: %s", ErrAllProvidersFailed, strings.Join(failures, "; "))
+}
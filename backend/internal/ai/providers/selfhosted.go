@@ -0,0 +1,39 @@
+package providers
+
+import (
+	"fmt"
+)
+
+// SelfHostedProvider talks to any server exposing the OpenAI chat completions
+// API (vLLM, llama.cpp server, Ollama), so course data never leaves the
+// tenant's network. The API key is optional.
+type SelfHostedProvider struct {
+	*OpenAIProvider
+}
+
+// NewSelfHostedProvider creates a provider for an OpenAI-compatible base URL,
+// e.g. http://ollama:11434/v1
+func NewSelfHostedProvider(config ProviderConfig) *SelfHostedProvider {
+	if config.Model == "" && len(config.Models) > 0 {
+		config.Model = config.Models[0]
+	}
+	return &SelfHostedProvider{OpenAIProvider: NewOpenAIProvider(config)}
+}
+
+// Name returns the provider name
+func (p *SelfHostedProvider) Name() string {
+	return "selfhosted"
+}
+
+// AvailableModels returns the models configured for the server
+func (p *SelfHostedProvider) AvailableModels() []string {
+	return p.config.Models
+}
+
+// ValidateAPIKey checks that the server is reachable and accepts the key
+func (p *SelfHostedProvider) ValidateAPIKey() error {
+	if p.config.BaseURL == "" {
+		return fmt.Errorf("base URL not set")
+	}
+	return p.OpenAIProvider.ValidateAPIKey()
+}
@@ -0,0 +1,54 @@
+package providers
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+)
+
+// maxSSELineSize bounds a single server-sent event line
+const maxSSELineSize = 1024 * 1024
+
+// readSSE calls onData with the payload of every "data:" line of a server-sent
+// event stream until onData returns true, the stream ends with [DONE] or EOF, or
+// ctx is cancelled. Lines are read whole, so events split across network reads
+// are never dropped.
+func readSSE(ctx context.Context, body io.Reader, onData func(data string) bool) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxSSELineSize)
+
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return nil
+		}
+		if onData(data) {
+			return nil
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return scanner.Err()
+}
+
+// sendChunk delivers a chunk unless the consumer has gone away
+func sendChunk(ctx context.Context, ch chan<- StreamChunk, chunk StreamChunk) bool {
+	select {
+	case ch <- chunk:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -34,6 +34,47 @@ type Chunk struct {
 	StartChar  int    `json:"start_char"`
 	EndChar    int    `json:"end_char"`
 	TokenCount int    `json:"token_count"` // Estimated
+
+	// Structural location, set by the structure-aware chunkers
+	Heading   string   `json:"heading,omitempty"`
+	Page      int      `json:"page,omitempty"`
+	StartTime *float64 `json:"start_time,omitempty"`
+	EndTime   *float64 `json:"end_time,omitempty"`
+}
+
+// Metadata returns the chunk's structural location for storage alongside its embedding
+func (ch Chunk) Metadata() map[string]interface{} {
+	metadata := map[string]interface{}{}
+	if ch.Heading != "" {
+		metadata["heading"] = ch.Heading
+	}
+	if ch.Page > 0 {
+		metadata["page"] = ch.Page
+	}
+	if ch.StartTime != nil {
+		metadata["start_time"] = *ch.StartTime
+	}
+	if ch.EndTime != nil {
+		metadata["end_time"] = *ch.EndTime
+	}
+	return metadata
+}
+
+// Locator returns a human-readable location such as "hal. 12" or "03:41"
+func (ch Chunk) Locator() string {
+	return FormatLocator(ch.Heading, ch.Page, ch.StartTime)
+}
+
+// FormatLocator formats a heading path, page or timestamp for citations
+func FormatLocator(heading string, page int, startTime *float64) string {
+	switch {
+	case page > 0:
+		return fmt.Sprintf("hal. %d", page)
+	case startTime != nil:
+		return FormatTimestamp(*startTime)
+	default:
+		return heading
+	}
 }
 
 // Chunker handles text chunking for embeddings
@@ -0,0 +1,149 @@
+package rag
+
+import (
+	"context"
+	"time"
+)
+
+// EvalQuestion is a golden question with the lessons that should answer it
+type EvalQuestion struct {
+	ID                string   `json:"id"`
+	Question          string   `json:"question"`
+	ExpectedLessonIDs []string `json:"expected_lesson_ids"`
+	ReferenceAnswer   string   `json:"reference_answer,omitempty"`
+}
+
+// EvalResult is the retrieval quality for one golden question
+type EvalResult struct {
+	QuestionID string `json:"question_id"`
+	Question   string `json:"question"`
+	// RetrievedLessonIDs are the distinct lessons of the retrieved chunks, in rank order
+	RetrievedLessonIDs []string `json:"retrieved_lesson_ids"`
+	// CitedLessonIDs are the lessons BuildContext would cite to the student
+	CitedLessonIDs   []string `json:"cited_lesson_ids"`
+	RecallAtK        float64  `json:"recall_at_k"`
+	ReciprocalRank   float64  `json:"reciprocal_rank"`
+	CitationAccuracy float64  `json:"citation_accuracy"`
+	LatencyMs        int64    `json:"latency_ms"`
+	Error            string   `json:"error,omitempty"`
+}
+
+// EvalSummary averages EvalResults over a question set. Questions that failed
+// count as misses.
+type EvalSummary struct {
+	Questions        int     `json:"questions"`
+	Errors           int     `json:"errors"`
+	RecallAtK        float64 `json:"recall_at_k"`
+	MRR              float64 `json:"mrr"`
+	CitationAccuracy float64 `json:"citation_accuracy"`
+	HitRate          float64 `json:"hit_rate"` // Share of questions with an expected lesson retrieved
+}
+
+// Evaluate runs golden questions through the retriever and scores each one
+func (r *Retriever) Evaluate(ctx context.Context, courseID string, questions []EvalQuestion) ([]EvalResult, EvalSummary) {
+	results := make([]EvalResult, 0, len(questions))
+	for _, q := range questions {
+		if ctx.Err() != nil {
+			break
+		}
+
+		start := time.Now()
+		chunks, err := r.Retrieve(ctx, courseID, q.Question)
+		latency := time.Since(start).Milliseconds()
+
+		var result EvalResult
+		if err != nil {
+			result = EvalResult{Error: err.Error()}
+		} else {
+			_, sources := r.BuildContext(chunks)
+			result = ScoreRetrieval(q.ExpectedLessonIDs, chunks, sources)
+		}
+		result.QuestionID = q.ID
+		result.Question = q.Question
+		result.LatencyMs = latency
+		results = append(results, result)
+	}
+
+	return results, SummarizeEval(results)
+}
+
+// ScoreRetrieval computes recall@k, reciprocal rank and citation accuracy of
+// retrieved chunks against the expected lessons. k is the number of chunks retrieved.
+func ScoreRetrieval(expectedLessonIDs []string, chunks []RetrievedChunk, sources []Source) EvalResult {
+	expected := make(map[string]bool, len(expectedLessonIDs))
+	for _, id := range expectedLessonIDs {
+		expected[id] = true
+	}
+
+	result := EvalResult{
+		RetrievedLessonIDs: []string{},
+		CitedLessonIDs:     []string{},
+	}
+
+	seen := make(map[string]bool)
+	found := 0
+	for rank, chunk := range chunks {
+		if seen[chunk.LessonID] {
+			continue
+		}
+		seen[chunk.LessonID] = true
+		result.RetrievedLessonIDs = append(result.RetrievedLessonIDs, chunk.LessonID)
+
+		if expected[chunk.LessonID] {
+			found++
+			if result.ReciprocalRank == 0 {
+				result.ReciprocalRank = 1 / float64(rank+1)
+			}
+		}
+	}
+	if len(expected) > 0 {
+		result.RecallAtK = float64(found) / float64(len(expected))
+	}
+
+	// Citations are per lesson location; a lesson cited twice counts once
+	cited := make(map[string]bool)
+	correct := 0
+	for _, source := range sources {
+		if cited[source.LessonID] {
+			continue
+		}
+		cited[source.LessonID] = true
+		result.CitedLessonIDs = append(result.CitedLessonIDs, source.LessonID)
+		if expected[source.LessonID] {
+			correct++
+		}
+	}
+	if len(result.CitedLessonIDs) > 0 {
+		result.CitationAccuracy = float64(correct) / float64(len(result.CitedLessonIDs))
+	}
+
+	return result
+}
+
+// SummarizeEval averages the metrics of a run
+func SummarizeEval(results []EvalResult) EvalSummary {
+	summary := EvalSummary{Questions: len(results)}
+	if len(results) == 0 {
+		return summary
+	}
+
+	hits := 0
+	for _, r := range results {
+		if r.Error != "" {
+			summary.Errors++
+		}
+		summary.RecallAtK += r.RecallAtK
+		summary.MRR += r.ReciprocalRank
+		summary.CitationAccuracy += r.CitationAccuracy
+		if r.ReciprocalRank > 0 {
+			hits++
+		}
+	}
+
+	n := float64(len(results))
+	summary.RecallAtK /= n
+	summary.MRR /= n
+	summary.CitationAccuracy /= n
+	summary.HitRate = float64(hits) / n
+	return summary
+}
@@ -0,0 +1,156 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/providers"
+)
+
+// SummaryPrompt instructs the model to fold older turns into a session's running summary
+const SummaryPrompt = `Kamu merangkum percakapan antara siswa dan AI Tutor agar percakapan bisa dilanjutkan tanpa riwayat lengkapnya.
+
+Gabungkan ringkasan sebelumnya (jika ada) dengan percakapan baru menjadi SATU ringkasan yang:
+- Mencatat topik dan pertanyaan yang sudah dibahas beserta inti jawabannya
+- Mencatat hal yang belum dipahami siswa, preferensi, atau tujuan belajar yang disebutkan
+- Menggunakan bahasa yang sama dengan percakapan
+- Maksimal 250 kata, berupa poin-poin singkat
+
+Tulis HANYA ringkasannya, tanpa pembuka atau penutup.`
+
+// summaryHeading introduces the running summary in the system prompt
+const summaryHeading = "\n\n## RINGKASAN PERCAKAPAN SEBELUMNYA\n"
+
+// EstimateTokens approximates the token count of text (~4 chars per token)
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// MessagesTokens approximates the token count of messages
+func MessagesTokens(messages []providers.Message) int {
+	total := 0
+	for _, m := range messages {
+		total += EstimateTokens(m.Content)
+	}
+	return total
+}
+
+// SplitHistory splits the history not yet in the summary into older turns to fold
+// into it and recent turns to keep verbatim. Nothing is folded while the history
+// fits tokenBudget; otherwise the last keepRecent messages are kept.
+func SplitHistory(history []providers.Message, tokenBudget, keepRecent int) (older, recent []providers.Message) {
+	if tokenBudget <= 0 || MessagesTokens(history) <= tokenBudget || len(history) <= keepRecent {
+		return nil, history
+	}
+	cut := len(history) - keepRecent
+	return history[:cut], history[cut:]
+}
+
+// BuildSummaryMessages builds the request that folds older turns into the running summary
+func BuildSummaryMessages(previousSummary string, turns []providers.Message) []providers.Message {
+	var sb strings.Builder
+	if previousSummary != "" {
+		sb.WriteString("Ringkasan sebelumnya:\n")
+		sb.WriteString(previousSummary)
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString("Percakapan baru:\n")
+	for _, m := range turns {
+		role := "Siswa"
+		if m.Role == "assistant" {
+			role = "Tutor"
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", role, m.Content)
+	}
+
+	return []providers.Message{
+		{Role: "system", Content: SummaryPrompt},
+		{Role: "user", Content: sb.String()},
+	}
+}
+
+// Conversation is the input of a tutor prompt
+type Conversation struct {
+	Context  string              // RAG context; empty when nothing was retrieved
+	Summary  string              // Running summary of earlier turns
+	History  []providers.Message // Recent turns not covered by the summary
+	Question string
+	// TokenLimit caps the estimated prompt size; 0 means unlimited. The oldest
+	// history is dropped first, then the context is truncated.
+	TokenLimit int
+}
+
+// BuildConversation creates the message array for the AI from the running summary,
+// recent turns and RAG context, fitted to the conversation's token limit
+func (b *PromptBuilder) BuildConversation(conv Conversation) []providers.Message {
+	system := b.template.SystemPrompt
+	if conv.Summary != "" {
+		system += summaryHeading + conv.Summary
+	}
+
+	question := conv.Question
+	if conv.Context != "" {
+		question = fmt.Sprintf(b.template.QuestionPrompt, conv.Question)
+	}
+
+	contextStr := conv.Context
+	history := conv.History
+	if conv.TokenLimit > 0 {
+		available := conv.TokenLimit - EstimateTokens(system) - EstimateTokens(question)
+		if contextStr != "" {
+			overhead := EstimateTokens(b.template.ContextPrompt) + EstimateTokens(contextAcknowledgement)
+			contextStr = truncateToTokens(contextStr, available-overhead)
+			available -= overhead + EstimateTokens(contextStr)
+		}
+		history = fitHistory(history, available)
+	}
+
+	messages := []providers.Message{{Role: "system", Content: system}}
+	if contextStr != "" {
+		messages = append(messages,
+			providers.Message{Role: "user", Content: fmt.Sprintf(b.template.ContextPrompt, contextStr)},
+			providers.Message{Role: "assistant", Content: contextAcknowledgement},
+		)
+	}
+	for _, msg := range history {
+		if msg.Role != "system" {
+			messages = append(messages, msg)
+		}
+	}
+	return append(messages, providers.Message{Role: "user", Content: question})
+}
+
+// fitHistory keeps the most recent messages that fit in maxTokens
+func fitHistory(history []providers.Message, maxTokens int) []providers.Message {
+	start := len(history)
+	used := 0
+	for start > 0 {
+		tokens := EstimateTokens(history[start-1].Content)
+		if used+tokens > maxTokens {
+			break
+		}
+		used += tokens
+		start--
+	}
+	// Don't open the history with a dangling assistant reply
+	for start < len(history) && history[start].Role == "assistant" {
+		start++
+	}
+	return history[start:]
+}
+
+// truncateToTokens cuts text to roughly maxTokens, on a UTF-8 boundary
+func truncateToTokens(text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	maxChars := maxTokens * 4
+	if len(text) <= maxChars {
+		return text
+	}
+	for maxChars > 0 && !utf8.RuneStart(text[maxChars]) {
+		maxChars--
+	}
+	return text[:maxChars]
+}
@@ -51,6 +51,9 @@ Berikan jawaban yang membantu berdasarkan materi di atas. Jika tidak ada informa
 	}
 }
 
+// contextAcknowledgement is the assistant turn that follows the RAG context
+const contextAcknowledgement = "Baik, saya sudah membaca materi referensi tersebut. Silakan ajukan pertanyaan Anda."
+
 // PromptBuilder builds prompts for the AI
 type PromptBuilder struct {
 	template PromptTemplate
@@ -78,7 +81,7 @@ func (b *PromptBuilder) BuildMessages(context, question string, chatHistory []pr
 		})
 		messages = append(messages, providers.Message{
 			Role:    "assistant",
-			Content: "Baik, saya sudah membaca materi referensi tersebut. Silakan ajukan pertanyaan Anda.",
+			Content: contextAcknowledgement,
 		})
 	}
 
@@ -18,6 +18,14 @@ type RetrievedChunk struct {
 	Text           string  `json:"text"`
 	SimilarityScore float64 `json:"similarity_score"`
 	SourceReference string  `json:"source_reference,omitempty"`
+	Heading         string   `json:"heading,omitempty"`
+	Page            int      `json:"page,omitempty"`
+	StartTime       *float64 `json:"start_time,omitempty"`
+}
+
+// Locator returns the chunk's page, timestamp or heading for citations
+func (c RetrievedChunk) Locator() string {
+	return FormatLocator(c.Heading, c.Page, c.StartTime)
 }
 
 // Source represents a source reference for citations
@@ -27,6 +35,10 @@ type Source struct {
 	ContentType string  `json:"content_type"`
 	Preview     string  `json:"preview"`
 	Relevance   float64 `json:"relevance"`
+	Heading     string   `json:"heading,omitempty"`    // Heading path within the lesson
+	Page        int      `json:"page,omitempty"`       // PDF page, for deep links
+	StartTime   *float64 `json:"start_time,omitempty"` // Video offset in seconds
+	Reference   string   `json:"reference,omitempty"`  // Display label, e.g. "hal. 12" or "03:41"
 }
 
 // RetrieverConfig holds configuration for the retriever
@@ -34,6 +46,7 @@ type RetrieverConfig struct {
 	TopK            int     // Number of chunks to retrieve
 	MinSimilarity   float64 // Minimum similarity threshold
 	MaxContextChars int     // Maximum total context characters
+	RRFScoreScale   float64 // Scales MinSimilarity to the RRF score range of hybrid search
 }
 
 // DefaultRetrieverConfig returns sensible defaults
@@ -42,6 +55,7 @@ func DefaultRetrieverConfig() RetrieverConfig {
 		TopK:            5,
 		MinSimilarity:   0.3, // Lowered from 0.7 to allow more relevant chunks
 		MaxContextChars: 8000, // ~2000 tokens
+		RRFScoreScale:   0.01, // RRF scores are ~0.01-0.03
 	}
 }
 
@@ -121,7 +135,11 @@ func (r *Retriever) Retrieve(ctx context.Context, courseID, query string) ([]Ret
 
 	// Filter by minimum similarity (RRF scores are typically smaller, so we adjust threshold)
 	var filtered []RetrievedChunk
-	minScore := r.config.MinSimilarity * 0.01 // Adjust for RRF scoring (scores are ~0.01-0.03 range)
+	scale := r.config.RRFScoreScale
+	if scale <= 0 {
+		scale = DefaultRetrieverConfig().RRFScoreScale
+	}
+	minScore := r.config.MinSimilarity * scale
 	for _, chunk := range chunks {
 		fmt.Printf("[Retriever] Chunk %s score: %.4f (threshold: %.4f)\n", chunk.ID, chunk.SimilarityScore, minScore)
 		if chunk.SimilarityScore >= minScore {
@@ -142,7 +160,7 @@ func (r *Retriever) BuildContext(chunks []RetrievedChunk) (string, []Source) {
 	var context string
 	var sources []Source
 	totalChars := 0
-	seenLessons := make(map[string]bool)
+	seenSources := make(map[string]bool)
 
 	for i, chunk := range chunks {
 		// Check if we've exceeded max context
@@ -151,19 +169,29 @@ func (r *Retriever) BuildContext(chunks []RetrievedChunk) (string, []Source) {
 		}
 
 		// Add to context
-		context += fmt.Sprintf("[Sumber %d: %s]\n%s\n\n", i+1, chunk.LessonTitle, chunk.Text)
+		locator := chunk.Locator()
+		label := chunk.LessonTitle
+		if locator != "" {
+			label += " — " + locator
+		}
+		context += fmt.Sprintf("[Sumber %d: %s]\n%s\n\n", i+1, label, chunk.Text)
 		totalChars += len(chunk.Text)
 
-		// Add unique source
-		if !seenLessons[chunk.LessonID] {
+		// Add unique source per lesson location
+		key := chunk.LessonID + "|" + locator
+		if !seenSources[key] {
 			sources = append(sources, Source{
 				LessonID:    chunk.LessonID,
 				LessonTitle: chunk.LessonTitle,
 				ContentType: chunk.ContentType,
 				Preview:     truncateText(chunk.Text, 100),
 				Relevance:   chunk.SimilarityScore,
+				Heading:     chunk.Heading,
+				Page:        chunk.Page,
+				StartTime:   chunk.StartTime,
+				Reference:   locator,
 			})
-			seenLessons[chunk.LessonID] = true
+			seenSources[key] = true
 		}
 	}
 
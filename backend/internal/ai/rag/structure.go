@@ -0,0 +1,227 @@
+package rag
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Section is a structural unit of a document: the text under one heading, one
+// PDF page, or one transcript cue. Chunks never span two sections' locators.
+type Section struct {
+	Heading   string   // Heading path, e.g. "Bab 2 > Pajak Penghasilan"
+	Page      int      // 1-based PDF page, 0 if not paged
+	StartTime *float64 // Seconds into the video, for transcript cues
+	EndTime   *float64
+	Text      string
+}
+
+// ChunkSections chunks each section on its own and tags the chunks with the
+// section's heading, page or time range
+func (c *Chunker) ChunkSections(sections []Section) []Chunk {
+	var chunks []Chunk
+	for _, section := range sections {
+		if strings.TrimSpace(section.Text) == "" {
+			continue
+		}
+		for _, chunk := range c.ChunkText(section.Text) {
+			if chunk.Text == "" {
+				continue
+			}
+			chunk.Index = len(chunks)
+			chunk.Heading = section.Heading
+			chunk.Page = section.Page
+			chunk.StartTime = section.StartTime
+			chunk.EndTime = section.EndTime
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks
+}
+
+var (
+	htmlHeadingRe = regexp.MustCompile(`(?is)<h([1-6])[^>]*>(.*?)</h[1-6]>`)
+	mdHeadingRe   = regexp.MustCompile(`(?m)^(#{1,6})[ \t]+(.+?)[ \t#]*$`)
+	htmlTagRe     = regexp.MustCompile(`<[^>]+>`)
+)
+
+// ChunkHTMLStructured chunks rich text along its headings. HTML headings
+// (<h1>..<h6>) are used when present, otherwise Markdown "#" headings.
+func (c *Chunker) ChunkHTMLStructured(content string) []Chunk {
+	if htmlHeadingRe.MatchString(content) {
+		return c.ChunkSections(splitByHeadings(content, htmlHeadingRe, func(m []string) (int, string) {
+			level, _ := strconv.Atoi(m[1])
+			return level, html.UnescapeString(strings.TrimSpace(htmlTagRe.ReplaceAllString(m[2], "")))
+		}, c.stripHTML))
+	}
+	if mdHeadingRe.MatchString(content) && !strings.Contains(content, "</") {
+		return c.ChunkSections(splitByHeadings(content, mdHeadingRe, func(m []string) (int, string) {
+			return len(m[1]), strings.TrimSpace(m[2])
+		}, func(s string) string { return s }))
+	}
+	return c.ChunkHTML(content)
+}
+
+// splitByHeadings cuts content at heading matches, tracking the heading path
+func splitByHeadings(content string, re *regexp.Regexp, heading func(m []string) (int, string), clean func(string) string) []Section {
+	var sections []Section
+	var path []string
+	var levels []int
+
+	matches := re.FindAllStringSubmatchIndex(content, -1)
+	current := ""
+	pos := 0
+	for _, loc := range matches {
+		sections = append(sections, Section{Heading: current, Text: clean(content[pos:loc[0]])})
+
+		groups := make([]string, len(loc)/2)
+		for i := range groups {
+			if loc[2*i] >= 0 {
+				groups[i] = content[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		level, title := heading(groups)
+
+		// Pop headings at the same or a deeper level
+		for len(levels) > 0 && levels[len(levels)-1] >= level {
+			levels = levels[:len(levels)-1]
+			path = path[:len(path)-1]
+		}
+		if title != "" {
+			levels = append(levels, level)
+			path = append(path, title)
+		}
+		current = strings.Join(path, " > ")
+		pos = loc[1]
+	}
+	sections = append(sections, Section{Heading: current, Text: clean(content[pos:])})
+
+	// Keep the heading title in the text so the chunk reads on its own
+	for i := range sections {
+		if sections[i].Heading != "" && strings.TrimSpace(sections[i].Text) != "" {
+			sections[i].Text = sections[i].Heading + "\n" + sections[i].Text
+		}
+	}
+	return sections
+}
+
+// ChunkPages chunks text page by page; pages are separated by form feeds as
+// produced by pdftotext
+func (c *Chunker) ChunkPages(text string) []Chunk {
+	pages := strings.Split(text, "\f")
+	sections := make([]Section, 0, len(pages))
+	for i, page := range pages {
+		sections = append(sections, Section{Page: i + 1, Text: page})
+	}
+	return c.ChunkSections(sections)
+}
+
+// Cue is one timed line of a transcript
+type Cue struct {
+	Start float64
+	End   float64
+	Text  string
+}
+
+var (
+	vttTimingRe = regexp.MustCompile(`((?:\d+:)?\d{1,2}:\d{2}[.,]\d{1,3})\s*-->\s*((?:\d+:)?\d{1,2}:\d{2}[.,]\d{1,3})`)
+	vttTagRe    = regexp.MustCompile(`<[^>]*>`)
+)
+
+// ParseVTTCues parses WebVTT cues, dropping inline tags and the repeated lines
+// that auto-generated captions roll over from one cue to the next
+func ParseVTTCues(vtt string) []Cue {
+	var cues []Cue
+	var current *Cue
+	seen := ""
+
+	flush := func() {
+		if current != nil && strings.TrimSpace(current.Text) != "" {
+			cues = append(cues, *current)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(vtt, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if m := vttTimingRe.FindStringSubmatch(line); m != nil {
+			flush()
+			current = &Cue{Start: parseVTTTime(m[1]), End: parseVTTTime(m[2])}
+			continue
+		}
+		if current == nil || line == "" {
+			if line == "" {
+				flush()
+			}
+			continue
+		}
+		text := strings.TrimSpace(html.UnescapeString(vttTagRe.ReplaceAllString(line, "")))
+		if text == "" || text == seen {
+			continue
+		}
+		seen = text
+		if current.Text != "" {
+			current.Text += " "
+		}
+		current.Text += text
+	}
+	flush()
+	return cues
+}
+
+// parseVTTTime parses "hh:mm:ss.mmm" or "mm:ss.mmm" into seconds
+func parseVTTTime(s string) float64 {
+	s = strings.Replace(s, ",", ".", 1)
+	parts := strings.Split(s, ":")
+	var seconds float64
+	for _, p := range parts {
+		v, _ := strconv.ParseFloat(p, 64)
+		seconds = seconds*60 + v
+	}
+	return seconds
+}
+
+// ChunkCues groups consecutive cues into chunks of up to MaxChunkSize
+// characters, each carrying the time range it covers
+func (c *Chunker) ChunkCues(cues []Cue) []Chunk {
+	var sections []Section
+	var text strings.Builder
+	var start, end float64
+
+	flush := func() {
+		if text.Len() == 0 {
+			return
+		}
+		s, e := start, end
+		sections = append(sections, Section{StartTime: &s, EndTime: &e, Text: text.String()})
+		text.Reset()
+	}
+
+	for _, cue := range cues {
+		if text.Len() > 0 && text.Len()+len(cue.Text)+1 > c.config.MaxChunkSize {
+			flush()
+		}
+		if text.Len() == 0 {
+			start = cue.Start
+		} else {
+			text.WriteString(" ")
+		}
+		text.WriteString(cue.Text)
+		end = cue.End
+	}
+	flush()
+
+	return c.ChunkSections(sections)
+}
+
+// FormatTimestamp formats seconds as "mm:ss", or "h:mm:ss" past an hour
+func FormatTimestamp(seconds float64) string {
+	total := int(seconds)
+	h, m, s := total/3600, (total%3600)/60, total%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%02d:%02d", m, s)
+}
@@ -0,0 +1,25 @@
+// Package config holds settings shared by several packages
+package config
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrJWTSecretMissing is returned when JWT_SECRET is not set
+var ErrJWTSecretMissing = errors.New("JWT_SECRET is not set")
+
+// JWTSecret returns the secret that signs auth tokens. Signed content URLs derive
+// their keys from it with a per-use prefix. The server refuses to start without
+// it, see CheckSecrets.
+func JWTSecret() string {
+	return os.Getenv("JWT_SECRET")
+}
+
+// CheckSecrets reports an error when a required secret is not configured
+func CheckSecrets() error {
+	if JWTSecret() == "" {
+		return ErrJWTSecretMissing
+	}
+	return nil
+}
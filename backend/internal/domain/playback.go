@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Playback limit errors
+var (
+	ErrPlaybackDeviceLimit     = errors.New("device limit reached")
+	ErrPlaybackStreamLimit     = errors.New("concurrent stream limit reached")
+	ErrPlaybackSessionEnded    = errors.New("playback session has ended")
+	ErrPlaybackSessionRequired = errors.New("playback session required")
+)
+
+// PlaybackDevice is a device registered to a user for protected playback
+type PlaybackDevice struct {
+	ID          string     `json:"id" db:"id"`
+	UserID      string     `json:"user_id" db:"user_id"`
+	Fingerprint string     `json:"-" db:"fingerprint"`
+	Label       *string    `json:"label,omitempty" db:"label"`
+	IPAddress   *string    `json:"ip_address,omitempty" db:"ip_address"`
+	FirstSeenAt time.Time  `json:"first_seen_at" db:"first_seen_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at" db:"last_seen_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// PlaybackSession is a single stream being played on a device
+type PlaybackSession struct {
+	ID              string     `json:"id" db:"id"`
+	UserID          string     `json:"user_id" db:"user_id"`
+	DeviceID        string     `json:"device_id" db:"device_id"`
+	LessonID        *string    `json:"lesson_id,omitempty" db:"lesson_id"`
+	IPAddress       *string    `json:"ip_address,omitempty" db:"ip_address"`
+	StartedAt       time.Time  `json:"started_at" db:"started_at"`
+	LastHeartbeatAt time.Time  `json:"last_heartbeat_at" db:"last_heartbeat_at"`
+	EndedAt         *time.Time `json:"ended_at,omitempty" db:"ended_at"`
+	EndReason       *string    `json:"end_reason,omitempty" db:"end_reason"`
+	// Joined fields (not in DB)
+	DeviceLabel *string `json:"device_label,omitempty" db:"device_label"`
+	LessonTitle *string `json:"lesson_title,omitempty" db:"lesson_title"`
+}
+
+// PlaybackLimits are the effective limits for a user; 0 means unlimited
+type PlaybackLimits struct {
+	MaxConcurrentStreams int           `json:"max_concurrent_streams"`
+	MaxDevices           int           `json:"max_devices"`
+	SessionTimeout       time.Duration `json:"-"`
+}
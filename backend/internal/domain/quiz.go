@@ -12,6 +12,23 @@ const (
 	QuestionShortAnswer    QuestionType = "short_answer"     // Text input
 )
 
+// GradingMode controls how short_answer questions are graded
+type GradingMode string
+
+const (
+	GradingExact      GradingMode = "exact"      // Case-insensitive match against accepted answers
+	GradingNormalized GradingMode = "normalized" // Punctuation/whitespace-insensitive match, tolerating small typos
+	GradingAI         GradingMode = "ai"         // Rubric-based LLM grading with partial points
+)
+
+// Answer grader, recorded on each answer
+const (
+	GradedByAuto       = "auto"
+	GradedByAI         = "ai"
+	GradedByInstructor = "instructor"
+	GradedByPending    = "pending" // Waiting for AI grading after submission
+)
+
 // Quiz represents a quiz attached to a lesson
 type Quiz struct {
 	ID                 string     `json:"id"`
@@ -40,6 +57,8 @@ type Question struct {
 	OrderIndex   int          `json:"order_index"`
 	Required     bool         `json:"required"`
 	Options      []Option     `json:"options,omitempty"`
+	GradingMode  GradingMode  `json:"grading_mode,omitempty"` // short_answer only, defaults to exact
+	Rubric       string       `json:"rubric,omitempty"`       // Grading guidance for GradingAI
 	CreatedAt    time.Time    `json:"created_at"`
 }
 
@@ -74,6 +93,8 @@ type Answer struct {
 	TextAnswer        string   `json:"text_answer,omitempty"`         // for short answer
 	IsCorrect         *bool    `json:"is_correct,omitempty"`
 	PointsEarned      int      `json:"points_earned"`
+	Feedback          string   `json:"feedback,omitempty"`  // From AI or instructor grading
+	GradedBy          string   `json:"graded_by,omitempty"` // auto, ai, instructor or pending
 }
 
 // CreateQuizRequest represents a request to create a quiz
@@ -110,6 +131,8 @@ type CreateQuestionRequest struct {
 	Points       int          `json:"points"`
 	Required     bool         `json:"required"`
 	Options      []CreateOptionRequest `json:"options,omitempty"`
+	GradingMode  GradingMode  `json:"grading_mode,omitempty"`
+	Rubric       string       `json:"rubric,omitempty"`
 }
 
 // CreateOptionRequest represents a request to create an option
@@ -126,6 +149,8 @@ type UpdateQuestionRequest struct {
 	Points       *int          `json:"points,omitempty"`
 	Required     *bool         `json:"required,omitempty"`
 	Options      []CreateOptionRequest `json:"options,omitempty"` // Replace all options
+	GradingMode  *GradingMode  `json:"grading_mode,omitempty"`
+	Rubric       *string       `json:"rubric,omitempty"`
 }
 
 // SubmitQuizRequest represents a user submitting quiz answers
@@ -152,6 +177,9 @@ type QuizResult struct {
 	CorrectCount   int              `json:"correct_count"`
 	TotalQuestions int              `json:"total_questions"`
 	Answers        []AnswerResult   `json:"answers,omitempty"`
+	// GradingPending is set while AI grading of some answers is still running;
+	// the score goes up as their points come in
+	GradingPending bool `json:"grading_pending,omitempty"`
 }
 
 // AnswerResult represents the result of a single answer
@@ -164,6 +192,13 @@ type AnswerResult struct {
 	PointsEarned   int      `json:"points_earned"`
 	MaxPoints      int      `json:"max_points"`
 	Explanation    string   `json:"explanation,omitempty"`
+	Feedback       string   `json:"feedback,omitempty"`
+}
+
+// RegradeAnswerRequest is an instructor override of an answer's grade
+type RegradeAnswerRequest struct {
+	PointsEarned int    `json:"points_earned"`
+	Feedback     string `json:"feedback"`
 }
 
 // QuizRepository defines the interface for quiz data access
@@ -179,6 +214,7 @@ type QuizRepository interface {
 	GetQuestionsByQuizID(quizID string) ([]Question, error)
 	GetQuestionByID(id string) (*Question, error)
 	CreateQuestion(question *Question) error
+	CreateQuestions(quizID string, questions []Question) error // All or nothing
 	UpdateQuestion(question *Question) error
 	DeleteQuestion(id string) error
 	ReorderQuestions(quizID string, questionIDs []string) error
@@ -198,4 +234,6 @@ type QuizRepository interface {
 	// Answers
 	CreateAnswers(answers []Answer) error
 	GetAnswersByAttemptID(attemptID string) ([]Answer, error)
+	GetAnswerByID(id string) (*Answer, error)
+	UpdateAnswerGrade(answer *Answer) error
 }
@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// LessonSubtitle is a WebVTT subtitle/caption track attached to a video lesson
+type LessonSubtitle struct {
+	ID               string    `json:"id" db:"id"`
+	LessonID         string    `json:"lesson_id" db:"lesson_id"`
+	Language         string    `json:"language" db:"language"`
+	Label            string    `json:"label" db:"label"`
+	IsDefault        bool      `json:"is_default" db:"is_default"`
+	ObjectPath       string    `json:"-" db:"object_path"`
+	OriginalFilename *string   `json:"original_filename,omitempty" db:"original_filename"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+}
@@ -28,10 +28,12 @@ type UIConfig struct {
 
 // FeatureConfig holds the feature flags for a tenant
 type FeatureConfig struct {
-	EnableQuiz          bool   `json:"enable_quiz"`
-	EnableCertificate   bool   `json:"enable_certificate"`
-	EnableForum         bool   `json:"enable_forum"`
-	DRMProtectionLevel  string `json:"drm_protection_level"` // basic, standard, advanced
+	EnableQuiz           bool   `json:"enable_quiz"`
+	EnableCertificate    bool   `json:"enable_certificate"`
+	EnableForum          bool   `json:"enable_forum"`
+	DRMProtectionLevel   string `json:"drm_protection_level"`             // basic, standard, advanced
+	MaxConcurrentStreams *int   `json:"max_concurrent_streams,omitempty"` // nil = platform default, 0 = unlimited
+	MaxDevices           *int   `json:"max_devices,omitempty"`            // nil = platform default, 0 = unlimited
 }
 
 // TenantRepository defines the interface for tenant data access
@@ -0,0 +1,48 @@
+package domain
+
+import "time"
+
+// Upload session statuses
+const (
+	UploadStatusUploading = "uploading"
+	UploadStatusCompleted = "completed"
+	UploadStatusAborted   = "aborted"
+	UploadStatusExpired   = "expired"
+)
+
+// UploadSession is a resumable multipart upload in progress
+type UploadSession struct {
+	ID              string     `json:"upload_id" db:"id"`
+	UserID          string     `json:"user_id" db:"user_id"`
+	ObjectKey       string     `json:"object_key" db:"object_key"`
+	StorageUploadID string     `json:"-" db:"storage_upload_id"`
+	Filename        string     `json:"filename" db:"filename"`
+	ContentType     string     `json:"content_type" db:"content_type"`
+	FileType        string     `json:"file_type" db:"file_type"`
+	TotalSize       int64      `json:"total_size" db:"total_size"`
+	PartSize        int64      `json:"part_size" db:"part_size"`
+	TotalParts      int        `json:"total_parts" db:"total_parts"`
+	Status          string     `json:"status" db:"status"`
+	ExpiresAt       time.Time  `json:"expires_at" db:"expires_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// ExpectedPartSize returns the exact size a part must have; only the last part may be shorter
+func (s *UploadSession) ExpectedPartSize(partNumber int) int64 {
+	if partNumber < s.TotalParts {
+		return s.PartSize
+	}
+	return s.TotalSize - s.PartSize*int64(s.TotalParts-1)
+}
+
+// UploadSessionPart is a verified part of an upload session
+type UploadSessionPart struct {
+	UploadID   string    `json:"-" db:"upload_id"`
+	PartNumber int       `json:"part_number" db:"part_number"`
+	Size       int64     `json:"size" db:"size"`
+	ETag       string    `json:"etag" db:"etag"`
+	SHA256     string    `json:"sha256" db:"sha256"`
+	UploadedAt time.Time `json:"uploaded_at" db:"uploaded_at"`
+}
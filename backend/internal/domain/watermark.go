@@ -0,0 +1,29 @@
+package domain
+
+import "time"
+
+// VideoWatermark records a forensic watermark code issued to a viewer of a protected lesson
+type VideoWatermark struct {
+	ID           string    `json:"id" db:"id"`
+	Code         string    `json:"code" db:"code"`
+	UserID       string    `json:"user_id" db:"user_id"`
+	LessonID     string    `json:"lesson_id" db:"lesson_id"`
+	CourseID     string    `json:"course_id" db:"course_id"`
+	EnrollmentID *string   `json:"enrollment_id,omitempty" db:"enrollment_id"`
+	IPAddress    *string   `json:"ip_address,omitempty" db:"ip_address"`
+	UserAgent    *string   `json:"user_agent,omitempty" db:"user_agent"`
+	IssuedAt     time.Time `json:"issued_at" db:"issued_at"`
+}
+
+// WatermarkTrace resolves a watermark code to the viewer and enrollment it was issued to
+type WatermarkTrace struct {
+	VideoWatermark
+	UserEmail          string     `json:"user_email" db:"user_email"`
+	UserName           string     `json:"user_name" db:"user_name"`
+	LessonTitle        string     `json:"lesson_title" db:"lesson_title"`
+	CourseTitle        string     `json:"course_title" db:"course_title"`
+	EnrolledAt         *time.Time `json:"enrolled_at,omitempty" db:"enrolled_at"`
+	EnrollmentProgress *int       `json:"enrollment_progress,omitempty" db:"enrollment_progress"`
+	TransactionID      *string    `json:"transaction_id,omitempty" db:"transaction_id"`
+	UserWatermarkCount int        `json:"user_watermark_count" db:"user_watermark_count"`
+}
@@ -0,0 +1,353 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Status represents the lifecycle state of a job
+type Status string
+
+const (
+	StatusPending   Status = "pending"   // Waiting to be leased (possibly scheduled in the future)
+	StatusRunning   Status = "running"   // Leased by a worker
+	StatusCompleted Status = "completed" // Handler returned without error
+	StatusDead      Status = "dead"      // Exhausted all attempts (dead-letter)
+)
+
+// Queue names used across the application
+const (
+	QueueDefault       = "default"
+	QueueVideo         = "video"
+	QueueAI            = "ai"
+	QueueNotifications = "notifications"
+)
+
+// Job represents a unit of background work stored in Postgres
+type Job struct {
+	ID          string          `db:"id" json:"id"`
+	Queue       string          `db:"queue" json:"queue"`
+	Type        string          `db:"job_type" json:"type"`
+	Payload     json.RawMessage `db:"payload" json:"payload"`
+	Status      Status          `db:"status" json:"status"`
+	Attempts    int             `db:"attempts" json:"attempts"`
+	MaxAttempts int             `db:"max_attempts" json:"max_attempts"`
+	RunAt       time.Time       `db:"run_at" json:"run_at"`
+	LockedBy    *string         `db:"locked_by" json:"locked_by,omitempty"`
+	LockedUntil *time.Time      `db:"locked_until" json:"locked_until,omitempty"`
+	LastError   *string         `db:"last_error" json:"last_error,omitempty"`
+	Sensitive   bool            `db:"sensitive" json:"sensitive"`
+	CreatedAt   time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time       `db:"updated_at" json:"updated_at"`
+	CompletedAt *time.Time      `db:"completed_at" json:"completed_at,omitempty"`
+}
+
+// Decode unmarshals the job payload into v
+func (j *Job) Decode(v interface{}) error {
+	if err := json.Unmarshal(j.Payload, v); err != nil {
+		return fmt.Errorf("invalid payload for job %s: %w", j.Type, err)
+	}
+	return nil
+}
+
+// EnqueueOptions customizes a single enqueued job
+type EnqueueOptions struct {
+	MaxAttempts int           // Defaults to DefaultMaxAttempts
+	Delay       time.Duration // Run no earlier than now + Delay
+	Sensitive   bool          // Payload contains secrets: hidden from the admin API and wiped once the job finishes
+}
+
+// ErrLeaseLost is returned when a worker records the outcome of a job it no longer
+// holds, e.g. because its lease expired and another worker picked the job up
+var ErrLeaseLost = errors.New("job lease lost")
+
+// DefaultMaxAttempts is used when EnqueueOptions.MaxAttempts is not set
+const DefaultMaxAttempts = 5
+
+// ListFilter narrows down List results
+type ListFilter struct {
+	Queue  string
+	Status string
+	Type   string
+	Limit  int
+	Offset int
+}
+
+// Queue is a Postgres-backed job queue
+type Queue struct {
+	db *sqlx.DB
+}
+
+// NewQueue creates a new Postgres job queue
+func NewQueue(db *sqlx.DB) *Queue {
+	return &Queue{db: db}
+}
+
+const jobColumns = `id, queue, job_type, payload, status, attempts, max_attempts, run_at,
+	locked_by, locked_until, last_error, sensitive, created_at, updated_at, completed_at`
+
+// Enqueue adds a job to a queue
+func (q *Queue) Enqueue(ctx context.Context, queue, jobType string, payload interface{}, opts *EnqueueOptions) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	maxAttempts := DefaultMaxAttempts
+	var delay time.Duration
+	sensitive := false
+	if opts != nil {
+		if opts.MaxAttempts > 0 {
+			maxAttempts = opts.MaxAttempts
+		}
+		delay = opts.Delay
+		sensitive = opts.Sensitive
+	}
+
+	var job Job
+	err = q.db.GetContext(ctx, &job, `
+		INSERT INTO jobs (queue, job_type, payload, max_attempts, run_at, sensitive)
+		VALUES ($1, $2, $3, $4, NOW() + make_interval(secs => $5), $6)
+		RETURNING `+jobColumns,
+		queue, jobType, string(data), maxAttempts, delay.Seconds(), sensitive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	return &job, nil
+}
+
+// Lease atomically claims the next runnable job of a queue for the lease duration.
+// Jobs whose lease expired (worker crashed, restarted or hung) become runnable
+// again, unless they have used all their attempts: those are dead-lettered, so a
+// job that keeps taking its worker down isn't run forever.
+// Every lease gets its own locked_by token, so a worker whose lease expired can't
+// record an outcome over the next attempt, even within the same process.
+// Returns nil, nil when the queue is empty.
+func (q *Queue) Lease(ctx context.Context, queue, workerID string, lease time.Duration) (*Job, error) {
+	_, err := q.db.ExecContext(ctx, `
+		UPDATE jobs SET status = 'dead', locked_by = NULL, locked_until = NULL,
+			payload = CASE WHEN sensitive THEN '{}'::jsonb ELSE payload END,
+			last_error = 'lease expired on the last attempt (worker crashed or hung)',
+			updated_at = NOW()
+		WHERE queue = $1 AND status = 'running' AND locked_until < NOW()
+			AND attempts >= max_attempts
+	`, queue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dead-letter expired jobs: %w", err)
+	}
+
+	var job Job
+	err = q.db.GetContext(ctx, &job, `
+		UPDATE jobs SET
+			status = 'running',
+			attempts = attempts + 1,
+			locked_by = $2 || '/' || gen_random_uuid()::text,
+			locked_until = NOW() + make_interval(secs => $3),
+			updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE queue = $1
+				AND ((status = 'pending' AND run_at <= NOW())
+					OR (status = 'running' AND locked_until < NOW() AND attempts < max_attempts))
+			ORDER BY run_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING `+jobColumns,
+		queue, workerID, lease.Seconds())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lease job: %w", err)
+	}
+	return &job, nil
+}
+
+// Extend pushes the lease of a running job further into the future; the worker
+// calls it as a heartbeat while the handler runs
+func (q *Queue) Extend(ctx context.Context, job *Job, lease time.Duration) error {
+	result, err := q.db.ExecContext(ctx, `
+		UPDATE jobs SET locked_until = NOW() + make_interval(secs => $3), updated_at = NOW()
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+	`, job.ID, job.LockedBy, lease.Seconds())
+	return leaseResult(result, err)
+}
+
+// Complete marks a job as successfully processed, wiping sensitive payloads.
+// Only the worker holding the lease can complete a job.
+func (q *Queue) Complete(ctx context.Context, job *Job) error {
+	result, err := q.db.ExecContext(ctx, `
+		UPDATE jobs SET status = 'completed', locked_by = NULL, locked_until = NULL,
+			payload = CASE WHEN sensitive THEN '{}'::jsonb ELSE payload END,
+			last_error = NULL, completed_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+	`, job.ID, job.LockedBy)
+	return leaseResult(result, err)
+}
+
+// Fail records a failed attempt. The job is rescheduled with exponential backoff,
+// or moved to the dead-letter state once it has used all attempts. Dead-lettered
+// jobs don't keep sensitive payloads. Only the worker holding the lease can fail a job.
+func (q *Queue) Fail(ctx context.Context, job *Job, cause error) error {
+	errMsg := cause.Error()
+
+	if job.Attempts >= job.MaxAttempts {
+		result, err := q.db.ExecContext(ctx, `
+			UPDATE jobs SET status = 'dead', locked_by = NULL, locked_until = NULL,
+				payload = CASE WHEN sensitive THEN '{}'::jsonb ELSE payload END,
+				last_error = $2, updated_at = NOW()
+			WHERE id = $1 AND locked_by = $3 AND status = 'running'
+		`, job.ID, errMsg, job.LockedBy)
+		return leaseResult(result, err)
+	}
+
+	result, err := q.db.ExecContext(ctx, `
+		UPDATE jobs SET status = 'pending', locked_by = NULL, locked_until = NULL,
+			last_error = $2, run_at = NOW() + make_interval(secs => $3), updated_at = NOW()
+		WHERE id = $1 AND locked_by = $4 AND status = 'running'
+	`, job.ID, errMsg, Backoff(job.Attempts).Seconds(), job.LockedBy)
+	return leaseResult(result, err)
+}
+
+// Release hands an interrupted job back to the queue right away, without using up
+// an attempt. Only the worker holding the lease can release a job.
+func (q *Queue) Release(ctx context.Context, job *Job) error {
+	result, err := q.db.ExecContext(ctx, `
+		UPDATE jobs SET status = 'pending', attempts = GREATEST(attempts - 1, 0),
+			locked_by = NULL, locked_until = NULL, run_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+	`, job.ID, job.LockedBy)
+	return leaseResult(result, err)
+}
+
+// leaseResult turns an update of a leased job that matched no row into ErrLeaseLost
+func leaseResult(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Retry puts a dead job back into the queue with a fresh attempt budget. Completed
+// jobs can't be retried, since that would repeat their side effects (payments,
+// messages), and neither can dead sensitive jobs because their payload was wiped.
+// Returns nil, nil when the job doesn't exist or can't be retried.
+func (q *Queue) Retry(ctx context.Context, id string) (*Job, error) {
+	var job Job
+	err := q.db.GetContext(ctx, &job, `
+		UPDATE jobs SET status = 'pending', attempts = 0, run_at = NOW(),
+			locked_by = NULL, locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'dead' AND NOT sensitive
+		RETURNING `+jobColumns, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry job: %w", err)
+	}
+	return &job, nil
+}
+
+// Redacted returns a copy of the job that is safe to show in the admin API
+func (j Job) Redacted() Job {
+	if j.Sensitive {
+		j.Payload = json.RawMessage(`{"redacted":true}`)
+	}
+	return j
+}
+
+// GetByID returns a job by ID, or nil if it doesn't exist
+func (q *Queue) GetByID(ctx context.Context, id string) (*Job, error) {
+	var job Job
+	err := q.db.GetContext(ctx, &job, `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &job, err
+}
+
+// List returns jobs matching the filter, newest first, plus the total count
+func (q *Queue) List(ctx context.Context, filter ListFilter) ([]Job, int, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	where := `WHERE ($1 = '' OR queue = $1) AND ($2 = '' OR status = $2) AND ($3 = '' OR job_type = $3)`
+
+	var total int
+	if err := q.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM jobs `+where,
+		filter.Queue, filter.Status, filter.Type); err != nil {
+		return nil, 0, fmt.Errorf("failed to count jobs: %w", err)
+	}
+
+	jobs := []Job{}
+	err := q.db.SelectContext(ctx, &jobs, `SELECT `+jobColumns+` FROM jobs `+where+`
+		ORDER BY created_at DESC LIMIT $4 OFFSET $5`,
+		filter.Queue, filter.Status, filter.Type, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	return jobs, total, nil
+}
+
+// QueueStats holds job counts per status for one queue
+type QueueStats struct {
+	Queue  string         `json:"queue"`
+	Counts map[string]int `json:"counts"`
+}
+
+// Stats returns job counts grouped by queue and status
+func (q *Queue) Stats(ctx context.Context) ([]QueueStats, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT queue, status, COUNT(*) FROM jobs GROUP BY queue, status ORDER BY queue`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []QueueStats
+	index := make(map[string]int)
+	for rows.Next() {
+		var queue, status string
+		var count int
+		if err := rows.Scan(&queue, &status, &count); err != nil {
+			return nil, err
+		}
+		i, ok := index[queue]
+		if !ok {
+			stats = append(stats, QueueStats{Queue: queue, Counts: make(map[string]int)})
+			i = len(stats) - 1
+			index[queue] = i
+		}
+		stats[i].Counts[status] = count
+	}
+	return stats, rows.Err()
+}
+
+// Backoff returns the delay before the next attempt: 30s, 1m, 2m, 4m ... capped at 1 hour
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > time.Hour {
+		delay = time.Hour
+	}
+	return delay
+}
@@ -0,0 +1,273 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// HandlerFunc processes a single job. Returning an error schedules a retry.
+type HandlerFunc func(ctx context.Context, job *Job) error
+
+// leaseDuration is how long a job stays leased without a heartbeat. The worker
+// renews it while the handler runs, so a crashed worker's job is picked up again
+// soon while a slow but healthy one (a long transcode) keeps its lease.
+const leaseDuration = 2 * time.Minute
+
+// QueueConfig controls how a worker consumes one queue
+type QueueConfig struct {
+	Name         string
+	Concurrency  int           // Number of jobs processed in parallel
+	Visibility   time.Duration // Handler timeout; the lease is renewed until then
+	PollInterval time.Duration // Sleep between polls when the queue is empty
+}
+
+// DefaultQueueConfigs returns the queues consumed by the application
+func DefaultQueueConfigs() []QueueConfig {
+	return []QueueConfig{
+		{Name: QueueDefault, Concurrency: 2, Visibility: 5 * time.Minute, PollInterval: 2 * time.Second},
+		{Name: QueueNotifications, Concurrency: 4, Visibility: 2 * time.Minute, PollInterval: 2 * time.Second},
+		{Name: QueueAI, Concurrency: 1, Visibility: 30 * time.Minute, PollInterval: 5 * time.Second},
+		{Name: QueueVideo, Concurrency: 1, Visibility: 2 * time.Hour, PollInterval: 5 * time.Second},
+	}
+}
+
+// Worker leases jobs from the queue and dispatches them to registered handlers
+type Worker struct {
+	queue     *Queue
+	configs   []QueueConfig
+	handlers  map[string]HandlerFunc
+	workerID  string
+	mu        sync.RWMutex
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	isRunning bool
+}
+
+// NewWorker creates a worker for the given queues
+func NewWorker(queue *Queue, configs []QueueConfig) *Worker {
+	hostname, _ := os.Hostname()
+	return &Worker{
+		queue:    queue,
+		configs:  configs,
+		handlers: make(map[string]HandlerFunc),
+		workerID: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}
+}
+
+// Register attaches a handler to a job type
+func (w *Worker) Register(jobType string, handler HandlerFunc) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[jobType] = handler
+}
+
+// Start launches the polling goroutines for every configured queue
+func (w *Worker) Start() {
+	if w.isRunning {
+		log.Println("[Jobs] Worker already running")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.isRunning = true
+
+	for _, cfg := range w.configs {
+		concurrency := cfg.Concurrency
+		if concurrency < 1 {
+			concurrency = 1
+		}
+		for i := 0; i < concurrency; i++ {
+			w.wg.Add(1)
+			go w.poll(ctx, cfg)
+		}
+		log.Printf("[Jobs] Consuming queue %s with concurrency %d", cfg.Name, concurrency)
+	}
+}
+
+// Stop cancels running jobs and waits for the polling goroutines to exit.
+// Interrupted jobs are handed back to the queue and picked up again after restart.
+func (w *Worker) Stop() {
+	if !w.isRunning {
+		return
+	}
+	w.cancel()
+	w.wg.Wait()
+	w.isRunning = false
+	log.Println("[Jobs] Worker stopped")
+}
+
+// IsRunning returns whether the worker is running
+func (w *Worker) IsRunning() bool {
+	return w.isRunning
+}
+
+// poll repeatedly leases and processes jobs from a single queue
+func (w *Worker) poll(ctx context.Context, cfg QueueConfig) {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		job, err := w.queue.Lease(ctx, cfg.Name, w.workerID, leaseDuration)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[Jobs] Failed to lease from %s: %v", cfg.Name, err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(cfg.PollInterval):
+			}
+			continue
+		}
+
+		w.process(ctx, cfg, job)
+	}
+}
+
+// process runs the handler for a leased job and records the outcome
+func (w *Worker) process(ctx context.Context, cfg QueueConfig, job *Job) {
+	w.mu.RLock()
+	handler, ok := w.handlers[job.Type]
+	w.mu.RUnlock()
+
+	// Use a fresh context for bookkeeping so shutdown doesn't lose the result
+	bookkeeping := context.Background()
+
+	if !ok {
+		log.Printf("[Jobs] No handler registered for job type %s (job %s)", job.Type, job.ID)
+		job.Attempts = job.MaxAttempts // Dead-letter immediately, retrying won't help
+		w.queue.Fail(bookkeeping, job, fmt.Errorf("no handler registered for %s", job.Type))
+		return
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, cfg.Visibility)
+	defer cancel()
+	go w.heartbeat(jobCtx, job)
+
+	log.Printf("[Jobs] Running %s job %s (attempt %d/%d)", job.Type, job.ID, job.Attempts, job.MaxAttempts)
+	err := runHandler(jobCtx, handler, job)
+
+	if err != nil {
+		if ctx.Err() != nil {
+			// Shutting down: hand the job back so it is retried after restart
+			log.Printf("[Jobs] Job %s interrupted by shutdown", job.ID)
+			if err := w.queue.Release(bookkeeping, job); err != nil {
+				log.Printf("[Jobs] Failed to release job %s: %v", job.ID, err)
+			}
+			return
+		}
+		log.Printf("[Jobs] Job %s (%s) failed: %v", job.ID, job.Type, err)
+		if failErr := w.queue.Fail(bookkeeping, job, err); failErr != nil {
+			log.Printf("[Jobs] Failed to record failure for job %s: %v", job.ID, failErr)
+		}
+		return
+	}
+
+	if err := w.queue.Complete(bookkeeping, job); err != nil {
+		log.Printf("[Jobs] Failed to mark job %s completed: %v", job.ID, err)
+	}
+}
+
+// heartbeat renews a job's lease until ctx is done: when the handler returns or
+// hits its timeout. A handler that hangs past the timeout loses the lease, and the
+// job is retried or dead-lettered once its attempts are used up.
+func (w *Worker) heartbeat(ctx context.Context, job *Job) {
+	ticker := time.NewTicker(leaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := w.queue.Extend(context.Background(), job, leaseDuration)
+		if ctx.Err() != nil {
+			return // Finished meanwhile; the outcome is recorded by process
+		}
+		if errors.Is(err, ErrLeaseLost) {
+			log.Printf("[Jobs] Job %s lost its lease", job.ID)
+			return
+		}
+		if err != nil {
+			log.Printf("[Jobs] Failed to renew lease of job %s: %v", job.ID, err)
+		}
+	}
+}
+
+// runHandler invokes a handler, converting panics into errors
+func runHandler(ctx context.Context, handler HandlerFunc, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// ===== Singleton for global access =====
+
+var (
+	defaultQueue  *Queue
+	defaultWorker *Worker
+)
+
+// Init initializes the global queue and worker with the given database
+func Init(db *sqlx.DB) {
+	if defaultQueue != nil {
+		return // Already initialized
+	}
+	defaultQueue = NewQueue(db)
+	defaultWorker = NewWorker(defaultQueue, DefaultQueueConfigs())
+}
+
+// Register attaches a handler to the global worker
+func Register(jobType string, handler HandlerFunc) {
+	if defaultWorker == nil {
+		log.Printf("[Jobs] Cannot register %s: job queue not initialized", jobType)
+		return
+	}
+	defaultWorker.Register(jobType, handler)
+}
+
+// Enqueue adds a job to the global queue
+func Enqueue(ctx context.Context, queue, jobType string, payload interface{}, opts *EnqueueOptions) (*Job, error) {
+	if defaultQueue == nil {
+		return nil, fmt.Errorf("job queue not initialized")
+	}
+	return defaultQueue.Enqueue(ctx, queue, jobType, payload, opts)
+}
+
+// Start starts the global worker
+func Start() {
+	if defaultWorker == nil {
+		log.Println("[Jobs] Job queue not initialized")
+		return
+	}
+	defaultWorker.Start()
+}
+
+// Stop stops the global worker
+func Stop() {
+	if defaultWorker != nil {
+		defaultWorker.Stop()
+	}
+}
+
+// GetQueue returns the global queue instance
+func GetQueue() *Queue {
+	return defaultQueue
+}
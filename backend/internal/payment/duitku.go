@@ -336,7 +336,7 @@ func (p *DuitkuProvider) HandleNotification(ctx context.Context, body []byte) (*
 
 	// Verify signature
 	if !p.verifySignatureFromBytes(body) {
-		return nil, fmt.Errorf("invalid callback signature")
+		return nil, ErrInvalidSignature
 	}
 
 	// Determine status based on resultCode
@@ -420,7 +420,7 @@ func (p *DuitkuProvider) verifySignatureFromBytes(body []byte) bool {
 	signature := values.Get("signature")
 	
 	if merchantOrderID == "" || amountStr == "" || signature == "" {
-		log.Printf("[Duitku] Missing required fields: orderID=%s, amount=%s, sig=%s", merchantOrderID, amountStr, signature != "")
+		log.Printf("[Duitku] Missing required fields: orderID=%s, amount=%s, sig=%t", merchantOrderID, amountStr, signature != "")
 		return false
 	}
 	
@@ -522,6 +522,20 @@ func (p *DuitkuProvider) GetTransactionStatus(ctx context.Context, orderID strin
 	}, nil
 }
 
+// Refund records a refund for a Duitku transaction. Duitku offers no refund API
+// to merchants, so the money is returned from the Duitku dashboard or by bank
+// transfer and the result is marked manual.
+func (p *DuitkuProvider) Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	log.Printf("[Duitku] Refund of %.0f for %s must be completed manually", req.Amount, req.OrderID)
+
+	return &RefundResult{
+		RefundKey: req.RefundKey,
+		Status:    RefundStatusManual,
+		Amount:    req.Amount,
+		Message:   "Duitku has no refund API; return the money from the Duitku dashboard or by bank transfer",
+	}, nil
+}
+
 // IsProduction returns whether provider is in production mode
 func (p *DuitkuProvider) IsProduction() bool {
 	return p.isProduction
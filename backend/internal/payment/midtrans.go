@@ -169,7 +169,7 @@ func (m *MidtransProvider) HandleNotification(ctx context.Context, data []byte)
 
 	// Verify signature
 	if !m.VerifySignature(notification) {
-		return nil, fmt.Errorf("invalid signature")
+		return nil, ErrInvalidSignature
 	}
 
 	orderID, _ := notification["order_id"].(string)
@@ -299,6 +299,82 @@ func (m *MidtransProvider) GetTransactionStatus(ctx context.Context, orderID str
 	}, nil
 }
 
+// Refund refunds a settled Midtrans transaction, fully or partially.
+// Midtrans only supports this for card and e-wallet payments; other payment
+// types are rejected by the API and have to be refunded manually.
+func (m *MidtransProvider) Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	payload := map[string]interface{}{
+		"refund_key": req.RefundKey,
+		"amount":     int64(req.Amount),
+		"reason":     truncateString(req.Reason, 255),
+	}
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	url := m.getAPIURL() + "/v2/" + req.OrderID + "/refund"
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.SetBasicAuth(m.serverKey, "")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	log.Printf("[Midtrans] Refund response for %s: %s", req.OrderID, string(body))
+
+	var refundResp struct {
+		StatusCode         string      `json:"status_code"`
+		StatusMessage      string      `json:"status_message"`
+		TransactionID      string      `json:"transaction_id"`
+		RefundAmount       string      `json:"refund_amount"`
+		RefundKey          string      `json:"refund_key"`
+		RefundChargebackID interface{} `json:"refund_chargeback_id"`
+	}
+
+	if err := json.Unmarshal(body, &refundResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if refundResp.StatusCode != "200" {
+		return nil, fmt.Errorf("midtrans refund failed: %s - %s", refundResp.StatusCode, refundResp.StatusMessage)
+	}
+
+	amount, err := strconv.ParseFloat(refundResp.RefundAmount, 64)
+	if err != nil {
+		amount = req.Amount
+	}
+
+	gatewayRef := refundResp.TransactionID
+	if refundResp.RefundChargebackID != nil {
+		gatewayRef = fmt.Sprintf("%v", refundResp.RefundChargebackID)
+	}
+
+	return &RefundResult{
+		RefundKey:   req.RefundKey,
+		Status:      RefundStatusSucceeded,
+		Amount:      amount,
+		GatewayRef:  gatewayRef,
+		Message:     refundResp.StatusMessage,
+		RawResponse: refundResp,
+	}, nil
+}
+
 // GetClientKey returns the client key for frontend use
 func (m *MidtransProvider) GetClientKey() string {
 	return m.clientKey
@@ -2,9 +2,13 @@ package payment
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrInvalidSignature is returned when a notification fails signature verification
+var ErrInvalidSignature = errors.New("invalid signature")
+
 // PaymentProvider defines the interface for payment gateway providers
 type PaymentProvider interface {
 	// GetName returns the provider name
@@ -21,6 +25,9 @@ type PaymentProvider interface {
 	
 	// GetTransactionStatus gets the current status of a transaction
 	GetTransactionStatus(ctx context.Context, orderID string) (*TransactionStatus, error)
+
+	// Refund returns all or part of a settled payment to the customer
+	Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error)
 }
 
 // CreateTransactionRequest holds the data needed to create a payment
@@ -75,6 +82,32 @@ type TransactionStatus struct {
 	GrossAmount       float64 `json:"gross_amount,omitempty"`
 }
 
+// Refund statuses
+const (
+	RefundStatusPending   = "pending"   // Reserved in the ledger; not confirmed by the gateway yet
+	RefundStatusSucceeded = "succeeded" // The gateway accepted the refund
+	RefundStatusManual    = "manual"    // The money is returned outside the gateway
+	RefundStatusFailed    = "failed"    // The gateway rejected the refund
+)
+
+// RefundRequest holds the data needed to refund a payment
+type RefundRequest struct {
+	OrderID   string  `json:"order_id"`
+	RefundKey string  `json:"refund_key"` // Unique per refund so a retried request isn't refunded twice
+	Amount    float64 `json:"amount"`
+	Reason    string  `json:"reason"`
+}
+
+// RefundResult holds the payment gateway response to a refund
+type RefundResult struct {
+	RefundKey   string      `json:"refund_key"`
+	Status      string      `json:"status"` // succeeded, manual
+	Amount      float64     `json:"amount"`
+	GatewayRef  string      `json:"gateway_ref,omitempty"`
+	Message     string      `json:"message,omitempty"`
+	RawResponse interface{} `json:"raw_response,omitempty"`
+}
+
 // ProviderFactory creates payment providers based on name
 type ProviderFactory struct {
 	providers map[string]PaymentProvider
@@ -0,0 +1,73 @@
+package payment
+
+// Transaction statuses fall into a few stages. Gateways report their own status
+// names (settlement, capture, deny, ...) while the admin panel and refunds use
+// success, failed, refunded and partially_refunded, so both map onto a stage.
+const (
+	stagePending    = "pending"
+	stageAuthorized = "authorized"
+	stagePaid       = "paid"
+	stageFailed     = "failed"
+	stagePartial    = "partially_refunded"
+	stageRefunded   = "refunded"
+)
+
+// statusStage returns the stage of a transaction status, or "" when unknown
+func statusStage(status string) string {
+	switch status {
+	case "pending":
+		return stagePending
+	case "capture":
+		return stageAuthorized
+	case "settlement", "success":
+		return stagePaid
+	case "deny", "cancel", "expire", "failure", "failed":
+		return stageFailed
+	case "partially_refunded":
+		return stagePartial
+	case "refund", "refunded":
+		return stageRefunded
+	default:
+		return ""
+	}
+}
+
+// allowedTransitions lists the stages a transaction may move to from each stage,
+// besides moving between statuses of the same stage. A failed order can still be
+// paid late, e.g. when it was expired locally before the gateway's own expiry,
+// but nothing goes back to pending and a paid order only moves on through a
+// refund.
+var allowedTransitions = map[string][]string{
+	stagePending:    {stageAuthorized, stagePaid, stageFailed},
+	stageAuthorized: {stagePaid, stageFailed, stagePartial, stageRefunded},
+	stagePaid:       {stagePartial, stageRefunded},
+	stageFailed:     {stageAuthorized, stagePaid},
+	stagePartial:    {stageRefunded},
+	stageRefunded:   {},
+}
+
+// CanTransition reports whether a transaction may move from one status to
+// another. Unknown statuses are only accepted from pending.
+func CanTransition(from, to string) bool {
+	if from == to {
+		return true
+	}
+
+	fromStage, toStage := statusStage(from), statusStage(to)
+	if fromStage == stagePending {
+		return true
+	}
+	if fromStage == "" || toStage == "" {
+		return false
+	}
+	if fromStage == toStage {
+		return true
+	}
+
+	for _, allowed := range allowedTransitions[fromStage] {
+		if allowed == toStage {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package payment
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     bool
+	}{
+		{"pending to settlement", "pending", "settlement", true},
+		{"pending to unknown gateway status", "pending", "authorize", true},
+		{"same status", "settlement", "settlement", true},
+		{"same stage", "settlement", "success", true},
+		{"capture to settlement", "capture", "settlement", true},
+		{"settlement to refund", "settlement", "refund", true},
+		{"settlement to partial refund", "settlement", "partially_refunded", true},
+		{"partial refund to refund", "partially_refunded", "refunded", true},
+		{"failed paid late", "failed", "settlement", true},
+		{"expired paid late", "expire", "success", true},
+		{"expired captured late", "expire", "capture", true},
+
+		{"settlement back to pending", "settlement", "pending", false},
+		{"refunded to paid", "refunded", "settlement", false},
+		{"refund to success", "refund", "success", false},
+		{"refunded to partial refund", "refunded", "partially_refunded", false},
+		{"settlement to failed", "settlement", "expire", false},
+		{"failed back to pending", "failed", "pending", false},
+		{"partial refund to paid", "partially_refunded", "settlement", false},
+		{"unknown from status", "authorize", "settlement", false},
+		{"unknown to status", "settlement", "authorize", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
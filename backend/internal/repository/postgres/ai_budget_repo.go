@@ -0,0 +1,249 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ================================
+// PRICING
+// ================================
+
+// ModelPricing is the price of a provider's model per 1M tokens; Model "*" is the provider fallback
+type ModelPricing struct {
+	ID                 string    `db:"id" json:"id"`
+	Provider           string    `db:"provider" json:"provider"`
+	Model              string    `db:"model" json:"model"`
+	InputPricePerMTok  float64   `db:"input_price_per_mtok" json:"input_price_per_mtok"`
+	OutputPricePerMTok float64   `db:"output_price_per_mtok" json:"output_price_per_mtok"`
+	UpdatedAt          time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// ListModelPricing returns the pricing table ordered by provider and model
+func (r *AIRepository) ListModelPricing(ctx context.Context) ([]ModelPricing, error) {
+	prices := []ModelPricing{}
+	err := r.db.SelectContext(ctx, &prices, `
+		SELECT id, provider, model, input_price_per_mtok, output_price_per_mtok, updated_at
+		FROM ai_model_pricing
+		ORDER BY provider, model
+	`)
+	return prices, err
+}
+
+// UpsertModelPricing creates or updates the price of a provider/model pair
+func (r *AIRepository) UpsertModelPricing(ctx context.Context, price *ModelPricing) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO ai_model_pricing (provider, model, input_price_per_mtok, output_price_per_mtok)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (provider, model) DO UPDATE
+		SET input_price_per_mtok = EXCLUDED.input_price_per_mtok,
+		    output_price_per_mtok = EXCLUDED.output_price_per_mtok,
+		    updated_at = NOW()
+		RETURNING id, updated_at
+	`, price.Provider, price.Model, price.InputPricePerMTok, price.OutputPricePerMTok).Scan(&price.ID, &price.UpdatedAt)
+}
+
+// DeleteModelPricing removes a price. Returns false if no such price.
+func (r *AIRepository) DeleteModelPricing(ctx context.Context, id string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM ai_model_pricing WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	count, _ := result.RowsAffected()
+	return count > 0, nil
+}
+
+// ================================
+// BUDGETS
+// ================================
+
+// UsageTotals is aggregated AI usage
+type UsageTotals struct {
+	Requests     int     `db:"requests" json:"requests"`
+	TokensInput  int64   `db:"tokens_input" json:"tokens_input"`
+	TokensOutput int64   `db:"tokens_output" json:"tokens_output"`
+	Cost         float64 `db:"cost" json:"cost"`
+}
+
+// Tokens returns input plus output tokens
+func (u UsageTotals) Tokens() int64 {
+	return u.TokensInput + u.TokensOutput
+}
+
+// CourseBudget is a course's monthly AI budget with its usage this month; nil limits are unlimited
+type CourseBudget struct {
+	CourseID          string   `db:"course_id" json:"course_id"`
+	CourseTitle       string   `db:"course_title" json:"course_title"`
+	MonthlyTokenLimit *int64   `db:"monthly_token_limit" json:"monthly_token_limit"`
+	MonthlyCostLimit  *float64 `db:"monthly_cost_limit" json:"monthly_cost_limit"`
+	TokensUsed        int64    `db:"tokens_used" json:"tokens_used"`
+	CostUsed          float64  `db:"cost_used" json:"cost_used"`
+}
+
+const usageTotalsColumns = `
+	COUNT(*) as requests,
+	COALESCE(SUM(tokens_input), 0) as tokens_input,
+	COALESCE(SUM(tokens_output), 0) as tokens_output,
+	COALESCE(SUM(estimated_cost), 0)::float8 as cost`
+
+// GetTenantBudget returns the user's tenant and the monthly budget configured in
+// its feature_config; nil limits mean not set
+func (r *AIRepository) GetTenantBudget(ctx context.Context, userID string) (tenantID *string, maxTokens *int64, maxCost *float64, err error) {
+	var row struct {
+		TenantID  sql.NullString  `db:"tenant_id"`
+		MaxTokens sql.NullInt64   `db:"max_tokens"`
+		MaxCost   sql.NullFloat64 `db:"max_cost"`
+	}
+	err = r.db.GetContext(ctx, &row, `
+		SELECT u.tenant_id,
+		       (t.feature_config->>'ai_monthly_token_budget')::bigint as max_tokens,
+		       (t.feature_config->>'ai_monthly_cost_budget')::float8 as max_cost
+		FROM users u
+		LEFT JOIN tenants t ON t.id = u.tenant_id
+		WHERE u.id = $1
+	`, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if row.TenantID.Valid {
+		tenantID = &row.TenantID.String
+	}
+	if row.MaxTokens.Valid {
+		maxTokens = &row.MaxTokens.Int64
+	}
+	if row.MaxCost.Valid {
+		maxCost = &row.MaxCost.Float64
+	}
+	return tenantID, maxTokens, maxCost, nil
+}
+
+// GetTenantMonthlyUsage sums this month's usage of all users of a tenant; a nil
+// tenant covers the users without one
+func (r *AIRepository) GetTenantMonthlyUsage(ctx context.Context, tenantID *string) (UsageTotals, error) {
+	var totals UsageTotals
+	err := r.db.GetContext(ctx, &totals, `
+		SELECT`+usageTotalsColumns+`
+		FROM ai_usage_log l
+		JOIN users u ON u.id = l.user_id
+		WHERE u.tenant_id IS NOT DISTINCT FROM $1
+		  AND l.created_at >= date_trunc('month', NOW())
+	`, tenantID)
+	return totals, err
+}
+
+const courseBudgetSelect = `
+	SELECT c.id as course_id, c.title as course_title,
+	       b.monthly_token_limit, b.monthly_cost_limit::float8 as monthly_cost_limit,
+	       COALESCE(u.tokens_used, 0) as tokens_used, COALESCE(u.cost_used, 0) as cost_used
+	FROM courses c
+	LEFT JOIN ai_course_budgets b ON b.course_id = c.id
+	LEFT JOIN (
+		SELECT course_id, SUM(tokens_input + tokens_output) as tokens_used,
+		       SUM(estimated_cost)::float8 as cost_used
+		FROM ai_usage_log
+		WHERE created_at >= date_trunc('month', NOW())
+		GROUP BY course_id
+	) u ON u.course_id = c.id`
+
+// GetCourseBudget returns a course's budget and usage this month; nil if the course does not exist
+func (r *AIRepository) GetCourseBudget(ctx context.Context, courseID string) (*CourseBudget, error) {
+	var budget CourseBudget
+	err := r.db.GetContext(ctx, &budget, courseBudgetSelect+` WHERE c.id = $1`, courseID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+// ListCourseBudgets returns courses that have a budget or AI usage this month, highest spend first
+func (r *AIRepository) ListCourseBudgets(ctx context.Context) ([]CourseBudget, error) {
+	budgets := []CourseBudget{}
+	err := r.db.SelectContext(ctx, &budgets, courseBudgetSelect+`
+		WHERE b.course_id IS NOT NULL OR u.course_id IS NOT NULL
+		ORDER BY cost_used DESC, tokens_used DESC, c.title
+	`)
+	return budgets, err
+}
+
+// SetCourseBudget sets a course's monthly limits; with both limits nil the budget is removed
+func (r *AIRepository) SetCourseBudget(ctx context.Context, courseID string, tokenLimit *int64, costLimit *float64) error {
+	if tokenLimit == nil && costLimit == nil {
+		_, err := r.db.ExecContext(ctx, `DELETE FROM ai_course_budgets WHERE course_id = $1`, courseID)
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO ai_course_budgets (course_id, monthly_token_limit, monthly_cost_limit)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (course_id) DO UPDATE
+		SET monthly_token_limit = EXCLUDED.monthly_token_limit,
+		    monthly_cost_limit = EXCLUDED.monthly_cost_limit,
+		    updated_at = NOW()
+	`, courseID, tokenLimit, costLimit)
+	return err
+}
+
+// ================================
+// REPORTS
+// ================================
+
+// UsageReportRow is AI usage aggregated by course, user or day
+type UsageReportRow struct {
+	Key   string `db:"key" json:"key"`
+	Label string `db:"label" json:"label"`
+	UsageTotals
+}
+
+// usageReportGroups maps a report grouping to its key and label expressions
+var usageReportGroups = map[string]struct{ key, label, join string }{
+	"course": {
+		key:   "COALESCE(l.course_id::text, '')",
+		label: "COALESCE(c.title, '(tanpa kursus)')",
+		join:  "LEFT JOIN courses c ON c.id = l.course_id",
+	},
+	"user": {
+		key:   "l.user_id::text",
+		label: "COALESCE(u.full_name, u.email)",
+		join:  "LEFT JOIN users u ON u.id = l.user_id",
+	},
+	"day": {
+		key:   "to_char(l.created_at, 'YYYY-MM-DD')",
+		label: "to_char(l.created_at, 'YYYY-MM-DD')",
+	},
+}
+
+// IsUsageReportGroup reports whether groupBy is a supported report grouping
+func IsUsageReportGroup(groupBy string) bool {
+	_, ok := usageReportGroups[groupBy]
+	return ok
+}
+
+// UsageReport aggregates usage in [from, to) grouped by "course", "user" or "day".
+// Days are listed chronologically, courses and users by spend.
+func (r *AIRepository) UsageReport(ctx context.Context, groupBy string, from, to time.Time) ([]UsageReportRow, error) {
+	group, ok := usageReportGroups[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown report grouping %q", groupBy)
+	}
+
+	order := "cost DESC, SUM(l.tokens_input + l.tokens_output) DESC"
+	if groupBy == "day" {
+		order = "key"
+	}
+
+	rows := []UsageReportRow{}
+	err := r.db.SelectContext(ctx, &rows, `
+		SELECT `+group.key+` as key, `+group.label+` as label,`+usageTotalsColumns+`
+		FROM ai_usage_log l `+group.join+`
+		WHERE l.created_at >= $1 AND l.created_at < $2
+		GROUP BY 1, 2
+		ORDER BY `+order, from, to)
+	return rows, err
+}
@@ -0,0 +1,191 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/rag"
+)
+
+// ================================
+// RAG EVALUATION
+// ================================
+
+// RAGEvalQuestion is a stored golden question of a course
+type RAGEvalQuestion struct {
+	ID                string         `db:"id" json:"id"`
+	CourseID          string         `db:"course_id" json:"course_id"`
+	Question          string         `db:"question" json:"question"`
+	ExpectedLessonIDs pq.StringArray `db:"expected_lesson_ids" json:"expected_lesson_ids"`
+	ReferenceAnswer   *string        `db:"reference_answer" json:"reference_answer,omitempty"`
+	CreatedAt         time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt         time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+// EvalQuestion converts the stored question for the evaluator
+func (q RAGEvalQuestion) EvalQuestion() rag.EvalQuestion {
+	eq := rag.EvalQuestion{
+		ID:                q.ID,
+		Question:          q.Question,
+		ExpectedLessonIDs: q.ExpectedLessonIDs,
+	}
+	if q.ReferenceAnswer != nil {
+		eq.ReferenceAnswer = *q.ReferenceAnswer
+	}
+	return eq
+}
+
+// RAGEvalRun is a stored evaluation run; Results is only loaded for a single run
+type RAGEvalRun struct {
+	ID               string           `db:"id" json:"id"`
+	CourseID         string           `db:"course_id" json:"course_id"`
+	Label            *string          `db:"label" json:"label,omitempty"`
+	Config           json.RawMessage  `db:"config" json:"config"`
+	QuestionCount    int              `db:"question_count" json:"question_count"`
+	ErrorCount       int              `db:"error_count" json:"error_count"`
+	RecallAtK        float64          `db:"recall_at_k" json:"recall_at_k"`
+	MRR              float64          `db:"mrr" json:"mrr"`
+	CitationAccuracy float64          `db:"citation_accuracy" json:"citation_accuracy"`
+	HitRate          float64          `db:"hit_rate" json:"hit_rate"`
+	Results          []rag.EvalResult `db:"-" json:"results,omitempty"`
+	CreatedAt        time.Time        `db:"created_at" json:"created_at"`
+}
+
+const ragEvalQuestionColumns = `id, course_id, question, expected_lesson_ids, reference_answer, created_at, updated_at`
+
+const ragEvalRunColumns = `
+	id, course_id, label, config, question_count, error_count,
+	recall_at_k, mrr, citation_accuracy, hit_rate, created_at`
+
+// ListEvalQuestions returns a course's golden questions, oldest first
+func (r *AIRepository) ListEvalQuestions(ctx context.Context, courseID string) ([]RAGEvalQuestion, error) {
+	questions := []RAGEvalQuestion{}
+	err := r.db.SelectContext(ctx, &questions, `
+		SELECT `+ragEvalQuestionColumns+`
+		FROM rag_eval_questions
+		WHERE course_id = $1
+		ORDER BY created_at
+	`, courseID)
+	return questions, err
+}
+
+// GetEvalQuestion returns a golden question; nil if not found
+func (r *AIRepository) GetEvalQuestion(ctx context.Context, id string) (*RAGEvalQuestion, error) {
+	var q RAGEvalQuestion
+	err := r.db.GetContext(ctx, &q, `SELECT `+ragEvalQuestionColumns+` FROM rag_eval_questions WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// CreateEvalQuestions adds golden questions to a course in one transaction
+func (r *AIRepository) CreateEvalQuestions(ctx context.Context, courseID, createdBy string, questions []RAGEvalQuestion) ([]RAGEvalQuestion, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	created := make([]RAGEvalQuestion, 0, len(questions))
+	for _, q := range questions {
+		var row RAGEvalQuestion
+		err := tx.GetContext(ctx, &row, `
+			INSERT INTO rag_eval_questions (course_id, question, expected_lesson_ids, reference_answer, created_by)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+ragEvalQuestionColumns,
+			courseID, q.Question, q.ExpectedLessonIDs, q.ReferenceAnswer, nullString(createdBy))
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, row)
+	}
+
+	return created, tx.Commit()
+}
+
+// UpdateEvalQuestion saves a golden question's text, expected lessons and reference answer
+func (r *AIRepository) UpdateEvalQuestion(ctx context.Context, q *RAGEvalQuestion) error {
+	return r.db.QueryRowContext(ctx, `
+		UPDATE rag_eval_questions
+		SET question = $2, expected_lesson_ids = $3, reference_answer = $4, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at
+	`, q.ID, q.Question, q.ExpectedLessonIDs, q.ReferenceAnswer).Scan(&q.UpdatedAt)
+}
+
+// DeleteEvalQuestion removes a golden question
+func (r *AIRepository) DeleteEvalQuestion(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM rag_eval_questions WHERE id = $1`, id)
+	return err
+}
+
+// CreateEvalRun stores an evaluation run with its per-question results
+func (r *AIRepository) CreateEvalRun(ctx context.Context, run *RAGEvalRun, createdBy string) error {
+	results, err := json.Marshal(run.Results)
+	if err != nil {
+		return err
+	}
+	config := run.Config
+	if len(config) == 0 {
+		config = json.RawMessage("{}")
+	}
+
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO rag_eval_runs (course_id, label, config, question_count, error_count,
+			recall_at_k, mrr, citation_accuracy, hit_rate, results, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at
+	`, run.CourseID, run.Label, []byte(config), run.QuestionCount, run.ErrorCount,
+		run.RecallAtK, run.MRR, run.CitationAccuracy, run.HitRate, results, nullString(createdBy),
+	).Scan(&run.ID, &run.CreatedAt)
+}
+
+// ListEvalRuns returns a course's runs without per-question results, newest first
+func (r *AIRepository) ListEvalRuns(ctx context.Context, courseID string, limit int) ([]RAGEvalRun, error) {
+	runs := []RAGEvalRun{}
+	err := r.db.SelectContext(ctx, &runs, `
+		SELECT `+ragEvalRunColumns+`
+		FROM rag_eval_runs
+		WHERE course_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, courseID, limit)
+	return runs, err
+}
+
+// GetEvalRun returns a run with its per-question results; nil if not found
+func (r *AIRepository) GetEvalRun(ctx context.Context, id string) (*RAGEvalRun, error) {
+	var row struct {
+		RAGEvalRun
+		RawResults []byte `db:"results"`
+	}
+	err := r.db.GetContext(ctx, &row, `SELECT `+ragEvalRunColumns+`, results FROM rag_eval_runs WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	run := row.RAGEvalRun
+	if err := json.Unmarshal(row.RawResults, &run.Results); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// CountCourseLessons returns how many of the given lessons belong to a course
+func (r *AIRepository) CountCourseLessons(ctx context.Context, courseID string, lessonIDs []string) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, `
+		SELECT COUNT(DISTINCT id) FROM lessons WHERE course_id = $1 AND id = ANY($2)
+	`, courseID, pq.Array(lessonIDs))
+	return count, err
+}
@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	"time"
+)
+
+// ================================
+// GUARDRAIL EVENTS
+// ================================
+
+// GuardrailEvent records a tutor message or retrieved chunk the guardrails acted on
+type GuardrailEvent struct {
+	ID          string    `db:"id" json:"id"`
+	UserID      *string   `db:"user_id" json:"user_id,omitempty"`
+	UserName    *string   `db:"user_name" json:"user_name,omitempty"`
+	CourseID    *string   `db:"course_id" json:"course_id,omitempty"`
+	CourseTitle *string   `db:"course_title" json:"course_title,omitempty"`
+	SessionID   *string   `db:"session_id" json:"session_id,omitempty"`
+	LessonID    *string   `db:"lesson_id" json:"lesson_id,omitempty"`
+	EventType   string    `db:"event_type" json:"event_type"`
+	Action      string    `db:"action" json:"action"`
+	Rule        *string   `db:"rule" json:"rule,omitempty"`
+	Excerpt     *string   `db:"excerpt" json:"excerpt,omitempty"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
+
+// GuardrailEventFilter narrows ListGuardrailEvents; empty fields match everything
+type GuardrailEventFilter struct {
+	EventType string
+	CourseID  string
+	Limit     int
+	Offset    int
+}
+
+// LogGuardrailEvent stores a guardrail event
+func (r *AIRepository) LogGuardrailEvent(ctx context.Context, e *GuardrailEvent) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO ai_guardrail_events (user_id, course_id, session_id, lesson_id, event_type, action, rule, excerpt)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, e.UserID, e.CourseID, e.SessionID, e.LessonID, e.EventType, e.Action, e.Rule, e.Excerpt)
+	return err
+}
+
+// ListGuardrailEvents returns guardrail events, newest first, and the total matching the filter
+func (r *AIRepository) ListGuardrailEvents(ctx context.Context, filter GuardrailEventFilter) ([]GuardrailEvent, int, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	where := `WHERE ($1 = '' OR e.event_type = $1) AND ($2 = '' OR e.course_id::text = $2)`
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM ai_guardrail_events e `+where,
+		filter.EventType, filter.CourseID); err != nil {
+		return nil, 0, err
+	}
+
+	events := []GuardrailEvent{}
+	err := r.db.SelectContext(ctx, &events, `
+		SELECT e.id, e.user_id, COALESCE(u.full_name, u.email) AS user_name,
+		       e.course_id, c.title AS course_title, e.session_id, e.lesson_id,
+		       e.event_type, e.action, e.rule, e.excerpt, e.created_at
+		FROM ai_guardrail_events e
+		LEFT JOIN users u ON u.id = e.user_id
+		LEFT JOIN courses c ON c.id = e.course_id
+		`+where+`
+		ORDER BY e.created_at DESC
+		LIMIT $3 OFFSET $4
+	`, filter.EventType, filter.CourseID, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
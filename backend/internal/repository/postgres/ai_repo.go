@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/ai/rag"
 )
 
@@ -45,6 +46,7 @@ func (r *AIRepository) Search(ctx context.Context, courseID string, embedding []
 			ce.chunk_index,
 			ce.chunk_text,
 			ce.source_reference,
+			ce.metadata,
 			1 - (ce.embedding <=> $1::vector) as similarity
 		FROM course_embeddings ce
 		LEFT JOIN lessons l ON ce.lesson_id = l.id
@@ -67,6 +69,7 @@ func (r *AIRepository) Search(ctx context.Context, courseID string, embedding []
 		rowCount++
 		var chunk rag.RetrievedChunk
 		var sourceRef sql.NullString
+		var metadata []byte
 
 		err := rows.Scan(
 			&chunk.ID,
@@ -77,6 +80,7 @@ func (r *AIRepository) Search(ctx context.Context, courseID string, embedding []
 			&chunk.ChunkIndex,
 			&chunk.Text,
 			&sourceRef,
+			&metadata,
 			&chunk.SimilarityScore,
 		)
 		if err != nil {
@@ -87,6 +91,7 @@ func (r *AIRepository) Search(ctx context.Context, courseID string, embedding []
 		if sourceRef.Valid {
 			chunk.SourceReference = sourceRef.String
 		}
+		applyChunkMetadata(&chunk, metadata)
 
 		results = append(results, chunk)
 	}
@@ -101,6 +106,25 @@ func (r *AIRepository) Search(ctx context.Context, courseID string, embedding []
 	return results, nil
 }
 
+// applyChunkMetadata copies the structural location stored by the chunker
+// (heading path, PDF page, transcript timestamp) onto a retrieved chunk
+func applyChunkMetadata(chunk *rag.RetrievedChunk, raw []byte) {
+	if len(raw) == 0 {
+		return
+	}
+	var metadata struct {
+		Heading   string   `json:"heading"`
+		Page      int      `json:"page"`
+		StartTime *float64 `json:"start_time"`
+	}
+	if err := json.Unmarshal(raw, &metadata); err != nil {
+		return
+	}
+	chunk.Heading = metadata.Heading
+	chunk.Page = metadata.Page
+	chunk.StartTime = metadata.StartTime
+}
+
 // HybridSearch performs combined vector + keyword search using RRF (Reciprocal Rank Fusion)
 // This improves accuracy for exact term searches like NIP, article numbers, codes, etc.
 func (r *AIRepository) HybridSearch(ctx context.Context, courseID string, embedding []float32, queryText string, limit int) ([]rag.RetrievedChunk, error) {
@@ -149,6 +173,7 @@ func (r *AIRepository) HybridSearch(ctx context.Context, courseID string, embedd
 			ce.chunk_index,
 			ce.chunk_text,
 			ce.source_reference,
+			ce.metadata,
 			c.rrf_score as similarity
 		FROM combined c
 		JOIN course_embeddings ce ON ce.id = c.id
@@ -168,6 +193,7 @@ func (r *AIRepository) HybridSearch(ctx context.Context, courseID string, embedd
 	for rows.Next() {
 		var chunk rag.RetrievedChunk
 		var sourceRef sql.NullString
+		var metadata []byte
 
 		err := rows.Scan(
 			&chunk.ID,
@@ -178,6 +204,7 @@ func (r *AIRepository) HybridSearch(ctx context.Context, courseID string, embedd
 			&chunk.ChunkIndex,
 			&chunk.Text,
 			&sourceRef,
+			&metadata,
 			&chunk.SimilarityScore,
 		)
 		if err != nil {
@@ -188,6 +215,7 @@ func (r *AIRepository) HybridSearch(ctx context.Context, courseID string, embedd
 		if sourceRef.Valid {
 			chunk.SourceReference = sourceRef.String
 		}
+		applyChunkMetadata(&chunk, metadata)
 
 		results = append(results, chunk)
 	}
@@ -291,6 +319,64 @@ func (r *AIRepository) DeleteByLesson(ctx context.Context, lessonID string) erro
 	return err
 }
 
+// GetLessonChunks returns the indexed chunks of the given lessons in reading
+// order (lesson order, then chunk index), up to limit chunks
+func (r *AIRepository) GetLessonChunks(ctx context.Context, courseID string, lessonIDs []string, limit int) ([]rag.RetrievedChunk, error) {
+	query := `
+		SELECT 
+			ce.id,
+			ce.course_id,
+			ce.lesson_id,
+			COALESCE(l.title, '') as lesson_title,
+			ce.content_type,
+			ce.chunk_index,
+			ce.chunk_text,
+			ce.source_reference,
+			ce.metadata
+		FROM course_embeddings ce
+		JOIN lessons l ON ce.lesson_id = l.id
+		WHERE ce.course_id = $1 AND ce.lesson_id = ANY($2)
+		ORDER BY l.order_index, l.id, ce.chunk_index
+		LIMIT $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, courseID, pq.Array(lessonIDs), limit)
+	if err != nil {
+		return nil, fmt.Errorf("lesson chunks query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var results []rag.RetrievedChunk
+	for rows.Next() {
+		var chunk rag.RetrievedChunk
+		var sourceRef sql.NullString
+		var metadata []byte
+
+		if err := rows.Scan(
+			&chunk.ID,
+			&chunk.CourseID,
+			&chunk.LessonID,
+			&chunk.LessonTitle,
+			&chunk.ContentType,
+			&chunk.ChunkIndex,
+			&chunk.Text,
+			&sourceRef,
+			&metadata,
+		); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+
+		if sourceRef.Valid {
+			chunk.SourceReference = sourceRef.String
+		}
+		applyChunkMetadata(&chunk, metadata)
+
+		results = append(results, chunk)
+	}
+
+	return results, rows.Err()
+}
+
 // GetEmbeddingCount returns total embeddings for a course
 func (r *AIRepository) GetEmbeddingCount(ctx context.Context, courseID string) (int, error) {
 	var count int
@@ -299,6 +385,103 @@ func (r *AIRepository) GetEmbeddingCount(ctx context.Context, courseID string) (
 	return count, err
 }
 
+// MaxIndexedEmbeddingDimensions is the largest vector size pgvector can index with HNSW
+const MaxIndexedEmbeddingDimensions = 2000
+
+// EmbeddingDimensions returns the size of the embedding column, or 0 if it is unconstrained
+func (r *AIRepository) EmbeddingDimensions(ctx context.Context) (int, error) {
+	var typmod int
+	query := `
+		SELECT atttypmod FROM pg_attribute
+		WHERE attrelid = 'course_embeddings'::regclass AND attname = 'embedding' AND NOT attisdropped
+	`
+	if err := r.db.QueryRowContext(ctx, query).Scan(&typmod); err != nil {
+		return 0, err
+	}
+	if typmod < 0 {
+		return 0, nil
+	}
+	return typmod, nil
+}
+
+// EmbeddingDimensionsError is returned when the stored embeddings have a different
+// size than the configured embedding model produces
+type EmbeddingDimensionsError struct {
+	Current  int // Size of the embedding column
+	Required int // Size the embedding model produces
+}
+
+func (e *EmbeddingDimensionsError) Error() string {
+	return fmt.Sprintf("embedding index has %d dimensions but the embedding model produces %d; rebuild the AI index to switch models", e.Current, e.Required)
+}
+
+// EnsureEmbeddingDimensions resizes the embedding column to match the embedder.
+// Stored embeddings of another size come from a different model and can't be
+// compared with new ones; they are only deleted (for every course) when rebuild
+// is set, otherwise an *EmbeddingDimensionsError is returned. An empty column is
+// resized either way. Returns true when the column was changed.
+func (r *AIRepository) EnsureEmbeddingDimensions(ctx context.Context, dims int, rebuild bool) (bool, error) {
+	if dims <= 0 {
+		return false, fmt.Errorf("invalid embedding dimensions: %d", dims)
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Serialize with concurrent processing jobs before reading the current size
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE course_embeddings IN ACCESS EXCLUSIVE MODE`); err != nil {
+		return false, err
+	}
+
+	var typmod int
+	err = tx.QueryRowContext(ctx, `
+		SELECT atttypmod FROM pg_attribute
+		WHERE attrelid = 'course_embeddings'::regclass AND attname = 'embedding' AND NOT attisdropped
+	`).Scan(&typmod)
+	if err != nil {
+		return false, err
+	}
+	if typmod == dims {
+		return false, nil
+	}
+
+	if !rebuild {
+		var stored bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM course_embeddings)`).Scan(&stored); err != nil {
+			return false, err
+		}
+		if stored {
+			return false, &EmbeddingDimensionsError{Current: typmod, Required: dims}
+		}
+	}
+
+	statements := []string{
+		`DROP INDEX IF EXISTS idx_course_embeddings_hnsw`,
+		`DELETE FROM course_embeddings`,
+		`UPDATE content_processing_status SET content_hash = NULL WHERE content_hash IS NOT NULL`,
+		fmt.Sprintf(`ALTER TABLE course_embeddings ALTER COLUMN embedding TYPE vector(%d)`, dims),
+	}
+	// Larger vectors can't be indexed; search falls back to an exact scan
+	if dims <= MaxIndexedEmbeddingDimensions {
+		statements = append(statements, `
+			CREATE INDEX idx_course_embeddings_hnsw
+			ON course_embeddings
+			USING hnsw (embedding vector_cosine_ops)
+			WITH (m = 16, ef_construction = 64)
+		`)
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
 // ================================
 // PROCESSING STATUS
 // ================================
@@ -313,6 +496,7 @@ type ProcessingStatus struct {
 	TotalChunks     int        `db:"total_chunks" json:"total_chunks"`
 	ProcessedChunks int        `db:"processed_chunks" json:"processed_chunks"`
 	ErrorMessage    *string    `db:"error_message" json:"error_message,omitempty"`
+	ContentHash     *string    `db:"content_hash" json:"content_hash,omitempty"`
 	StartedAt       *time.Time `db:"started_at" json:"started_at,omitempty"`
 	CompletedAt     *time.Time `db:"completed_at" json:"completed_at,omitempty"`
 	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
@@ -362,7 +546,7 @@ func (r *AIRepository) UpdateProcessingStatus(ctx context.Context, id, newStatus
 func (r *AIRepository) GetProcessingStatus(ctx context.Context, courseID string) ([]ProcessingStatus, error) {
 	query := `
 		SELECT id, course_id, lesson_id, status, content_type, 
-		       total_chunks, processed_chunks, error_message,
+		       total_chunks, processed_chunks, error_message, content_hash,
 		       started_at, completed_at, created_at
 		FROM content_processing_status
 		WHERE course_id = $1
@@ -380,7 +564,7 @@ func (r *AIRepository) GetProcessingStatus(ctx context.Context, courseID string)
 		var s ProcessingStatus
 		err := rows.Scan(
 			&s.ID, &s.CourseID, &s.LessonID, &s.Status, &s.ContentType,
-			&s.TotalChunks, &s.ProcessedChunks, &s.ErrorMessage,
+			&s.TotalChunks, &s.ProcessedChunks, &s.ErrorMessage, &s.ContentHash,
 			&s.StartedAt, &s.CompletedAt, &s.CreatedAt,
 		)
 		if err != nil {
@@ -392,6 +576,69 @@ func (r *AIRepository) GetProcessingStatus(ctx context.Context, courseID string)
 	return statuses, nil
 }
 
+// GetLessonContentHashes returns the content hash of every successfully indexed lesson of a course
+func (r *AIRepository) GetLessonContentHashes(ctx context.Context, courseID string) (map[string]string, error) {
+	query := `
+		SELECT lesson_id, content_hash FROM content_processing_status
+		WHERE course_id = $1 AND lesson_id IS NOT NULL AND status = 'completed' AND content_hash IS NOT NULL
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]string)
+	for rows.Next() {
+		var lessonID, hash string
+		if err := rows.Scan(&lessonID, &hash); err != nil {
+			return nil, err
+		}
+		hashes[lessonID] = hash
+	}
+	return hashes, rows.Err()
+}
+
+// SaveLessonProcessingStatus records the outcome of indexing one lesson. A failed
+// lesson keeps no hash so the next run retries it.
+func (r *AIRepository) SaveLessonProcessingStatus(ctx context.Context, courseID, lessonID, contentType, newStatus, contentHash string, chunks int, errorMsg *string) error {
+	query := `
+		INSERT INTO content_processing_status (
+			course_id, lesson_id, status, content_type, total_chunks, processed_chunks,
+			error_message, content_hash, started_at, completed_at
+		) VALUES ($1, $2, $3, $4, $5, $5, $6, $7, NOW(), NOW())
+		ON CONFLICT (lesson_id) WHERE lesson_id IS NOT NULL DO UPDATE SET
+			status = EXCLUDED.status,
+			content_type = EXCLUDED.content_type,
+			total_chunks = EXCLUDED.total_chunks,
+			processed_chunks = EXCLUDED.processed_chunks,
+			error_message = EXCLUDED.error_message,
+			content_hash = EXCLUDED.content_hash,
+			started_at = EXCLUDED.started_at,
+			completed_at = EXCLUDED.completed_at
+	`
+
+	_, err := r.db.ExecContext(ctx, query, courseID, lessonID, newStatus, contentType, chunks, errorMsg, nullString(contentHash))
+	return err
+}
+
+// HasProcessedContent reports whether a course has been indexed successfully before
+func (r *AIRepository) HasProcessedContent(ctx context.Context, courseID string) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM content_processing_status WHERE course_id = $1 AND status = 'completed')`
+	err := r.db.QueryRowContext(ctx, query, courseID).Scan(&exists)
+	return exists, err
+}
+
+// ListProcessedCourses returns the courses whose content was processed before
+func (r *AIRepository) ListProcessedCourses(ctx context.Context) ([]string, error) {
+	courseIDs := []string{}
+	query := `SELECT DISTINCT course_id FROM content_processing_status WHERE status = 'completed'`
+	err := r.db.SelectContext(ctx, &courseIDs, query)
+	return courseIDs, err
+}
+
 // ================================
 // CHAT SESSIONS
 // ================================
@@ -407,6 +654,10 @@ type ChatSession struct {
 	TotalTokensUsed int       `db:"total_tokens_used" json:"total_tokens_used"`
 	CreatedAt       time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt       time.Time `db:"updated_at" json:"updated_at"`
+
+	// Running summary of the messages up to SummarizedUntil, used in place of them in prompts
+	Summary         *string    `db:"summary" json:"-"`
+	SummarizedUntil *time.Time `db:"summarized_until" json:"-"`
 }
 
 // ChatMessage represents a message in a chat session
@@ -422,26 +673,27 @@ type ChatMessage struct {
 	CreatedAt  time.Time       `db:"created_at" json:"created_at"`
 }
 
-// GetOrCreateActiveSession gets or creates an active chat session
+// DefaultChatTitle is the title of a session until it is named
+const DefaultChatTitle = "Chat Baru"
+
+const chatSessionColumns = `id, user_id, course_id, title, is_active, message_count,
+	total_tokens_used, created_at, updated_at, summary, summarized_until`
+
+// GetOrCreateActiveSession returns the student's most recently used open session
+// for a course, creating one if there is none
 func (r *AIRepository) GetOrCreateActiveSession(ctx context.Context, userID, courseID string) (*ChatSession, error) {
-	// Try to get existing active session
-	query := `
-		SELECT id, user_id, course_id, title, is_active, message_count, 
-		       total_tokens_used, created_at, updated_at
+	var session ChatSession
+	err := r.db.GetContext(ctx, &session, `
+		SELECT `+chatSessionColumns+`
 		FROM ai_chat_sessions
 		WHERE user_id = $1 AND course_id = $2 AND is_active = true
-	`
-
-	var session ChatSession
-	err := r.db.QueryRowContext(ctx, query, userID, courseID).Scan(
-		&session.ID, &session.UserID, &session.CourseID, &session.Title,
-		&session.IsActive, &session.MessageCount, &session.TotalTokensUsed,
-		&session.CreatedAt, &session.UpdatedAt,
-	)
+		ORDER BY updated_at DESC
+		LIMIT 1
+	`, userID, courseID)
 
 	if err == sql.ErrNoRows {
 		// Create new session
-		return r.CreateChatSession(ctx, userID, courseID)
+		return r.CreateChatSession(ctx, userID, courseID, DefaultChatTitle)
 	}
 
 	if err != nil {
@@ -452,22 +704,63 @@ func (r *AIRepository) GetOrCreateActiveSession(ctx context.Context, userID, cou
 }
 
 // CreateChatSession creates a new chat session
-func (r *AIRepository) CreateChatSession(ctx context.Context, userID, courseID string) (*ChatSession, error) {
-	query := `
+func (r *AIRepository) CreateChatSession(ctx context.Context, userID, courseID, title string) (*ChatSession, error) {
+	var session ChatSession
+	err := r.db.GetContext(ctx, &session, `
 		INSERT INTO ai_chat_sessions (user_id, course_id, title, is_active)
-		VALUES ($1, $2, 'Chat Baru', true)
-		RETURNING id, user_id, course_id, title, is_active, message_count, 
-		          total_tokens_used, created_at, updated_at
-	`
+		VALUES ($1, $2, $3, true)
+		RETURNING `+chatSessionColumns,
+		userID, courseID, title)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
 
+// GetChatSessionByID returns a chat session; nil if not found
+func (r *AIRepository) GetChatSessionByID(ctx context.Context, id string) (*ChatSession, error) {
 	var session ChatSession
-	err := r.db.QueryRowContext(ctx, query, userID, courseID).Scan(
-		&session.ID, &session.UserID, &session.CourseID, &session.Title,
-		&session.IsActive, &session.MessageCount, &session.TotalTokensUsed,
-		&session.CreatedAt, &session.UpdatedAt,
-	)
+	err := r.db.GetContext(ctx, &session, `SELECT `+chatSessionColumns+` FROM ai_chat_sessions WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListChatSessions returns a student's sessions for a course, most recently used
+// first. Archived sessions are only included when asked for.
+func (r *AIRepository) ListChatSessions(ctx context.Context, userID, courseID string, includeArchived bool) ([]ChatSession, error) {
+	sessions := []ChatSession{}
+	err := r.db.SelectContext(ctx, &sessions, `
+		SELECT `+chatSessionColumns+`
+		FROM ai_chat_sessions
+		WHERE user_id = $1 AND course_id = $2 AND (is_active = true OR $3)
+		ORDER BY is_active DESC, updated_at DESC
+	`, userID, courseID, includeArchived)
+	return sessions, err
+}
+
+// RenameChatSession sets a session's title
+func (r *AIRepository) RenameChatSession(ctx context.Context, id, title string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE ai_chat_sessions SET title = $2 WHERE id = $1`, id, title)
+	return err
+}
+
+// SetChatSessionArchived archives or reopens a session
+func (r *AIRepository) SetChatSessionArchived(ctx context.Context, id string, archived bool) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE ai_chat_sessions SET is_active = $2, updated_at = NOW() WHERE id = $1
+	`, id, !archived)
+	return err
+}
 
-	return &session, err
+// DeleteChatSession removes a session and its messages
+func (r *AIRepository) DeleteChatSession(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM ai_chat_sessions WHERE id = $1`, id)
+	return err
 }
 
 // AddChatMessage adds a message to a session
@@ -548,6 +841,32 @@ func (r *AIRepository) GetChatHistory(ctx context.Context, sessionID string, lim
 	return messages, nil
 }
 
+// GetUnsummarizedHistory returns the latest messages of a session that are not
+// covered by its running summary, oldest first
+func (r *AIRepository) GetUnsummarizedHistory(ctx context.Context, session *ChatSession, limit int) ([]ChatMessage, error) {
+	messages := []ChatMessage{}
+	err := r.db.SelectContext(ctx, &messages, `
+		SELECT * FROM (
+			SELECT id, session_id, role, content, sources, tokens_used, provider, model, created_at
+			FROM ai_chat_messages
+			WHERE session_id = $1 AND ($2::timestamptz IS NULL OR created_at > $2)
+			ORDER BY created_at DESC
+			LIMIT $3
+		) recent
+		ORDER BY created_at ASC
+	`, session.ID, session.SummarizedUntil, limit)
+	return messages, err
+}
+
+// UpdateChatSummary saves a session's running summary, which now covers the
+// messages created up to summarizedUntil
+func (r *AIRepository) UpdateChatSummary(ctx context.Context, sessionID, summary string, summarizedUntil time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE ai_chat_sessions SET summary = $2, summarized_until = $3 WHERE id = $1
+	`, sessionID, summary, summarizedUntil)
+	return err
+}
+
 // ClearChatSession deactivates a session
 func (r *AIRepository) ClearChatSession(ctx context.Context, sessionID string) error {
 	query := `UPDATE ai_chat_sessions SET is_active = false, updated_at = NOW() WHERE id = $1`
@@ -559,11 +878,18 @@ func (r *AIRepository) ClearChatSession(ctx context.Context, sessionID string) e
 // USAGE LOGGING
 // ================================
 
-// LogUsage logs AI usage for rate limiting and analytics
+// LogUsage logs AI usage for rate limiting and analytics. The cost is estimated
+// from the model's price at the time of the call, falling back to the provider's '*' price.
 func (r *AIRepository) LogUsage(ctx context.Context, userID, courseID, actionType, provider, model string, tokensInput, tokensOutput int) error {
 	query := `
-		INSERT INTO ai_usage_log (user_id, course_id, action_type, provider, model, tokens_input, tokens_output)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO ai_usage_log (user_id, course_id, action_type, provider, model, tokens_input, tokens_output, estimated_cost)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE((
+			SELECT (input_price_per_mtok * $6 + output_price_per_mtok * $7) / 1000000
+			FROM ai_model_pricing
+			WHERE provider = $4 AND model IN ($5, '*')
+			ORDER BY model = '*'
+			LIMIT 1
+		), 0))
 	`
 
 	_, err := r.db.ExecContext(ctx, query,
@@ -220,6 +220,72 @@ func (r *CouponRepository) RecordUsage(usage *domain.CouponUsage) error {
 	).Scan(&usage.ID, &usage.UsedAt)
 }
 
+// RecordUsageOnce records the coupon usage of a paid transaction and counts it
+// against the coupon, unless a usage was already recorded for the transaction.
+// It reports whether the usage was recorded.
+func (r *CouponRepository) RecordUsageOnce(usage *domain.CouponUsage) (bool, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO coupon_usages (coupon_id, user_id, transaction_id, discount_applied)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (coupon_id, transaction_id) DO NOTHING
+		RETURNING id, used_at
+	`, usage.CouponID, usage.UserID, usage.TransactionID, usage.DiscountApplied).Scan(&usage.ID, &usage.UsedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE coupons SET usage_count = usage_count + 1, updated_at = $1 WHERE id = $2",
+		time.Now(), usage.CouponID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// ReleaseUsage removes the coupon usage recorded for a transaction and gives the
+// use back to the coupon. It reports whether a usage was released.
+func (r *CouponRepository) ReleaseUsage(transactionID string) (bool, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var couponID string
+	err = tx.QueryRow(
+		"DELETE FROM coupon_usages WHERE transaction_id = $1 RETURNING coupon_id",
+		transactionID,
+	).Scan(&couponID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE coupons SET usage_count = GREATEST(usage_count - 1, 0), updated_at = $1 WHERE id = $2",
+		time.Now(), couponID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
 // ValidateCouponForUser checks if a coupon is valid for a specific user and course
 func (r *CouponRepository) ValidateCouponForUser(couponID, userID, courseID string) (bool, string) {
 	coupon, err := r.GetByID(couponID)
@@ -0,0 +1,289 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Reconciliation actions
+const (
+	ReconcileSettled        = "settled"         // Paid at the gateway; the webhook was lost
+	ReconcileFailed         = "failed"          // Failed or cancelled at the gateway
+	ReconcileExpired        = "expired"         // Stale pending order with no payment
+	ReconcileStatusMismatch = "status_mismatch" // Paid locally but not at the gateway
+	ReconcileAmountMismatch = "amount_mismatch" // Gateway amount differs from ours
+	ReconcileError          = "error"           // The gateway couldn't be queried
+)
+
+// PaymentReconciliation is one correction or discrepancy found by reconciliation
+type PaymentReconciliation struct {
+	ID             string    `json:"id"`
+	TransactionID  *string   `json:"transaction_id,omitempty"`
+	OrderID        *string   `json:"order_id,omitempty"`
+	PaymentGateway *string   `json:"payment_gateway,omitempty"`
+	LocalStatus    *string   `json:"local_status,omitempty"`
+	GatewayStatus  *string   `json:"gateway_status,omitempty"`
+	Action         string    `json:"action"`
+	LocalAmount    *float64  `json:"local_amount,omitempty"`
+	GatewayAmount  *float64  `json:"gateway_amount,omitempty"`
+	Note           *string   `json:"note,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// PaymentReconciliationReport is the daily discrepancy report for finance
+type PaymentReconciliationReport struct {
+	ID               string          `json:"id"`
+	ReportDate       string          `json:"report_date"` // YYYY-MM-DD
+	Summary          json.RawMessage `json:"summary"`
+	DiscrepancyCount int             `json:"discrepancy_count"`
+	CreatedAt        time.Time       `json:"created_at"`
+}
+
+// reconcileColumns are the transaction fields reconciliation needs
+const reconcileColumns = `id::text, user_id::text, course_id::text, order_id, amount, status, payment_gateway,
+	metadata, coupon_id::text, discount_amount, expired_at, created_at, updated_at`
+
+// scanReconcileTransactions reads rows selected with reconcileColumns
+func scanReconcileTransactions(rows *sql.Rows) ([]*Transaction, error) {
+	defer rows.Close()
+
+	var transactions []*Transaction
+	for rows.Next() {
+		var tx Transaction
+		var courseID, orderID, gateway, couponID sql.NullString
+		var discountAmount sql.NullFloat64
+		var expiredAt sql.NullTime
+		var metadata []byte
+
+		err := rows.Scan(
+			&tx.ID, &tx.UserID, &courseID, &orderID, &tx.Amount, &tx.Status, &gateway,
+			&metadata, &couponID, &discountAmount, &expiredAt, &tx.CreatedAt, &tx.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if courseID.Valid {
+			tx.CourseID = &courseID.String
+		}
+		if orderID.Valid {
+			tx.OrderID = &orderID.String
+		}
+		if gateway.Valid {
+			tx.PaymentGateway = gateway.String
+		}
+		if couponID.Valid {
+			tx.CouponID = &couponID.String
+		}
+		if discountAmount.Valid {
+			tx.DiscountAmount = &discountAmount.Float64
+		}
+		if expiredAt.Valid {
+			tx.ExpiredAt = &expiredAt.Time
+		}
+		if metadata != nil {
+			tx.Metadata = metadata
+		}
+
+		transactions = append(transactions, &tx)
+	}
+
+	return transactions, rows.Err()
+}
+
+// ListPendingForReconcile returns gateway transactions still pending that were
+// created before olderThan: never checked first, then the longest since last checked
+func (r *TransactionRepository) ListPendingForReconcile(olderThan time.Time, limit int) ([]*Transaction, error) {
+	rows, err := r.db.Query(`
+		SELECT `+reconcileColumns+`
+		FROM transactions
+		WHERE status = 'pending' AND order_id IS NOT NULL AND created_at < $1
+		  AND payment_gateway IN ('midtrans', 'duitku')
+		ORDER BY last_reconciled_at ASC NULLS FIRST, created_at ASC
+		LIMIT $2
+	`, olderThan, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanReconcileTransactions(rows)
+}
+
+// MarkReconcileChecked records that reconciliation looked at a pending transaction,
+// moving it to the back of the queue so orders that can't be resolved (gateway
+// errors, gateway no longer configured) don't starve newer ones
+func (r *TransactionRepository) MarkReconcileChecked(id string) error {
+	_, err := r.db.Exec(`UPDATE transactions SET last_reconciled_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+// ListSettledBetween returns gateway transactions marked paid in [from, to)
+func (r *TransactionRepository) ListSettledBetween(from, to time.Time, limit int) ([]*Transaction, error) {
+	rows, err := r.db.Query(`
+		SELECT `+reconcileColumns+`
+		FROM transactions
+		WHERE status IN ('success', 'settlement', 'capture') AND order_id IS NOT NULL
+		  AND payment_gateway IN ('midtrans', 'duitku')
+		  AND COALESCE(settlement_time, updated_at) >= $1 AND COALESCE(settlement_time, updated_at) < $2
+		ORDER BY created_at ASC
+		LIMIT $3
+	`, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanReconcileTransactions(rows)
+}
+
+// ResolvePending moves a transaction out of pending with the gateway's status. It
+// reports false when the transaction was no longer pending, e.g. because its
+// webhook arrived in the meantime.
+func (r *TransactionRepository) ResolvePending(id, status string, paymentType, gatewayRef *string, settlementTime *time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE transactions SET
+			status = $2,
+			payment_type = COALESCE($3, payment_type),
+			payment_gateway_ref = COALESCE($4, payment_gateway_ref),
+			settlement_time = COALESCE($5, settlement_time),
+			updated_at = $6
+		WHERE id = $1 AND status = 'pending'
+	`, id, status, paymentType, gatewayRef, settlementTime, time.Now())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// LogReconciliation records a reconciliation correction or discrepancy
+func (r *TransactionRepository) LogReconciliation(entry *PaymentReconciliation) error {
+	return r.db.QueryRow(`
+		INSERT INTO payment_reconciliations (transaction_id, order_id, payment_gateway, local_status,
+		                                     gateway_status, action, local_amount, gateway_amount, note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at
+	`,
+		entry.TransactionID, entry.OrderID, entry.PaymentGateway, entry.LocalStatus,
+		entry.GatewayStatus, entry.Action, entry.LocalAmount, entry.GatewayAmount, entry.Note,
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// ListReconciliations returns the entries logged in [from, to), newest first
+func (r *TransactionRepository) ListReconciliations(from, to time.Time) ([]*PaymentReconciliation, error) {
+	rows, err := r.db.Query(`
+		SELECT id, transaction_id::text, order_id, payment_gateway, local_status, gateway_status,
+		       action, local_amount, gateway_amount, note, created_at
+		FROM payment_reconciliations
+		WHERE created_at >= $1 AND created_at < $2
+		ORDER BY created_at DESC
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*PaymentReconciliation{}
+	for rows.Next() {
+		var entry PaymentReconciliation
+		var transactionID, orderID, gateway, localStatus, gatewayStatus, note sql.NullString
+		var localAmount, gatewayAmount sql.NullFloat64
+
+		err := rows.Scan(
+			&entry.ID, &transactionID, &orderID, &gateway, &localStatus, &gatewayStatus,
+			&entry.Action, &localAmount, &gatewayAmount, &note, &entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if transactionID.Valid {
+			entry.TransactionID = &transactionID.String
+		}
+		if orderID.Valid {
+			entry.OrderID = &orderID.String
+		}
+		if gateway.Valid {
+			entry.PaymentGateway = &gateway.String
+		}
+		if localStatus.Valid {
+			entry.LocalStatus = &localStatus.String
+		}
+		if gatewayStatus.Valid {
+			entry.GatewayStatus = &gatewayStatus.String
+		}
+		if localAmount.Valid {
+			entry.LocalAmount = &localAmount.Float64
+		}
+		if gatewayAmount.Valid {
+			entry.GatewayAmount = &gatewayAmount.Float64
+		}
+		if note.Valid {
+			entry.Note = &note.String
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// SaveReconciliationReport stores the report for a day, replacing an earlier one
+func (r *TransactionRepository) SaveReconciliationReport(report *PaymentReconciliationReport) error {
+	return r.db.QueryRow(`
+		INSERT INTO payment_reconciliation_reports (report_date, summary, discrepancy_count)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (report_date) DO UPDATE SET
+			summary = EXCLUDED.summary,
+			discrepancy_count = EXCLUDED.discrepancy_count,
+			created_at = CURRENT_TIMESTAMP
+		RETURNING id, created_at
+	`, report.ReportDate, []byte(report.Summary), report.DiscrepancyCount).Scan(&report.ID, &report.CreatedAt)
+}
+
+// GetReconciliationReport returns the report for a day, or nil
+func (r *TransactionRepository) GetReconciliationReport(date string) (*PaymentReconciliationReport, error) {
+	var report PaymentReconciliationReport
+	var summary []byte
+	err := r.db.QueryRow(`
+		SELECT id, to_char(report_date, 'YYYY-MM-DD'), summary, discrepancy_count, created_at
+		FROM payment_reconciliation_reports WHERE report_date = $1
+	`, date).Scan(&report.ID, &report.ReportDate, &summary, &report.DiscrepancyCount, &report.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	report.Summary = summary
+	return &report, nil
+}
+
+// ListReconciliationReports returns daily reports, newest first, with the total count
+func (r *TransactionRepository) ListReconciliationReports(limit, offset int) ([]*PaymentReconciliationReport, int, error) {
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM payment_reconciliation_reports`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT id, to_char(report_date, 'YYYY-MM-DD'), summary, discrepancy_count, created_at
+		FROM payment_reconciliation_reports
+		ORDER BY report_date DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	reports := []*PaymentReconciliationReport{}
+	for rows.Next() {
+		var report PaymentReconciliationReport
+		var summary []byte
+		if err := rows.Scan(&report.ID, &report.ReportDate, &summary, &report.DiscrepancyCount, &report.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		report.Summary = summary
+		reports = append(reports, &report)
+	}
+
+	return reports, total, rows.Err()
+}
@@ -0,0 +1,292 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/domain"
+)
+
+// PlaybackRepository handles playback device and session data access
+type PlaybackRepository struct {
+	db *sqlx.DB
+}
+
+// NewPlaybackRepository creates a new playback repository
+func NewPlaybackRepository(db *sqlx.DB) *PlaybackRepository {
+	return &PlaybackRepository{db: db}
+}
+
+const playbackSessionColumns = `
+	s.id, s.user_id, s.device_id, s.lesson_id, s.ip_address, s.started_at,
+	s.last_heartbeat_at, s.ended_at, s.end_reason`
+
+// GetTenantLimits returns the limits configured on the user's tenant; nil means not set
+func (r *PlaybackRepository) GetTenantLimits(userID string) (maxStreams, maxDevices *int, err error) {
+	var row struct {
+		MaxStreams sql.NullInt64 `db:"max_streams"`
+		MaxDevices sql.NullInt64 `db:"max_devices"`
+	}
+	err = r.db.Get(&row, `
+		SELECT (t.feature_config->>'max_concurrent_streams')::int as max_streams,
+		       (t.feature_config->>'max_devices')::int as max_devices
+		FROM users u
+		LEFT JOIN tenants t ON t.id = u.tenant_id
+		WHERE u.id = $1
+	`, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if row.MaxStreams.Valid {
+		v := int(row.MaxStreams.Int64)
+		maxStreams = &v
+	}
+	if row.MaxDevices.Valid {
+		v := int(row.MaxDevices.Int64)
+		maxDevices = &v
+	}
+	return maxStreams, maxDevices, nil
+}
+
+// StartSession registers the device if needed and starts (or resumes) its playback session.
+// A device only ever holds one session, so reloading a player or switching lessons reuses it.
+// Returns domain.ErrPlaybackDeviceLimit or domain.ErrPlaybackStreamLimit when a limit is hit.
+func (r *PlaybackRepository) StartSession(userID, lessonID, fingerprint, label, ip string, limits domain.PlaybackLimits, deviceInactiveAfter time.Duration) (*domain.PlaybackSession, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Serialize session starts per user so concurrent requests can't both squeeze under the limit
+	if _, err := tx.Exec(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return nil, err
+	}
+
+	timeoutSecs := limits.SessionTimeout.Seconds()
+	if _, err := tx.Exec(`
+		UPDATE playback_sessions
+		SET ended_at = NOW(), end_reason = 'expired'
+		WHERE user_id = $1 AND ended_at IS NULL
+		  AND last_heartbeat_at < NOW() - make_interval(secs => $2)
+	`, userID, timeoutSecs); err != nil {
+		return nil, err
+	}
+
+	var device struct {
+		ID        string     `db:"id"`
+		RevokedAt *time.Time `db:"revoked_at"`
+	}
+	err = tx.Get(&device, `
+		SELECT id, revoked_at FROM playback_devices WHERE user_id = $1 AND fingerprint = $2
+	`, userID, fingerprint)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	isNewDevice := err == sql.ErrNoRows || device.RevokedAt != nil
+
+	if isNewDevice && limits.MaxDevices > 0 {
+		var registered int
+		err = tx.Get(&registered, `
+			SELECT COUNT(*) FROM playback_devices
+			WHERE user_id = $1 AND revoked_at IS NULL
+			  AND last_seen_at >= NOW() - make_interval(secs => $2)
+		`, userID, deviceInactiveAfter.Seconds())
+		if err != nil {
+			return nil, err
+		}
+		if registered >= limits.MaxDevices {
+			return nil, domain.ErrPlaybackDeviceLimit
+		}
+	}
+
+	err = tx.Get(&device.ID, `
+		INSERT INTO playback_devices (user_id, fingerprint, label, ip_address)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, fingerprint) DO UPDATE
+		SET label = EXCLUDED.label, ip_address = EXCLUDED.ip_address,
+		    last_seen_at = NOW(), revoked_at = NULL,
+		    first_seen_at = CASE WHEN playback_devices.revoked_at IS NULL
+		                         THEN playback_devices.first_seen_at ELSE NOW() END
+		RETURNING id
+	`, userID, fingerprint, label, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resume the device's current session if it has one
+	var session domain.PlaybackSession
+	err = tx.Get(&session, `
+		UPDATE playback_sessions s
+		SET lesson_id = $2, ip_address = $3, last_heartbeat_at = NOW()
+		WHERE s.device_id = $1 AND s.ended_at IS NULL
+		RETURNING`+playbackSessionColumns, device.ID, lessonID, ip)
+	if err == nil {
+		return &session, tx.Commit()
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if limits.MaxConcurrentStreams > 0 {
+		var active int
+		err = tx.Get(&active, `
+			SELECT COUNT(*) FROM playback_sessions WHERE user_id = $1 AND ended_at IS NULL
+		`, userID)
+		if err != nil {
+			return nil, err
+		}
+		if active >= limits.MaxConcurrentStreams {
+			return nil, domain.ErrPlaybackStreamLimit
+		}
+	}
+
+	err = tx.Get(&session, `
+		INSERT INTO playback_sessions AS s (user_id, device_id, lesson_id, ip_address)
+		VALUES ($1, $2, $3, $4)
+		RETURNING`+playbackSessionColumns, userID, device.ID, lessonID, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, tx.Commit()
+}
+
+// Heartbeat keeps a session alive. Returns domain.ErrPlaybackSessionEnded if the session
+// was ended, revoked or missed its heartbeat window.
+func (r *PlaybackRepository) Heartbeat(sessionID, userID string, timeout time.Duration) (*domain.PlaybackSession, error) {
+	var session domain.PlaybackSession
+	err := r.db.Get(&session, `
+		UPDATE playback_sessions s
+		SET last_heartbeat_at = NOW()
+		WHERE s.id = $1 AND s.user_id = $2 AND s.ended_at IS NULL
+		  AND s.last_heartbeat_at >= NOW() - make_interval(secs => $3)
+		RETURNING`+playbackSessionColumns, sessionID, userID, timeout.Seconds())
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrPlaybackSessionEnded
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.db.Exec(`UPDATE playback_devices SET last_seen_at = NOW() WHERE id = $1`, session.DeviceID)
+	return &session, nil
+}
+
+// TouchSession keeps a device's session alive for a content request and points it at
+// the lesson. Returns domain.ErrPlaybackSessionEnded if the session isn't active or
+// doesn't belong to the requesting device.
+func (r *PlaybackRepository) TouchSession(sessionID, userID, fingerprint, lessonID string, timeout time.Duration) (*domain.PlaybackSession, error) {
+	var session domain.PlaybackSession
+	err := r.db.Get(&session, `
+		UPDATE playback_sessions s
+		SET last_heartbeat_at = NOW(), lesson_id = $4
+		FROM playback_devices d
+		WHERE s.id = $1 AND s.user_id = $2 AND s.ended_at IS NULL
+		  AND s.last_heartbeat_at >= NOW() - make_interval(secs => $5)
+		  AND d.id = s.device_id AND d.fingerprint = $3 AND d.revoked_at IS NULL
+		RETURNING`+playbackSessionColumns, sessionID, userID, fingerprint, lessonID, timeout.Seconds())
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrPlaybackSessionEnded
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// EndSession ends a user's session; ending an already ended session is not an error
+func (r *PlaybackRepository) EndSession(sessionID, userID string) error {
+	_, err := r.db.Exec(`
+		UPDATE playback_sessions SET ended_at = NOW(), end_reason = 'ended'
+		WHERE id = $1 AND user_id = $2 AND ended_at IS NULL
+	`, sessionID, userID)
+	return err
+}
+
+// ListDevices returns the user's registered (non-revoked) devices, most recently used first
+func (r *PlaybackRepository) ListDevices(userID string) ([]domain.PlaybackDevice, error) {
+	devices := []domain.PlaybackDevice{}
+	err := r.db.Select(&devices, `
+		SELECT id, user_id, fingerprint, label, ip_address, first_seen_at, last_seen_at, revoked_at
+		FROM playback_devices
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY last_seen_at DESC
+	`, userID)
+	return devices, err
+}
+
+// ListActiveSessions returns the user's sessions that are still within their heartbeat window
+func (r *PlaybackRepository) ListActiveSessions(userID string, timeout time.Duration) ([]domain.PlaybackSession, error) {
+	sessions := []domain.PlaybackSession{}
+	err := r.db.Select(&sessions, `
+		SELECT`+playbackSessionColumns+`, d.label as device_label, l.title as lesson_title
+		FROM playback_sessions s
+		JOIN playback_devices d ON d.id = s.device_id
+		LEFT JOIN lessons l ON l.id = s.lesson_id
+		WHERE s.user_id = $1 AND s.ended_at IS NULL
+		  AND s.last_heartbeat_at >= NOW() - make_interval(secs => $2)
+		ORDER BY s.started_at DESC
+	`, userID, timeout.Seconds())
+	return sessions, err
+}
+
+// RevokeDevice revokes one device and ends its sessions. Returns false if no such device.
+func (r *PlaybackRepository) RevokeDevice(userID, deviceID string) (bool, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE playback_devices SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, deviceID, userID)
+	if err != nil {
+		return false, err
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE playback_sessions SET ended_at = NOW(), end_reason = 'revoked'
+		WHERE device_id = $1 AND ended_at IS NULL
+	`, deviceID); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// RevokeAllDevices revokes every device of a user and ends all their sessions
+func (r *PlaybackRepository) RevokeAllDevices(userID string) (int, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE playback_sessions SET ended_at = NOW(), end_reason = 'revoked'
+		WHERE user_id = $1 AND ended_at IS NULL
+	`, userID); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE playback_devices SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+	count, _ := result.RowsAffected()
+
+	return int(count), tx.Commit()
+}
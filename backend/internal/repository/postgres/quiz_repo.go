@@ -145,7 +145,7 @@ func (r *QuizRepository) Delete(id string) error {
 func (r *QuizRepository) GetQuestionsByQuizID(quizID string) ([]domain.Question, error) {
 	query := `
 		SELECT id, quiz_id, question_type, question_text, explanation, points, 
-		       order_index, required, created_at
+		       order_index, required, COALESCE(grading_mode, 'exact'), rubric, created_at
 		FROM quiz_questions 
 		WHERE quiz_id = $1
 		ORDER BY order_index ASC
@@ -160,11 +160,11 @@ func (r *QuizRepository) GetQuestionsByQuizID(quizID string) ([]domain.Question,
 	var questions []domain.Question
 	for rows.Next() {
 		var q domain.Question
-		var explanation sql.NullString
+		var explanation, rubric sql.NullString
 		
 		err := rows.Scan(
 			&q.ID, &q.QuizID, &q.Type, &q.QuestionText, &explanation,
-			&q.Points, &q.OrderIndex, &q.Required, &q.CreatedAt,
+			&q.Points, &q.OrderIndex, &q.Required, &q.GradingMode, &rubric, &q.CreatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -173,6 +173,7 @@ func (r *QuizRepository) GetQuestionsByQuizID(quizID string) ([]domain.Question,
 		if explanation.Valid {
 			q.Explanation = explanation.String
 		}
+		q.Rubric = rubric.String
 		
 		// Load options for this question
 		q.Options, err = r.GetOptionsByQuestionID(q.ID)
@@ -190,16 +191,16 @@ func (r *QuizRepository) GetQuestionsByQuizID(quizID string) ([]domain.Question,
 func (r *QuizRepository) GetQuestionByID(id string) (*domain.Question, error) {
 	query := `
 		SELECT id, quiz_id, question_type, question_text, explanation, points, 
-		       order_index, required, created_at
+		       order_index, required, COALESCE(grading_mode, 'exact'), rubric, created_at
 		FROM quiz_questions WHERE id = $1
 	`
 	
 	var q domain.Question
-	var explanation sql.NullString
+	var explanation, rubric sql.NullString
 	
 	err := r.db.QueryRow(query, id).Scan(
 		&q.ID, &q.QuizID, &q.Type, &q.QuestionText, &explanation,
-		&q.Points, &q.OrderIndex, &q.Required, &q.CreatedAt,
+		&q.Points, &q.OrderIndex, &q.Required, &q.GradingMode, &rubric, &q.CreatedAt,
 	)
 	
 	if err == sql.ErrNoRows {
@@ -212,6 +213,7 @@ func (r *QuizRepository) GetQuestionByID(id string) (*domain.Question, error) {
 	if explanation.Valid {
 		q.Explanation = explanation.String
 	}
+	q.Rubric = rubric.String
 	
 	// Load options
 	q.Options, err = r.GetOptionsByQuestionID(q.ID)
@@ -231,8 +233,8 @@ func (r *QuizRepository) CreateQuestion(question *domain.Question) error {
 	
 	query := `
 		INSERT INTO quiz_questions (quiz_id, question_type, question_text, explanation,
-		                            points, order_index, required, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		                            points, order_index, required, grading_mode, rubric, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING id
 	`
 	
@@ -245,16 +247,72 @@ func (r *QuizRepository) CreateQuestion(question *domain.Question) error {
 	
 	return r.db.QueryRow(query,
 		question.QuizID, question.Type, question.QuestionText, explanation,
-		question.Points, question.OrderIndex, question.Required, question.CreatedAt,
+		question.Points, question.OrderIndex, question.Required,
+		gradingModeOrDefault(question.GradingMode), nullString(question.Rubric), question.CreatedAt,
 	).Scan(&question.ID)
 }
 
+// CreateQuestions inserts several questions with their options in one
+// transaction, appended after the quiz's existing questions
+func (r *QuizRepository) CreateQuestions(quizID string, questions []domain.Question) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM quiz_questions WHERE quiz_id = $1`, quizID).Scan(&count); err != nil {
+		return err
+	}
+	
+	now := time.Now()
+	for i := range questions {
+		q := &questions[i]
+		q.QuizID = quizID
+		q.OrderIndex = count + i
+		q.CreatedAt = now
+		
+		var explanation *string
+		if q.Explanation != "" {
+			explanation = &q.Explanation
+		}
+		
+		err := tx.QueryRow(`
+			INSERT INTO quiz_questions (quiz_id, question_type, question_text, explanation,
+			                            points, order_index, required, grading_mode, rubric, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id
+		`, q.QuizID, q.Type, q.QuestionText, explanation, q.Points, q.OrderIndex, q.Required,
+			gradingModeOrDefault(q.GradingMode), nullString(q.Rubric), q.CreatedAt).Scan(&q.ID)
+		if err != nil {
+			return err
+		}
+		
+		for j := range q.Options {
+			opt := &q.Options[j]
+			opt.QuestionID = q.ID
+			opt.OrderIndex = j
+			err := tx.QueryRow(`
+				INSERT INTO quiz_options (question_id, option_text, is_correct, order_index)
+				VALUES ($1, $2, $3, $4)
+				RETURNING id
+			`, opt.QuestionID, opt.OptionText, opt.IsCorrect, opt.OrderIndex).Scan(&opt.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	
+	return tx.Commit()
+}
+
 // UpdateQuestion updates an existing question
 func (r *QuizRepository) UpdateQuestion(question *domain.Question) error {
 	query := `
 		UPDATE quiz_questions SET
 			question_type = $2, question_text = $3, explanation = $4,
-			points = $5, required = $6
+			points = $5, required = $6, grading_mode = $7, rubric = $8
 		WHERE id = $1
 	`
 	
@@ -266,6 +324,7 @@ func (r *QuizRepository) UpdateQuestion(question *domain.Question) error {
 	_, err := r.db.Exec(query,
 		question.ID, question.Type, question.QuestionText, explanation,
 		question.Points, question.Required,
+		gradingModeOrDefault(question.GradingMode), nullString(question.Rubric),
 	)
 	return err
 }
@@ -495,8 +554,9 @@ func (r *QuizRepository) CreateAnswers(answers []domain.Answer) error {
 	
 	query := `
 		INSERT INTO quiz_answers (attempt_id, question_id, selected_option_ids, 
-		                          text_answer, is_correct, points_earned)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		                          text_answer, is_correct, points_earned,
+		                          feedback, graded_by, graded_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
 		RETURNING id
 	`
 	
@@ -505,11 +565,15 @@ func (r *QuizRepository) CreateAnswers(answers []domain.Answer) error {
 		if answers[i].TextAnswer != "" {
 			textAnswer = &answers[i].TextAnswer
 		}
+		if answers[i].GradedBy == "" {
+			answers[i].GradedBy = domain.GradedByAuto
+		}
 		
 		err := r.db.QueryRow(query,
 			answers[i].AttemptID, answers[i].QuestionID, 
 			pq.Array(answers[i].SelectedOptionIDs), textAnswer,
 			answers[i].IsCorrect, answers[i].PointsEarned,
+			nullString(answers[i].Feedback), answers[i].GradedBy,
 		).Scan(&answers[i].ID)
 		if err != nil {
 			return err
@@ -522,8 +586,7 @@ func (r *QuizRepository) CreateAnswers(answers []domain.Answer) error {
 // GetAnswersByAttemptID retrieves all answers for an attempt
 func (r *QuizRepository) GetAnswersByAttemptID(attemptID string) ([]domain.Answer, error) {
 	query := `
-		SELECT id, attempt_id, question_id, selected_option_ids, text_answer, 
-		       is_correct, points_earned
+		SELECT ` + answerColumns + `
 		FROM quiz_answers WHERE attempt_id = $1
 	`
 	
@@ -535,28 +598,90 @@ func (r *QuizRepository) GetAnswersByAttemptID(attemptID string) ([]domain.Answe
 	
 	var answers []domain.Answer
 	for rows.Next() {
-		var a domain.Answer
-		var textAnswer sql.NullString
-		var isCorrect sql.NullBool
-		
-		err := rows.Scan(
-			&a.ID, &a.AttemptID, &a.QuestionID, 
-			pq.Array(&a.SelectedOptionIDs), &textAnswer,
-			&isCorrect, &a.PointsEarned,
-		)
+		a, err := scanAnswer(rows)
 		if err != nil {
 			return nil, err
 		}
-		
-		if textAnswer.Valid {
-			a.TextAnswer = textAnswer.String
-		}
-		if isCorrect.Valid {
-			a.IsCorrect = &isCorrect.Bool
-		}
-		
-		answers = append(answers, a)
+		answers = append(answers, *a)
 	}
 	
 	return answers, nil
 }
+
+// answerColumns is the column list read by scanAnswer
+const answerColumns = `id, attempt_id, question_id, selected_option_ids, text_answer, 
+		       is_correct, points_earned, feedback, COALESCE(graded_by, 'auto')`
+
+// scanAnswer scans one quiz_answers row selected with answerColumns
+func scanAnswer(row interface{ Scan(...interface{}) error }) (*domain.Answer, error) {
+	var a domain.Answer
+	var textAnswer, feedback sql.NullString
+	var isCorrect sql.NullBool
+	
+	err := row.Scan(
+		&a.ID, &a.AttemptID, &a.QuestionID, 
+		pq.Array(&a.SelectedOptionIDs), &textAnswer,
+		&isCorrect, &a.PointsEarned, &feedback, &a.GradedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	
+	if textAnswer.Valid {
+		a.TextAnswer = textAnswer.String
+	}
+	if isCorrect.Valid {
+		a.IsCorrect = &isCorrect.Bool
+	}
+	a.Feedback = feedback.String
+	
+	return &a, nil
+}
+
+// GetAnswerByID retrieves a single answer
+func (r *QuizRepository) GetAnswerByID(id string) (*domain.Answer, error) {
+	query := `SELECT ` + answerColumns + ` FROM quiz_answers WHERE id = $1`
+	
+	a, err := scanAnswer(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+// UpdateAnswerGrade stores a new grade for an answer
+func (r *QuizRepository) UpdateAnswerGrade(answer *domain.Answer) error {
+	query := `
+		UPDATE quiz_answers SET
+			is_correct = $2, points_earned = $3, feedback = $4, graded_by = $5, graded_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(query, answer.ID, answer.IsCorrect, answer.PointsEarned,
+		nullString(answer.Feedback), answer.GradedBy)
+	return err
+}
+
+// CompletePendingGrade stores the AI grade of an answer that is waiting for it.
+// It returns false when the answer was graded meanwhile, e.g. by the instructor.
+func (r *QuizRepository) CompletePendingGrade(answer *domain.Answer) (bool, error) {
+	query := `
+		UPDATE quiz_answers SET
+			is_correct = $2, points_earned = $3, feedback = $4, graded_by = $5, graded_at = NOW()
+		WHERE id = $1 AND graded_by = $6
+	`
+	result, err := r.db.Exec(query, answer.ID, answer.IsCorrect, answer.PointsEarned,
+		nullString(answer.Feedback), answer.GradedBy, domain.GradedByPending)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// gradingModeOrDefault returns mode, or exact matching when unset
+func gradingModeOrDefault(mode domain.GradingMode) domain.GradingMode {
+	if mode == "" {
+		return domain.GradingExact
+	}
+	return mode
+}
@@ -0,0 +1,203 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrRefundExceedsBalance is returned when a refund is larger than what is left to refund
+var ErrRefundExceedsBalance = errors.New("refund exceeds the refundable amount")
+
+// ErrRefundNotPending is returned when settling a refund that was already settled
+var ErrRefundNotPending = errors.New("refund is not pending")
+
+// TransactionRefund is one entry in a transaction's refund ledger
+type TransactionRefund struct {
+	ID                string    `json:"id"`
+	TransactionID     string    `json:"transaction_id"`
+	Amount            float64   `json:"amount"`
+	Reason            string    `json:"reason"`
+	Provider          *string   `json:"provider,omitempty"`
+	RefundKey         string    `json:"refund_key"`
+	GatewayRef        *string   `json:"gateway_ref,omitempty"`
+	Status            string    `json:"status"` // pending, succeeded, manual, failed
+	Message           *string   `json:"message,omitempty"`
+	IsFull            bool      `json:"is_full"`
+	EnrollmentPolicy  string    `json:"enrollment_policy,omitempty"` // revoke or retain, applied if the refund completes the transaction
+	EnrollmentRevoked bool      `json:"enrollment_revoked"`
+	CouponReleased    bool      `json:"coupon_released"`
+	CreatedBy         *string   `json:"created_by,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// ReserveRefund adds a pending refund to the ledger. Pending refunds count against
+// the refundable balance, which is re-checked under a row lock on the
+// transaction, so concurrent refunds can't reserve more than was paid. The
+// refund is reserved before the gateway is asked to move any money.
+func (r *TransactionRepository) ReserveRefund(refund *TransactionRefund) error {
+	dbTx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer dbTx.Rollback()
+
+	var amount, refunded, pending float64
+	err = dbTx.QueryRow(
+		`SELECT amount, refunded_amount FROM transactions WHERE id = $1 FOR UPDATE`,
+		refund.TransactionID,
+	).Scan(&amount, &refunded)
+	if err != nil {
+		return err
+	}
+
+	err = dbTx.QueryRow(
+		`SELECT COALESCE(SUM(amount), 0) FROM transaction_refunds WHERE transaction_id = $1 AND status = 'pending'`,
+		refund.TransactionID,
+	).Scan(&pending)
+	if err != nil {
+		return err
+	}
+
+	if refunded+pending+refund.Amount > amount+0.005 {
+		return ErrRefundExceedsBalance
+	}
+	refund.IsFull = refunded+pending+refund.Amount >= amount-0.005
+	refund.Status = "pending"
+
+	err = dbTx.QueryRow(`
+		INSERT INTO transaction_refunds (transaction_id, amount, reason, provider, refund_key, status, is_full, enrollment_policy, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at
+	`,
+		refund.TransactionID, refund.Amount, refund.Reason, refund.Provider, refund.RefundKey,
+		refund.Status, refund.IsFull, refund.EnrollmentPolicy, refund.CreatedBy,
+	).Scan(&refund.ID, &refund.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return dbTx.Commit()
+}
+
+// CompleteRefund settles a pending refund with its outcome in refund.Status. Unless
+// the refund failed, its amount is added to the transaction's refunded_amount and
+// the transaction moves to refunded or partially_refunded, which is returned.
+// ErrRefundNotPending is returned when the refund was already settled.
+func (r *TransactionRepository) CompleteRefund(refund *TransactionRefund) (string, error) {
+	dbTx, err := r.db.Beginx()
+	if err != nil {
+		return "", err
+	}
+	defer dbTx.Rollback()
+
+	var amount, refunded float64
+	var status string
+	err = dbTx.QueryRow(
+		`SELECT amount, refunded_amount, status FROM transactions WHERE id = $1 FOR UPDATE`,
+		refund.TransactionID,
+	).Scan(&amount, &refunded, &status)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := dbTx.Exec(`
+		UPDATE transaction_refunds SET status = $2, gateway_ref = $3, message = $4
+		WHERE id = $1 AND status = 'pending'
+	`, refund.ID, refund.Status, refund.GatewayRef, refund.Message)
+	if err != nil {
+		return "", err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return "", err
+	} else if affected == 0 {
+		return "", ErrRefundNotPending
+	}
+
+	if refund.Status != "failed" {
+		refunded += refund.Amount
+		status = "partially_refunded"
+		if refunded >= amount-0.005 {
+			status = "refunded"
+		}
+
+		_, err = dbTx.Exec(
+			`UPDATE transactions SET refunded_amount = $2, status = $3, updated_at = $4 WHERE id = $1`,
+			refund.TransactionID, refunded, status, time.Now(),
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return status, dbTx.Commit()
+}
+
+// UpdateRefundEffects records whether a refund revoked the enrollment and released the coupon
+func (r *TransactionRepository) UpdateRefundEffects(id string, enrollmentRevoked, couponReleased bool) error {
+	_, err := r.db.Exec(
+		`UPDATE transaction_refunds SET enrollment_revoked = $2, coupon_released = $3 WHERE id = $1`,
+		id, enrollmentRevoked, couponReleased,
+	)
+	return err
+}
+
+// GetRefund returns a refund of a transaction, or nil
+func (r *TransactionRepository) GetRefund(transactionID, id string) (*TransactionRefund, error) {
+	refunds, err := r.ListRefunds(transactionID)
+	if err != nil {
+		return nil, err
+	}
+	for _, refund := range refunds {
+		if refund.ID == id {
+			return refund, nil
+		}
+	}
+	return nil, nil
+}
+
+// ListRefunds returns a transaction's refund ledger, oldest first
+func (r *TransactionRepository) ListRefunds(transactionID string) ([]*TransactionRefund, error) {
+	rows, err := r.db.Query(`
+		SELECT id, transaction_id, amount, reason, provider, refund_key, gateway_ref, status, message,
+		       is_full, COALESCE(enrollment_policy, ''), enrollment_revoked, coupon_released, created_by::text, created_at
+		FROM transaction_refunds
+		WHERE transaction_id = $1
+		ORDER BY created_at ASC
+	`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refunds := []*TransactionRefund{}
+	for rows.Next() {
+		var refund TransactionRefund
+		var provider, gatewayRef, message, createdBy sql.NullString
+
+		err := rows.Scan(
+			&refund.ID, &refund.TransactionID, &refund.Amount, &refund.Reason, &provider, &refund.RefundKey,
+			&gatewayRef, &refund.Status, &message, &refund.IsFull, &refund.EnrollmentPolicy, &refund.EnrollmentRevoked,
+			&refund.CouponReleased, &createdBy, &refund.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if provider.Valid {
+			refund.Provider = &provider.String
+		}
+		if gatewayRef.Valid {
+			refund.GatewayRef = &gatewayRef.String
+		}
+		if message.Valid {
+			refund.Message = &message.String
+		}
+		if createdBy.Valid {
+			refund.CreatedBy = &createdBy.String
+		}
+		refunds = append(refunds, &refund)
+	}
+
+	return refunds, rows.Err()
+}
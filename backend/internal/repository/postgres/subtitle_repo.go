@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/domain"
+)
+
+// SubtitleRepository handles lesson subtitle track data access
+type SubtitleRepository struct {
+	db *sqlx.DB
+}
+
+// NewSubtitleRepository creates a new subtitle repository
+func NewSubtitleRepository(db *sqlx.DB) *SubtitleRepository {
+	return &SubtitleRepository{db: db}
+}
+
+const subtitleColumns = `id, lesson_id, language, label, is_default, object_path, original_filename, created_at, updated_at`
+
+// ListByLesson returns a lesson's tracks, default first
+func (r *SubtitleRepository) ListByLesson(lessonID string) ([]domain.LessonSubtitle, error) {
+	subtitles := []domain.LessonSubtitle{}
+	err := r.db.Select(&subtitles, `
+		SELECT `+subtitleColumns+` FROM lesson_subtitles
+		WHERE lesson_id = $1
+		ORDER BY is_default DESC, label ASC
+	`, lessonID)
+	return subtitles, err
+}
+
+// GetByID returns a lesson's track by ID, or nil
+func (r *SubtitleRepository) GetByID(lessonID, id string) (*domain.LessonSubtitle, error) {
+	var s domain.LessonSubtitle
+	err := r.db.Get(&s, `
+		SELECT `+subtitleColumns+` FROM lesson_subtitles WHERE id = $1 AND lesson_id = $2
+	`, id, lessonID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Upsert creates the track for a language or replaces the existing one.
+// A default track clears the default flag on the lesson's other tracks.
+func (r *SubtitleRepository) Upsert(s *domain.LessonSubtitle) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if s.IsDefault {
+		if _, err := tx.Exec(`
+			UPDATE lesson_subtitles SET is_default = false
+			WHERE lesson_id = $1 AND language <> $2 AND is_default = true
+		`, s.LessonID, s.Language); err != nil {
+			return err
+		}
+	}
+
+	err = tx.QueryRowx(`
+		INSERT INTO lesson_subtitles (lesson_id, language, label, is_default, object_path, original_filename)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (lesson_id, language) DO UPDATE
+		SET label = EXCLUDED.label, is_default = EXCLUDED.is_default,
+		    object_path = EXCLUDED.object_path, original_filename = EXCLUDED.original_filename,
+		    updated_at = NOW()
+		RETURNING id, created_at, updated_at
+	`, s.LessonID, s.Language, s.Label, s.IsDefault, s.ObjectPath, s.OriginalFilename).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Update changes a track's label and default flag
+func (r *SubtitleRepository) Update(s *domain.LessonSubtitle) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if s.IsDefault {
+		if _, err := tx.Exec(`
+			UPDATE lesson_subtitles SET is_default = false
+			WHERE lesson_id = $1 AND id <> $2 AND is_default = true
+		`, s.LessonID, s.ID); err != nil {
+			return err
+		}
+	}
+
+	err = tx.QueryRowx(`
+		UPDATE lesson_subtitles SET label = $2, is_default = $3, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at
+	`, s.ID, s.Label, s.IsDefault).Scan(&s.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a track
+func (r *SubtitleRepository) Delete(id string) error {
+	_, err := r.db.Exec(`DELETE FROM lesson_subtitles WHERE id = $1`, id)
+	return err
+}
@@ -35,6 +35,8 @@ type Transaction struct {
 	CouponID       *string  `json:"coupon_id,omitempty"`
 	OriginalAmount *float64 `json:"original_amount,omitempty"`
 	DiscountAmount *float64 `json:"discount_amount,omitempty"`
+	// Sum of the refunds in the refund ledger
+	RefundedAmount float64 `json:"refunded_amount"`
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 }
@@ -54,7 +56,7 @@ func (r *TransactionRepository) GetByID(id string) (*Transaction, error) {
 	query := `
 		SELECT id::text, tenant_id::text, user_id::text, course_id::text, order_id, amount, currency, status,
 		       payment_gateway, payment_gateway_ref, payment_method, metadata,
-		       created_at, updated_at
+		       refunded_amount, created_at, updated_at
 		FROM transactions WHERE id = $1
 	`
 	
@@ -65,7 +67,7 @@ func (r *TransactionRepository) GetByID(id string) (*Transaction, error) {
 	err := r.db.QueryRow(query, id).Scan(
 		&tx.ID, &tenantID, &tx.UserID, &courseID, &orderID, &tx.Amount, &tx.Currency,
 		&tx.Status, &tx.PaymentGateway, &gatewayRef, &method, &metadata,
-		&tx.CreatedAt, &tx.UpdatedAt,
+		&tx.RefundedAmount, &tx.CreatedAt, &tx.UpdatedAt,
 	)
 	
 	if err == sql.ErrNoRows {
@@ -150,6 +152,20 @@ func (r *TransactionRepository) UpdateStatus(id, status string) error {
 	return err
 }
 
+// MarkWebinarFollowUp records that a webinar-only purchase's follow-up was queued.
+// It returns false when an earlier call already did, so the follow-up is sent once.
+func (r *TransactionRepository) MarkWebinarFollowUp(id string) (bool, error) {
+	result, err := r.db.Exec(`UPDATE transactions SET webinar_followup_at = NOW() WHERE id = $1 AND webinar_followup_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
 // Delete removes a transaction from the database
 func (r *TransactionRepository) Delete(id string) error {
 	query := `DELETE FROM transactions WHERE id = $1`
@@ -170,7 +186,7 @@ func (r *TransactionRepository) GetByOrderID(orderID string) (*Transaction, erro
 		SELECT id, tenant_id, user_id, course_id, order_id, amount, gross_amount, currency, status,
 		       payment_gateway, payment_gateway_ref, payment_method, payment_type, snap_token,
 		       payment_url, fraud_status, transaction_time, settlement_time, expired_at,
-		       metadata, coupon_id, original_amount, discount_amount, created_at, updated_at
+		       metadata, coupon_id, original_amount, discount_amount, refunded_amount, created_at, updated_at
 		FROM transactions WHERE order_id = $1
 	`
 	
@@ -185,7 +201,7 @@ func (r *TransactionRepository) GetByOrderID(orderID string) (*Transaction, erro
 		&tx.ID, &tenantID, &tx.UserID, &courseID, &orderIDVal, &tx.Amount, &grossAmount, &tx.Currency,
 		&tx.Status, &tx.PaymentGateway, &gatewayRef, &method, &paymentType, &snapToken,
 		&paymentURL, &fraudStatus, &transactionTime, &settlementTime, &expiredAt,
-		&metadata, &couponID, &originalAmount, &discountAmount, &tx.CreatedAt, &tx.UpdatedAt,
+		&metadata, &couponID, &originalAmount, &discountAmount, &tx.RefundedAmount, &tx.CreatedAt, &tx.UpdatedAt,
 	)
 	
 	if err == sql.ErrNoRows {
@@ -397,7 +413,7 @@ func (r *TransactionRepository) ListByTenant(tenantID string, limit, offset int)
 	if tenantID == "" || tenantID == "default" {
 		query := `
 			SELECT id::text, tenant_id::text, user_id::text, course_id::text, order_id, amount, currency, status,
-			       payment_gateway, payment_gateway_ref, payment_method, metadata,
+			       payment_gateway, payment_gateway_ref, payment_method, metadata, refunded_amount,
 			       created_at, updated_at
 			FROM transactions 
 			WHERE tenant_id IS NULL
@@ -409,7 +425,7 @@ func (r *TransactionRepository) ListByTenant(tenantID string, limit, offset int)
 	
 	query := `
 		SELECT id::text, tenant_id::text, user_id::text, course_id::text, order_id, amount, currency, status,
-		       payment_gateway, payment_gateway_ref, payment_method, metadata,
+		       payment_gateway, payment_gateway_ref, payment_method, metadata, refunded_amount,
 		       created_at, updated_at
 		FROM transactions 
 		WHERE tenant_id = $1 OR tenant_id IS NULL
@@ -423,7 +439,7 @@ func (r *TransactionRepository) ListByTenant(tenantID string, limit, offset int)
 func (r *TransactionRepository) ListByUser(userID string, limit, offset int) ([]*Transaction, error) {
 	query := `
 		SELECT id::text, tenant_id::text, user_id::text, course_id::text, order_id, amount, currency, status,
-		       payment_gateway, payment_gateway_ref, payment_method, metadata,
+		       payment_gateway, payment_gateway_ref, payment_method, metadata, refunded_amount,
 		       created_at, updated_at
 		FROM transactions 
 		WHERE user_id = $1
@@ -446,7 +462,7 @@ func (r *TransactionRepository) CountByUser(userID string) (int, error) {
 func (r *TransactionRepository) ListByStatus(tenantID, status string, limit, offset int) ([]*Transaction, error) {
 	query := `
 		SELECT id::text, tenant_id::text, user_id::text, course_id::text, order_id, amount, currency, status,
-		       payment_gateway, payment_gateway_ref, payment_method, metadata,
+		       payment_gateway, payment_gateway_ref, payment_method, metadata, refunded_amount,
 		       created_at, updated_at
 		FROM transactions 
 		WHERE (tenant_id = $1 OR tenant_id IS NULL) AND status = $2
@@ -516,7 +532,7 @@ func (r *TransactionRepository) scanTransactionRows(rows *sql.Rows) ([]*Transact
 		err := rows.Scan(
 			&tx.ID, &tenantID, &tx.UserID, &courseID, &orderID, &tx.Amount, &tx.Currency,
 			&tx.Status, &tx.PaymentGateway, &gatewayRef, &method, &metadata,
-			&tx.CreatedAt, &tx.UpdatedAt,
+			&tx.RefundedAmount, &tx.CreatedAt, &tx.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
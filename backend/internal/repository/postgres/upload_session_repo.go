@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/domain"
+)
+
+// UploadSessionRepository handles resumable upload session data access
+type UploadSessionRepository struct {
+	db *sqlx.DB
+}
+
+// NewUploadSessionRepository creates a new upload session repository
+func NewUploadSessionRepository(db *sqlx.DB) *UploadSessionRepository {
+	return &UploadSessionRepository{db: db}
+}
+
+const uploadSessionColumns = `id, user_id, object_key, storage_upload_id, filename, content_type, file_type,
+	total_size, part_size, total_parts, status, expires_at, created_at, updated_at, completed_at`
+
+// Create inserts a new upload session
+func (r *UploadSessionRepository) Create(s *domain.UploadSession) error {
+	return r.db.QueryRow(`
+		INSERT INTO upload_sessions (user_id, object_key, storage_upload_id, filename, content_type,
+		                             file_type, total_size, part_size, total_parts, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at, updated_at
+	`, s.UserID, s.ObjectKey, s.StorageUploadID, s.Filename, s.ContentType, s.FileType,
+		s.TotalSize, s.PartSize, s.TotalParts, s.Status, s.ExpiresAt).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+}
+
+// GetByID returns an upload session, or nil
+func (r *UploadSessionRepository) GetByID(id string) (*domain.UploadSession, error) {
+	var s domain.UploadSession
+	err := r.db.Get(&s, `SELECT `+uploadSessionColumns+` FROM upload_sessions WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListParts returns the verified parts of an upload, in order
+func (r *UploadSessionRepository) ListParts(uploadID string) ([]domain.UploadSessionPart, error) {
+	parts := []domain.UploadSessionPart{}
+	err := r.db.Select(&parts, `
+		SELECT upload_id, part_number, size, etag, sha256, uploaded_at
+		FROM upload_session_parts
+		WHERE upload_id = $1
+		ORDER BY part_number
+	`, uploadID)
+	return parts, err
+}
+
+// SavePart records a verified part (replacing a previous attempt) and extends the session's expiry
+func (r *UploadSessionRepository) SavePart(part *domain.UploadSessionPart, expiresAt time.Time) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowx(`
+		INSERT INTO upload_session_parts (upload_id, part_number, size, etag, sha256)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (upload_id, part_number) DO UPDATE
+		SET size = EXCLUDED.size, etag = EXCLUDED.etag, sha256 = EXCLUDED.sha256, uploaded_at = NOW()
+		RETURNING uploaded_at
+	`, part.UploadID, part.PartNumber, part.Size, part.ETag, part.SHA256).Scan(&part.UploadedAt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE upload_sessions SET expires_at = $2, updated_at = NOW() WHERE id = $1
+	`, part.UploadID, expiresAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeletePart forgets a part, e.g. after a re-sent copy failed verification and
+// storage may no longer hold the bytes that were recorded
+func (r *UploadSessionRepository) DeletePart(uploadID string, partNumber int) error {
+	_, err := r.db.Exec(`DELETE FROM upload_session_parts WHERE upload_id = $1 AND part_number = $2`, uploadID, partNumber)
+	return err
+}
+
+// UpdateStatus moves an uploading session to a final status. Returns false if it was
+// no longer uploading (e.g. completed concurrently or already cleaned up).
+func (r *UploadSessionRepository) UpdateStatus(id, status string) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE upload_sessions
+		SET status = $2, updated_at = NOW(),
+		    completed_at = CASE WHEN $2 = 'completed' THEN NOW() ELSE completed_at END
+		WHERE id = $1 AND status = 'uploading'
+	`, id, status)
+	if err != nil {
+		return false, err
+	}
+	count, _ := result.RowsAffected()
+	return count > 0, nil
+}
+
+// ListExpired returns uploading sessions whose expiry has passed
+func (r *UploadSessionRepository) ListExpired(limit int) ([]domain.UploadSession, error) {
+	sessions := []domain.UploadSession{}
+	err := r.db.Select(&sessions, `
+		SELECT `+uploadSessionColumns+` FROM upload_sessions
+		WHERE status = 'uploading' AND expires_at < NOW()
+		ORDER BY expires_at
+		LIMIT $1
+	`, limit)
+	return sessions, err
+}
+
+// IsTracked reports whether a storage upload ID belongs to a session that is still uploading
+func (r *UploadSessionRepository) IsTracked(storageUploadID string) (bool, error) {
+	var tracked bool
+	err := r.db.Get(&tracked, `
+		SELECT EXISTS(SELECT 1 FROM upload_sessions WHERE storage_upload_id = $1 AND status = 'uploading')
+	`, storageUploadID)
+	return tracked, err
+}
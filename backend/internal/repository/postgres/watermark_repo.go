@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/domain"
+)
+
+// WatermarkRepository handles forensic watermark data access
+type WatermarkRepository struct {
+	db *sqlx.DB
+}
+
+// NewWatermarkRepository creates a new watermark repository
+func NewWatermarkRepository(db *sqlx.DB) *WatermarkRepository {
+	return &WatermarkRepository{db: db}
+}
+
+// Create records a newly issued watermark code
+func (r *WatermarkRepository) Create(w *domain.VideoWatermark) error {
+	return r.db.QueryRow(`
+		INSERT INTO video_watermarks (code, user_id, lesson_id, course_id, enrollment_id, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, issued_at
+	`, w.Code, w.UserID, w.LessonID, w.CourseID, w.EnrollmentID, w.IPAddress, w.UserAgent).Scan(&w.ID, &w.IssuedAt)
+}
+
+// GetRecent returns the latest code issued to a user for a lesson since the given time, or nil
+func (r *WatermarkRepository) GetRecent(userID, lessonID string, since time.Time) (*domain.VideoWatermark, error) {
+	var w domain.VideoWatermark
+	err := r.db.Get(&w, `
+		SELECT id, code, user_id, lesson_id, course_id, enrollment_id, ip_address, user_agent, issued_at
+		FROM video_watermarks
+		WHERE user_id = $1 AND lesson_id = $2 AND issued_at >= $3
+		ORDER BY issued_at DESC
+		LIMIT 1
+	`, userID, lessonID, since)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// GetTraceByCode resolves a watermark code to the viewer, lesson and enrollment, or nil
+func (r *WatermarkRepository) GetTraceByCode(code string) (*domain.WatermarkTrace, error) {
+	var t domain.WatermarkTrace
+	err := r.db.Get(&t, `
+		SELECT
+			w.id, w.code, w.user_id, w.lesson_id, w.course_id, w.enrollment_id,
+			w.ip_address, w.user_agent, w.issued_at,
+			u.email as user_email,
+			COALESCE(u.full_name, '') as user_name,
+			l.title as lesson_title,
+			c.title as course_title,
+			e.enrolled_at,
+			e.progress_percentage as enrollment_progress,
+			e.transaction_id::text as transaction_id,
+			(SELECT COUNT(*) FROM video_watermarks vw WHERE vw.user_id = w.user_id) as user_watermark_count
+		FROM video_watermarks w
+		JOIN users u ON u.id = w.user_id
+		JOIN lessons l ON l.id = w.lesson_id
+		JOIN courses c ON c.id = w.course_id
+		LEFT JOIN enrollments e ON e.id = w.enrollment_id
+		WHERE w.code = $1
+	`, code)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
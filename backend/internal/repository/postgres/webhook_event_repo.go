@@ -0,0 +1,252 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Webhook event statuses
+const (
+	WebhookReceived   = "received"   // Stored, not processed yet
+	WebhookProcessing = "processing" // Claimed by a delivery or a replay
+	WebhookProcessed  = "processed"  // Applied to the transaction
+	WebhookIgnored    = "ignored"    // Valid, but an illegal status transition
+	WebhookRejected   = "rejected"   // Unparseable or failed signature verification
+	WebhookFailed     = "failed"     // Processing failed; a retry or replay may succeed
+)
+
+// WebhookClaimLease is how long a claimed event may stay processing before another
+// delivery can claim it, e.g. after the process handling it died
+const WebhookClaimLease = 5 * time.Minute
+
+// WebhookEvent is a payment gateway notification as it was received, with the
+// outcome of processing it
+type WebhookEvent struct {
+	ID             string          `json:"id"`
+	Provider       string          `json:"provider"`
+	EventKey       string          `json:"event_key"`
+	OrderID        *string         `json:"order_id,omitempty"`
+	TransactionID  *string         `json:"transaction_id,omitempty"`
+	GatewayStatus  *string         `json:"gateway_status,omitempty"`
+	Headers        json.RawMessage `json:"headers"`
+	Body           string          `json:"body"`
+	SignatureValid *bool           `json:"signature_valid,omitempty"`
+	Status         string          `json:"status"`
+	FromStatus     *string         `json:"from_status,omitempty"`
+	ToStatus       *string         `json:"to_status,omitempty"`
+	Error          *string         `json:"error,omitempty"`
+	Attempts       int             `json:"attempts"`
+	DuplicateCount int             `json:"duplicate_count"`
+	ClaimedAt      *time.Time      `json:"claimed_at,omitempty"`
+	ReceivedAt     time.Time       `json:"received_at"`
+	LastReceivedAt time.Time       `json:"last_received_at"`
+	ProcessedAt    *time.Time      `json:"processed_at,omitempty"`
+}
+
+// WebhookEventFilter narrows the webhook event list; empty fields match everything
+type WebhookEventFilter struct {
+	Provider string
+	Status   string
+	OrderID  string
+}
+
+const webhookEventColumns = `id, provider, event_key, order_id, transaction_id::text, gateway_status, headers, body,
+	signature_valid, status, from_status, to_status, error, attempts, duplicate_count,
+	claimed_at, received_at, last_received_at, processed_at`
+
+// scanWebhookEvent reads a row selected with webhookEventColumns
+func scanWebhookEvent(row interface{ Scan(...interface{}) error }) (*WebhookEvent, error) {
+	var event WebhookEvent
+	var orderID, transactionID, gatewayStatus, fromStatus, toStatus, errMsg sql.NullString
+	var signatureValid sql.NullBool
+	var claimedAt, processedAt sql.NullTime
+	var headers []byte
+
+	err := row.Scan(
+		&event.ID, &event.Provider, &event.EventKey, &orderID, &transactionID, &gatewayStatus, &headers, &event.Body,
+		&signatureValid, &event.Status, &fromStatus, &toStatus, &errMsg, &event.Attempts, &event.DuplicateCount,
+		&claimedAt, &event.ReceivedAt, &event.LastReceivedAt, &processedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if orderID.Valid {
+		event.OrderID = &orderID.String
+	}
+	if transactionID.Valid {
+		event.TransactionID = &transactionID.String
+	}
+	if gatewayStatus.Valid {
+		event.GatewayStatus = &gatewayStatus.String
+	}
+	if signatureValid.Valid {
+		event.SignatureValid = &signatureValid.Bool
+	}
+	if fromStatus.Valid {
+		event.FromStatus = &fromStatus.String
+	}
+	if toStatus.Valid {
+		event.ToStatus = &toStatus.String
+	}
+	if errMsg.Valid {
+		event.Error = &errMsg.String
+	}
+	if claimedAt.Valid {
+		event.ClaimedAt = &claimedAt.Time
+	}
+	if processedAt.Valid {
+		event.ProcessedAt = &processedAt.Time
+	}
+	event.Headers = headers
+
+	return &event, nil
+}
+
+// RecordWebhookEvent stores a received webhook. When the provider already sent an
+// event with the same key, the stored event is loaded into event instead, its
+// duplicate count is bumped, and false is returned.
+func (r *TransactionRepository) RecordWebhookEvent(event *WebhookEvent) (bool, error) {
+	headers := []byte(event.Headers)
+	if len(headers) == 0 {
+		headers = []byte("{}")
+	}
+
+	var inserted bool
+	err := r.db.QueryRow(`
+		INSERT INTO webhook_events (provider, event_key, order_id, gateway_status, headers, body)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (provider, event_key) DO UPDATE SET
+			duplicate_count = webhook_events.duplicate_count + 1,
+			last_received_at = CURRENT_TIMESTAMP
+		RETURNING id, (xmax = 0)
+	`, event.Provider, event.EventKey, event.OrderID, event.GatewayStatus, headers, event.Body).Scan(&event.ID, &inserted)
+	if err != nil {
+		return false, err
+	}
+	if inserted {
+		event.Status = WebhookReceived
+		return true, nil
+	}
+
+	stored, err := r.GetWebhookEvent(event.ID)
+	if err != nil {
+		return false, err
+	}
+	if stored == nil {
+		return false, fmt.Errorf("webhook event %s disappeared", event.ID)
+	}
+	*event = *stored
+	return false, nil
+}
+
+// ClaimWebhookEvent marks an event as being processed and counts the attempt. A
+// finished event is only claimed when force is set, as it is for admin replays,
+// and one that is processing only when its claim is older than WebhookClaimLease;
+// otherwise false is returned.
+func (r *TransactionRepository) ClaimWebhookEvent(id string, force bool) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE webhook_events SET status = 'processing', attempts = attempts + 1, claimed_at = NOW()
+		WHERE id = $1 AND (
+			status IN ('received', 'failed')
+			OR (status = 'processing' AND (claimed_at IS NULL OR claimed_at < NOW() - make_interval(secs => $3)))
+			OR ($2 AND status <> 'processing')
+		)
+	`, id, force, WebhookClaimLease.Seconds())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// FinishWebhookEvent stores the outcome of processing an event
+func (r *TransactionRepository) FinishWebhookEvent(event *WebhookEvent) error {
+	now := time.Now()
+	_, err := r.db.Exec(`
+		UPDATE webhook_events SET
+			status = $2,
+			signature_valid = $3,
+			order_id = COALESCE($4, order_id),
+			transaction_id = COALESCE($5::uuid, transaction_id),
+			gateway_status = COALESCE($6, gateway_status),
+			from_status = $7,
+			to_status = $8,
+			error = $9,
+			processed_at = $10
+		WHERE id = $1
+	`,
+		event.ID, event.Status, event.SignatureValid, event.OrderID, event.TransactionID,
+		event.GatewayStatus, event.FromStatus, event.ToStatus, event.Error, now,
+	)
+	if err == nil {
+		event.ProcessedAt = &now
+	}
+	return err
+}
+
+// GetWebhookEvent returns a stored webhook event, or nil
+func (r *TransactionRepository) GetWebhookEvent(id string) (*WebhookEvent, error) {
+	event, err := scanWebhookEvent(r.db.QueryRow(`SELECT `+webhookEventColumns+` FROM webhook_events WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return event, err
+}
+
+// ListWebhookEvents returns stored webhook events, newest first, with the total count
+func (r *TransactionRepository) ListWebhookEvents(filter WebhookEventFilter, limit, offset int) ([]*WebhookEvent, int, error) {
+	where := `WHERE ($1 = '' OR provider = $1) AND ($2 = '' OR status = $2) AND ($3 = '' OR order_id = $3)`
+
+	var total int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM webhook_events `+where, filter.Provider, filter.Status, filter.OrderID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT `+webhookEventColumns+`
+		FROM webhook_events `+where+`
+		ORDER BY received_at DESC
+		LIMIT $4 OFFSET $5
+	`, filter.Provider, filter.Status, filter.OrderID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []*WebhookEvent{}
+	for rows.Next() {
+		event, err := scanWebhookEvent(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+
+	return events, total, rows.Err()
+}
+
+// TransitionFromCallback moves a transaction from one status to another with the
+// data of a gateway notification. It reports false when the transaction was no
+// longer in the from status, e.g. because another notification got there first.
+func (r *TransactionRepository) TransitionFromCallback(orderID, fromStatus, toStatus string, paymentType *string, fraudStatus *string, transactionTime *time.Time, settlementTime *time.Time, paymentGatewayRef *string) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE transactions SET
+			status = $3,
+			payment_type = $4,
+			fraud_status = $5,
+			transaction_time = $6,
+			settlement_time = $7,
+			payment_gateway_ref = $8,
+			updated_at = $9
+		WHERE order_id = $1 AND status = $2
+	`, orderID, fromStatus, toStatus, paymentType, fraudStatus, transactionTime, settlementTime, paymentGatewayRef, time.Now())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
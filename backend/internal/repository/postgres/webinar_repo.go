@@ -253,6 +253,29 @@ func (r *WebinarRepository) RegisterUser(webinarID, userID, source string) error
 	return err
 }
 
+// UnregisterPurchase removes a registration made by a webinar-only payment, with
+// its pending reminders, and reports whether there was one. Registrations from
+// other sources (a campaign, a course purchase) are left alone.
+func (r *WebinarRepository) UnregisterPurchase(webinarID, userID string) (bool, error) {
+	result, err := r.db.Exec(`
+		DELETE FROM webinar_registrations
+		WHERE webinar_id = $1 AND user_id = $2 AND registration_source = 'payment_gateway'
+	`, webinarID, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+
+	_, err = r.db.Exec(
+		`DELETE FROM webinar_reminders WHERE webinar_id = $1 AND user_id = $2 AND status = 'pending'`,
+		webinarID, userID,
+	)
+	return true, err
+}
+
 // IsUserRegistered checks if user is registered for webinar
 func (r *WebinarRepository) IsUserRegistered(webinarID, userID string) (bool, error) {
 	query := `SELECT EXISTS(SELECT 1 FROM webinar_registrations WHERE webinar_id = $1 AND user_id = $2)`
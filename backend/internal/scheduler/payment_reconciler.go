@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// PaymentReconcileScheduler periodically reconciles pending payments with the
+// payment gateway and writes the daily discrepancy report. The work itself is
+// passed in by the handlers package, which owns the payment success path.
+type PaymentReconcileScheduler struct {
+	reconcile     func(ctx context.Context)
+	report        func(ctx context.Context)
+	ticker        *time.Ticker
+	done          chan bool
+	isRunning     bool
+	checkInterval time.Duration
+}
+
+// NewPaymentReconcileScheduler creates a new payment reconciliation scheduler
+func NewPaymentReconcileScheduler(reconcile, report func(ctx context.Context)) *PaymentReconcileScheduler {
+	return &PaymentReconcileScheduler{
+		reconcile:     reconcile,
+		report:        report,
+		done:          make(chan bool),
+		isRunning:     false,
+		checkInterval: 15 * time.Minute,
+	}
+}
+
+// Start begins the reconciliation loop
+func (s *PaymentReconcileScheduler) Start() {
+	if s.isRunning {
+		log.Println("[Reconcile] Already running")
+		return
+	}
+
+	s.ticker = time.NewTicker(s.checkInterval)
+	s.isRunning = true
+
+	go func() {
+		log.Println("[Reconcile] Payment reconciliation scheduler started")
+
+		s.run()
+
+		for {
+			select {
+			case <-s.done:
+				log.Println("[Reconcile] Payment reconciliation scheduler stopped")
+				return
+			case <-s.ticker.C:
+				s.run()
+			}
+		}
+	}()
+}
+
+// Stop stops the reconciliation loop
+func (s *PaymentReconcileScheduler) Stop() {
+	if !s.isRunning {
+		return
+	}
+
+	s.ticker.Stop()
+	s.done <- true
+	s.isRunning = false
+}
+
+// run reconciles pending payments, then writes yesterday's report if it's missing
+func (s *PaymentReconcileScheduler) run() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	s.reconcile(ctx)
+	s.report(ctx)
+}
+
+// ===== Singleton for global access =====
+
+var defaultPaymentReconciler *PaymentReconcileScheduler
+
+// InitPaymentReconciler initializes the global payment reconciliation scheduler
+func InitPaymentReconciler(reconcile, report func(ctx context.Context)) {
+	if defaultPaymentReconciler != nil {
+		return // Already initialized
+	}
+	defaultPaymentReconciler = NewPaymentReconcileScheduler(reconcile, report)
+}
+
+// StartPaymentReconciler starts the global payment reconciliation scheduler
+func StartPaymentReconciler() {
+	if defaultPaymentReconciler == nil {
+		log.Println("[Reconcile] Scheduler not initialized")
+		return
+	}
+	defaultPaymentReconciler.Start()
+}
+
+// StopPaymentReconciler stops the global payment reconciliation scheduler
+func StopPaymentReconciler() {
+	if defaultPaymentReconciler != nil {
+		defaultPaymentReconciler.Stop()
+	}
+}
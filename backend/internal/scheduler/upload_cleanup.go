@@ -0,0 +1,169 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/domain"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/repository/postgres"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/storage"
+)
+
+// UploadCleanupScheduler aborts resumable uploads that were abandoned, so their
+// parts don't pile up in storage
+type UploadCleanupScheduler struct {
+	db            *sqlx.DB
+	uploadRepo    *postgres.UploadSessionRepository
+	ticker        *time.Ticker
+	done          chan bool
+	isRunning     bool
+	checkInterval time.Duration
+}
+
+// NewUploadCleanupScheduler creates a new upload cleanup scheduler
+func NewUploadCleanupScheduler(db *sqlx.DB) *UploadCleanupScheduler {
+	return &UploadCleanupScheduler{
+		db:            db,
+		uploadRepo:    postgres.NewUploadSessionRepository(db),
+		done:          make(chan bool),
+		isRunning:     false,
+		checkInterval: 1 * time.Hour,
+	}
+}
+
+// Start begins the cleanup loop
+func (s *UploadCleanupScheduler) Start() {
+	if s.isRunning {
+		log.Println("[UploadCleanup] Already running")
+		return
+	}
+
+	s.ticker = time.NewTicker(s.checkInterval)
+	s.isRunning = true
+
+	go func() {
+		log.Println("[UploadCleanup] Upload cleanup scheduler started")
+
+		s.cleanup()
+
+		for {
+			select {
+			case <-s.done:
+				log.Println("[UploadCleanup] Upload cleanup scheduler stopped")
+				return
+			case <-s.ticker.C:
+				s.cleanup()
+			}
+		}
+	}()
+}
+
+// Stop stops the cleanup loop
+func (s *UploadCleanupScheduler) Stop() {
+	if !s.isRunning {
+		return
+	}
+
+	s.ticker.Stop()
+	s.done <- true
+	s.isRunning = false
+}
+
+// sessionTTL reads upload_session_ttl_hours from settings
+func (s *UploadCleanupScheduler) sessionTTL() time.Duration {
+	hours := 24
+	var value string
+	if err := s.db.Get(&value, `SELECT value FROM settings WHERE key = 'upload_session_ttl_hours'`); err == nil {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			hours = parsed
+		}
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// cleanup expires idle sessions and aborts untracked uploads left in storage
+func (s *UploadCleanupScheduler) cleanup() {
+	store := storage.GetStorage()
+	if store == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	s.expireSessions(ctx, store)
+	s.abortOrphans(ctx, store)
+}
+
+// expireSessions aborts sessions whose expiry has passed without new parts
+func (s *UploadCleanupScheduler) expireSessions(ctx context.Context, store storage.Storage) {
+	sessions, err := s.uploadRepo.ListExpired(500)
+	if err != nil {
+		log.Printf("[UploadCleanup] Error fetching expired uploads: %v", err)
+		return
+	}
+
+	for _, session := range sessions {
+		if err := store.AbortMultipartUpload(ctx, "", session.ObjectKey, session.StorageUploadID); err != nil {
+			// Storage may have already dropped it; the session is expired either way
+			log.Printf("[UploadCleanup] Failed to abort upload %s: %v", session.ID, err)
+		}
+		if _, err := s.uploadRepo.UpdateStatus(session.ID, domain.UploadStatusExpired); err != nil {
+			log.Printf("[UploadCleanup] Failed to expire upload %s: %v", session.ID, err)
+			continue
+		}
+		log.Printf("[UploadCleanup] Expired abandoned upload %s (%s)", session.ID, session.ObjectKey)
+	}
+}
+
+// abortOrphans aborts incomplete storage uploads older than the TTL that no active
+// session tracks, e.g. when the server crashed between starting an upload and saving it
+func (s *UploadCleanupScheduler) abortOrphans(ctx context.Context, store storage.Storage) {
+	stale, err := store.ListStaleMultipartUploads(ctx, "", time.Now().Add(-s.sessionTTL()))
+	if err != nil {
+		log.Printf("[UploadCleanup] Error listing incomplete uploads: %v", err)
+	}
+
+	for _, upload := range stale {
+		tracked, err := s.uploadRepo.IsTracked(upload.UploadID)
+		if err != nil || tracked {
+			continue
+		}
+		if err := store.AbortMultipartUpload(ctx, "", upload.ObjectName, upload.UploadID); err != nil {
+			log.Printf("[UploadCleanup] Failed to abort orphaned upload of %s: %v", upload.ObjectName, err)
+			continue
+		}
+		log.Printf("[UploadCleanup] Aborted orphaned upload of %s (started %s)", upload.ObjectName, upload.Initiated.Format(time.RFC3339))
+	}
+}
+
+// ===== Singleton for global access =====
+
+var defaultUploadCleanup *UploadCleanupScheduler
+
+// InitUploadCleanup initializes the global upload cleanup scheduler
+func InitUploadCleanup(db *sqlx.DB) {
+	if defaultUploadCleanup != nil {
+		return // Already initialized
+	}
+	defaultUploadCleanup = NewUploadCleanupScheduler(db)
+}
+
+// StartUploadCleanup starts the global upload cleanup scheduler
+func StartUploadCleanup() {
+	if defaultUploadCleanup == nil {
+		log.Println("[UploadCleanup] Scheduler not initialized")
+		return
+	}
+	defaultUploadCleanup.Start()
+}
+
+// StopUploadCleanup stops the global upload cleanup scheduler
+func StopUploadCleanup() {
+	if defaultUploadCleanup != nil {
+		defaultUploadCleanup.Stop()
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/domain"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/jobs"
 )
 
 // WhatsAppService handles WhatsApp message sending
@@ -120,7 +122,7 @@ type WebinarConfirmationData struct {
 	MeetingURL      string
 	MeetingPassword string
 	IsNewUser       bool
-	TempPassword    string
+	TempPassword    string // Generated once at checkout; jobs carrying it are sensitive
 	LMSUrl          string
 }
 
@@ -414,22 +416,90 @@ func GetWhatsAppService() *WhatsAppService {
 	return defaultWAService
 }
 
-// SendWebinarConfirmationAsync sends confirmation in background goroutine
+// WhatsApp job types processed by the background job queue
+const (
+	JobWebinarConfirmation     = "whatsapp.webinar_confirmation"
+	JobWebinarOnlyConfirmation = "whatsapp.webinar_only_confirmation"
+	JobPaymentSuccess          = "whatsapp.payment_success"
+)
+
+// webinarConfirmationJob is the payload of webinar confirmation jobs
+type webinarConfirmationJob struct {
+	Phone string                  `json:"phone"`
+	Data  WebinarConfirmationData `json:"data"`
+}
+
+// paymentSuccessJob is the payload of payment success jobs
+type paymentSuccessJob struct {
+	Phone      string `json:"phone"`
+	UserName   string `json:"user_name"`
+	CourseName string `json:"course_name"`
+	LMSUrl     string `json:"lms_url"`
+}
+
+// SendWebinarConfirmationAsync queues a webinar confirmation for background delivery
 func SendWebinarConfirmationAsync(phone string, data WebinarConfirmationData) {
-	go func() {
-		if err := GetWhatsAppService().SendWebinarConfirmation(phone, data); err != nil {
-			log.Printf("[WhatsApp] Failed to send webinar confirmation to %s: %v", phone, err)
-		}
-	}()
+	enqueueWhatsApp(JobWebinarConfirmation, webinarConfirmationJob{Phone: phone, Data: data}, data.TempPassword != "", func() error {
+		return GetWhatsAppService().SendWebinarConfirmation(phone, data)
+	})
 }
 
-// SendWebinarOnlyConfirmationAsync sends confirmation for webinar-only campaign in background
+// SendWebinarOnlyConfirmationAsync queues a webinar-only campaign confirmation for background delivery
 func SendWebinarOnlyConfirmationAsync(phone string, data WebinarConfirmationData) {
-	go func() {
-		if err := GetWhatsAppService().SendWebinarOnlyConfirmation(phone, data); err != nil {
-			log.Printf("[WhatsApp] Failed to send webinar only confirmation to %s: %v", phone, err)
+	enqueueWhatsApp(JobWebinarOnlyConfirmation, webinarConfirmationJob{Phone: phone, Data: data}, data.TempPassword != "", func() error {
+		return GetWhatsAppService().SendWebinarOnlyConfirmation(phone, data)
+	})
+}
+
+// SendPaymentSuccessAsync queues a payment success message for background delivery
+func SendPaymentSuccessAsync(phone, userName, courseName, lmsURL string) {
+	payload := paymentSuccessJob{Phone: phone, UserName: userName, CourseName: courseName, LMSUrl: lmsURL}
+	enqueueWhatsApp(JobPaymentSuccess, payload, false, func() error {
+		return GetWhatsAppService().SendPaymentSuccess(phone, userName, courseName, lmsURL)
+	})
+}
+
+// enqueueWhatsApp puts a send on the notifications queue, falling back to a goroutine
+// if the queue is unavailable so messages are never silently dropped. Sensitive
+// payloads (a temporary password) are wiped once the job finishes and can't be
+// retried, so a password is never sent twice or reset.
+func enqueueWhatsApp(jobType string, payload interface{}, sensitive bool, send func() error) {
+	opts := &jobs.EnqueueOptions{Sensitive: sensitive}
+	if _, err := jobs.Enqueue(context.Background(), jobs.QueueNotifications, jobType, payload, opts); err != nil {
+		log.Printf("[WhatsApp] Failed to enqueue %s, sending directly: %v", jobType, err)
+		go func() {
+			if err := send(); err != nil {
+				log.Printf("[WhatsApp] Failed to send %s: %v", jobType, err)
+			}
+		}()
+	}
+}
+
+// RegisterJobHandlers registers the WhatsApp job handlers with the job queue
+func RegisterJobHandlers() {
+	jobs.Register(JobWebinarConfirmation, func(ctx context.Context, job *jobs.Job) error {
+		var p webinarConfirmationJob
+		if err := job.Decode(&p); err != nil {
+			return err
+		}
+		return GetWhatsAppService().SendWebinarConfirmation(p.Phone, p.Data)
+	})
+
+	jobs.Register(JobWebinarOnlyConfirmation, func(ctx context.Context, job *jobs.Job) error {
+		var p webinarConfirmationJob
+		if err := job.Decode(&p); err != nil {
+			return err
+		}
+		return GetWhatsAppService().SendWebinarOnlyConfirmation(p.Phone, p.Data)
+	})
+
+	jobs.Register(JobPaymentSuccess, func(ctx context.Context, job *jobs.Job) error {
+		var p paymentSuccessJob
+		if err := job.Decode(&p); err != nil {
+			return err
 		}
-	}()
+		return GetWhatsAppService().SendPaymentSuccess(p.Phone, p.UserName, p.CourseName, p.LMSUrl)
+	})
 }
 
 // Buffer for batch processing if needed
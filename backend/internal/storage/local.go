@@ -0,0 +1,541 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/config"
+)
+
+// LocalSignedURLPrefix is the route that serves signed local storage URLs
+const LocalSignedURLPrefix = "/api/storage/"
+
+// Local storage keeps object metadata and in-progress multipart uploads in
+// dot-directories next to the buckets; bucket names never start with a dot.
+const (
+	localMetaDir    = ".meta"
+	localUploadsDir = ".uploads"
+)
+
+// ErrInvalidSignature is returned when a signed URL doesn't verify
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// ErrSignatureExpired is returned when a signed URL is past its expiry
+var ErrSignatureExpired = errors.New("signature expired")
+
+// LocalStorage implements Storage on the local filesystem. Pre-signed URLs are
+// emulated with HMAC-signed links served by the application itself.
+type LocalStorage struct {
+	rootDir       string
+	defaultBucket string
+	presignExpiry time.Duration
+	publicURL     string
+	signingKey    []byte
+}
+
+// LocalConfig holds configuration for filesystem storage
+type LocalConfig struct {
+	RootDir         string
+	PublicURL       string // Base URL of this API for signed links, e.g. https://lms.example.com
+	SigningKey      string
+	BucketContent   string
+	PresignedExpiry int // in seconds
+}
+
+// localObjectMeta is stored alongside each object
+type localObjectMeta struct {
+	ContentType string `json:"content_type"`
+}
+
+// localUploadMeta describes an in-progress multipart upload
+type localUploadMeta struct {
+	Bucket      string    `json:"bucket"`
+	ObjectName  string    `json:"object_name"`
+	ContentType string    `json:"content_type"`
+	Initiated   time.Time `json:"initiated"`
+}
+
+// LoadLocalConfigFromEnv loads filesystem storage configuration from environment variables
+func LoadLocalConfigFromEnv() *LocalConfig {
+	expiry := 7200 // default 2 hours
+	if exp := os.Getenv("STORAGE_LOCAL_PRESIGNED_EXPIRY"); exp != "" {
+		if parsed, err := strconv.Atoi(exp); err == nil {
+			expiry = parsed
+		}
+	}
+
+	signingKey := os.Getenv("STORAGE_LOCAL_SIGNING_KEY")
+	if signingKey == "" {
+		signingKey = "local-storage:" + config.JWTSecret()
+	}
+
+	return &LocalConfig{
+		RootDir:         getEnvOrDefault("STORAGE_LOCAL_ROOT", "./data/storage"),
+		PublicURL:       strings.TrimRight(os.Getenv("STORAGE_LOCAL_PUBLIC_URL"), "/"),
+		SigningKey:      signingKey,
+		BucketContent:   getEnvOrDefault("STORAGE_LOCAL_BUCKET", BucketCourseContent),
+		PresignedExpiry: expiry,
+	}
+}
+
+// NewLocalStorage creates a filesystem storage rooted at cfg.RootDir
+func NewLocalStorage(cfg *LocalConfig) (*LocalStorage, error) {
+	if cfg.RootDir == "" {
+		return nil, fmt.Errorf("STORAGE_LOCAL_ROOT is required")
+	}
+
+	root, err := filepath.Abs(cfg.RootDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage root: %w", err)
+	}
+
+	for _, dir := range []string{cfg.BucketContent, localMetaDir, localUploadsDir} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0750); err != nil {
+			return nil, fmt.Errorf("failed to create storage directory: %w", err)
+		}
+	}
+
+	return &LocalStorage{
+		rootDir:       root,
+		defaultBucket: cfg.BucketContent,
+		presignExpiry: time.Duration(cfg.PresignedExpiry) * time.Second,
+		publicURL:     cfg.PublicURL,
+		signingKey:    []byte(cfg.SigningKey),
+	}, nil
+}
+
+// resolve returns the bucket and on-disk path of an object, refusing names that
+// would escape the bucket
+func (l *LocalStorage) resolve(bucket, objectName string) (string, string, error) {
+	if bucket == "" {
+		bucket = l.defaultBucket
+	}
+	if bucket == "" || strings.HasPrefix(bucket, ".") || strings.ContainsAny(bucket, `/\`) {
+		return "", "", fmt.Errorf("invalid bucket name %q", bucket)
+	}
+
+	cleaned := strings.TrimPrefix(path.Clean("/"+objectName), "/")
+	if cleaned == "" || cleaned != strings.TrimPrefix(objectName, "/") {
+		return "", "", fmt.Errorf("invalid object name %q", objectName)
+	}
+
+	return bucket, filepath.Join(l.rootDir, bucket, filepath.FromSlash(cleaned)), nil
+}
+
+// metaPath returns where an object's metadata is kept
+func (l *LocalStorage) metaPath(bucket, objectName string) string {
+	return filepath.Join(l.rootDir, localMetaDir, bucket, filepath.FromSlash(strings.TrimPrefix(objectName, "/"))+".json")
+}
+
+// writeFileAtomic writes reader to a temp file beside target and renames it into place
+func writeFileAtomic(target string, reader io.Reader) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, reader)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return written, err
+	}
+
+	return written, os.Rename(tmp.Name(), target)
+}
+
+// Upload writes an object to disk
+func (l *LocalStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string) error {
+	bucket, target, err := l.resolve(bucket, objectName)
+	if err != nil {
+		return err
+	}
+
+	written, err := writeFileAtomic(target, reader)
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	if size >= 0 && written != size {
+		os.Remove(target)
+		return fmt.Errorf("failed to upload file: expected %d bytes, got %d", size, written)
+	}
+
+	if err := l.writeMeta(bucket, objectName, contentType); err != nil {
+		return fmt.Errorf("failed to save file metadata: %w", err)
+	}
+
+	log.Printf("Uploaded file to local storage: %s/%s", bucket, objectName)
+	return nil
+}
+
+// writeMeta records an object's content type
+func (l *LocalStorage) writeMeta(bucket, objectName, contentType string) error {
+	data, err := json.Marshal(localObjectMeta{ContentType: contentType})
+	if err != nil {
+		return err
+	}
+	_, err = writeFileAtomic(l.metaPath(bucket, objectName), strings.NewReader(string(data)))
+	return err
+}
+
+// GetPresignedURL generates an HMAC-signed URL served by ServeSignedObject
+func (l *LocalStorage) GetPresignedURL(ctx context.Context, bucket, objectName string, expiry time.Duration) (string, error) {
+	bucket, _, err := l.resolve(bucket, objectName)
+	if err != nil {
+		return "", err
+	}
+	if expiry == 0 {
+		expiry = l.presignExpiry
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", l.sign(bucket, objectName, expires))
+
+	escaped := make([]string, 0)
+	for _, segment := range strings.Split(strings.TrimPrefix(objectName, "/"), "/") {
+		escaped = append(escaped, url.PathEscape(segment))
+	}
+
+	return l.publicURL + LocalSignedURLPrefix + url.PathEscape(bucket) + "/" + strings.Join(escaped, "/") + "?" + query.Encode(), nil
+}
+
+// GetPresignedURLWithDefaultExpiry generates a signed URL with the default expiry
+func (l *LocalStorage) GetPresignedURLWithDefaultExpiry(ctx context.Context, bucket, objectName string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(l.presignExpiry)
+
+	signedURL, err := l.GetPresignedURL(ctx, bucket, objectName, l.presignExpiry)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return signedURL, expiresAt, nil
+}
+
+// sign computes the signature of a bucket/object/expiry triple
+func (l *LocalStorage) sign(bucket, objectName, expires string) string {
+	mac := hmac.New(sha256.New, l.signingKey)
+	mac.Write([]byte(bucket + "/" + strings.TrimPrefix(objectName, "/") + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignedURL checks the expires/signature query parameters of a signed URL
+func (l *LocalStorage) VerifySignedURL(bucket, objectName, expires, signature string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(l.sign(bucket, objectName, expires)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > expiresAt {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+// Open opens an object for serving with range support
+func (l *LocalStorage) Open(bucket, objectName string) (*os.File, ObjectInfo, error) {
+	info, err := l.GetObjectInfo(context.Background(), bucket, objectName)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	_, target, _ := l.resolve(bucket, objectName)
+	f, err := os.Open(target)
+	if err != nil {
+		return nil, ObjectInfo{}, fmt.Errorf("failed to get object: %w", err)
+	}
+	return f, info, nil
+}
+
+// Delete removes an object from disk
+func (l *LocalStorage) Delete(ctx context.Context, bucket, objectName string) error {
+	bucket, target, err := l.resolve(bucket, objectName)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	os.Remove(l.metaPath(bucket, objectName))
+
+	log.Printf("Deleted file from local storage: %s/%s", bucket, objectName)
+	return nil
+}
+
+// DeleteFolder removes a folder of objects from disk
+func (l *LocalStorage) DeleteFolder(ctx context.Context, bucket, folder string) error {
+	folder = strings.TrimSuffix(folder, "/")
+	bucket, target, err := l.resolve(bucket, folder)
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(target); err != nil {
+		return fmt.Errorf("failed to delete folder: %w", err)
+	}
+	os.RemoveAll(strings.TrimSuffix(l.metaPath(bucket, folder), ".json"))
+
+	log.Printf("Deleted folder from local storage: %s/%s", bucket, folder)
+	return nil
+}
+
+// Exists checks if an object exists on disk
+func (l *LocalStorage) Exists(ctx context.Context, bucket, objectName string) (bool, error) {
+	_, target, err := l.resolve(bucket, objectName)
+	if err != nil {
+		return false, err
+	}
+
+	info, err := os.Stat(target)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !info.IsDir(), nil
+}
+
+// GetObject opens an object for reading
+func (l *LocalStorage) GetObject(ctx context.Context, bucket, objectName string) (io.ReadCloser, error) {
+	_, target, err := l.resolve(bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	return f, nil
+}
+
+// GetObjectInfo returns an object's size and content type
+func (l *LocalStorage) GetObjectInfo(ctx context.Context, bucket, objectName string) (ObjectInfo, error) {
+	bucket, target, err := l.resolve(bucket, objectName)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	stat, err := os.Stat(target)
+	if err != nil || stat.IsDir() {
+		return ObjectInfo{}, fmt.Errorf("failed to get object info: %w", os.ErrNotExist)
+	}
+
+	contentType := ""
+	if data, err := os.ReadFile(l.metaPath(bucket, objectName)); err == nil {
+		var meta localObjectMeta
+		if json.Unmarshal(data, &meta) == nil {
+			contentType = meta.ContentType
+		}
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(objectName))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return ObjectInfo{
+		Key:          objectName,
+		Size:         stat.Size(),
+		ContentType:  contentType,
+		LastModified: stat.ModTime(),
+	}, nil
+}
+
+// GetDefaultBucket returns the default bucket name
+func (l *LocalStorage) GetDefaultBucket() string {
+	return l.defaultBucket
+}
+
+// GetDefaultExpiry returns the default signed URL expiry duration
+func (l *LocalStorage) GetDefaultExpiry() time.Duration {
+	return l.presignExpiry
+}
+
+// ===== Multipart uploads =====
+
+// uploadDir returns the directory holding an upload's parts, validating the ID
+func (l *LocalStorage) uploadDir(uploadID string) (string, error) {
+	if _, err := hex.DecodeString(uploadID); err != nil || len(uploadID) != 32 {
+		return "", fmt.Errorf("invalid upload ID")
+	}
+	return filepath.Join(l.rootDir, localUploadsDir, uploadID), nil
+}
+
+// partPath returns where a part is stored
+func partPath(dir string, partNumber int) string {
+	return filepath.Join(dir, fmt.Sprintf("part-%05d", partNumber))
+}
+
+// NewMultipartUpload starts a multipart upload and returns its upload ID
+func (l *LocalStorage) NewMultipartUpload(ctx context.Context, bucket, objectName, contentType string) (string, error) {
+	bucket, _, err := l.resolve(bucket, objectName)
+	if err != nil {
+		return "", err
+	}
+
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+	uploadID := hex.EncodeToString(raw)
+
+	dir, _ := l.uploadDir(uploadID)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+
+	data, err := json.Marshal(localUploadMeta{
+		Bucket:      bucket,
+		ObjectName:  objectName,
+		ContentType: contentType,
+		Initiated:   time.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "upload.json"), data, 0640); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+
+	return uploadID, nil
+}
+
+// readUploadMeta loads an upload's metadata
+func readUploadMeta(dir string) (*localUploadMeta, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "upload.json"))
+	if err != nil {
+		return nil, fmt.Errorf("upload not found")
+	}
+	var meta localUploadMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// PutPart stores one part. The ETag is the hex MD5 of the part, as with S3.
+func (l *LocalStorage) PutPart(ctx context.Context, bucket, objectName, uploadID string, partNumber int, reader io.Reader, size int64, md5Base64 string) (*UploadedPart, error) {
+	dir, err := l.uploadDir(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := readUploadMeta(dir); err != nil {
+		return nil, fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	}
+
+	hasher := md5.New()
+	written, err := writeFileAtomic(partPath(dir, partNumber), io.TeeReader(reader, hasher))
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	}
+
+	sum := hasher.Sum(nil)
+	if written != size || (md5Base64 != "" && base64.StdEncoding.EncodeToString(sum) != md5Base64) {
+		os.Remove(partPath(dir, partNumber))
+		return nil, fmt.Errorf("failed to upload part %d: content does not match the declared size or Content-MD5", partNumber)
+	}
+
+	return &UploadedPart{PartNumber: partNumber, ETag: hex.EncodeToString(sum), Size: written}, nil
+}
+
+// CompleteMultipartUpload concatenates the parts into the final object
+func (l *LocalStorage) CompleteMultipartUpload(ctx context.Context, bucket, objectName, uploadID string, parts []UploadedPart) error {
+	dir, err := l.uploadDir(uploadID)
+	if err != nil {
+		return err
+	}
+	meta, err := readUploadMeta(dir)
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	readers := make([]io.Reader, 0, len(parts))
+	for _, part := range parts {
+		f, err := os.Open(partPath(dir, part.PartNumber))
+		if err != nil {
+			return fmt.Errorf("failed to complete multipart upload: part %d missing", part.PartNumber)
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+
+	if err := l.Upload(ctx, meta.Bucket, meta.ObjectName, io.MultiReader(readers...), -1, meta.ContentType); err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	os.RemoveAll(dir)
+	return nil
+}
+
+// AbortMultipartUpload discards an upload and its parts
+func (l *LocalStorage) AbortMultipartUpload(ctx context.Context, bucket, objectName, uploadID string) error {
+	dir, err := l.uploadDir(uploadID)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return nil
+}
+
+// ListStaleMultipartUploads returns incomplete uploads started before the cutoff
+func (l *LocalStorage) ListStaleMultipartUploads(ctx context.Context, bucket string, before time.Time) ([]StaleMultipartUpload, error) {
+	if bucket == "" {
+		bucket = l.defaultBucket
+	}
+
+	entries, err := os.ReadDir(filepath.Join(l.rootDir, localUploadsDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list incomplete uploads: %w", err)
+	}
+
+	var stale []StaleMultipartUpload
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		meta, err := readUploadMeta(filepath.Join(l.rootDir, localUploadsDir, entry.Name()))
+		if err != nil || meta.Bucket != bucket || !meta.Initiated.Before(before) {
+			continue
+		}
+		stale = append(stale, StaleMultipartUpload{
+			ObjectName: meta.ObjectName,
+			UploadID:   entry.Name(),
+			Initiated:  meta.Initiated,
+		})
+	}
+	return stale, nil
+}
@@ -33,7 +33,9 @@ type MinioConfig struct {
 	SecretKey       string
 	UseSSL          bool
 	BucketContent   string
-	PresignedExpiry int // in seconds
+	PresignedExpiry int    // in seconds
+	Region          string // Required by some S3 providers; empty for MinIO
+	PathStyle       bool   // Address buckets as endpoint/bucket instead of bucket.endpoint
 }
 
 // LoadMinioConfigFromEnv loads MinIO configuration from environment variables
@@ -61,6 +63,30 @@ func LoadMinioConfigFromEnv() *MinioConfig {
 	}
 }
 
+// LoadS3ConfigFromEnv loads configuration for a generic S3-compatible provider
+// (AWS S3, Wasabi, Ceph RGW, ...) from environment variables
+func LoadS3ConfigFromEnv() *MinioConfig {
+	expiry := 7200 // default 2 hours
+	if exp := os.Getenv("S3_PRESIGNED_EXPIRY"); exp != "" {
+		if parsed, err := strconv.Atoi(exp); err == nil {
+			expiry = parsed
+		}
+	}
+
+	endpoint := getEnvOrDefault("S3_ENDPOINT", "s3.amazonaws.com")
+	return &MinioConfig{
+		Endpoint:        endpoint,
+		PublicEndpoint:  getEnvOrDefault("S3_PUBLIC_ENDPOINT", endpoint),
+		AccessKey:       os.Getenv("S3_ACCESS_KEY"),
+		SecretKey:       os.Getenv("S3_SECRET_KEY"),
+		UseSSL:          os.Getenv("S3_USE_SSL") != "false",
+		BucketContent:   getEnvOrDefault("S3_BUCKET", BucketCourseContent),
+		PresignedExpiry: expiry,
+		Region:          os.Getenv("S3_REGION"),
+		PathStyle:       os.Getenv("S3_FORCE_PATH_STYLE") == "true",
+	}
+}
+
 func getEnvOrDefault(key, defaultVal string) string {
 	if val := os.Getenv(key); val != "" {
 		return val
@@ -74,10 +100,17 @@ func NewMinioStorage(cfg *MinioConfig) (*MinioStorage, error) {
 		return nil, fmt.Errorf("MINIO_ENDPOINT is required")
 	}
 
+	bucketLookup := minio.BucketLookupAuto
+	if cfg.PathStyle {
+		bucketLookup = minio.BucketLookupPath
+	}
+
 	// Main client for internal operations (connects via Docker network)
 	client, err := minio.New(cfg.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
-		Secure: cfg.UseSSL,
+		Creds:        credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:       cfg.UseSSL,
+		Region:       cfg.Region,
+		BucketLookup: bucketLookup,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create MinIO client: %w", err)
@@ -91,8 +124,10 @@ func NewMinioStorage(cfg *MinioConfig) (*MinioStorage, error) {
 	}
 	
 	publicClient, err = minio.New(publicEndpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
-		Secure: cfg.UseSSL,
+		Creds:        credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:       cfg.UseSSL,
+		Region:       cfg.Region,
+		BucketLookup: bucketLookup,
 	})
 	if err != nil {
 		log.Printf("Warning: Failed to create public MinIO client, using internal: %v", err)
@@ -213,8 +248,26 @@ func (m *MinioStorage) Delete(ctx context.Context, bucket, objectName string) er
 	return nil
 }
 
+// DeleteFolder removes every object under a folder from MinIO
+func (m *MinioStorage) DeleteFolder(ctx context.Context, bucket, folder string) error {
+	if bucket == "" {
+		bucket = m.defaultBucket
+	}
+
+	objects := m.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{
+		Prefix:    strings.TrimSuffix(folder, "/") + "/",
+		Recursive: true,
+	})
+	for removeErr := range m.client.RemoveObjects(ctx, bucket, objects, minio.RemoveObjectsOptions{}) {
+		return fmt.Errorf("failed to delete %s: %w", removeErr.ObjectName, removeErr.Err)
+	}
+
+	log.Printf("Deleted folder from MinIO: %s/%s", bucket, folder)
+	return nil
+}
+
 // GetObject retrieves an object from MinIO and returns it as an io.ReadCloser
-func (m *MinioStorage) GetObject(ctx context.Context, bucket, objectName string) (*minio.Object, error) {
+func (m *MinioStorage) GetObject(ctx context.Context, bucket, objectName string) (io.ReadCloser, error) {
 	if bucket == "" {
 		bucket = m.defaultBucket
 	}
@@ -228,17 +281,22 @@ func (m *MinioStorage) GetObject(ctx context.Context, bucket, objectName string)
 }
 
 // GetObjectInfo retrieves object info including content type
-func (m *MinioStorage) GetObjectInfo(ctx context.Context, bucket, objectName string) (minio.ObjectInfo, error) {
+func (m *MinioStorage) GetObjectInfo(ctx context.Context, bucket, objectName string) (ObjectInfo, error) {
 	if bucket == "" {
 		bucket = m.defaultBucket
 	}
 
 	info, err := m.client.StatObject(ctx, bucket, objectName, minio.StatObjectOptions{})
 	if err != nil {
-		return minio.ObjectInfo{}, fmt.Errorf("failed to get object info: %w", err)
+		return ObjectInfo{}, fmt.Errorf("failed to get object info: %w", err)
 	}
 
-	return info, nil
+	return ObjectInfo{
+		Key:          info.Key,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		LastModified: info.LastModified,
+	}, nil
 }
 
 // Exists checks if an object exists in MinIO
@@ -268,31 +326,64 @@ func (m *MinioStorage) GetDefaultExpiry() time.Duration {
 	return m.presignExpiry
 }
 
+// Storage drivers selectable with STORAGE_DRIVER
+const (
+	DriverMinio = "minio"
+	DriverS3    = "s3"
+	DriverLocal = "local"
+)
+
 // Singleton instance for global access
-var defaultStorage *MinioStorage
+var defaultStorage Storage
 
-// InitStorage initializes the global storage instance
+// InitStorage initializes the global storage instance from STORAGE_DRIVER. Without a
+// driver, MinIO is used when MINIO_ENDPOINT is set.
 func InitStorage() error {
-	cfg := LoadMinioConfigFromEnv()
-	
-	// If MinIO is not configured, skip initialization
-	if cfg.Endpoint == "" {
-		log.Println("MinIO not configured, skipping storage initialization")
-		return nil
+	driver := strings.ToLower(os.Getenv("STORAGE_DRIVER"))
+	if driver == "" && os.Getenv("MINIO_ENDPOINT") != "" {
+		driver = DriverMinio
 	}
 
-	storage, err := NewMinioStorage(cfg)
-	if err != nil {
-		return err
+	switch driver {
+	case DriverMinio:
+		cfg := LoadMinioConfigFromEnv()
+		storage, err := NewMinioStorage(cfg)
+		if err != nil {
+			return err
+		}
+		defaultStorage = storage
+		log.Printf("MinIO storage initialized: endpoint=%s, bucket=%s", cfg.Endpoint, cfg.BucketContent)
+
+	case DriverS3:
+		cfg := LoadS3ConfigFromEnv()
+		storage, err := NewMinioStorage(cfg)
+		if err != nil {
+			return err
+		}
+		defaultStorage = storage
+		log.Printf("S3 storage initialized: endpoint=%s, region=%s, bucket=%s", cfg.Endpoint, cfg.Region, cfg.BucketContent)
+
+	case DriverLocal:
+		cfg := LoadLocalConfigFromEnv()
+		storage, err := NewLocalStorage(cfg)
+		if err != nil {
+			return err
+		}
+		defaultStorage = storage
+		log.Printf("Local storage initialized: root=%s, bucket=%s", cfg.RootDir, cfg.BucketContent)
+
+	case "":
+		log.Println("Storage not configured, skipping storage initialization")
+
+	default:
+		return fmt.Errorf("unknown STORAGE_DRIVER %q (expected minio, s3 or local)", driver)
 	}
 
-	defaultStorage = storage
-	log.Printf("MinIO storage initialized: endpoint=%s, bucket=%s", cfg.Endpoint, cfg.BucketContent)
 	return nil
 }
 
-// GetStorage returns the global storage instance
-func GetStorage() *MinioStorage {
+// GetStorage returns the global storage instance, or nil if storage is not configured
+func GetStorage() Storage {
 	return defaultStorage
 }
 
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// UploadedPart is a part stored by a multipart upload
+type UploadedPart struct {
+	PartNumber int
+	ETag       string
+	Size       int64
+}
+
+// StaleMultipartUpload is an incomplete multipart upload found in the bucket
+type StaleMultipartUpload struct {
+	ObjectName string
+	UploadID   string
+	Initiated  time.Time
+}
+
+// core exposes the low-level S3 multipart API
+func (m *MinioStorage) core() minio.Core {
+	return minio.Core{Client: m.client}
+}
+
+// NewMultipartUpload starts a multipart upload and returns its storage upload ID
+func (m *MinioStorage) NewMultipartUpload(ctx context.Context, bucket, objectName, contentType string) (string, error) {
+	if bucket == "" {
+		bucket = m.defaultBucket
+	}
+
+	uploadID, err := m.core().NewMultipartUpload(ctx, bucket, objectName, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+	return uploadID, nil
+}
+
+// PutPart streams one part of a multipart upload. When md5Base64 is set, storage
+// rejects the part if the received bytes don't match it.
+func (m *MinioStorage) PutPart(ctx context.Context, bucket, objectName, uploadID string, partNumber int, reader io.Reader, size int64, md5Base64 string) (*UploadedPart, error) {
+	if bucket == "" {
+		bucket = m.defaultBucket
+	}
+
+	part, err := m.core().PutObjectPart(ctx, bucket, objectName, uploadID, partNumber, reader, size, minio.PutObjectPartOptions{
+		Md5Base64: md5Base64,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	}
+
+	return &UploadedPart{PartNumber: part.PartNumber, ETag: part.ETag, Size: part.Size}, nil
+}
+
+// CompleteMultipartUpload assembles the given parts into the final object
+func (m *MinioStorage) CompleteMultipartUpload(ctx context.Context, bucket, objectName, uploadID string, parts []UploadedPart) error {
+	if bucket == "" {
+		bucket = m.defaultBucket
+	}
+
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	for _, p := range parts {
+		completeParts = append(completeParts, minio.CompletePart{PartNumber: p.PartNumber, ETag: p.ETag})
+	}
+
+	if _, err := m.core().CompleteMultipartUpload(ctx, bucket, objectName, uploadID, completeParts, minio.PutObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return nil
+}
+
+// AbortMultipartUpload discards a multipart upload and its stored parts
+func (m *MinioStorage) AbortMultipartUpload(ctx context.Context, bucket, objectName, uploadID string) error {
+	if bucket == "" {
+		bucket = m.defaultBucket
+	}
+
+	if err := m.core().AbortMultipartUpload(ctx, bucket, objectName, uploadID); err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return nil
+}
+
+// ListStaleMultipartUploads returns incomplete multipart uploads started before the cutoff
+func (m *MinioStorage) ListStaleMultipartUploads(ctx context.Context, bucket string, before time.Time) ([]StaleMultipartUpload, error) {
+	if bucket == "" {
+		bucket = m.defaultBucket
+	}
+
+	var stale []StaleMultipartUpload
+	for upload := range m.client.ListIncompleteUploads(ctx, bucket, "", true) {
+		if upload.Err != nil {
+			return stale, fmt.Errorf("failed to list incomplete uploads: %w", upload.Err)
+		}
+		if upload.Initiated.Before(before) {
+			stale = append(stale, StaleMultipartUpload{
+				ObjectName: upload.Key,
+				UploadID:   upload.UploadID,
+				Initiated:  upload.Initiated,
+			})
+		}
+	}
+	return stale, nil
+}
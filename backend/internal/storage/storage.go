@@ -17,8 +17,60 @@ type Storage interface {
 	// Delete removes an object from the storage
 	Delete(ctx context.Context, bucket, objectName string) error
 
+	// DeleteFolder removes every object whose name starts with folder + "/"
+	DeleteFolder(ctx context.Context, bucket, folder string) error
+
 	// Exists checks if an object exists in the storage
 	Exists(ctx context.Context, bucket, objectName string) (bool, error)
+
+	// GetPresignedURLWithDefaultExpiry generates a pre-signed URL using the configured expiry
+	GetPresignedURLWithDefaultExpiry(ctx context.Context, bucket, objectName string) (string, time.Time, error)
+
+	// GetObject opens an object for reading
+	GetObject(ctx context.Context, bucket, objectName string) (io.ReadCloser, error)
+
+	// GetObjectInfo returns an object's size and content type
+	GetObjectInfo(ctx context.Context, bucket, objectName string) (ObjectInfo, error)
+
+	// GetDefaultBucket returns the bucket used when an empty bucket name is passed
+	GetDefaultBucket() string
+
+	// GetDefaultExpiry returns the default pre-signed URL expiry
+	GetDefaultExpiry() time.Duration
+
+	MultipartStorage
+}
+
+// Both drivers must satisfy the full interface
+var (
+	_ Storage = (*MinioStorage)(nil)
+	_ Storage = (*LocalStorage)(nil)
+)
+
+// MultipartStorage uploads large objects in independently retried parts
+type MultipartStorage interface {
+	// NewMultipartUpload starts a multipart upload and returns its storage upload ID
+	NewMultipartUpload(ctx context.Context, bucket, objectName, contentType string) (string, error)
+
+	// PutPart stores one part; a non-empty md5Base64 is verified against the received bytes
+	PutPart(ctx context.Context, bucket, objectName, uploadID string, partNumber int, reader io.Reader, size int64, md5Base64 string) (*UploadedPart, error)
+
+	// CompleteMultipartUpload assembles the parts into the final object
+	CompleteMultipartUpload(ctx context.Context, bucket, objectName, uploadID string, parts []UploadedPart) error
+
+	// AbortMultipartUpload discards an upload and its parts
+	AbortMultipartUpload(ctx context.Context, bucket, objectName, uploadID string) error
+
+	// ListStaleMultipartUploads returns incomplete uploads started before the cutoff
+	ListStaleMultipartUploads(ctx context.Context, bucket string, before time.Time) ([]StaleMultipartUpload, error)
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
 }
 
 // ContentType constants for common file types
@@ -0,0 +1,84 @@
+package video
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ContentTokenAudience distinguishes content tokens from application JWTs
+const ContentTokenAudience = "hls-content"
+
+// Content token validation errors
+var (
+	ErrContentTokenMissing  = errors.New("content token missing")
+	ErrContentTokenInvalid  = errors.New("content token invalid")
+	ErrContentTokenExpired  = errors.New("content token expired")
+	ErrContentTokenMismatch = errors.New("content token not valid for this lesson or client")
+)
+
+// ContentTokenClaims scope a short-lived token to one lesson's HLS files and,
+// optionally, to the client that requested it. Everything needed to serve a
+// segment is in the claims, so validation never touches the database.
+type ContentTokenClaims struct {
+	LessonID string `json:"lid"`
+	Role     string `json:"role,omitempty"`
+	HLSPath  string `json:"path"`
+	IPHash   string `json:"iph,omitempty"`
+	UAHash   string `json:"uah,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// ClientFingerprint hashes an IP or user agent so it isn't exposed in URLs
+func ClientFingerprint(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// IssueContentToken signs a content token for a user, valid for ttl
+func IssueContentToken(secret []byte, userID string, claims ContentTokenClaims, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Subject:   userID,
+		Audience:  jwt.ClaimStrings{ContentTokenAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims).SignedString(secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// ParseContentToken verifies a content token for a lesson. Client binding is only
+// checked for the parts (IP, user agent) the token was bound to.
+func ParseContentToken(secret []byte, tokenString, lessonID, clientIP, userAgent string) (*ContentTokenClaims, error) {
+	if tokenString == "" {
+		return nil, ErrContentTokenMissing
+	}
+
+	claims := &ContentTokenClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(ContentTokenAudience), jwt.WithExpirationRequired())
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, ErrContentTokenExpired
+	}
+	if err != nil {
+		return nil, ErrContentTokenInvalid
+	}
+
+	if claims.LessonID != lessonID ||
+		(claims.IPHash != "" && claims.IPHash != ClientFingerprint(clientIP)) ||
+		(claims.UAHash != "" && claims.UAHash != ClientFingerprint(userAgent)) {
+		return nil, ErrContentTokenMismatch
+	}
+	return claims, nil
+}
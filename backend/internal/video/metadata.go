@@ -0,0 +1,110 @@
+package video
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Metadata is the technical information ffprobe reports for a video
+type Metadata struct {
+	Duration   float64 `json:"duration"` // In seconds
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	VideoCodec string  `json:"video_codec"`
+	AudioCodec string  `json:"audio_codec,omitempty"`
+	FrameRate  float64 `json:"frame_rate"`
+	Bitrate    int64   `json:"bitrate"` // In bits per second
+	SizeBytes  int64   `json:"size_bytes"`
+}
+
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType    string `json:"codec_type"`
+		CodecName    string `json:"codec_name"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+		AvgFrameRate string `json:"avg_frame_rate"`
+		Duration     string `json:"duration"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+		BitRate  string `json:"bit_rate"`
+		Size     string `json:"size"`
+	} `json:"format"`
+}
+
+// ProbeVideo extracts metadata from a local file or URL using ffprobe
+func ProbeVideo(ctx context.Context, input string) (*Metadata, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		input,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var probe ffprobeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	meta := &Metadata{}
+	meta.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	meta.Bitrate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+	meta.SizeBytes, _ = strconv.ParseInt(probe.Format.Size, 10, 64)
+
+	hasVideo := false
+	for _, s := range probe.Streams {
+		switch s.CodecType {
+		case "video":
+			if hasVideo {
+				continue
+			}
+			hasVideo = true
+			meta.VideoCodec = s.CodecName
+			meta.Width = s.Width
+			meta.Height = s.Height
+			meta.FrameRate = parseFrameRate(s.AvgFrameRate)
+			if meta.Duration == 0 {
+				meta.Duration, _ = strconv.ParseFloat(s.Duration, 64)
+			}
+		case "audio":
+			if meta.AudioCodec == "" {
+				meta.AudioCodec = s.CodecName
+			}
+		}
+	}
+
+	if !hasVideo {
+		return nil, fmt.Errorf("no video stream found")
+	}
+	if meta.Duration <= 0 {
+		return nil, fmt.Errorf("could not determine video duration")
+	}
+
+	return meta, nil
+}
+
+// parseFrameRate parses ffprobe's rational frame rate, e.g. "30000/1001"
+func parseFrameRate(rate string) float64 {
+	num, den, found := strings.Cut(rate, "/")
+	if !found {
+		f, _ := strconv.ParseFloat(rate, 64)
+		return f
+	}
+	n, err1 := strconv.ParseFloat(num, 64)
+	d, err2 := strconv.ParseFloat(den, 64)
+	if err1 != nil || err2 != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
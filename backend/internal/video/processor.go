@@ -8,7 +8,6 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -18,18 +17,66 @@ import (
 
 // HLSConfig contains configuration for HLS encoding
 type HLSConfig struct {
-	SegmentDuration int    // Segment duration in seconds
-	KeyInfoPath     string // Path to key info file
-	OutputDir       string // Output directory for HLS files
+	SegmentDuration int         // Segment duration in seconds
+	KeyInfoPath     string      // Path to key info file
+	OutputDir       string      // Output directory for HLS files
+	Renditions      []Rendition // Bitrate ladder, lowest first; empty = single rendition at source quality
+}
+
+// Rendition describes one variant stream of an adaptive bitrate ladder
+type Rendition struct {
+	Name         string // Variant name used for filenames, e.g. "720p"
+	Height       int    // Output height in pixels (width keeps aspect ratio)
+	VideoBitrate int    // Target video bitrate in kbps
+	AudioBitrate int    // Audio bitrate in kbps
+}
+
+// Bandwidth returns the peak bandwidth in bits per second for EXT-X-STREAM-INF
+func (r Rendition) Bandwidth() int {
+	return (r.maxRate() + r.AudioBitrate) * 1000
+}
+
+// maxRate allows a small burst above the target bitrate
+func (r Rendition) maxRate() int {
+	return r.VideoBitrate * 107 / 100
+}
+
+// StandardRenditions is the default ladder tuned for mobile networks
+var StandardRenditions = []Rendition{
+	{Name: "360p", Height: 360, VideoBitrate: 800, AudioBitrate: 96},
+	{Name: "480p", Height: 480, VideoBitrate: 1400, AudioBitrate: 128},
+	{Name: "720p", Height: 720, VideoBitrate: 2800, AudioBitrate: 128},
+	{Name: "1080p", Height: 1080, VideoBitrate: 5000, AudioBitrate: 192},
+}
+
+// RenditionsByName returns the standard renditions matching the given names, in ladder order.
+// Unknown names are ignored.
+func RenditionsByName(names []string) []Rendition {
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		wanted[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	var renditions []Rendition
+	for _, r := range StandardRenditions {
+		if wanted[r.Name] {
+			renditions = append(renditions, r)
+		}
+	}
+	return renditions
 }
 
 // DefaultHLSConfig returns default HLS configuration
 func DefaultHLSConfig() HLSConfig {
 	return HLSConfig{
 		SegmentDuration: 10, // 10 second segments
+		Renditions:      StandardRenditions,
 	}
 }
 
+// MasterPlaylistName is the filename of the multi-variant playlist
+const MasterPlaylistName = "master.m3u8"
+
 // EncryptionKey holds the encryption key and IV for a video
 type EncryptionKey struct {
 	Key []byte // 16-byte AES-128 key
@@ -53,24 +100,43 @@ func GenerateEncryptionKey() (*EncryptionKey, error) {
 
 // HLSResult contains the result of HLS processing
 type HLSResult struct {
-	ManifestPath string   // Path to master .m3u8 file
-	SegmentPaths []string // Paths to .ts segment files
-	KeyPath      string   // Path to key file
-	Duration     float64  // Total duration in seconds
+	ManifestPath string          // Path to master .m3u8 file
+	Variants     []VariantResult // Per-rendition playlists referenced by the master
+	SegmentPaths []string        // Paths to .ts segment files
+	KeyPath      string          // Path to key file
+	Duration     float64         // Total duration in seconds
+}
+
+// VariantResult describes a single rendition produced by ConvertToHLS
+type VariantResult struct {
+	Rendition    Rendition
+	PlaylistPath string // Path to the variant .m3u8 file
+	Width        int    // Output width, 0 if unknown
+	Height       int    // Output height, 0 if unknown
 }
 
 // Processor handles video processing operations
 type Processor struct {
 	tempDir string
+	config  HLSConfig
 }
 
-// NewProcessor creates a new video processor
+// NewProcessor creates a new video processor with the default HLS configuration
 func NewProcessor() (*Processor, error) {
+	return NewProcessorWithConfig(DefaultHLSConfig())
+}
+
+// NewProcessorWithConfig creates a new video processor with a custom HLS configuration
+func NewProcessorWithConfig(cfg HLSConfig) (*Processor, error) {
+	if cfg.SegmentDuration <= 0 {
+		cfg.SegmentDuration = DefaultHLSConfig().SegmentDuration
+	}
+
 	tempDir, err := os.MkdirTemp("", "hls-processing-*")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temp dir: %w", err)
 	}
-	return &Processor{tempDir: tempDir}, nil
+	return &Processor{tempDir: tempDir, config: cfg}, nil
 }
 
 // Cleanup removes temporary files
@@ -106,28 +172,118 @@ func (p *Processor) ConvertToHLS(ctx context.Context, inputPath string, encKey *
 		return nil, fmt.Errorf("failed to write keyinfo file: %w", err)
 	}
 
-	// Manifest and segment paths
-	manifestPath := filepath.Join(outputDir, "playlist.m3u8")
-	segmentPattern := filepath.Join(outputDir, "segment_%03d.ts")
+	srcWidth, srcHeight, err := GetVideoDimensions(ctx, inputPath)
+	if err != nil {
+		log.Printf("Warning: could not determine video dimensions: %v", err)
+	}
+
+	var variants []VariantResult
+	for _, rendition := range selectRenditions(p.config.Renditions, srcHeight) {
+		variant, err := p.encodeVariant(ctx, inputPath, keyInfoPath, outputDir, rendition, srcWidth, srcHeight)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, *variant)
+	}
+
+	// Write master playlist referencing every variant
+	manifestPath := filepath.Join(outputDir, MasterPlaylistName)
+	if err := os.WriteFile(manifestPath, []byte(buildMasterPlaylist(variants)), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write master playlist: %w", err)
+	}
+
+	// Collect segment files
+	segments, err := filepath.Glob(filepath.Join(outputDir, "*.ts"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list segments: %w", err)
+	}
+
+	log.Printf("HLS conversion complete: %d variants, %d segments created", len(variants), len(segments))
+
+	// Duration is informational only, don't fail the conversion if ffprobe is missing
+	duration, err := GetVideoDuration(ctx, inputPath)
+	if err != nil {
+		log.Printf("Warning: could not determine video duration: %v", err)
+	}
+
+	return &HLSResult{
+		ManifestPath: manifestPath,
+		Variants:     variants,
+		SegmentPaths: segments,
+		KeyPath:      keyPath,
+		Duration:     duration,
+	}, nil
+}
+
+// selectRenditions drops renditions that would upscale the source.
+// The lowest rendition is always kept so every video gets at least one variant.
+func selectRenditions(ladder []Rendition, sourceHeight int) []Rendition {
+	if len(ladder) == 0 {
+		// Single rendition at source quality
+		return []Rendition{{Name: "source", AudioBitrate: 128}}
+	}
+	if sourceHeight <= 0 {
+		return ladder
+	}
+
+	var selected []Rendition
+	for _, r := range ladder {
+		if r.Height <= sourceHeight {
+			selected = append(selected, r)
+		}
+	}
+	if len(selected) == 0 {
+		selected = ladder[:1]
+	}
+	return selected
+}
+
+// encodeVariant runs FFmpeg for a single rendition of the ladder
+func (p *Processor) encodeVariant(ctx context.Context, inputPath, keyInfoPath, outputDir string, r Rendition, srcWidth, srcHeight int) (*VariantResult, error) {
+	playlistPath := filepath.Join(outputDir, r.Name+".m3u8")
+	segmentPattern := filepath.Join(outputDir, r.Name+"_%03d.ts")
 
-	// Build FFmpeg command
 	args := []string{
 		"-i", inputPath,
 		"-c:v", "libx264",
 		"-c:a", "aac",
 		"-preset", "fast",
-		"-crf", "23",
+	}
+
+	width, height := srcWidth, srcHeight
+	if r.Height > 0 {
+		// Scale to target height, keep aspect ratio with an even width
+		args = append(args,
+			"-vf", fmt.Sprintf("scale=-2:%d", r.Height),
+			"-b:v", fmt.Sprintf("%dk", r.VideoBitrate),
+			"-maxrate", fmt.Sprintf("%dk", r.maxRate()),
+			"-bufsize", fmt.Sprintf("%dk", r.VideoBitrate*2),
+		)
+		height = r.Height
+		width = 0
+		if srcWidth > 0 && srcHeight > 0 {
+			width = (srcWidth * r.Height / srcHeight) &^ 1
+		}
+	} else {
+		args = append(args, "-crf", "23")
+	}
+
+	if r.AudioBitrate > 0 {
+		args = append(args, "-b:a", fmt.Sprintf("%dk", r.AudioBitrate))
+	}
+
+	args = append(args,
 		"-sc_threshold", "0",
 		"-g", "48",
 		"-keyint_min", "48",
-		"-hls_time", "10",
+		"-hls_time", fmt.Sprintf("%d", p.config.SegmentDuration),
 		"-hls_list_size", "0",
 		"-hls_segment_filename", segmentPattern,
 		"-hls_key_info_file", keyInfoPath,
 		"-hls_playlist_type", "vod",
 		"-f", "hls",
-		manifestPath,
-	}
+		playlistPath,
+	)
 
 	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
 
@@ -135,26 +291,42 @@ func (p *Processor) ConvertToHLS(ctx context.Context, inputPath string, encKey *
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	log.Printf("Running FFmpeg: ffmpeg %s", strings.Join(args, " "))
+	log.Printf("Running FFmpeg (%s): ffmpeg %s", r.Name, strings.Join(args, " "))
 
 	if err := cmd.Run(); err != nil {
 		log.Printf("FFmpeg error output: %s", stderr.String())
-		return nil, fmt.Errorf("FFmpeg failed: %w, stderr: %s", err, stderr.String())
+		return nil, fmt.Errorf("FFmpeg failed for %s: %w, stderr: %s", r.Name, err, stderr.String())
 	}
 
-	// Collect segment files
-	segments, err := filepath.Glob(filepath.Join(outputDir, "segment_*.ts"))
-	if err != nil {
-		return nil, fmt.Errorf("failed to list segments: %w", err)
-	}
+	return &VariantResult{
+		Rendition:    r,
+		PlaylistPath: playlistPath,
+		Width:        width,
+		Height:       height,
+	}, nil
+}
 
-	log.Printf("HLS conversion complete: %d segments created", len(segments))
+// buildMasterPlaylist renders a master playlist with one EXT-X-STREAM-INF per variant
+func buildMasterPlaylist(variants []VariantResult) string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+
+	for _, v := range variants {
+		bandwidth := v.Rendition.Bandwidth()
+		if bandwidth == 0 {
+			bandwidth = 3000000 // Unknown bitrate for source-quality renditions
+		}
+
+		b.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d", bandwidth))
+		if v.Width > 0 && v.Height > 0 {
+			b.WriteString(fmt.Sprintf(",RESOLUTION=%dx%d", v.Width, v.Height))
+		}
+		b.WriteString(fmt.Sprintf(",NAME=\"%s\"\n", v.Rendition.Name))
+		b.WriteString(filepath.Base(v.PlaylistPath) + "\n")
+	}
 
-	return &HLSResult{
-		ManifestPath: manifestPath,
-		SegmentPaths: segments,
-		KeyPath:      keyPath,
-	}, nil
+	return b.String()
 }
 
 // GetOutputDir returns the output directory path
@@ -211,8 +383,8 @@ func ValidateFFmpeg() error {
 }
 
 // GetVideoDuration returns the duration of a video file in seconds
-func GetVideoDuration(inputPath string) (float64, error) {
-	cmd := exec.Command("ffprobe",
+func GetVideoDuration(ctx context.Context, inputPath string) (float64, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
 		"-v", "error",
 		"-show_entries", "format=duration",
 		"-of", "default=noprint_wrappers=1:nokey=1",
@@ -232,3 +404,27 @@ func GetVideoDuration(inputPath string) (float64, error) {
 
 	return duration, nil
 }
+
+// GetVideoDimensions returns the width and height of the first video stream
+func GetVideoDimensions(ctx context.Context, inputPath string) (int, int, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height",
+		"-of", "csv=s=x:p=0",
+		inputPath,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get video dimensions: %w", err)
+	}
+
+	var width, height int
+	_, err = fmt.Sscanf(strings.TrimSpace(string(output)), "%dx%d", &width, &height)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse dimensions: %w", err)
+	}
+
+	return width, height, nil
+}
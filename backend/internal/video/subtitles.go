@@ -0,0 +1,138 @@
+package video
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SubtitleGroupID is the EXT-X-MEDIA group shared by all subtitle renditions
+const SubtitleGroupID = "subs"
+
+// srtTimestamp matches SRT timestamps, which use a comma before the milliseconds
+var srtTimestamp = regexp.MustCompile(`(\d{1,2}:\d{2}:\d{2}),(\d{3})`)
+
+// SubtitleTrack describes one subtitle rendition for the master playlist
+type SubtitleTrack struct {
+	Name      string // Label shown in the player
+	Language  string // BCP 47 language code
+	IsDefault bool
+	URI       string // Subtitle media playlist URI
+}
+
+// ConvertSubtitle converts an uploaded SRT or WebVTT file into normalized WebVTT
+func ConvertSubtitle(filename string, data []byte) (string, error) {
+	content := normalizeSubtitleText(string(data))
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".srt":
+		return SRTToVTT(content)
+	case ".vtt":
+		if !strings.HasPrefix(content, "WEBVTT") {
+			return "", fmt.Errorf("invalid WebVTT file: missing WEBVTT header")
+		}
+		if !strings.Contains(content, "-->") {
+			return "", fmt.Errorf("invalid WebVTT file: no cues found")
+		}
+		return content, nil
+	default:
+		return "", fmt.Errorf("unsupported subtitle format %q, use .srt or .vtt", filepath.Ext(filename))
+	}
+}
+
+// SRTToVTT converts SubRip subtitles to WebVTT. Cue numbers are kept as cue identifiers.
+func SRTToVTT(srt string) (string, error) {
+	srt = normalizeSubtitleText(srt)
+
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+
+	cues := 0
+	for _, line := range strings.Split(srt, "\n") {
+		if strings.Contains(line, "-->") {
+			line = srtTimestamp.ReplaceAllString(line, "$1.$2")
+			cues++
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	if cues == 0 {
+		return "", fmt.Errorf("invalid SRT file: no cues found")
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n", nil
+}
+
+// normalizeSubtitleText strips a UTF-8 BOM and converts line endings to \n
+func normalizeSubtitleText(s string) string {
+	s = strings.TrimPrefix(s, "\uFEFF")
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.TrimSpace(s) + "\n"
+}
+
+// BuildSubtitlePlaylist returns a media playlist that serves a whole WebVTT file as one segment
+func BuildSubtitlePlaylist(vttURI string, duration float64) string {
+	if duration <= 0 {
+		duration = 1
+	}
+	target := int(math.Ceil(duration))
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+	b.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", target))
+	b.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	b.WriteString(fmt.Sprintf("#EXTINF:%.3f,\n", duration))
+	b.WriteString(vttURI + "\n")
+	b.WriteString("#EXT-X-ENDLIST\n")
+	return b.String()
+}
+
+// AddSubtitleRenditions adds EXT-X-MEDIA subtitle renditions to a master playlist and
+// links every variant stream to the subtitle group
+func AddSubtitleRenditions(master string, tracks []SubtitleTrack) string {
+	if len(tracks) == 0 {
+		return master
+	}
+
+	var media strings.Builder
+	for _, t := range tracks {
+		isDefault := "NO"
+		if t.IsDefault {
+			isDefault = "YES"
+		}
+		media.WriteString(fmt.Sprintf(
+			"#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"%s\",NAME=\"%s\",LANGUAGE=\"%s\",DEFAULT=%s,AUTOSELECT=YES,URI=\"%s\"\n",
+			SubtitleGroupID, strings.ReplaceAll(t.Name, `"`, "'"), t.Language, isDefault, t.URI))
+	}
+
+	var b strings.Builder
+	inserted := false
+	for _, line := range strings.Split(strings.TrimRight(master, "\n"), "\n") {
+		if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			if !inserted {
+				b.WriteString(media.String())
+				inserted = true
+			}
+			line += fmt.Sprintf(",SUBTITLES=\"%s\"", SubtitleGroupID)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// WrapMediaPlaylist builds a single-variant master playlist around a media playlist URI,
+// so renditions such as subtitles can be attached to legacy single-playlist output
+func WrapMediaPlaylist(mediaURI string) string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+	b.WriteString("#EXT-X-STREAM-INF:BANDWIDTH=3000000\n")
+	b.WriteString(mediaURI + "\n")
+	return b.String()
+}
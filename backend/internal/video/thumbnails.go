@@ -0,0 +1,160 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PosterFilename is the name of the poster thumbnail stored with a lesson's video output
+const PosterFilename = "thumbnail.jpg"
+
+// SpriteVTTFilename is the name of the WebVTT file mapping seek times to sprite tiles
+const SpriteVTTFilename = "thumbnails.vtt"
+
+// SpriteConfig controls the seek-preview sprite sheets
+type SpriteConfig struct {
+	Interval   int // Seconds between preview frames
+	TileWidth  int // Width of each tile in pixels (height keeps aspect ratio)
+	Columns    int
+	Rows       int
+	MaxSprites int // Upper bound on frames, the interval grows for long videos
+}
+
+// DefaultSpriteConfig returns the default sprite configuration
+func DefaultSpriteConfig() SpriteConfig {
+	return SpriteConfig{
+		Interval:   10,
+		TileWidth:  160,
+		Columns:    10,
+		Rows:       10,
+		MaxSprites: 500,
+	}
+}
+
+// SpriteResult describes generated sprite sheets
+type SpriteResult struct {
+	VTTPath    string   // Local path to the WebVTT file
+	SheetPaths []string // Local paths to the sprite sheet images
+}
+
+// GeneratePoster extracts a single frame as a JPEG poster. The frame is taken at 10%
+// of the duration to skip black intro frames.
+func GeneratePoster(ctx context.Context, inputPath, outputPath string, duration float64, width int) error {
+	at := duration * 0.1
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-y",
+		"-ss", fmt.Sprintf("%.2f", at),
+		"-i", inputPath,
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:-2", width),
+		"-q:v", "3",
+		outputPath,
+	)
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg poster failed: %w, stderr: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// GenerateSprites renders seek-preview sprite sheets and the WebVTT file that indexes them.
+// Image references in the VTT are bare filenames; callers rewrite them when serving.
+func GenerateSprites(ctx context.Context, inputPath, outputDir string, meta *Metadata, cfg SpriteConfig) (*SpriteResult, error) {
+	if meta.Width <= 0 || meta.Height <= 0 {
+		return nil, fmt.Errorf("invalid video dimensions %dx%d", meta.Width, meta.Height)
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create sprite directory: %w", err)
+	}
+
+	interval := cfg.Interval
+	if cfg.MaxSprites > 0 && int(meta.Duration)/interval > cfg.MaxSprites {
+		interval = int(meta.Duration)/cfg.MaxSprites + 1
+	}
+
+	// Tile height follows the source aspect ratio, rounded to an even number
+	tileHeight := cfg.TileWidth * meta.Height / meta.Width
+	tileHeight += tileHeight % 2
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-y",
+		"-i", inputPath,
+		"-an",
+		"-vf", fmt.Sprintf("fps=1/%d,scale=%d:%d,tile=%dx%d", interval, cfg.TileWidth, tileHeight, cfg.Columns, cfg.Rows),
+		"-q:v", "5",
+		filepath.Join(outputDir, "sprite_%03d.jpg"),
+	)
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg sprites failed: %w, stderr: %s", err, stderr.String())
+	}
+
+	sheets, err := filepath.Glob(filepath.Join(outputDir, "sprite_*.jpg"))
+	if err != nil || len(sheets) == 0 {
+		return nil, fmt.Errorf("no sprite sheets generated")
+	}
+	sort.Strings(sheets)
+
+	vtt := BuildSpriteVTT(sheets, meta.Duration, interval, cfg.TileWidth, tileHeight, cfg.Columns, cfg.Rows)
+	vttPath := filepath.Join(outputDir, SpriteVTTFilename)
+	if err := os.WriteFile(vttPath, []byte(vtt), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write sprite VTT: %w", err)
+	}
+
+	return &SpriteResult{VTTPath: vttPath, SheetPaths: sheets}, nil
+}
+
+// BuildSpriteVTT builds a WebVTT thumbnail track where each cue points at a tile
+// using the media fragment syntax "sprite_001.jpg#xywh=x,y,w,h"
+func BuildSpriteVTT(sheetPaths []string, duration float64, interval, tileWidth, tileHeight, columns, rows int) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+
+	perSheet := columns * rows
+	frames := int(duration) / interval
+	if int(duration)%interval != 0 {
+		frames++
+	}
+
+	for i := 0; i < frames; i++ {
+		sheet := i / perSheet
+		if sheet >= len(sheetPaths) {
+			break
+		}
+		tile := i % perSheet
+		x := (tile % columns) * tileWidth
+		y := (tile / columns) * tileHeight
+
+		start := float64(i * interval)
+		end := start + float64(interval)
+		if end > duration {
+			end = duration
+		}
+
+		fmt.Fprintf(&b, "%s --> %s\n%s#xywh=%d,%d,%d,%d\n\n",
+			formatVTTTimestamp(start), formatVTTTimestamp(end),
+			filepath.Base(sheetPaths[sheet]), x, y, tileWidth, tileHeight)
+	}
+
+	return b.String()
+}
+
+// formatVTTTimestamp formats seconds as HH:MM:SS.mmm
+func formatVTTTimestamp(seconds float64) string {
+	d := time.Duration(seconds * float64(time.Second))
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	s := int(d.Seconds()) % 60
+	ms := int(d.Milliseconds()) % 1000
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, s, ms)
+}
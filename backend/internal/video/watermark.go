@@ -0,0 +1,60 @@
+package video
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+)
+
+// watermarkAlphabet omits characters that are easily confused in a blurry frame (0/O, 1/I)
+const watermarkAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// WatermarkCodeLength is the number of significant characters in a watermark code
+const WatermarkCodeLength = 10
+
+// GenerateWatermarkCode returns a random watermark code formatted as XXXXX-XXXXX
+func GenerateWatermarkCode() (string, error) {
+	buf := make([]byte, WatermarkCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate watermark code: %w", err)
+	}
+	code := make([]byte, WatermarkCodeLength)
+	for i, b := range buf {
+		code[i] = watermarkAlphabet[int(b)%len(watermarkAlphabet)]
+	}
+	return FormatWatermarkCode(string(code)), nil
+}
+
+// NormalizeWatermarkCode converts a code read off a leaked frame into its canonical
+// form: upper-case, separators and whitespace dropped, then re-formatted for display.
+func NormalizeWatermarkCode(input string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(input) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return FormatWatermarkCode(b.String())
+}
+
+// FormatWatermarkCode inserts the display separator into a bare code
+func FormatWatermarkCode(code string) string {
+	if len(code) != WatermarkCodeLength {
+		return code
+	}
+	half := WatermarkCodeLength / 2
+	return code[:half] + "-" + code[half:]
+}
+
+// MaskEmail hides most of the local part of an email so the overlay identifies without exposing it
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return email
+	}
+	local, domain := email[:at], email[at:]
+	if len(local) <= 2 {
+		return local[:1] + "***" + domain
+	}
+	return local[:2] + "***" + local[len(local)-1:] + domain
+}
@@ -11,6 +11,8 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/db"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/handlers"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/config"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/jobs"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/scheduler"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/service"
 	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/storage"
@@ -27,6 +29,11 @@ func main() {
 		log.Println("Global Timezone set to Asia/Jakarta")
 	}
 
+	// Tokens and signed URLs can't be trusted with a guessable secret
+	if err := config.CheckSecrets(); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+
 	// Initialize Database
 	db.Init()
 
@@ -39,11 +46,28 @@ func main() {
 	// Initialize WhatsApp Service
 	service.InitWhatsAppService()
 
+	// Initialize and start background job queue
+	jobs.Init(db.DB)
+	service.RegisterJobHandlers()
+	handlers.RegisterJobHandlers()
+	jobs.Start()
+	defer jobs.Stop()
+
 	// Initialize and start reminder scheduler
 	scheduler.InitScheduler(db.DB)
 	scheduler.StartScheduler()
 	defer scheduler.StopScheduler()
 
+	// Initialize and start abandoned upload cleanup
+	scheduler.InitUploadCleanup(db.DB)
+	scheduler.StartUploadCleanup()
+	defer scheduler.StopUploadCleanup()
+
+	// Initialize and start payment reconciliation with the gateways
+	scheduler.InitPaymentReconciler(handlers.ReconcilePendingPayments, handlers.WriteDailyPaymentReport)
+	scheduler.StartPaymentReconciler()
+	defer scheduler.StopPaymentReconciler()
+
 	e := EchoServer()
 
 	port := os.Getenv("PORT")
@@ -69,7 +93,8 @@ func EchoServer() *echo.Echo {
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins:     corsOrigins,
 		AllowMethods:     []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
-		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
+		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, "X-Device-ID", "X-Playback-Session", "Content-MD5", "X-Checksum-SHA256"},
+		ExposeHeaders:    []string{"X-Watermark-Code"},
 		AllowCredentials: true,
 	}))
 
@@ -159,15 +184,37 @@ func EchoServer() *echo.Echo {
 	
 	// HLS Encrypted Video Streaming
 	api.GET("/content/:lessonId/hls/manifest", handlers.GetHLSManifest)
-	api.GET("/content/:lessonId/hls/segment/:filename", handlers.GetHLSSegment)
-	api.GET("/content/:lessonId/hls/key", handlers.GetHLSKey)
+	api.GET("/content/:lessonId/hls/token", handlers.GetHLSContentToken)
+	// Variants, segments and keys are authorized by the lesson-scoped content token in the URI
+	e.GET("/api/content/:lessonId/hls/variant/:filename", handlers.GetHLSVariant)
+	e.GET("/api/content/:lessonId/hls/segment/:filename", handlers.GetHLSSegment)
+	e.GET("/api/content/:lessonId/hls/key", handlers.GetHLSKey)
 	api.GET("/content/:lessonId/hls/status", handlers.GetHLSStatus)
+	api.GET("/content/:lessonId/watermark", handlers.GetLessonWatermark)
+
+	// Video metadata, poster and seek-preview thumbnails
+	api.GET("/content/:lessonId/video-metadata", handlers.GetLessonVideoMetadata)
+	api.GET("/content/:lessonId/thumbnails.vtt", handlers.GetLessonThumbnailTrack)
+
+	// Subtitle / caption tracks
+	api.GET("/content/:lessonId/subtitles", handlers.ListLessonSubtitles)
+	api.GET("/content/:lessonId/subtitles/:subtitleId", handlers.GetLessonSubtitle)
+	api.GET("/content/:lessonId/subtitles/:subtitleId/playlist.m3u8", handlers.GetLessonSubtitlePlaylist)
+
+	// Playback sessions (concurrent stream / device limits)
+	api.POST("/content/:lessonId/playback/start", handlers.StartPlayback)
+	api.POST("/content/playback/:sessionId/heartbeat", handlers.PlaybackHeartbeat)
+	api.POST("/content/playback/:sessionId/end", handlers.EndPlayback)
 	
 	// External PDF Proxy (bypass CORS for external PDFs)
 	api.POST("/content/proxy-pdf", handlers.ProxyExternalPDF)
 	
 	// Public images (thumbnails, etc) - no auth required
 	e.GET("/api/images/:objectKey", handlers.GetPublicImage)
+	// Lesson poster and sprite images (authorized by URL signature)
+	e.GET("/api/media/lessons/:lessonId/:filename", handlers.GetVideoMediaImage)
+	// Signed URLs for local-disk storage (emulates pre-signed URLs; signature checked in handler)
+	e.GET("/api/storage/:bucket/*", handlers.ServeSignedObject)
 
 	// Activities & Stats
 	api.GET("/activities", handlers.GetRecentActivities)
@@ -189,8 +236,13 @@ func EchoServer() *echo.Echo {
 
 	// AI Tutor Chat (for students)
 	api.POST("/courses/:id/chat", handlers.SendChatMessage)
+	api.POST("/courses/:id/chat/stream", handlers.StreamChatMessage)
 	api.GET("/courses/:id/chat/session", handlers.GetChatSession)
 	api.DELETE("/courses/:id/chat/session", handlers.ClearChatSession)
+	api.GET("/courses/:id/chat/sessions", handlers.ListChatSessions)
+	api.POST("/courses/:id/chat/sessions", handlers.CreateChatSession)
+	api.PUT("/courses/:id/chat/sessions/:sessionId", handlers.UpdateChatSession)
+	api.DELETE("/courses/:id/chat/sessions/:sessionId", handlers.DeleteChatSession)
 	api.GET("/courses/:id/chat/quota", handlers.GetChatQuota)
 	api.GET("/courses/:id/ai-status", handlers.GetAIStatus)
 
@@ -233,6 +285,13 @@ func EchoServer() *echo.Echo {
 	admin.GET("/users/:id", handlers.GetUser)
 	admin.PUT("/users/:id", handlers.UpdateUser)
 	admin.DELETE("/users/:id", handlers.DeleteUser)
+	admin.GET("/users/:id/devices", handlers.AdminListUserDevices)
+	admin.DELETE("/users/:id/devices", handlers.AdminRevokeAllUserDevices)
+	admin.DELETE("/users/:id/devices/:deviceId", handlers.AdminRevokeUserDevice)
+
+	// Playback limits (platform defaults and per-tenant overrides)
+	admin.GET("/playback/limits", handlers.GetPlaybackLimits)
+	admin.PUT("/playback/limits", handlers.UpdatePlaybackLimits)
 	
 	// Admin Course Management
 	admin.GET("/courses", handlers.AdminListCourses)
@@ -248,17 +307,35 @@ func EchoServer() *echo.Echo {
 	admin.PUT("/lessons/:id/move", handlers.MoveLesson)
 	admin.DELETE("/lessons/:id", handlers.DeleteLesson)
 	admin.PUT("/courses/:courseId/lessons/reorder", handlers.ReorderLessons)
+	admin.POST("/lessons/:id/hls", handlers.ProcessLessonHLS)
+	admin.POST("/lessons/:id/metadata", handlers.ProcessLessonVideoMetadata)
+	admin.GET("/lessons/:id/subtitles", handlers.AdminListLessonSubtitles)
+	admin.POST("/lessons/:id/subtitles", handlers.AdminUploadLessonSubtitle)
+	admin.PUT("/lessons/:id/subtitles/:subtitleId", handlers.AdminUpdateLessonSubtitle)
+	admin.DELETE("/lessons/:id/subtitles/:subtitleId", handlers.AdminDeleteLessonSubtitle)
+
+	// Forensic watermark lookup (trace leaked video to a viewer)
+	admin.GET("/watermarks/:code", handlers.LookupWatermark)
 
 	
 	// Admin Transactions
 	admin.GET("/transactions", handlers.ListTransactions)
 	admin.GET("/transactions/:id", handlers.GetTransaction)
 	admin.PUT("/transactions/:id/status", handlers.UpdateTransactionStatus)
+	admin.POST("/transactions/:id/refund", handlers.RefundTransaction)
+	admin.GET("/transactions/:id/ledger", handlers.GetTransactionLedger)
+	admin.POST("/transactions/:id/refunds/:refund_id/retry", handlers.RetryRefund)
 	admin.DELETE("/transactions/:id", handlers.DeleteTransaction)
 
 	// Admin Payment Settings
 	admin.GET("/payment/settings", handlers.GetPaymentSettings)
 	admin.PUT("/payment/settings", handlers.UpdatePaymentSettings)
+	admin.POST("/payment/reconcile", handlers.RunPaymentReconciliation)
+	admin.GET("/payment/reconcile/reports", handlers.ListPaymentReconciliationReports)
+	admin.GET("/payment/reconcile/reports/:date", handlers.GetPaymentReconciliationReport)
+	admin.GET("/payment/webhooks", handlers.ListWebhookEvents)
+	admin.GET("/payment/webhooks/:id", handlers.GetWebhookEvent)
+	admin.POST("/payment/webhooks/:id/replay", handlers.ReplayWebhookEvent)
 
 	// Admin Categories
 	admin.GET("/categories", handlers.ListCategories)
@@ -283,16 +360,49 @@ func EchoServer() *echo.Echo {
 	admin.PUT("/ai/settings", handlers.UpdateAISettings)
 	admin.POST("/ai/validate-key", handlers.ValidateAIKey)
 	admin.GET("/ai/providers", handlers.GetAIProviders)
+	admin.GET("/ai/providers/health", handlers.GetAIProviderHealth)
 	admin.GET("/ai/models", handlers.FetchProviderModels) // Fetch models from provider API
 	admin.DELETE("/ai/key", handlers.ClearAPIKey)
+	admin.POST("/ai/rebuild-index", handlers.RebuildAIIndex)
+
+	// AI cost accounting and budgets
+	admin.GET("/ai/pricing", handlers.GetAIModelPricing)
+	admin.PUT("/ai/pricing", handlers.UpsertAIModelPricing)
+	admin.DELETE("/ai/pricing/:id", handlers.DeleteAIModelPricing)
+	admin.GET("/ai/budgets", handlers.GetAIBudgets)
+	admin.PUT("/ai/budgets", handlers.UpdateAIBudgetSettings)
+	admin.PUT("/ai/budgets/courses/:courseId", handlers.UpdateCourseAIBudget)
+	admin.GET("/ai/usage", handlers.GetAIUsageReport)
+
+	// AI Tutor guardrail events
+	admin.GET("/ai/guardrails/events", handlers.ListAIGuardrailEvents)
 
 	// Admin AI Content Processing
 	admin.POST("/courses/:id/process-ai", handlers.ProcessCourseContent)
 	admin.GET("/courses/:id/ai-processing-status", handlers.GetProcessingStatus)
 	admin.DELETE("/courses/:id/embeddings", handlers.ClearCourseEmbeddings)
 
+	// Admin RAG evaluation
+	admin.GET("/courses/:id/rag-eval/questions", handlers.ListRAGEvalQuestions)
+	admin.POST("/courses/:id/rag-eval/questions", handlers.CreateRAGEvalQuestions)
+	admin.PUT("/rag-eval/questions/:questionId", handlers.UpdateRAGEvalQuestion)
+	admin.DELETE("/rag-eval/questions/:questionId", handlers.DeleteRAGEvalQuestion)
+	admin.GET("/courses/:id/rag-eval/runs", handlers.ListRAGEvalRuns)
+	admin.POST("/courses/:id/rag-eval/runs", handlers.RunRAGEvaluation)
+	admin.GET("/rag-eval/runs/:runId", handlers.GetRAGEvalRun)
+
+	// Admin Background Jobs
+	admin.GET("/jobs", handlers.ListJobs)
+	admin.GET("/jobs/:id", handlers.GetJob)
+	admin.POST("/jobs/:id/retry", handlers.RetryJob)
+
 	// Admin File Upload
 	admin.POST("/upload", handlers.UploadFile)
+	admin.POST("/uploads/multipart", handlers.CreateMultipartUpload)
+	admin.GET("/uploads/multipart/:uploadId", handlers.GetMultipartUpload)
+	admin.PUT("/uploads/multipart/:uploadId/parts/:partNumber", handlers.UploadMultipartPart)
+	admin.POST("/uploads/multipart/:uploadId/complete", handlers.CompleteMultipartUpload)
+	admin.DELETE("/uploads/multipart/:uploadId", handlers.AbortMultipartUpload)
 
 	// Admin Rating Management
 	admin.GET("/ratings", handlers.AdminGetAllRatings)
@@ -329,6 +439,10 @@ func EchoServer() *echo.Echo {
 	admin.PUT("/questions/:id", handlers.UpdateQuestion)
 	admin.DELETE("/questions/:id", handlers.DeleteQuestion)
 	admin.PUT("/quizzes/:quizId/questions/reorder", handlers.ReorderQuestions)
+	admin.POST("/quizzes/:quizId/ai-draft", handlers.GenerateQuizDraft)
+	admin.POST("/quizzes/:quizId/ai-draft/accept", handlers.AcceptQuizDraft)
+	admin.GET("/quizzes/:quizId/answers", handlers.ListQuizShortAnswers)
+	admin.PUT("/quiz-answers/:id/grade", handlers.RegradeQuizAnswer)
 
 	// Admin Course Review Management (for instructor workflow)
 	admin.GET("/reviews", handlers.AdminListPendingReviews)
@@ -380,6 +494,10 @@ func EchoServer() *echo.Echo {
 	instructor.POST("/courses/:courseId/lessons", handlers.InstructorCreateLesson)
 	instructor.PUT("/lessons/:id", handlers.InstructorUpdateLesson)
 	instructor.DELETE("/lessons/:id", handlers.InstructorDeleteLesson)
+	instructor.GET("/lessons/:id/subtitles", handlers.InstructorListLessonSubtitles)
+	instructor.POST("/lessons/:id/subtitles", handlers.InstructorUploadLessonSubtitle)
+	instructor.PUT("/lessons/:id/subtitles/:subtitleId", handlers.InstructorUpdateLessonSubtitle)
+	instructor.DELETE("/lessons/:id/subtitles/:subtitleId", handlers.InstructorDeleteLessonSubtitle)
 
 	// Instructor Quiz Management
 	instructor.POST("/lessons/:lessonId/quiz", handlers.InstructorCreateQuiz)
@@ -389,6 +507,10 @@ func EchoServer() *echo.Echo {
 	instructor.POST("/quizzes/:quizId/questions", handlers.InstructorAddQuestion)
 	instructor.PUT("/questions/:id", handlers.InstructorUpdateQuestion)
 	instructor.DELETE("/questions/:id", handlers.InstructorDeleteQuestion)
+	instructor.POST("/quizzes/:quizId/ai-draft", handlers.GenerateQuizDraft)
+	instructor.POST("/quizzes/:quizId/ai-draft/accept", handlers.AcceptQuizDraft)
+	instructor.GET("/quizzes/:quizId/answers", handlers.ListQuizShortAnswers)
+	instructor.PUT("/quiz-answers/:id/grade", handlers.RegradeQuizAnswer)
 
 	// Instructor Analytics
 	instructor.GET("/courses/:id/students", handlers.InstructorCourseStudents)
@@ -407,6 +529,11 @@ func EchoServer() *echo.Echo {
 
 	// Instructor File Upload
 	instructor.POST("/upload", handlers.UploadFile)
+	instructor.POST("/uploads/multipart", handlers.CreateMultipartUpload)
+	instructor.GET("/uploads/multipart/:uploadId", handlers.GetMultipartUpload)
+	instructor.PUT("/uploads/multipart/:uploadId/parts/:partNumber", handlers.UploadMultipartPart)
+	instructor.POST("/uploads/multipart/:uploadId/complete", handlers.CompleteMultipartUpload)
+	instructor.DELETE("/uploads/multipart/:uploadId", handlers.AbortMultipartUpload)
 
 	// Student Quiz Routes (protected)
 	api.GET("/lessons/:lessonId/quiz", handlers.GetQuizForStudent)
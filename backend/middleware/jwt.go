@@ -2,19 +2,16 @@ package middleware
 
 import (
 	"log"
-	"os"
 	"strings"
 	
 	"github.com/golang-jwt/jwt/v5"
 	echojwt "github.com/labstack/echo-jwt/v4"
+	"github.com/lman-kadiv-doti/secure-whitelabel-lms/backend/internal/config"
 	"github.com/labstack/echo/v4"
 )
 
 func JWTMiddleware() echo.MiddlewareFunc {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "secret" // Default for development only
-	}
+	jwtSecret := config.JWTSecret()
 	
 	config := echojwt.Config{
 		NewClaimsFunc: func(c echo.Context) jwt.Claims {
@@ -0,0 +1,6 @@
+-- Migration: Track HLS processing failures
+-- Stores the last processing error so admins can see why a video failed
+
+ALTER TABLE video_encryption_keys ADD COLUMN IF NOT EXISTS error_message TEXT;
+ALTER TABLE video_encryption_keys ADD COLUMN IF NOT EXISTS source_path VARCHAR(500);
+ALTER TABLE video_encryption_keys ADD COLUMN IF NOT EXISTS duration_seconds INT DEFAULT 0;
@@ -0,0 +1,9 @@
+-- Migration: Adaptive bitrate HLS
+-- New videos are stored as a master playlist with one playlist per rendition.
+-- Videos processed before this migration keep their single playlist.m3u8.
+
+ALTER TABLE video_encryption_keys ADD COLUMN IF NOT EXISTS manifest_file VARCHAR(100) DEFAULT 'playlist.m3u8';
+
+-- Default bitrate ladder (comma separated rendition names)
+INSERT INTO settings (key, value) VALUES ('hls_renditions', '360p,480p,720p,1080p')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,28 @@
+-- Migration: Persistent background job queue
+-- Replaces fire-and-forget goroutines for video transcoding, AI processing and notifications
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    queue VARCHAR(50) NOT NULL DEFAULT 'default',
+    job_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, running, completed, dead
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 5,
+    run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_by VARCHAR(255),
+    locked_until TIMESTAMPTZ,
+    last_error TEXT,
+    sensitive BOOLEAN NOT NULL DEFAULT FALSE, -- payload wiped on completion
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+-- Index for leasing the next runnable job of a queue
+CREATE INDEX IF NOT EXISTS idx_jobs_queue_runnable ON jobs(queue, run_at) WHERE status IN ('pending', 'running');
+
+-- Index for the admin job list
+CREATE INDEX IF NOT EXISTS idx_jobs_status_created ON jobs(status, created_at DESC);
+
+COMMENT ON TABLE jobs IS 'Postgres-backed job queue with leases, retries and dead-lettering';
@@ -0,0 +1,23 @@
+-- Migration: Per-viewer forensic watermarks
+-- Every watermark code shown on a protected video is recorded so a leaked frame can be traced back.
+-- The code is drawn by the player as an overlay; the video stream itself is not marked.
+
+CREATE TABLE IF NOT EXISTS video_watermarks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code VARCHAR(20) NOT NULL UNIQUE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    lesson_id UUID NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+    course_id UUID NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    enrollment_id UUID REFERENCES enrollments(id) ON DELETE SET NULL,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    issued_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_video_watermarks_user_lesson ON video_watermarks(user_id, lesson_id, issued_at DESC);
+
+-- Watermark overlay appearance
+INSERT INTO settings (key, value) VALUES
+    ('watermark_opacity', '0.35'),
+    ('watermark_move_interval_seconds', '20')
+ON CONFLICT (key) DO NOTHING;
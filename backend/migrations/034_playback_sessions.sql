@@ -0,0 +1,39 @@
+-- Migration: Playback session and device registry
+-- Limits how many devices an account may register and how many streams it may play at once
+
+CREATE TABLE IF NOT EXISTS playback_devices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    fingerprint VARCHAR(64) NOT NULL,
+    label VARCHAR(255),
+    ip_address VARCHAR(45),
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ,
+    UNIQUE(user_id, fingerprint)
+);
+
+CREATE INDEX IF NOT EXISTS idx_playback_devices_user ON playback_devices(user_id) WHERE revoked_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS playback_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_id UUID NOT NULL REFERENCES playback_devices(id) ON DELETE CASCADE,
+    lesson_id UUID REFERENCES lessons(id) ON DELETE SET NULL,
+    ip_address VARCHAR(45),
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ended_at TIMESTAMPTZ,
+    end_reason VARCHAR(50) -- ended, expired, replaced, revoked
+);
+
+CREATE INDEX IF NOT EXISTS idx_playback_sessions_active ON playback_sessions(user_id, last_heartbeat_at) WHERE ended_at IS NULL;
+
+-- Platform-wide defaults; a tenant can override them with max_concurrent_streams / max_devices
+-- in its feature_config. 0 means unlimited.
+INSERT INTO settings (key, value) VALUES
+    ('playback_max_concurrent_streams', '2'),
+    ('playback_max_devices', '3'),
+    ('playback_session_timeout_seconds', '90'),
+    ('playback_device_inactive_days', '30')
+ON CONFLICT (key) DO NOTHING;
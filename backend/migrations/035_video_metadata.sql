@@ -0,0 +1,27 @@
+-- Migration: Automatic video metadata, poster thumbnails and seek-preview sprites
+-- Filled by ffprobe/ffmpeg when a lesson video is uploaded or replaced
+
+CREATE TABLE IF NOT EXISTS lesson_video_metadata (
+    lesson_id UUID PRIMARY KEY REFERENCES lessons(id) ON DELETE CASCADE,
+    source_path VARCHAR(500) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, processing, ready, failed
+    duration_seconds INT,
+    width INT,
+    height INT,
+    video_codec VARCHAR(50),
+    audio_codec VARCHAR(50),
+    frame_rate NUMERIC(8,3),
+    bitrate BIGINT,
+    size_bytes BIGINT,
+    poster_path VARCHAR(500),
+    sprite_vtt_path VARCHAR(500),
+    error_message TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Total of all lesson video durations; courses.duration is derived from it
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS duration_seconds INT DEFAULT 0;
+
+INSERT INTO settings (key, value) VALUES ('video_sprite_interval_seconds', '10')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,21 @@
+-- Migration: Subtitle / caption tracks for video lessons
+-- Tracks are stored as WebVTT in object storage (SRT uploads are converted)
+
+CREATE TABLE IF NOT EXISTS lesson_subtitles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    lesson_id UUID NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+    language VARCHAR(20) NOT NULL, -- BCP 47 code, e.g. 'id', 'en', 'pt-BR'
+    label VARCHAR(100) NOT NULL,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    object_path VARCHAR(500) NOT NULL,
+    original_filename VARCHAR(255),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE(lesson_id, language)
+);
+
+CREATE INDEX IF NOT EXISTS idx_lesson_subtitles_lesson ON lesson_subtitles(lesson_id);
+
+-- At most one default track per lesson
+CREATE UNIQUE INDEX IF NOT EXISTS idx_lesson_subtitles_default
+ON lesson_subtitles(lesson_id) WHERE is_default = true;
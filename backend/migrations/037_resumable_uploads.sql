@@ -0,0 +1,38 @@
+-- Migration: Resumable chunked uploads
+-- Large files are uploaded in parts straight into a storage multipart upload
+
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    object_key VARCHAR(500) NOT NULL,
+    storage_upload_id VARCHAR(255) NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    file_type VARCHAR(20) NOT NULL, -- video, document, archive, image
+    total_size BIGINT NOT NULL,
+    part_size BIGINT NOT NULL,
+    total_parts INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'uploading', -- uploading, completed, aborted, expired
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_user ON upload_sessions(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_expiry ON upload_sessions(expires_at) WHERE status = 'uploading';
+
+CREATE TABLE IF NOT EXISTS upload_session_parts (
+    upload_id UUID NOT NULL REFERENCES upload_sessions(id) ON DELETE CASCADE,
+    part_number INT NOT NULL,
+    size BIGINT NOT NULL,
+    etag VARCHAR(255) NOT NULL,
+    sha256 VARCHAR(64) NOT NULL,
+    uploaded_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (upload_id, part_number)
+);
+
+INSERT INTO settings (key, value) VALUES
+    ('upload_max_video_size_mb', '5120'),
+    ('upload_session_ttl_hours', '24')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,8 @@
+-- Migration: HLS content tokens
+-- Segment, key and variant URIs carry short-lived tokens scoped to one lesson
+
+INSERT INTO settings (key, value) VALUES
+    ('hls_content_token_ttl_seconds', '300'),
+    ('hls_content_token_bind_ip', 'false'),
+    ('hls_content_token_bind_user_agent', 'true')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,8 @@
+-- Migration: AI provider failover chain and circuit breaker settings
+
+INSERT INTO settings (key, value) VALUES
+    ('ai_fallback_chain', '[]'),
+    ('ai_breaker_failure_threshold', '3'),
+    ('ai_breaker_cooldown_seconds', '60'),
+    ('ai_provider_timeout_seconds', '30')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,9 @@
+-- Migration: Self-hosted OpenAI-compatible AI provider
+-- The embedding column is resized at runtime to the embedder's dimensions
+-- (see AIRepository.EnsureEmbeddingDimensions), so no fixed size is set here.
+
+INSERT INTO settings (key, value) VALUES
+    ('ai_selfhosted_base_url', ''),
+    ('ai_selfhosted_models', ''),
+    ('ai_selfhosted_embedding_dimensions', '0')
+ON CONFLICT (key) DO NOTHING;
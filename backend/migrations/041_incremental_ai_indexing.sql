@@ -0,0 +1,8 @@
+-- Migration: Incremental AI indexing
+-- Each lesson gets one processing status row holding a hash of the content its
+-- embeddings were built from, so unchanged lessons are skipped on re-processing.
+
+ALTER TABLE content_processing_status ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_processing_status_lesson_unique
+ON content_processing_status(lesson_id) WHERE lesson_id IS NOT NULL;
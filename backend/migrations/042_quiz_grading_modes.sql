@@ -0,0 +1,12 @@
+-- Migration: Short answer grading modes
+-- Questions choose how short answers are graded (exact, normalized or AI rubric);
+-- answers keep the grader's feedback so instructors can review and override it
+
+ALTER TABLE quiz_questions ADD COLUMN IF NOT EXISTS grading_mode VARCHAR(20) DEFAULT 'exact';
+ALTER TABLE quiz_questions ADD COLUMN IF NOT EXISTS rubric TEXT;
+
+ALTER TABLE quiz_answers ADD COLUMN IF NOT EXISTS feedback TEXT;
+ALTER TABLE quiz_answers ADD COLUMN IF NOT EXISTS graded_by VARCHAR(20) DEFAULT 'auto';
+ALTER TABLE quiz_answers ADD COLUMN IF NOT EXISTS graded_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_quiz_answers_question_id ON quiz_answers(question_id);
@@ -0,0 +1,48 @@
+-- Migration: AI cost accounting and monthly budgets
+-- Prices are per 1M tokens. model '*' is the fallback price for any other model of the provider.
+-- ai_usage_log.estimated_cost is filled in when usage is logged, so later price
+-- changes do not rewrite historical spend.
+
+CREATE TABLE IF NOT EXISTS ai_model_pricing (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    provider VARCHAR(30) NOT NULL,
+    model VARCHAR(100) NOT NULL DEFAULT '*',
+    input_price_per_mtok DECIMAL(12, 6) NOT NULL DEFAULT 0,
+    output_price_per_mtok DECIMAL(12, 6) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(provider, model)
+);
+
+INSERT INTO ai_model_pricing (provider, model, input_price_per_mtok, output_price_per_mtok) VALUES
+    ('openai', 'gpt-4-turbo', 10, 30),
+    ('openai', 'gpt-4o', 2.5, 10),
+    ('openai', 'gpt-4o-mini', 0.15, 0.6),
+    ('openai', 'gpt-3.5-turbo', 0.5, 1.5),
+    ('claude', 'claude-3-5-sonnet-20241022', 3, 15),
+    ('claude', 'claude-3-opus-20240229', 15, 75),
+    ('claude', 'claude-3-haiku-20240307', 0.25, 1.25),
+    ('gemini', 'gemini-1.5-pro', 1.25, 5),
+    ('gemini', 'gemini-1.5-flash', 0.075, 0.3),
+    ('gemini', 'gemini-2.5-flash', 0.3, 2.5),
+    ('selfhosted', '*', 0, 0)
+ON CONFLICT (provider, model) DO NOTHING;
+
+-- Per-course monthly budgets; NULL means no course-level limit
+CREATE TABLE IF NOT EXISTS ai_course_budgets (
+    course_id UUID PRIMARY KEY REFERENCES courses(id) ON DELETE CASCADE,
+    monthly_token_limit BIGINT,
+    monthly_cost_limit DECIMAL(12, 2),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_ai_usage_log_course_date ON ai_usage_log(course_id, created_at);
+
+-- Platform-wide monthly budget (0 = unlimited); a tenant can override it with
+-- ai_monthly_token_budget / ai_monthly_cost_budget in its feature_config.
+-- Requests are refused once a budget is used up unless hard stop is disabled.
+INSERT INTO settings (key, value) VALUES
+    ('ai_budget_monthly_tokens', '0'),
+    ('ai_budget_monthly_cost', '0'),
+    ('ai_budget_warning_percent', '80'),
+    ('ai_budget_hard_stop', 'true')
+ON CONFLICT (key) DO NOTHING;
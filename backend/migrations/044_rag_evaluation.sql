@@ -0,0 +1,36 @@
+-- Migration: Offline RAG evaluation
+-- Golden question sets per course and stored evaluation runs, so retrieval
+-- configurations can be compared over time.
+
+CREATE TABLE IF NOT EXISTS rag_eval_questions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    course_id UUID NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    question TEXT NOT NULL,
+    expected_lesson_ids UUID[] NOT NULL DEFAULT '{}',
+    reference_answer TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_rag_eval_questions_course ON rag_eval_questions(course_id);
+
+CREATE TABLE IF NOT EXISTS rag_eval_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    course_id UUID NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    label VARCHAR(255),
+    -- Retriever and chunker configuration the run was made with
+    config JSONB NOT NULL DEFAULT '{}',
+    question_count INT NOT NULL DEFAULT 0,
+    error_count INT NOT NULL DEFAULT 0,
+    recall_at_k DOUBLE PRECISION NOT NULL DEFAULT 0,
+    mrr DOUBLE PRECISION NOT NULL DEFAULT 0,
+    citation_accuracy DOUBLE PRECISION NOT NULL DEFAULT 0,
+    hit_rate DOUBLE PRECISION NOT NULL DEFAULT 0,
+    -- Per-question results
+    results JSONB NOT NULL DEFAULT '[]',
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_rag_eval_runs_course ON rag_eval_runs(course_id, created_at DESC);
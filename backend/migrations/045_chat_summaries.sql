@@ -0,0 +1,16 @@
+-- Migration: Rolling conversation summaries for the AI Tutor
+-- Older turns of long sessions are folded into a running summary so prompts
+-- stay within a token budget without losing early context.
+
+ALTER TABLE ai_chat_sessions ADD COLUMN IF NOT EXISTS summary TEXT;
+-- created_at of the newest message covered by the summary
+ALTER TABLE ai_chat_sessions ADD COLUMN IF NOT EXISTS summarized_until TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_ai_chat_messages_session_created ON ai_chat_messages(session_id, created_at);
+
+-- ai_prompt_token_limit caps the estimated prompt size (summary + recent turns +
+-- course context); history beyond ai_history_token_budget is summarized.
+INSERT INTO settings (key, value) VALUES
+    ('ai_prompt_token_limit', '6000'),
+    ('ai_history_token_budget', '2000')
+ON CONFLICT (key) DO NOTHING;
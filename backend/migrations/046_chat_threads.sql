@@ -0,0 +1,8 @@
+-- Migration: Multiple named AI Tutor conversations per course
+-- A student may keep several open sessions per course; is_active = false now
+-- means the session is archived.
+
+DROP INDEX IF EXISTS idx_ai_chat_sessions_active;
+
+CREATE INDEX IF NOT EXISTS idx_ai_chat_sessions_user_course
+ON ai_chat_sessions(user_id, course_id, updated_at DESC);
@@ -0,0 +1,29 @@
+-- Migration: AI Tutor guardrails
+-- Events recorded when a tutor message is blocked, retrieved material is
+-- dropped for prompt injection, or personal data is redacted.
+
+CREATE TABLE IF NOT EXISTS ai_guardrail_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    course_id UUID REFERENCES courses(id) ON DELETE CASCADE,
+    session_id UUID REFERENCES ai_chat_sessions(id) ON DELETE SET NULL,
+    lesson_id UUID REFERENCES lessons(id) ON DELETE SET NULL,
+    -- prompt_injection, context_injection, off_topic, pii_redacted
+    event_type VARCHAR(50) NOT NULL,
+    -- blocked, filtered, redacted
+    action VARCHAR(20) NOT NULL,
+    -- Matched rule, or the kinds of personal data redacted
+    rule VARCHAR(100),
+    -- Redacted excerpt of the offending text
+    excerpt TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_ai_guardrail_events_created ON ai_guardrail_events(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_ai_guardrail_events_type ON ai_guardrail_events(event_type, created_at DESC);
+
+INSERT INTO settings (key, value) VALUES
+    ('ai_guard_injection', 'true'),
+    ('ai_guard_pii_redaction', 'true'),
+    ('ai_guard_off_topic', 'false')
+ON CONFLICT (key) DO NOTHING;
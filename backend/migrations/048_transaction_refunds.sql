@@ -0,0 +1,32 @@
+-- Migration: Transaction refunds
+-- Ledger of every refund issued against a transaction. A transaction's
+-- refunded_amount is the sum of its succeeded and manual refunds.
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS refunded_amount DECIMAL(12,2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS transaction_refunds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    amount DECIMAL(12,2) NOT NULL,
+    reason TEXT NOT NULL,
+    -- Payment gateway the refund went through; empty for manual refunds
+    provider VARCHAR(50),
+    -- Idempotency key sent to the gateway
+    refund_key VARCHAR(100) NOT NULL UNIQUE,
+    gateway_ref VARCHAR(255),
+    -- pending, succeeded, manual, failed
+    status VARCHAR(20) NOT NULL,
+    message TEXT,
+    is_full BOOLEAN NOT NULL DEFAULT false,
+    enrollment_revoked BOOLEAN NOT NULL DEFAULT false,
+    coupon_released BOOLEAN NOT NULL DEFAULT false,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_refunds_transaction ON transaction_refunds(transaction_id, created_at);
+
+-- What happens to the enrollment when a transaction is fully refunded: revoke or retain
+INSERT INTO settings (key, value) VALUES
+    ('refund_enrollment_policy', 'revoke')
+ON CONFLICT (key) DO NOTHING;
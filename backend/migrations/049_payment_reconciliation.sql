@@ -0,0 +1,39 @@
+-- Migration: Payment reconciliation
+-- Pending transactions are periodically checked against the payment gateway, so a
+-- lost webhook doesn't leave a paid order pending. Every correction is logged and
+-- summarized in a daily discrepancy report for finance.
+
+CREATE TABLE IF NOT EXISTS payment_reconciliations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL,
+    order_id VARCHAR(255),
+    payment_gateway VARCHAR(50),
+    local_status VARCHAR(50),
+    gateway_status VARCHAR(50),
+    -- settled, failed, expired, status_mismatch, amount_mismatch, error
+    action VARCHAR(30) NOT NULL,
+    local_amount DECIMAL(12,2),
+    gateway_amount DECIMAL(12,2),
+    note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_reconciliations_created ON payment_reconciliations(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_payment_reconciliations_transaction ON payment_reconciliations(transaction_id);
+
+CREATE TABLE IF NOT EXISTS payment_reconciliation_reports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    report_date DATE NOT NULL UNIQUE,
+    -- Counts per action and totals for the day
+    summary JSONB NOT NULL DEFAULT '{}',
+    discrepancy_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_pending_created ON transactions(created_at) WHERE status = 'pending';
+
+INSERT INTO settings (key, value) VALUES
+    ('payment_reconcile_enabled', 'true'),
+    -- Pending orders older than this are expired when the gateway has no payment
+    ('payment_pending_expiry_hours', '24')
+ON CONFLICT (key) DO NOTHING;
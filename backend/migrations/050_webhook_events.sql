@@ -0,0 +1,35 @@
+-- Migration: Webhook event store
+-- Every payment gateway notification that passes signature verification is
+-- stored as received, before it is processed. Retried notifications are deduplicated by the gateway's event
+-- identity, and admins can inspect and replay any stored event.
+
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    provider VARCHAR(50) NOT NULL,
+    -- Gateway event identity: order, gateway reference and status, or a hash of
+    -- the body when the payload can't be parsed
+    event_key VARCHAR(255) NOT NULL,
+    order_id VARCHAR(255),
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL,
+    gateway_status VARCHAR(50),
+    headers JSONB NOT NULL DEFAULT '{}',
+    body TEXT NOT NULL,
+    -- NULL until the signature has been checked
+    signature_valid BOOLEAN,
+    -- received, processing, processed, ignored, rejected, failed
+    status VARCHAR(20) NOT NULL DEFAULT 'received',
+    from_status VARCHAR(50),
+    to_status VARCHAR(50),
+    error TEXT,
+    attempts INT NOT NULL DEFAULT 0,
+    duplicate_count INT NOT NULL DEFAULT 0,
+    received_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_received_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP WITH TIME ZONE,
+
+    UNIQUE(provider, event_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_events_received ON webhook_events(received_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_events_order ON webhook_events(order_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_events_status ON webhook_events(status);
@@ -0,0 +1,10 @@
+-- Migration: Rotate pending orders through reconciliation
+-- Each run checks the pending orders that were checked longest ago, so orders that
+-- can't be resolved (gateway errors, gateway no longer configured) don't keep newer
+-- orders out of the batch.
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS last_reconciled_at TIMESTAMP WITH TIME ZONE;
+
+DROP INDEX IF EXISTS idx_transactions_pending_created;
+CREATE INDEX IF NOT EXISTS idx_transactions_pending_reconcile
+    ON transactions(last_reconciled_at NULLS FIRST, created_at) WHERE status = 'pending';
@@ -0,0 +1,6 @@
+-- Migration: Webhook claim lease
+-- A webhook event stays 'processing' while a delivery handles it. If the process
+-- dies mid-way, the claim expires and the gateway's next retry claims it again
+-- instead of getting a 409 until an admin replays it.
+
+ALTER TABLE webhook_events ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMP WITH TIME ZONE;
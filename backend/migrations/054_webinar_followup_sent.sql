@@ -0,0 +1,7 @@
+-- Migration: Webinar follow-up marker
+-- A webinar-only purchase has no enrollment to show it was fulfilled, so the
+-- transaction records when its registration and WhatsApp follow-up were queued.
+-- Replayed webhooks and repeated success notifications then don't message the
+-- buyer again.
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS webinar_followup_at TIMESTAMP WITH TIME ZONE;
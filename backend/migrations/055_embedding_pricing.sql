@@ -0,0 +1,12 @@
+-- Migration: Embedding model pricing
+-- Embedding calls (course indexing, tutor queries, RAG evaluations) are logged in
+-- ai_usage_log with action_type 'embedding' and count towards the AI budgets.
+-- Only input tokens are billed for embeddings.
+
+INSERT INTO ai_model_pricing (provider, model, input_price_per_mtok, output_price_per_mtok) VALUES
+    ('openai', 'text-embedding-ada-002', 0.1, 0),
+    ('openai', 'text-embedding-3-small', 0.02, 0),
+    ('openai', 'text-embedding-3-large', 0.13, 0),
+    ('gemini', 'gemini-embedding-001', 0.15, 0),
+    ('gemini', 'text-embedding-004', 0, 0)
+ON CONFLICT (provider, model) DO NOTHING;
@@ -0,0 +1,6 @@
+-- Migration: Refund enrollment policy
+-- The enrollment policy (revoke or retain) the admin chose for a refund, so a
+-- pending refund that is retried applies the same choice instead of the global
+-- refund_enrollment_policy setting. Empty for refunds reserved before this column.
+
+ALTER TABLE transaction_refunds ADD COLUMN IF NOT EXISTS enrollment_policy VARCHAR(10);
@@ -15,24 +15,45 @@ interface Source {
     contentType: string
     preview: string
     relevance: number
+    heading?: string
+    page?: number
+    startTime?: number
+    reference?: string
 }
 
+// The API sends sources in snake_case; map them to the shape the widget renders
+function mapSources(sources: any[] | null | undefined): Source[] | undefined {
+    if (!Array.isArray(sources)) return undefined
+    return sources.map((s: any) => ({
+        lessonId: s.lessonId ?? s.lesson_id,
+        lessonTitle: s.lessonTitle ?? s.lesson_title,
+        contentType: s.contentType ?? s.content_type,
+        preview: s.preview,
+        relevance: s.relevance,
+        heading: s.heading,
+        page: s.page,
+        startTime: s.startTime ?? s.start_time,
+        reference: s.reference
+    }))
+}
+
+// Sessions are sent as-is by the API (snake_case)
 interface ChatSession {
     id: string
-    userId: string
-    courseId: string
+    course_id: string
     title: string
-    isActive: boolean
-    messageCount: number
-    totalTokensUsed: number
-    createdAt: string
-    updatedAt: string
+    is_active: boolean
+    message_count: number
+    total_tokens_used: number
+    created_at: string
+    updated_at: string
 }
 
 interface QuotaInfo {
     used: number
     limit: number
     remaining: number
+    budget_warning?: string
 }
 
 interface AIStatus {
@@ -50,6 +71,7 @@ export function useAITutor(courseId: string) {
     // State
     const messages = ref<ChatMessage[]>([])
     const session = ref<ChatSession | null>(null)
+    const sessions = ref<ChatSession[]>([])
     const quota = ref<QuotaInfo>({ used: 0, limit: 50, remaining: 50 })
     const aiStatus = ref<AIStatus | null>(null)
     const isLoading = ref(false)
@@ -87,15 +109,16 @@ export function useAITutor(courseId: string) {
     }
 
     // Actions
-    async function loadSession() {
+    async function loadSession(sessionId?: string) {
         try {
             isLoading.value = true
             error.value = null
 
-            const data = await fetchWithAuth(`/api/courses/${courseId}/chat/session`)
+            const query = sessionId ? `?session_id=${encodeURIComponent(sessionId)}` : ''
+            const data = await fetchWithAuth(`/api/courses/${courseId}/chat/session${query}`)
 
             session.value = data.session
-            messages.value = data.messages || []
+            messages.value = (data.messages || []).map((m: any) => ({ ...m, sources: mapSources(m.sources) }))
             quota.value = data.quota
 
             if (messages.value.length === 0) {
@@ -140,29 +163,69 @@ export function useAITutor(courseId: string) {
             }
             messages.value.push(tempUserMsg)
 
-            // Send to API
-            const data = await fetchWithAuth(`/api/courses/${courseId}/chat`, {
+            // Stream the reply token by token (server-sent events)
+            const headers = new Headers({ 'Content-Type': 'application/json' })
+            if (token.value) {
+                headers.set('Authorization', `Bearer ${token.value}`)
+            }
+            const response = await fetch(`${apiBase.value}/api/courses/${courseId}/chat/stream`, {
                 method: 'POST',
-                body: JSON.stringify({ message: content.trim() })
+                headers,
+                body: JSON.stringify({ message: content.trim(), session_id: session.value?.id })
             })
+            if (!response.ok || !response.body) {
+                const errorData = await response.json().catch(() => ({}))
+                throw new Error(errorData.error || `HTTP ${response.status}`)
+            }
 
-            // Add assistant response
-            const assistantMsg: ChatMessage = {
-                id: data.session_id + '-' + Date.now(),
+            messages.value.push({
+                id: `stream-${Date.now()}`,
                 role: 'assistant',
-                content: data.message,
-                sources: data.sources,
-                tokensUsed: data.tokens_used,
+                content: '',
                 createdAt: new Date().toISOString()
-            }
-            messages.value.push(assistantMsg)
-
-            // Update quota
-            quota.value = data.quota
-
-            // Update session if provided
-            if (data.session_id) {
-                session.value = { ...session.value!, id: data.session_id }
+            })
+            const assistantMsg = messages.value[messages.value.length - 1]
+
+            const reader = response.body.getReader()
+            const decoder = new TextDecoder()
+            let buffer = ''
+            let done = false
+
+            while (!done) {
+                const { value, done: streamDone } = await reader.read()
+                if (streamDone) break
+                buffer += decoder.decode(value, { stream: true })
+
+                // Events are separated by a blank line
+                let boundary
+                while ((boundary = buffer.indexOf('\n\n')) !== -1) {
+                    const raw = buffer.slice(0, boundary)
+                    buffer = buffer.slice(boundary + 2)
+
+                    const event = raw.match(/^event: (.*)$/m)?.[1]
+                    const dataLine = raw.match(/^data: (.*)$/m)?.[1]
+                    if (!event || !dataLine) continue
+                    const data = JSON.parse(dataLine)
+
+                    if (event === 'token') {
+                        assistantMsg.content += data.content
+                    } else if (event === 'done') {
+                        assistantMsg.id = data.message_id || assistantMsg.id
+                        assistantMsg.content = data.message
+                        assistantMsg.sources = mapSources(data.sources)
+                        assistantMsg.tokensUsed = data.tokens_used
+                        quota.value = data.quota
+                        if (data.session_id) {
+                            session.value = { ...session.value!, id: data.session_id }
+                        }
+                        done = true
+                        // The first question names a new conversation
+                        loadSessions()
+                    } else if (event === 'error') {
+                        messages.value = messages.value.filter(m => m !== assistantMsg)
+                        throw new Error(data.error)
+                    }
+                }
             }
 
         } catch (err: any) {
@@ -177,7 +240,8 @@ export function useAITutor(courseId: string) {
 
     async function clearSession() {
         try {
-            await fetchWithAuth(`/api/courses/${courseId}/chat/session`, {
+            const query = session.value ? `?session_id=${encodeURIComponent(session.value.id)}` : ''
+            await fetchWithAuth(`/api/courses/${courseId}/chat/session${query}`, {
                 method: 'DELETE'
             })
 
@@ -191,12 +255,77 @@ export function useAITutor(courseId: string) {
 
             // Reload session to get new one
             await loadSession()
+            await loadSessions()
         } catch (err: any) {
             error.value = err.message
             throw err
         }
     }
 
+    async function loadSessions() {
+        try {
+            const data = await fetchWithAuth(`/api/courses/${courseId}/chat/sessions`)
+            sessions.value = data.sessions || []
+        } catch (err: any) {
+            console.error('Failed to load AI sessions:', err)
+        }
+    }
+
+    async function createSession(title?: string) {
+        try {
+            const created = await fetchWithAuth(`/api/courses/${courseId}/chat/sessions`, {
+                method: 'POST',
+                body: JSON.stringify({ title })
+            })
+            await loadSessions()
+            await loadSession(created.id)
+        } catch (err: any) {
+            error.value = err.message
+        }
+    }
+
+    async function selectSession(sessionId: string) {
+        if (session.value?.id === sessionId) return
+        await loadSession(sessionId)
+    }
+
+    async function updateSession(sessionId: string, changes: { title?: string, archived?: boolean }) {
+        try {
+            await fetchWithAuth(`/api/courses/${courseId}/chat/sessions/${sessionId}`, {
+                method: 'PUT',
+                body: JSON.stringify(changes)
+            })
+            await loadSessions()
+            if (changes.archived && session.value?.id === sessionId) {
+                // Continue in the most recent open conversation, or a new one
+                await loadSession(sessions.value[0]?.id)
+                await loadSessions()
+            } else if (changes.title && session.value?.id === sessionId) {
+                session.value = { ...session.value, title: changes.title }
+            }
+        } catch (err: any) {
+            error.value = err.message
+        }
+    }
+
+    const renameSession = (sessionId: string, title: string) => updateSession(sessionId, { title })
+    const archiveSession = (sessionId: string) => updateSession(sessionId, { archived: true })
+
+    async function deleteSession(sessionId: string) {
+        try {
+            await fetchWithAuth(`/api/courses/${courseId}/chat/sessions/${sessionId}`, {
+                method: 'DELETE'
+            })
+            await loadSessions()
+            if (session.value?.id === sessionId) {
+                await loadSession(sessions.value[0]?.id)
+                await loadSessions()
+            }
+        } catch (err: any) {
+            error.value = err.message
+        }
+    }
+
     async function loadQuota() {
         try {
             const data = await fetchWithAuth(`/api/courses/${courseId}/chat/quota`)
@@ -209,14 +338,14 @@ export function useAITutor(courseId: string) {
     function toggleChat() {
         isOpen.value = !isOpen.value
         if (isOpen.value && messages.value.length <= 1) {
-            loadSession()
+            loadSession().then(loadSessions)
         }
     }
 
     function openChat() {
         isOpen.value = true
         if (messages.value.length <= 1) {
-            loadSession()
+            loadSession().then(loadSessions)
         }
     }
 
@@ -245,6 +374,7 @@ Saya akan menjawab berdasarkan materi yang tersedia di kursus ini. 🎓`
         // State
         messages,
         session,
+        sessions,
         quota,
         aiStatus,
         isLoading,
@@ -260,6 +390,12 @@ Saya akan menjawab berdasarkan materi yang tersedia di kursus ini. 🎓`
 
         // Actions
         loadSession,
+        loadSessions,
+        createSession,
+        selectSession,
+        renameSession,
+        archiveSession,
+        deleteSession,
         loadAIStatus,
         sendMessage,
         clearSession,
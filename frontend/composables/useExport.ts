@@ -125,6 +125,22 @@ export const useExport = () => {
     downloadCSV(csv, `instructors_${date}.csv`)
   }
   
+  // Export AI usage report
+  const exportAIUsage = (rows: any[], groupBy: string, from: string, to: string) => {
+    const keyLabels: Record<string, string> = { course: 'Kursus', user: 'User', day: 'Tanggal' }
+    const columns = [
+      { key: 'key', label: 'ID' },
+      { key: 'label', label: keyLabels[groupBy] || groupBy },
+      { key: 'requests', label: 'Permintaan' },
+      { key: 'tokens_input', label: 'Token Input' },
+      { key: 'tokens_output', label: 'Token Output' },
+      { key: 'cost', label: 'Estimasi Biaya (USD)' }
+    ]
+    
+    const csv = toCSV(rows, columns)
+    downloadCSV(csv, `ai_usage_${groupBy}_${from}_${to}.csv`)
+  }
+  
   // Generic export
   const exportData = (data: any[], columns: { key: string; label: string }[], filename: string) => {
     const csv = toCSV(data, columns)
@@ -138,6 +154,7 @@ export const useExport = () => {
     exportCourses,
     exportTransactions,
     exportInstructors,
+    exportAIUsage,
     exportData
   }
 }
@@ -353,6 +353,8 @@ export const useInstructorPanel = () => {
         explanation?: string
         points?: number
         required?: boolean
+        grading_mode?: string
+        rubric?: string
         options?: { option_text: string; is_correct: boolean }[]
     }) => {
         loading.value = true
@@ -376,6 +378,8 @@ export const useInstructorPanel = () => {
         explanation?: string
         points?: number
         required?: boolean
+        grading_mode?: string
+        rubric?: string
         options?: { id?: string; option_text: string; is_correct: boolean }[]
     }) => {
         loading.value = true
@@ -0,0 +1,150 @@
+/**
+ * Composable for protected video playback sessions.
+ * The backend limits how many devices can play at once: a session is started before the
+ * video is requested, kept alive with heartbeats while the player is open, and ended when
+ * the player closes so the stream slot is freed right away.
+ */
+
+interface PlaybackSessionOptions {
+    // Called when the backend ends the session, e.g. because an admin revoked the device
+    onEnded?: (message: string) => void
+}
+
+const playbackErrorMessages: Record<string, string> = {
+    stream_limit: 'Akun ini sedang memutar video di perangkat lain. Hentikan pemutaran di perangkat lain lalu coba lagi.',
+    device_limit: 'Batas perangkat terdaftar untuk akun ini telah tercapai. Hubungi admin untuk menghapus perangkat lama.',
+    session_ended: 'Sesi pemutaran video telah berakhir. Putar ulang video untuk melanjutkan.'
+}
+
+/**
+ * Stable identifier of this browser, sent as X-Device-ID so the backend can tell devices apart
+ */
+export const getPlaybackDeviceId = (): string => {
+    if (typeof localStorage === 'undefined') return ''
+    let deviceId = localStorage.getItem('device_id')
+    if (!deviceId) {
+        deviceId = crypto.randomUUID()
+        localStorage.setItem('device_id', deviceId)
+    }
+    return deviceId
+}
+
+export const usePlaybackSession = (options: PlaybackSessionOptions = {}) => {
+    const config = useRuntimeConfig()
+    const apiBase = config.public.apiBase || 'http://localhost:8080'
+    const token = useCookie('token')
+
+    const sessionId = ref<string | null>(null)
+    const error = ref<string | null>(null)
+    let heartbeatTimer: ReturnType<typeof setInterval> | null = null
+
+    const authHeaders = (): Record<string, string> => ({
+        'Authorization': `Bearer ${token.value}`,
+        'X-Device-ID': getPlaybackDeviceId()
+    })
+
+    /**
+     * Headers that tie a content request to the current playback session
+     */
+    const playbackHeaders = (): Record<string, string> => {
+        const headers: Record<string, string> = { 'X-Device-ID': getPlaybackDeviceId() }
+        if (sessionId.value) {
+            headers['X-Playback-Session'] = sessionId.value
+        }
+        return headers
+    }
+
+    const stopHeartbeat = () => {
+        if (heartbeatTimer) {
+            clearInterval(heartbeatTimer)
+            heartbeatTimer = null
+        }
+    }
+
+    const heartbeat = async () => {
+        if (!sessionId.value) return
+
+        try {
+            const response = await fetch(`${apiBase}/api/content/playback/${sessionId.value}/heartbeat`, {
+                method: 'POST',
+                headers: authHeaders()
+            })
+            if (response.status === 409) {
+                stopHeartbeat()
+                sessionId.value = null
+                error.value = playbackErrorMessages.session_ended
+                options.onEnded?.(error.value)
+            }
+        } catch (err) {
+            // A missed heartbeat is retried on the next tick
+            console.error('Playback heartbeat failed:', err)
+        }
+    }
+
+    /**
+     * Start (or resume) this device's playback session for a lesson
+     * @param lessonId - The lesson about to be played
+     * @returns Whether playback may go ahead; error holds the reason when it may not
+     */
+    const start = async (lessonId: string): Promise<boolean> => {
+        error.value = null
+        stopHeartbeat()
+
+        try {
+            const response = await fetch(`${apiBase}/api/content/${lessonId}/playback/start`, {
+                method: 'POST',
+                headers: authHeaders()
+            })
+            const data = await response.json().catch(() => ({}))
+
+            if (!response.ok) {
+                error.value = playbackErrorMessages[data.code] || 'Gagal memulai pemutaran video'
+                return false
+            }
+
+            // Admins are not subject to playback limits and get no session
+            sessionId.value = data.session?.id || null
+            if (sessionId.value) {
+                const interval = Math.max(data.heartbeat_interval_seconds || 30, 10) * 1000
+                heartbeatTimer = setInterval(heartbeat, interval)
+            }
+            return true
+        } catch (err: any) {
+            error.value = 'Gagal memulai pemutaran video'
+            console.error('Failed to start playback session:', err)
+            return false
+        }
+    }
+
+    /**
+     * End the playback session, freeing its stream slot
+     */
+    const end = () => {
+        stopHeartbeat()
+        if (!sessionId.value) return
+
+        const id = sessionId.value
+        sessionId.value = null
+        fetch(`${apiBase}/api/content/playback/${id}/end`, {
+            method: 'POST',
+            headers: authHeaders(),
+            keepalive: true
+        }).catch(err => console.error('Failed to end playback session:', err))
+    }
+
+    if (getCurrentInstance()) {
+        onMounted(() => window.addEventListener('pagehide', end))
+        onUnmounted(() => {
+            window.removeEventListener('pagehide', end)
+            end()
+        })
+    }
+
+    return {
+        sessionId: readonly(sessionId),
+        error: readonly(error),
+        start,
+        end,
+        playbackHeaders
+    }
+}
@@ -25,6 +25,8 @@ export interface Question {
     points: number
     order_index: number
     required: boolean
+    grading_mode?: 'exact' | 'normalized' | 'ai'
+    rubric?: string
     options?: Option[]
     created_at: string
 }
@@ -65,6 +67,8 @@ export interface CreateQuestionRequest {
     explanation?: string
     points?: number
     required?: boolean
+    grading_mode?: string
+    rubric?: string
     options?: { option_text: string; is_correct: boolean }[]
 }
 
@@ -74,6 +78,8 @@ export interface UpdateQuestionRequest {
     explanation?: string
     points?: number
     required?: boolean
+    grading_mode?: string
+    rubric?: string
     options?: { option_text: string; is_correct: boolean }[]
 }
 
@@ -99,6 +105,7 @@ export interface QuizResult {
     correct_count: number
     total_questions: number
     answers?: AnswerResult[]
+    grading_pending?: boolean // AI is still grading some answers; the score may go up
 }
 
 export interface AnswerResult {
@@ -110,6 +117,7 @@ export interface AnswerResult {
     points_earned: number
     max_points: number
     explanation?: string
+    feedback?: string
 }
 
 export interface QuizStatus {
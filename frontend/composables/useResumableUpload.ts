@@ -0,0 +1,171 @@
+/**
+ * Composable for resumable uploads of large files (videos).
+ * The file is sent in parts that are verified one by one; an interrupted upload of the
+ * same file resumes with the parts that are still missing instead of starting over.
+ */
+
+interface UploadSessionPart {
+    part_number: number
+    size: number
+    sha256: string
+}
+
+interface UploadSession {
+    upload_id: string
+    status: string
+    total_size: number
+    part_size: number
+    total_parts: number
+    parts: UploadSessionPart[]
+    missing_parts: number[]
+}
+
+export interface ResumableUploadResult {
+    url: string
+    filename: string
+    size: number
+    type: string
+    object_key: string
+}
+
+const PART_RETRIES = 3
+
+const toHex = (buffer: ArrayBuffer): string =>
+    Array.from(new Uint8Array(buffer)).map(b => b.toString(16).padStart(2, '0')).join('')
+
+export const useResumableUpload = () => {
+    const config = useRuntimeConfig()
+    const apiBase = config.public.apiBase || 'http://localhost:8080'
+
+    const uploading = ref(false)
+    const progress = ref(0)
+    const error = ref<string | null>(null)
+
+    const request = async (path: string, init: RequestInit = {}) => {
+        const token = useCookie('token')
+        const response = await fetch(`${apiBase}${path}`, {
+            ...init,
+            headers: {
+                ...(init.headers || {}),
+                'Authorization': `Bearer ${token.value}`
+            }
+        })
+        const data = await response.json().catch(() => ({}))
+        if (!response.ok) {
+            throw new Error(data.error || 'Upload failed')
+        }
+        return data
+    }
+
+    // Uploads of the same file are resumed from the session remembered here
+    const resumeKey = (file: File) => `resumable_upload:${file.name}:${file.size}:${file.lastModified}`
+
+    const openSession = async (basePath: string, file: File): Promise<UploadSession> => {
+        const uploadId = localStorage.getItem(resumeKey(file))
+        if (uploadId) {
+            try {
+                const session: UploadSession = await request(`${basePath}/uploads/multipart/${uploadId}`)
+                if (session.status === 'uploading' && session.total_size === file.size) {
+                    return session
+                }
+            } catch {
+                // Expired or cleaned up: start a new upload
+            }
+            localStorage.removeItem(resumeKey(file))
+        }
+
+        const session: UploadSession = await request(`${basePath}/uploads/multipart`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ filename: file.name, size: file.size, content_type: file.type })
+        })
+        localStorage.setItem(resumeKey(file), session.upload_id)
+        return session
+    }
+
+    const partBlob = (session: UploadSession, file: File, partNumber: number) => {
+        const start = (partNumber - 1) * session.part_size
+        return file.slice(start, Math.min(start + session.part_size, file.size))
+    }
+
+    const partDigest = async (blob: Blob) => crypto.subtle.digest('SHA-256', await blob.arrayBuffer())
+
+    const uploadPart = async (basePath: string, session: UploadSession, file: File, partNumber: number) => {
+        const blob = partBlob(session, file, partNumber)
+        const checksum = toHex(await partDigest(blob))
+
+        for (let attempt = 1; ; attempt++) {
+            try {
+                await request(`${basePath}/uploads/multipart/${session.upload_id}/parts/${partNumber}`, {
+                    method: 'PUT',
+                    headers: { 'X-Checksum-SHA256': checksum },
+                    body: blob
+                })
+                return
+            } catch (err) {
+                if (attempt >= PART_RETRIES) throw err
+            }
+        }
+    }
+
+    /**
+     * Upload a file in verified parts, resuming a previous attempt of the same file
+     * @param basePath - '/api/admin' or '/api/instructor'
+     * @param file - The file to upload
+     * @param onProgress - Called with the percentage uploaded so far
+     * @returns The stored object, like the single-request upload endpoint
+     */
+    const upload = async (basePath: string, file: File, onProgress?: (percent: number) => void): Promise<ResumableUploadResult | null> => {
+        const setProgress = (percent: number) => {
+            progress.value = percent
+            onProgress?.(percent)
+        }
+
+        uploading.value = true
+        setProgress(0)
+        error.value = null
+
+        try {
+            const session = await openSession(basePath, file)
+            const partSize = (n: number) => partBlob(session, file, n).size
+
+            let uploaded = file.size - session.missing_parts.reduce((sum, n) => sum + partSize(n), 0)
+            setProgress(Math.round((uploaded / file.size) * 100))
+
+            for (const partNumber of session.missing_parts) {
+                await uploadPart(basePath, session, file, partNumber)
+                uploaded += partSize(partNumber)
+                setProgress(Math.round((uploaded / file.size) * 100))
+            }
+
+            // Whole-file checksum: SHA-256 of every part's raw SHA-256 digest, in order
+            const digests = new Uint8Array(session.total_parts * 32)
+            for (let n = 1; n <= session.total_parts; n++) {
+                digests.set(new Uint8Array(await partDigest(partBlob(session, file, n))), (n - 1) * 32)
+            }
+            const checksum = toHex(await crypto.subtle.digest('SHA-256', digests))
+
+            const result: ResumableUploadResult = await request(`${basePath}/uploads/multipart/${session.upload_id}/complete`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ checksum_sha256: checksum })
+            })
+            localStorage.removeItem(resumeKey(file))
+            setProgress(100)
+            return result
+        } catch (err: any) {
+            error.value = err.message || 'Gagal mengupload file'
+            console.error('Resumable upload failed:', err)
+            return null
+        } finally {
+            uploading.value = false
+        }
+    }
+
+    return {
+        uploading: readonly(uploading),
+        progress: readonly(progress),
+        error: readonly(error),
+        upload
+    }
+}
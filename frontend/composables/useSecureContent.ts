@@ -9,6 +9,14 @@ interface SecureContentResponse {
     type: string
 }
 
+export interface LessonWatermark {
+    code: string
+    text: string
+    opacity: number
+    move_interval_seconds: number
+    issued_at: string
+}
+
 export const useSecureContent = () => {
     const config = useRuntimeConfig()
     const apiBase = config.public.apiBase || 'http://localhost:8080'
@@ -22,9 +30,10 @@ export const useSecureContent = () => {
     /**
      * Get a secure URL for video content
      * @param lessonId - The lesson ID to get content for
+     * @param headers - Extra headers, e.g. the playback session from usePlaybackSession
      * @returns Pre-signed URL or null if not available
      */
-    const getSecureVideoUrl = async (lessonId: string): Promise<string | null> => {
+    const getSecureVideoUrl = async (lessonId: string, headers: Record<string, string> = {}): Promise<string | null> => {
         // Check cache first
         const cached = urlCache.value.get(`video-${lessonId}`)
         if (cached && cached.expiresAt > new Date()) {
@@ -38,6 +47,7 @@ export const useSecureContent = () => {
             const token = useCookie('token')
             const response = await fetch(`${apiBase}/api/content/${lessonId}/url`, {
                 headers: {
+                    ...headers,
                     'Authorization': `Bearer ${token.value}`
                 }
             })
@@ -179,8 +189,10 @@ export const useSecureContent = () => {
     /**
      * Fetch content as blob URL (for cases where we need direct URL in src)
      * This fetches the content and creates a local blob URL
+     * @param lessonId - The lesson ID to get content for
+     * @param headers - Extra headers; videos need the playback session from usePlaybackSession
      */
-    const getContentBlobUrl = async (lessonId: string): Promise<string | null> => {
+    const getContentBlobUrl = async (lessonId: string, headers: Record<string, string> = {}): Promise<string | null> => {
         loading.value = true
         error.value = null
 
@@ -188,6 +200,7 @@ export const useSecureContent = () => {
             const token = useCookie('token')
             const response = await fetch(`${apiBase}/api/content/${lessonId}/stream`, {
                 headers: {
+                    ...headers,
                     'Authorization': `Bearer ${token.value}`
                 }
             })
@@ -201,6 +214,10 @@ export const useSecureContent = () => {
                     error.value = 'Konten tidak ditemukan'
                     return null
                 }
+                if (response.status === 409 || response.status === 428) {
+                    error.value = 'Sesi pemutaran video tidak aktif. Putar ulang video untuk melanjutkan.'
+                    return null
+                }
                 throw new Error('Failed to get content')
             }
 
@@ -223,6 +240,33 @@ export const useSecureContent = () => {
         }
     }
 
+    /**
+     * Get the forensic watermark a protected lesson must be played with.
+     * The overlay text carries a code that traces a leaked recording back to the viewer.
+     * @param lessonId - The lesson ID to get the watermark for
+     * @returns The watermark, or null when the lesson doesn't need one
+     */
+    const getLessonWatermark = async (lessonId: string): Promise<LessonWatermark | null> => {
+        try {
+            const token = useCookie('token')
+            const response = await fetch(`${apiBase}/api/content/${lessonId}/watermark`, {
+                headers: {
+                    'Authorization': `Bearer ${token.value}`
+                }
+            })
+
+            if (!response.ok) {
+                throw new Error('Failed to get watermark')
+            }
+
+            const data = await response.json()
+            return data.enabled ? data.watermark : null
+        } catch (err: any) {
+            console.error('Error getting watermark:', err)
+            return null
+        }
+    }
+
     /**
      * Get thumbnail URL for course thumbnails
      * This uses the public /api/images/:objectKey endpoint for MinIO objects
@@ -255,6 +299,7 @@ export const useSecureContent = () => {
         getSecureDocumentUrl,
         getStreamUrl,
         getContentBlobUrl,
+        getLessonWatermark,
         getThumbnailUrl,
         clearCache,
         isMinioObject,